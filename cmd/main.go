@@ -1,344 +1,1746 @@
-package main
-
-import (
-	"fmt"
-	"math"
-	"os"
-	"path/filepath"
-	"strings"
-	"time"
-
-	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/generator"
-	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/pkg/csv"
-	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/pkg/excel"
-	"github.com/spf13/cobra"
-)
-
-var (
-	version          = "dev" // Set by build process
-	outputFile       string
-	recordCount      int
-	batchSize        int
-	tableName        string
-	closedPercentage int
-	splitOutput      bool
-	model            string
-	apiKey           string
-)
-
-var rootCmd = &cobra.Command{
-	Use:   "bulk-generator",
-	Short: "Generate bulk data for ServiceNow tables",
-	Long: `A utility to generate bulk data for ServiceNow tables.
-This version supports generating incident and CSM case records with realistic data using LLM integration.`,
-	RunE: runBulkGenerator,
-}
-
-var versionCmd = &cobra.Command{
-	Use:   "version",
-	Short: "Print the version number",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("NOW Dynamic Data Generator %s\n", version)
-	},
-}
-
-func init() {
-	rootCmd.AddCommand(versionCmd)
-	rootCmd.Flags().StringVarP(&outputFile, "output", "o", "bulk-data.xlsx", "Output file name")
-	rootCmd.Flags().IntVarP(&recordCount, "count", "c", 10000, "Number of records to generate")
-	rootCmd.Flags().IntVarP(&batchSize, "batch", "b", 1000, "Batch size for processing")
-	rootCmd.Flags().StringVarP(&tableName, "table", "t", "incident", "Table name (incident, case, hr_case, change_request, or knowledge_article)")
-	rootCmd.Flags().IntVar(&closedPercentage, "closed", 30, "Percentage of closed cases (0-100)")
-	rootCmd.Flags().BoolVar(&splitOutput, "split", false, "Split output into separate files for closed and open cases")
-	rootCmd.Flags().StringVarP(&model, "model", "m", "google/gemini-2.0-flash-001", "LLM model to use")
-	rootCmd.Flags().StringVarP(&apiKey, "api-key", "k", "", "OpenRouter API key (or set OPENROUTER_API_KEY env var)")
-}
-
-func runBulkGenerator(cmd *cobra.Command, args []string) error {
-	fmt.Println("Starting bulk data generation...")
-
-	// Get API key from environment if not provided
-	if apiKey == "" {
-		apiKey = os.Getenv("OPENROUTER_API_KEY")
-	}
-
-	// Create generator config
-	config := generator.Config{
-		RecordCount:      recordCount,
-		BatchSize:        batchSize,
-		TableName:        tableName,
-		ClosedPercentage: closedPercentage,
-		SplitOutput:      splitOutput,
-		APIKey:           apiKey,
-		Model:            model,
-	}
-
-	// Create bulk generator
-	bg := generator.NewBulkGenerator(config)
-
-	fmt.Printf("Using OpenRouter with model: %s\n", model)
-
-	// Determine output format
-	isCSV := strings.HasSuffix(strings.ToLower(outputFile), ".csv")
-
-	// Start timing
-	startTime := time.Now()
-
-	if splitOutput {
-		return generateSplitOutput(bg, isCSV, startTime)
-	} else {
-		return generateSingleOutput(bg, isCSV, startTime)
-	}
-}
-
-func generateSingleOutput(bg *generator.BulkGenerator, isCSV bool, startTime time.Time) error {
-	fmt.Printf("Output format is %s: %s\n", getFormatName(isCSV), outputFile)
-
-	var writer interface {
-		SetHeaders([]string) error
-		WriteRecords([]interface{}) error
-		Close() error
-	}
-
-	// Create appropriate writer
-	if isCSV {
-		csvWriter, err := csv.NewWriter(outputFile)
-		if err != nil {
-			return fmt.Errorf("failed to create CSV writer: %w", err)
-		}
-		writer = csvWriter
-	} else {
-		excelWriter := excel.NewWriter(tableName)
-		defer func() {
-			if err := excelWriter.SaveToFile(outputFile); err != nil {
-				fmt.Printf("Error saving Excel file: %v\n", err)
-			}
-		}()
-		writer = excelWriter
-	}
-
-	defer writer.Close()
-
-	// Set headers
-	var headers []string
-	if tableName == "incident" {
-		if isCSV {
-			headers = csv.GetIncidentHeaders()
-		} else {
-			headers = excel.GetIncidentHeaders()
-		}
-	} else {
-		if isCSV {
-			headers = csv.GetCaseHeaders()
-		} else {
-			headers = excel.GetCaseHeaders()
-		}
-	}
-
-	if err := writer.SetHeaders(headers); err != nil {
-		return fmt.Errorf("failed to set headers: %w", err)
-	}
-
-	// Generate data in batches
-	recordsGenerated := 0
-	totalBatches := int(math.Ceil(float64(recordCount) / float64(batchSize)))
-
-	fmt.Printf("Generating %d records in %d batches of %d...\n", recordCount, totalBatches, batchSize)
-
-	for batchNum := 0; batchNum < totalBatches; batchNum++ {
-		currentBatchSize := min(batchSize, recordCount-recordsGenerated)
-		fmt.Printf("Generating batch %d/%d (%d records)...\n", batchNum+1, totalBatches, currentBatchSize)
-
-		// Generate the batch
-		records, err := bg.GenerateBatch(currentBatchSize)
-		if err != nil {
-			return fmt.Errorf("failed to generate batch: %w", err)
-		}
-
-		// Write the batch
-		if err := writer.WriteRecords(records); err != nil {
-			return fmt.Errorf("failed to write records: %w", err)
-		}
-
-		recordsGenerated += currentBatchSize
-		fmt.Printf("Progress: %d/%d records (%.0f%%)\n", recordsGenerated, recordCount, float64(recordsGenerated)/float64(recordCount)*100)
-	}
-
-	// Save Excel file if needed
-	if !isCSV {
-		if excelWriter, ok := writer.(*excel.Writer); ok {
-			if err := excelWriter.SaveToFile(outputFile); err != nil {
-				return fmt.Errorf("failed to save Excel file: %w", err)
-			}
-		}
-	}
-
-	elapsed := time.Since(startTime)
-	fmt.Printf("Data generation complete! Generated %d records in %v\n", recordsGenerated, elapsed)
-	fmt.Printf("%s data written to %s\n", getFormatName(isCSV), outputFile)
-
-	return nil
-}
-
-func generateSplitOutput(bg *generator.BulkGenerator, isCSV bool, startTime time.Time) error {
-	// Get the base filename without extension
-	fileExt := filepath.Ext(outputFile)
-	fileBase := strings.TrimSuffix(outputFile, fileExt)
-
-	// Create filenames for closed and open cases
-	closedFile := fmt.Sprintf("%s-closed%s", fileBase, fileExt)
-	openFile := fmt.Sprintf("%s-open%s", fileBase, fileExt)
-
-	fmt.Printf("Splitting output into separate files:\n")
-	fmt.Printf("- Closed cases: %s\n", closedFile)
-	fmt.Printf("- Open cases: %s\n", openFile)
-
-	// Create writers for both files
-	var closedWriter, openWriter interface {
-		SetHeaders([]string) error
-		WriteRecord(interface{}) error
-		Close() error
-	}
-
-	if isCSV {
-		cw, err := csv.NewWriter(closedFile)
-		if err != nil {
-			return fmt.Errorf("failed to create closed CSV writer: %w", err)
-		}
-		closedWriter = cw
-
-		ow, err := csv.NewWriter(openFile)
-		if err != nil {
-			return fmt.Errorf("failed to create open CSV writer: %w", err)
-		}
-		openWriter = ow
-	} else {
-		closedWriter = excel.NewWriter(tableName)
-		openWriter = excel.NewWriter(tableName)
-	}
-
-	defer closedWriter.Close()
-	defer openWriter.Close()
-
-	// Set headers for both writers
-	var headers []string
-	if tableName == "incident" {
-		if isCSV {
-			headers = csv.GetIncidentHeaders()
-		} else {
-			headers = excel.GetIncidentHeaders()
-		}
-	} else {
-		if isCSV {
-			headers = csv.GetCaseHeaders()
-		} else {
-			headers = excel.GetCaseHeaders()
-		}
-	}
-
-	if err := closedWriter.SetHeaders(headers); err != nil {
-		return fmt.Errorf("failed to set closed headers: %w", err)
-	}
-	if err := openWriter.SetHeaders(headers); err != nil {
-		return fmt.Errorf("failed to set open headers: %w", err)
-	}
-
-	// Generate data in batches
-	recordsGenerated := 0
-	closedRecords := 0
-	openRecords := 0
-	totalBatches := int(math.Ceil(float64(recordCount) / float64(batchSize)))
-
-	fmt.Printf("Generating %d records in %d batches of %d...\n", recordCount, totalBatches, batchSize)
-
-	for batchNum := 0; batchNum < totalBatches; batchNum++ {
-		currentBatchSize := min(batchSize, recordCount-recordsGenerated)
-		fmt.Printf("Generating batch %d/%d (%d records)...\n", batchNum+1, totalBatches, currentBatchSize)
-
-		// Generate the batch
-		records, err := bg.GenerateBatch(currentBatchSize)
-		if err != nil {
-			return fmt.Errorf("failed to generate batch: %w", err)
-		}
-
-		// Split records into closed and open
-		for _, record := range records {
-			isClosed := isRecordClosed(record)
-
-			if isClosed {
-				if err := closedWriter.WriteRecord(record); err != nil {
-					return fmt.Errorf("failed to write closed record: %w", err)
-				}
-				closedRecords++
-			} else {
-				if err := openWriter.WriteRecord(record); err != nil {
-					return fmt.Errorf("failed to write open record: %w", err)
-				}
-				openRecords++
-			}
-		}
-
-		recordsGenerated += currentBatchSize
-		fmt.Printf("Progress: %d/%d records (%.0f%%)\n", recordsGenerated, recordCount, float64(recordsGenerated)/float64(recordCount)*100)
-	}
-
-	// Save Excel files if needed
-	if !isCSV {
-		if excelWriter, ok := closedWriter.(*excel.Writer); ok {
-			if err := excelWriter.SaveToFile(closedFile); err != nil {
-				return fmt.Errorf("failed to save closed Excel file: %w", err)
-			}
-		}
-		if excelWriter, ok := openWriter.(*excel.Writer); ok {
-			if err := excelWriter.SaveToFile(openFile); err != nil {
-				return fmt.Errorf("failed to save open Excel file: %w", err)
-			}
-		}
-	}
-
-	elapsed := time.Since(startTime)
-	fmt.Printf("Data generation complete! Generated %d records in %v\n", recordsGenerated, elapsed)
-	fmt.Printf("- %d closed records\n", closedRecords)
-	fmt.Printf("- %d open records\n", openRecords)
-	fmt.Printf("%s data written to %s and %s\n", getFormatName(isCSV), closedFile, openFile)
-
-	return nil
-}
-
-func isRecordClosed(record interface{}) bool {
-	switch r := record.(type) {
-	case *generator.IncidentRecord:
-		return r.IncidentState == "Resolved" || r.IncidentState == "Closed"
-	case *generator.CaseRecord:
-		return r.State == "Resolved" || r.State == "Closed"
-	case *generator.HRCaseRecord:
-		return r.State == "Resolved" || r.State == "Closed"
-	case *generator.ChangeRequestRecord:
-		return r.State == "Closed"
-	case *generator.KnowledgeArticleRecord:
-		return r.WorkflowState == "published" // Knowledge articles are considered "closed" when published
-	default:
-		return false
-	}
-}
-
-func getFormatName(isCSV bool) string {
-	if isCSV {
-		return "CSV"
-	}
-	return "Excel"
-}
-
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
-func main() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
-}
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/checkpoint"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/generator"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/profile"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/pkg/csv"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/pkg/excel"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/pkg/json"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/pkg/parquet"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/pkg/servicenow"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/pkg/sql"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/pkg/xml"
+	"github.com/spf13/cobra"
+)
+
+var (
+	version          = "dev" // Set by build process
+	outputFile       string
+	recordCount      int
+	batchSize        int
+	tableName        string
+	tableFlags       []string
+	closedPercentage int
+	splitOutput      bool
+	model            string
+	apiKey           string
+	topologyFile     string
+	topologyServices int
+	topologyCIs      int
+	topologyOut      string
+	referenceFile    string
+	referenceOut     string
+	numberPrefix     string
+	numberStart      int64
+	numberDigits     int
+	lastNumber       string
+	referenceMode    string
+	compression      string
+	sqlDialect       string
+	sqlCopy          bool
+	columnSpec       string
+	configFile       string
+	seed             int64
+	workers          int
+	runTimeout       time.Duration
+	resume           bool
+	onError          string
+
+	// generationProfile is the --config profile, if any
+	generationProfile *profile.Profile
+
+	// profileTables are the tables of a multi-table profile, used unless --table is given
+	profileTables []profile.Table
+
+	// runID and runStarted identify the run in computed columns
+	runID      = fmt.Sprintf("%s-%04x", time.Now().UTC().Format("20060102T150405"), rand.Intn(0x10000))
+	runStarted = time.Now()
+
+	instanceURL       string
+	instanceUser      string
+	instancePassword  string
+	oauthClientID     string
+	oauthClientSecret string
+	importSetTable    string
+	loadParallelism   int
+	loadRetries       int
+	loadResultsFile   string
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "bulk-generator",
+	Short: "Generate bulk data for ServiceNow tables",
+	Long: `A utility to generate bulk data for ServiceNow tables.
+This version supports generating incident and CSM case records with realistic data using LLM integration.`,
+	RunE: runBulkGenerator,
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version number",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Printf("NOW Dynamic Data Generator %s\n", version)
+	},
+}
+
+var loadCmd = &cobra.Command{
+	Use:   "load",
+	Short: "Generate records and load them straight into a ServiceNow instance",
+	Long: `Generate records and load them into a ServiceNow instance through the Table API,
+or through the Import Set API when --import-set names a staging table. A results file
+maps the generated record numbers to the sys_ids created on the instance.`,
+	RunE: runLoad,
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(loadCmd)
+	loadCmd.Flags().StringVar(&instanceURL, "instance", "", "Instance URL, e.g. https://dev12345.service-now.com (or set SERVICENOW_INSTANCE env var)")
+	loadCmd.Flags().StringVar(&instanceUser, "username", "", "Instance user name (or set SERVICENOW_USERNAME env var)")
+	loadCmd.Flags().StringVar(&instancePassword, "password", "", "Instance password (or set SERVICENOW_PASSWORD env var)")
+	loadCmd.Flags().StringVar(&oauthClientID, "client-id", "", "OAuth client ID; uses OAuth instead of basic authentication")
+	loadCmd.Flags().StringVar(&oauthClientSecret, "client-secret", "", "OAuth client secret (or set SERVICENOW_CLIENT_SECRET env var)")
+	loadCmd.Flags().StringVar(&importSetTable, "import-set", "", "Load through this import set staging table instead of the Table API")
+	loadCmd.Flags().IntVar(&loadParallelism, "parallel", 4, "Number of records loaded concurrently")
+	loadCmd.Flags().IntVar(&loadRetries, "retries", 5, "Retries for rate limited (429) or failed (5xx) requests")
+	loadCmd.Flags().StringVar(&loadResultsFile, "results", "load-results.csv", "Results file mapping record numbers to created sys_ids")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Generation profile (YAML) with the settings of the run; flags override it")
+	rootCmd.PersistentFlags().StringVarP(&outputFile, "output", "o", "bulk-data.xlsx", "Output file name")
+	rootCmd.PersistentFlags().IntVarP(&recordCount, "count", "c", 10000, "Number of records to generate")
+	rootCmd.PersistentFlags().IntVarP(&batchSize, "batch", "b", 1000, "Batch size for processing")
+	rootCmd.PersistentFlags().DurationVar(&runTimeout, "timeout", 0, "Stop the run after this long (e.g. 30m), keeping the records generated so far")
+	rootCmd.Flags().BoolVar(&resume, "resume", false, "Resume an interrupted run from the checkpoint saved next to its output")
+	rootCmd.PersistentFlags().StringVar(&onError, "on-error", generator.OnErrorFailFast, "What to do with records that cannot be generated: fail-fast, skip, retry=N, or placeholder")
+	rootCmd.PersistentFlags().IntVar(&workers, "workers", 0, "Records generated at once (default 10 with the LLM, one per CPU without)")
+	rootCmd.PersistentFlags().StringArrayVarP(&tableFlags, "table", "t", []string{"incident"}, "Table name (incident, case, hr_case, change_request, knowledge_article, cmdb, or master_data); repeat as name=count to generate several tables")
+	rootCmd.PersistentFlags().IntVar(&closedPercentage, "closed", 30, "Percentage of closed cases (0-100)")
+	rootCmd.PersistentFlags().BoolVar(&splitOutput, "split", false, "Split output into separate files for closed and open cases")
+	rootCmd.PersistentFlags().StringVarP(&model, "model", "m", "google/gemini-2.0-flash-001", "LLM model to use")
+	rootCmd.PersistentFlags().StringVarP(&apiKey, "api-key", "k", "", "OpenRouter API key (or set OPENROUTER_API_KEY env var)")
+	rootCmd.PersistentFlags().StringVar(&topologyFile, "topology", "", "CMDB topology file (JSON) with services, CIs and relationships")
+	rootCmd.PersistentFlags().IntVar(&topologyServices, "topology-services", 0, "Generate a synthetic CMDB topology with this many services")
+	rootCmd.PersistentFlags().IntVar(&topologyCIs, "topology-cis", 0, "Number of CIs in the synthetic topology (default 10 per service)")
+	rootCmd.PersistentFlags().StringVar(&topologyOut, "topology-out", "", "Write the synthetic topology to this file")
+	rootCmd.PersistentFlags().StringVar(&referenceFile, "reference-data", "", "Reference data file (JSON) with users, groups, accounts and contacts to use")
+	rootCmd.PersistentFlags().StringVar(&numberPrefix, "number-prefix", "", "Record number prefix (default per table, e.g. INC)")
+	rootCmd.PersistentFlags().Int64Var(&numberStart, "number-start", 0, "First record number (default per table, e.g. 10001)")
+	rootCmd.PersistentFlags().IntVar(&numberDigits, "number-digits", 0, "Zero-padded digits in record numbers (default 7)")
+	rootCmd.PersistentFlags().StringVar(&lastNumber, "last-number", "", "Continue numbering after this existing record number (e.g. INC0012345)")
+	rootCmd.PersistentFlags().StringVar(&referenceMode, "references", "display", "Write reference fields as display values, sys_ids, or both (display, sys_id, both)")
+	rootCmd.PersistentFlags().StringVar(&compression, "compression", "snappy", "Parquet compression codec ("+strings.Join(parquet.Compressions(), ", ")+")")
+	rootCmd.PersistentFlags().StringVar(&sqlDialect, "sql-dialect", "postgres", "SQL dialect of .sql output (postgres, mysql, sqlite)")
+	rootCmd.PersistentFlags().BoolVar(&sqlCopy, "sql-copy", false, "Load rows with COPY instead of INSERT in postgres .sql output")
+	rootCmd.PersistentFlags().StringVar(&columnSpec, "columns", "", "Columns to write, in order: field, field:name, * for the other fields, name=value for constant or computed columns ({run_id}, {run_at}, {row}, {field})")
+	rootCmd.PersistentFlags().Int64Var(&seed, "seed", 0, "Seed of the random values, to repeat a run (default random)")
+	rootCmd.PersistentFlags().StringVar(&referenceOut, "reference-out", "", "Reference data file written in master_data mode (default <output>-reference.json)")
+}
+
+func runBulkGenerator(cmd *cobra.Command, args []string) error {
+	fmt.Println("Starting bulk data generation...")
+
+	if err := applyProfile(cmd); err != nil {
+		return err
+	}
+
+	// Determine output format
+	format := detectOutputFormat(outputFile)
+
+	runs, err := tableRuns(format)
+	if err != nil {
+		return err
+	}
+	tableName = runs[0].table
+
+	// A single table written to CSV, NDJSON or Excel is checkpointed after each batch,
+	// so it can be resumed if it is interrupted
+	if len(runs) == 1 {
+		runs[0].checkpointed = checkpointable(runs[0], format)
+	}
+	if resume {
+		if err := resumeRun(runs); err != nil {
+			return err
+		}
+	}
+
+	bg, err := newBulkGenerator()
+	if err != nil {
+		return err
+	}
+	if runs[0].resume != nil {
+		bg.Numbering.Restore(runs[0].resume.Numbering)
+	}
+
+	ctx, cancel := runContext(cmd)
+	defer cancel()
+
+	// Start timing
+	startTime := time.Now()
+
+	if len(runs) == 1 {
+		return stopped(cmd, ctx, generateTable(ctx, bg, runs[0], format, nil, startTime))
+	}
+
+	// The tables of a multi-table run share one workbook, or get a file each
+	var workbook *excel.Workbook
+	if format == formatExcel {
+		workbook = excel.NewWorkbook()
+		defer workbook.Close()
+	}
+
+	names := make([]string, len(runs))
+	for i, run := range runs {
+		names[i] = run.table
+	}
+	fmt.Printf("Generating %d tables: %s\n", len(runs), strings.Join(names, ", "))
+
+	var runErr error
+	for _, run := range runs {
+		if ctx.Err() != nil {
+			runErr = fmt.Errorf("stopped before %s: %w", run.table, context.Cause(ctx))
+			break
+		}
+		fmt.Printf("\n=== %s ===\n", run.table)
+		tableGenerator := bg.ForTable(run.table, run.closed)
+		if err := generateTable(ctx, tableGenerator, run, format, workbook, time.Now()); err != nil {
+			runErr = fmt.Errorf("failed to generate %s: %w", run.table, err)
+			break
+		}
+		// Tables generated later refer to the users, groups, accounts and contacts of master_data
+		bg.ReferenceData = tableGenerator.ReferenceData
+	}
+
+	// A stopped run still saves the sheets written so far
+	if runErr != nil && ctx.Err() == nil {
+		return runErr
+	}
+	if workbook != nil {
+		if err := workbook.SaveToFile(outputFile); err != nil {
+			return fmt.Errorf("failed to save Excel file: %w", err)
+		}
+		fmt.Printf("\nExcel data written to %s\n", outputFile)
+	}
+	if runErr != nil {
+		return stopped(cmd, ctx, runErr)
+	}
+	fmt.Printf("\nGenerated %d tables in %v\n", len(runs), time.Since(startTime))
+
+	return nil
+}
+
+// runContext returns the context of a run. SIGINT and SIGTERM cancel it, so no new
+// records are started and the records generated so far are written to a valid file; a
+// second signal quits at once. With --timeout, the run is stopped the same way once
+// the timeout is reached.
+func runContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(cmd.Context())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(signals)
+		select {
+		case sig := <-signals:
+			fmt.Printf("\nReceived %v: finishing the records in progress and saving the output (repeat to quit at once)\n", sig)
+			cancel(fmt.Errorf("interrupted by %v", sig))
+		case <-ctx.Done():
+		}
+	}()
+
+	stop := func() { cancel(nil) }
+	if runTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, runTimeout, fmt.Errorf("--timeout of %v reached", runTimeout))
+		stop = func() {
+			cancelTimeout()
+			cancel(nil)
+		}
+	}
+	return ctx, stop
+}
+
+// checkpointable reports whether a table run can be checkpointed and resumed. Excel
+// workbooks are rebuilt from a journal of the records, while CSV and NDJSON files are
+// appended to.
+func checkpointable(run tableRun, format outputFormat) bool {
+	if run.table == "cmdb" || run.table == "master_data" || splitOutput {
+		return false
+	}
+	return format == formatCSV || format == formatNDJSON || format == formatExcel
+}
+
+// resumeRun loads the checkpoint of an interrupted run, checks that it was saved by a
+// run with the same settings and restores the seed and run ID it used
+func resumeRun(runs []tableRun) error {
+	if len(runs) > 1 {
+		return fmt.Errorf("--resume applies to a single table, not to the %d tables of the run", len(runs))
+	}
+	run := &runs[0]
+	if !run.checkpointed {
+		return fmt.Errorf("--resume applies to a single table other than cmdb and master_data, written without --split to CSV, NDJSON or Excel")
+	}
+
+	filename := checkpoint.Path(run.output)
+	cp, err := checkpoint.Load(filename)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	if cp.Table != run.table {
+		problems = append(problems, fmt.Sprintf("table %s, not %s", cp.Table, run.table))
+	}
+	if cp.Count != run.count {
+		problems = append(problems, fmt.Sprintf("count %d, not %d", cp.Count, run.count))
+	}
+	if cp.Columns != run.spec {
+		problems = append(problems, fmt.Sprintf("columns %q, not %q", cp.Columns, run.spec))
+	}
+	if mode, err := models.ParseReferenceMode(referenceMode); err == nil && cp.References != string(mode) {
+		problems = append(problems, fmt.Sprintf("references %s, not %s", cp.References, mode))
+	}
+	if seed != 0 && cp.Seed != seed {
+		problems = append(problems, fmt.Sprintf("seed %d, not %d", cp.Seed, seed))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("cannot resume from %s, saved by a run with %s", filename, strings.Join(problems, ", "))
+	}
+	if cp.Written >= cp.Count {
+		return fmt.Errorf("cannot resume from %s: all %d records were written", filename, cp.Count)
+	}
+
+	seed = cp.Seed
+	runID = cp.RunID
+	runStarted = cp.RunAt
+	run.resume = cp
+	fmt.Printf("Resuming run %s from %s: %d of %d records written\n", runID, filename, cp.Written, cp.Count)
+	return nil
+}
+
+// stopped marks the error of a run stopped by a signal or --timeout as partial output.
+// Other errors are returned as is.
+func stopped(cmd *cobra.Command, ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	// The flags were fine, so the usage is not printed
+	cmd.SilenceUsage = true
+	return fmt.Errorf("partial output: %w", err)
+}
+
+// partial returns the error of a table stopped by ctx after written of total records
+func partial(ctx context.Context, written, total int) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	return fmt.Errorf("stopped after %d of %d records: %w", written, total, context.Cause(ctx))
+}
+
+// reportGenerated prints how many records of a table were generated, and why
+// generation stopped early if it did
+func reportGenerated(ctx context.Context, generated, total int, what string, elapsed time.Duration) {
+	if ctx.Err() != nil {
+		fmt.Printf("Data generation stopped (%v)! Generated %d of %d %s in %v\n", context.Cause(ctx), generated, total, what, elapsed)
+		return
+	}
+	fmt.Printf("Data generation complete! Generated %d %s in %v\n", generated, what, elapsed)
+}
+
+// reportFailures prints how many records failed and were skipped or replaced by
+// placeholders under --on-error
+func reportFailures(failed int) {
+	if failed == 0 {
+		return
+	}
+	if onError == generator.OnErrorPlaceholder {
+		fmt.Printf("%d records failed and were written as placeholders\n", failed)
+	} else {
+		fmt.Printf("%d records failed and were skipped\n", failed)
+	}
+}
+
+// generateTable generates the records of a table into its output, or into sheets of
+// workbook when one is given
+func generateTable(ctx context.Context, bg *generator.BulkGenerator, run tableRun, format outputFormat, workbook *excel.Workbook, startTime time.Time) error {
+	switch {
+	case run.table == "cmdb":
+		if splitOutput {
+			fmt.Println("Note: --split is ignored for cmdb, which writes one file per CI class")
+		}
+		return generateCMDBOutput(ctx, bg, run, format, workbook, startTime)
+	case run.table == "master_data":
+		if splitOutput {
+			fmt.Println("Note: --split is ignored for master_data, which writes one file per table")
+		}
+		return generateMasterDataOutput(ctx, bg, run, format, workbook, startTime)
+	case splitOutput:
+		return generateSplitOutput(ctx, bg, run, format, workbook, startTime)
+	default:
+		return generateSingleOutput(ctx, bg, run, format, workbook, startTime)
+	}
+}
+
+// tableRun is a table generated by a run, with its own record count, closed
+// percentage, output file and columns
+type tableRun struct {
+	table   string
+	count   int
+	closed  int
+	output  string
+	columns *models.ColumnSpec
+	spec    string
+
+	// checkpointed runs save a checkpoint after each batch; resume is the checkpoint the
+	// run carries on from, if any
+	checkpointed bool
+	resume       *checkpoint.Checkpoint
+}
+
+// tableRuns returns the tables of the run, from the --table flags or the tables of the
+// profile. master_data is generated first and cmdb second, so other tables can refer
+// to their records. The column specs are checked against every table before anything
+// is generated.
+func tableRuns(format outputFormat) ([]tableRun, error) {
+	var runs []tableRun
+	if len(profileTables) > 0 {
+		for _, t := range profileTables {
+			run := tableRun{table: t.Table, count: recordCount, closed: closedPercentage}
+			if t.Count > 0 {
+				run.count = t.Count
+			}
+			if t.Closed != nil {
+				run.closed = *t.Closed
+			}
+			spec := columnSpec
+			if t.Columns != "" {
+				spec = t.Columns
+			}
+			runs = append(runs, run)
+			if err := parseColumns(&runs[len(runs)-1], spec); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		seen := make(map[string]bool)
+		for _, value := range tableFlags {
+			name, count, hasCount := strings.Cut(value, "=")
+			run := tableRun{table: strings.TrimSpace(name), count: recordCount, closed: closedPercentage}
+			if hasCount {
+				n, err := strconv.Atoi(strings.TrimSpace(count))
+				if err != nil || n <= 0 {
+					return nil, fmt.Errorf("invalid --table %s: the count must be a positive number", value)
+				}
+				run.count = n
+			}
+			if !contains(generator.Tables(), run.table) {
+				return nil, fmt.Errorf("unknown table %s (known tables: %s)", run.table, strings.Join(generator.Tables(), ", "))
+			}
+			if seen[run.table] {
+				return nil, fmt.Errorf("table %s is given more than once", run.table)
+			}
+			seen[run.table] = true
+			runs = append(runs, run)
+			if err := parseColumns(&runs[len(runs)-1], columnSpec); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("no table to generate")
+	}
+
+	if len(runs) > 1 {
+		if numberPrefix != "" || numberStart > 0 || numberDigits > 0 || lastNumber != "" {
+			return nil, fmt.Errorf("numbering settings apply to a single table, not to the %d tables of the run", len(runs))
+		}
+		rank := map[string]int{"master_data": 0, "cmdb": 1}
+		sort.SliceStable(runs, func(i, j int) bool {
+			ri, ok := rank[runs[i].table]
+			if !ok {
+				ri = len(rank)
+			}
+			rj, ok := rank[runs[j].table]
+			if !ok {
+				rj = len(rank)
+			}
+			return ri < rj
+		})
+	}
+
+	// cmdb and master_data name their files after the output file; other tables of a
+	// multi-table run get their own file unless they go to sheets of one workbook
+	fileExt := filepath.Ext(outputFile)
+	fileBase := strings.TrimSuffix(outputFile, fileExt)
+	for i := range runs {
+		runs[i].output = outputFile
+		if len(runs) > 1 && format != formatExcel && runs[i].table != "cmdb" && runs[i].table != "master_data" {
+			runs[i].output = fmt.Sprintf("%s-%s%s", fileBase, runs[i].table, fileExt)
+		}
+	}
+	return runs, nil
+}
+
+// recordTables returns the tables whose records a generated table writes
+func recordTables(table string) []string {
+	switch table {
+	case "cmdb":
+		return []string{"cmdb_ci", "cmdb_rel_ci"}
+	case "master_data":
+		return []string{"sys_user", "sys_user_group", "sys_user_grmember", "customer_account", "customer_contact"}
+	default:
+		return []string{table}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// newBulkGenerator creates a generator from the generation flags
+func newBulkGenerator() (*generator.BulkGenerator, error) {
+	// Seeded runs date their records from the start of the day, so runs on the same day
+	// repeat the same values
+	now := runStarted
+	if seed != 0 {
+		now = runStarted.Truncate(24 * time.Hour)
+		fmt.Printf("Using seed %d\n", seed)
+	}
+
+	// Get API key from environment if not provided
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENROUTER_API_KEY")
+	}
+
+	referenceData, err := loadReferenceData()
+	if err != nil {
+		return nil, err
+	}
+
+	mode, err := models.ParseReferenceMode(referenceMode)
+	if err != nil {
+		return nil, err
+	}
+	referenceMode = string(mode)
+
+	numbering, err := buildNumbering()
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := generator.ParseErrorPolicy(onError)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --on-error: %w", err)
+	}
+
+	// Create generator config
+	config := generator.Config{
+		RecordCount:      recordCount,
+		BatchSize:        batchSize,
+		TableName:        tableName,
+		ClosedPercentage: closedPercentage,
+		SplitOutput:      splitOutput,
+		APIKey:           apiKey,
+		Model:            model,
+		ReferenceData:    referenceData,
+		Numbering:        numbering,
+		Workers:          workers,
+		Seed:             seed,
+		Now:              now,
+		ErrorPolicy:      policy,
+	}
+	if generationProfile != nil {
+		config.TextEngine = generationProfile.Text.Engine
+		config.Temperature = generationProfile.Text.Temperature
+		config.TextFallback = generationProfile.Text.Fallback
+		config.Distributions = generationProfile.GeneratorDistributions()
+		config.Prompts = generationProfile.Text.Prompts
+		if config.Rules, err = generator.ParseRules(generationProfile.GeneratorRules()); err != nil {
+			return nil, fmt.Errorf("invalid rules: %w", err)
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Create bulk generator
+	bg := generator.NewBulkGenerator(config)
+
+	if config.TextEngine == generator.TextTemplate {
+		fmt.Println("Using template text without LLM calls")
+	} else {
+		fmt.Printf("Using OpenRouter with model: %s\n", model)
+	}
+
+	return bg, nil
+}
+
+// runLoad generates records in batches and loads each batch into the instance
+func runLoad(cmd *cobra.Command, args []string) error {
+	if err := applyProfile(cmd); err != nil {
+		return err
+	}
+
+	runs, err := tableRuns(formatCSV)
+	if err != nil {
+		return err
+	}
+	if len(runs) > 1 {
+		return fmt.Errorf("load takes a single table, not %d", len(runs))
+	}
+	run := runs[0]
+	tableName = run.table
+	recordCount = run.count
+	closedPercentage = run.closed
+
+	switch tableName {
+	case "incident", "case", "hr_case", "change_request", "knowledge_article":
+	default:
+		return fmt.Errorf("load supports the incident, case, hr_case, change_request and knowledge_article tables, not %s", tableName)
+	}
+
+	if instanceURL == "" {
+		instanceURL = os.Getenv("SERVICENOW_INSTANCE")
+	}
+	if instanceUser == "" {
+		instanceUser = os.Getenv("SERVICENOW_USERNAME")
+	}
+	if instancePassword == "" {
+		instancePassword = os.Getenv("SERVICENOW_PASSWORD")
+	}
+	if oauthClientSecret == "" {
+		oauthClientSecret = os.Getenv("SERVICENOW_CLIENT_SECRET")
+	}
+
+	loader, err := servicenow.NewLoader(servicenow.Config{
+		InstanceURL:  instanceURL,
+		Username:     instanceUser,
+		Password:     instancePassword,
+		ClientID:     oauthClientID,
+		ClientSecret: oauthClientSecret,
+		Table:        generator.ServiceNowTable(tableName),
+		ImportSet:    importSetTable,
+		Parallelism:  loadParallelism,
+		MaxRetries:   loadRetries,
+		ResultsFile:  loadResultsFile,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create loader: %w", err)
+	}
+	defer loader.Close()
+
+	sink, err := projectWriter(loader, run.columns, tableName)
+	if err != nil {
+		return err
+	}
+	// The loader, and its projection, stop loading when the run is stopped
+	load, ok := sink.(contextWriter)
+	if !ok {
+		return fmt.Errorf("failed to load: %T cannot be stopped with the run", sink)
+	}
+
+	fmt.Println("Starting bulk data load...")
+
+	bg, err := newBulkGenerator()
+	if err != nil {
+		return err
+	}
+
+	target := "table " + generator.ServiceNowTable(tableName)
+	if importSetTable != "" {
+		target = "import set " + importSetTable
+	}
+	fmt.Printf("Loading into %s on %s with %d parallel requests\n", target, instanceURL, loadParallelism)
+
+	ctx, cancel := runContext(cmd)
+	defer cancel()
+
+	startTime := time.Now()
+	recordsGenerated := 0
+	failedRecords := 0
+
+	// The next batch is generated while this one is loaded. When the run is stopped,
+	// loading stops too, and the records not loaded are marked cancelled in the results.
+	batches, stop := bg.Batches(ctx, recordCount, batchSize)
+	defer stop()
+	for batch := range batches {
+		if batch.Err != nil && ctx.Err() == nil {
+			return fmt.Errorf("failed to generate batch: %w", batch.Err)
+		}
+
+		if err := load.WriteRecordsContext(ctx, batch.Records); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return fmt.Errorf("failed to load records: %w", err)
+		}
+
+		recordsGenerated += len(batch.Records)
+		failedRecords += len(batch.Failures)
+		fmt.Printf("Progress: %d/%d records loaded, %d failed\n", loader.Loaded(), recordCount, loader.Failed())
+	}
+
+	if err := loader.Close(); err != nil {
+		return err
+	}
+
+	elapsed := time.Since(startTime)
+	if ctx.Err() != nil {
+		fmt.Printf("Data load stopped (%v)! Loaded %d of %d records in %v\n", context.Cause(ctx), loader.Loaded(), recordCount, elapsed)
+	} else {
+		fmt.Printf("Data load complete! Loaded %d of %d records in %v\n", loader.Loaded(), recordsGenerated, elapsed)
+	}
+	reportFailures(failedRecords)
+	if loadResultsFile != "" {
+		fmt.Printf("Load results written to %s\n", loadResultsFile)
+	}
+	if loader.Failed() > 0 {
+		return fmt.Errorf("%d records failed to load", loader.Failed())
+	}
+	if err := partial(ctx, recordsGenerated, recordCount); err != nil {
+		return stopped(cmd, ctx, err)
+	}
+
+	return nil
+}
+
+// applyProfile loads the --config profile into the settings of the run. Flags given on
+// the command line keep their values.
+func applyProfile(cmd *cobra.Command) error {
+	if configFile == "" {
+		return nil
+	}
+	p, err := profile.Load(configFile)
+	if err != nil {
+		return err
+	}
+	generationProfile = p
+
+	flags := cmd.Flags()
+	setString := func(name string, target *string, value string) {
+		if value != "" && !flags.Changed(name) {
+			*target = value
+		}
+	}
+	setInt := func(name string, target *int, value int) {
+		if value != 0 && !flags.Changed(name) {
+			*target = value
+		}
+	}
+	setBool := func(name string, target *bool, value *bool) {
+		if value != nil && !flags.Changed(name) {
+			*target = *value
+		}
+	}
+
+	if !flags.Changed("table") {
+		if p.Table != "" {
+			tableFlags = []string{p.Table}
+		}
+		profileTables = p.Tables
+	}
+	if p.Seed != nil && !flags.Changed("seed") {
+		seed = *p.Seed
+	}
+	setInt("count", &recordCount, p.Count)
+	setInt("batch", &batchSize, p.Batch)
+	setInt("workers", &workers, p.Workers)
+	setString("on-error", &onError, p.OnError)
+	if p.Timeout != 0 && !flags.Changed("timeout") {
+		runTimeout = p.Timeout
+	}
+	if p.Closed != nil && !flags.Changed("closed") {
+		closedPercentage = *p.Closed
+	}
+	setBool("split", &splitOutput, p.Split)
+	setString("references", &referenceMode, p.References)
+	setString("model", &model, p.Text.Model)
+
+	setString("reference-data", &referenceFile, p.ReferenceData.File)
+	setString("topology", &topologyFile, p.ReferenceData.Topology)
+	setInt("topology-services", &topologyServices, p.ReferenceData.TopologyServices)
+	setInt("topology-cis", &topologyCIs, p.ReferenceData.TopologyCIs)
+	setString("topology-out", &topologyOut, p.ReferenceData.TopologyOut)
+
+	setString("number-prefix", &numberPrefix, p.Numbering.Prefix)
+	if p.Numbering.Start != 0 && !flags.Changed("number-start") {
+		numberStart = p.Numbering.Start
+	}
+	setInt("number-digits", &numberDigits, p.Numbering.Digits)
+	setString("last-number", &lastNumber, p.Numbering.Last)
+
+	setString("output", &outputFile, p.Output.File)
+	setString("columns", &columnSpec, p.Output.Columns)
+	setString("compression", &compression, p.Output.Compression)
+	setString("sql-dialect", &sqlDialect, p.Output.SQLDialect)
+	setBool("sql-copy", &sqlCopy, p.Output.SQLCopy)
+	setString("reference-out", &referenceOut, p.Output.ReferenceOut)
+
+	fmt.Printf("Using profile %s\n", configFile)
+	return nil
+}
+
+// loadReferenceData builds the reference data from the built-in or loaded master data,
+// applying a loaded or synthetic CMDB topology
+func loadReferenceData() (*models.ReferenceData, error) {
+	referenceData := models.GetReferenceData()
+
+	if referenceFile != "" {
+		loaded, err := models.LoadReferenceData(referenceFile)
+		if err != nil {
+			return nil, err
+		}
+		referenceData = loaded
+		fmt.Printf("Loaded reference data from %s: %d users, %d groups, %d accounts, %d contacts\n",
+			referenceFile, len(referenceData.SysUser), len(referenceData.SysUserGroup), len(referenceData.Account), len(referenceData.Contact))
+	}
+
+	if topologyFile != "" && topologyServices > 0 {
+		return nil, fmt.Errorf("--topology and --topology-services cannot be used together")
+	}
+
+	if topologyFile != "" {
+		topology, err := models.LoadTopology(topologyFile)
+		if err != nil {
+			return nil, err
+		}
+		referenceData.ApplyTopology(topology)
+		fmt.Printf("Loaded CMDB topology from %s: %d services, %d CIs, %d relationships\n",
+			topologyFile, len(referenceData.CmdbCiService), len(referenceData.CmdbCi), len(referenceData.CmdbRelCi))
+	}
+
+	if topologyServices > 0 {
+		ciCount := topologyCIs
+		if ciCount <= 0 {
+			ciCount = topologyServices * 10
+		}
+		topology := models.GenerateTopology(topologyRand(), topologyServices, ciCount)
+		referenceData.ApplyTopology(topology)
+		fmt.Printf("Generated synthetic CMDB topology: %d services, %d CIs, %d relationships\n",
+			len(referenceData.CmdbCiService), len(referenceData.CmdbCi), len(referenceData.CmdbRelCi))
+
+		if topologyOut != "" {
+			if err := models.SaveTopology(topology, topologyOut); err != nil {
+				return nil, err
+			}
+			fmt.Printf("Synthetic topology written to %s\n", topologyOut)
+		}
+	}
+
+	return referenceData, nil
+}
+
+// topologyRand returns the random source of a synthetic topology, seeded with --seed
+// so a seeded run repeats its topology
+func topologyRand() *rand.Rand {
+	if seed != 0 {
+		return rand.New(rand.NewSource(seed))
+	}
+	return rand.New(rand.NewSource(rand.Int63()))
+}
+
+// buildNumbering applies the numbering flags to the table being generated
+func buildNumbering() (*generator.Numbering, error) {
+	numbering := generator.NewNumbering()
+
+	format := numbering.Format(tableName)
+	if numberPrefix != "" {
+		format.Prefix = numberPrefix
+	}
+	if numberStart > 0 {
+		format.Start = numberStart
+	}
+	if numberDigits > 0 {
+		format.Digits = numberDigits
+	}
+	if err := numbering.SetFormat(tableName, format); err != nil {
+		return nil, err
+	}
+
+	if lastNumber != "" {
+		if err := numbering.ContinueFrom(tableName, lastNumber); err != nil {
+			return nil, err
+		}
+	}
+
+	return numbering, nil
+}
+
+func generateSingleOutput(ctx context.Context, bg *generator.BulkGenerator, run tableRun, format outputFormat, workbook *excel.Workbook, startTime time.Time) error {
+	fmt.Printf("Output format is %s: %s\n", getFormatName(format), run.output)
+
+	// Create appropriate writer
+	var writer recordWriter
+	var excelWriter *excel.Writer
+	var err error
+	if workbook != nil {
+		writer, err = workbook.AddSheet(run.table)
+	} else if run.resume != nil && format != formatExcel {
+		writer, err = appendRecordWriter(format, run.output, run.resume.Offset)
+	} else {
+		writer, err = newRecordWriter(format, run.output, run.table)
+	}
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	output := writer
+
+	// Save what was written if generation fails; the workbook must be saved before it is closed
+	if workbook == nil {
+		excelWriter, _ = writer.(*excel.Writer)
+	}
+	if excelWriter != nil {
+		defer func() {
+			if err := excelWriter.SaveToFile(run.output); err != nil {
+				fmt.Printf("Error saving Excel file: %v\n", err)
+			}
+		}()
+	}
+
+	writer, err = projectWriter(writer, run.columns, run.table)
+	if err != nil {
+		return err
+	}
+
+	// Set headers
+	headers, err := tableHeaders(run.columns, run.table)
+	if err != nil {
+		return err
+	}
+
+	writer.SetReferenceMode(models.ReferenceMode(referenceMode))
+	if err := writer.SetHeaders(headers); err != nil {
+		return fmt.Errorf("failed to set headers: %w", err)
+	}
+
+	// A resumed run carries on after the records written before
+	var progress *runCheckpoint
+	if run.checkpointed {
+		if progress, err = startCheckpoint(run, format, output, writer); err != nil {
+			return err
+		}
+		defer progress.close()
+	}
+	recordsGenerated := 0
+	failedRecords := 0
+	if run.resume != nil {
+		recordsGenerated = run.resume.Written
+	}
+	remaining := run.count - recordsGenerated
+
+	// Generate data in batches
+	totalBatches := int(math.Ceil(float64(remaining) / float64(batchSize)))
+
+	fmt.Printf("Generating %d records in %d batches of %d...\n", remaining, totalBatches, batchSize)
+
+	// The next batch is generated while this one is written; when the run is stopped, the
+	// last batch holds the records completed until then
+	batches, stop := bg.Batches(ctx, remaining, batchSize)
+	defer stop()
+	for batch := range batches {
+		if batch.Err != nil && ctx.Err() == nil {
+			return fmt.Errorf("failed to generate batch: %w", batch.Err)
+		}
+
+		// Write the batch
+		if err := writer.WriteRecords(batch.Records); err != nil {
+			return fmt.Errorf("failed to write records: %w", err)
+		}
+
+		recordsGenerated += len(batch.Records)
+		failedRecords += len(batch.Failures)
+		if progress != nil {
+			if err := progress.save(batch, recordsGenerated); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Progress: %d/%d records (%.0f%%)\n", recordsGenerated, run.count, float64(recordsGenerated)/float64(run.count)*100)
+	}
+
+	// Save Excel file if needed
+	if excelWriter != nil {
+		if err := excelWriter.SaveToFile(run.output); err != nil {
+			return fmt.Errorf("failed to save Excel file: %w", err)
+		}
+	}
+
+	reportGenerated(ctx, recordsGenerated, run.count, "records", time.Since(startTime))
+	reportFailures(failedRecords)
+	if workbook != nil {
+		fmt.Printf("Excel data written to sheet %s\n", run.table)
+	} else {
+		fmt.Printf("%s data written to %s\n", getFormatName(format), run.output)
+	}
+	if progress != nil {
+		if err := progress.finish(ctx); err != nil {
+			return err
+		}
+	}
+
+	return partial(ctx, recordsGenerated, run.count)
+}
+
+func generateSplitOutput(ctx context.Context, bg *generator.BulkGenerator, run tableRun, format outputFormat, workbook *excel.Workbook, startTime time.Time) error {
+	// Get the base filename without extension
+	fileExt := filepath.Ext(run.output)
+	fileBase := strings.TrimSuffix(run.output, fileExt)
+
+	// Create filenames for closed and open cases; in a shared workbook they name sheets
+	closedFile := fmt.Sprintf("%s-closed%s", fileBase, fileExt)
+	openFile := fmt.Sprintf("%s-open%s", fileBase, fileExt)
+	if workbook != nil {
+		closedFile = run.table + "-closed"
+		openFile = run.table + "-open"
+	}
+
+	fmt.Printf("Splitting output into separate files:\n")
+	fmt.Printf("- Closed cases: %s\n", closedFile)
+	fmt.Printf("- Open cases: %s\n", openFile)
+
+	// Create writers for both files
+	newWriter := func(filename string) (recordWriter, error) {
+		if workbook != nil {
+			return workbook.AddSheet(filename)
+		}
+		return newRecordWriter(format, filename, run.table)
+	}
+	closedWriter, err := newWriter(closedFile)
+	if err != nil {
+		return fmt.Errorf("failed to create closed writer: %w", err)
+	}
+	defer closedWriter.Close()
+
+	openWriter, err := newWriter(openFile)
+	if err != nil {
+		return fmt.Errorf("failed to create open writer: %w", err)
+	}
+	defer openWriter.Close()
+
+	// Excel writers are saved at the end, so keep them before wrapping them in a projection
+	var closedExcel, openExcel *excel.Writer
+	if workbook == nil {
+		closedExcel, _ = closedWriter.(*excel.Writer)
+		openExcel, _ = openWriter.(*excel.Writer)
+	}
+	if closedWriter, err = projectWriter(closedWriter, run.columns, run.table); err != nil {
+		return err
+	}
+	if openWriter, err = projectWriter(openWriter, run.columns, run.table); err != nil {
+		return err
+	}
+
+	// Set headers for both writers
+	headers, err := tableHeaders(run.columns, run.table)
+	if err != nil {
+		return err
+	}
+
+	closedWriter.SetReferenceMode(models.ReferenceMode(referenceMode))
+	openWriter.SetReferenceMode(models.ReferenceMode(referenceMode))
+	if err := closedWriter.SetHeaders(headers); err != nil {
+		return fmt.Errorf("failed to set closed headers: %w", err)
+	}
+	if err := openWriter.SetHeaders(headers); err != nil {
+		return fmt.Errorf("failed to set open headers: %w", err)
+	}
+
+	// Generate data in batches
+	recordsGenerated := 0
+	failedRecords := 0
+	closedRecords := 0
+	openRecords := 0
+	totalBatches := int(math.Ceil(float64(run.count) / float64(batchSize)))
+
+	fmt.Printf("Generating %d records in %d batches of %d...\n", run.count, totalBatches, batchSize)
+
+	// The next batch is generated while this one is written; when the run is stopped, the
+	// last batch holds the records completed until then
+	batches, stop := bg.Batches(ctx, run.count, batchSize)
+	defer stop()
+	for batch := range batches {
+		if batch.Err != nil && ctx.Err() == nil {
+			return fmt.Errorf("failed to generate batch: %w", batch.Err)
+		}
+
+		// Split records into closed and open
+		for _, record := range batch.Records {
+			isClosed := generator.RecordClosed(run.table, record)
+
+			if isClosed {
+				if err := closedWriter.WriteRecord(record); err != nil {
+					return fmt.Errorf("failed to write closed record: %w", err)
+				}
+				closedRecords++
+			} else {
+				if err := openWriter.WriteRecord(record); err != nil {
+					return fmt.Errorf("failed to write open record: %w", err)
+				}
+				openRecords++
+			}
+		}
+
+		recordsGenerated += len(batch.Records)
+		failedRecords += len(batch.Failures)
+		fmt.Printf("Progress: %d/%d records (%.0f%%)\n", recordsGenerated, run.count, float64(recordsGenerated)/float64(run.count)*100)
+	}
+
+	// Save Excel files if needed
+	if closedExcel != nil {
+		if err := closedExcel.SaveToFile(closedFile); err != nil {
+			return fmt.Errorf("failed to save closed Excel file: %w", err)
+		}
+	}
+	if openExcel != nil {
+		if err := openExcel.SaveToFile(openFile); err != nil {
+			return fmt.Errorf("failed to save open Excel file: %w", err)
+		}
+	}
+
+	reportGenerated(ctx, recordsGenerated, run.count, "records", time.Since(startTime))
+	reportFailures(failedRecords)
+	fmt.Printf("- %d closed records\n", closedRecords)
+	fmt.Printf("- %d open records\n", openRecords)
+	fmt.Printf("%s data written to %s and %s\n", getFormatName(format), closedFile, openFile)
+
+	return partial(ctx, recordsGenerated, run.count)
+}
+
+// generateCMDBOutput writes each CI class and the relationships to a file of their own,
+// or to sheets of one workbook. A shared workbook is saved by the caller.
+func generateCMDBOutput(ctx context.Context, bg *generator.BulkGenerator, run tableRun, format outputFormat, workbook *excel.Workbook, startTime time.Time) error {
+	// Get the base filename without extension
+	fileExt := filepath.Ext(run.output)
+	fileBase := strings.TrimSuffix(run.output, fileExt)
+
+	writers := make(map[string]recordWriter)
+	filenames := make(map[string]string)
+	counts := make(map[string]int)
+	var classes []string
+
+	// Excel output is a single workbook with a sheet per class
+	ownWorkbook := workbook == nil && format == formatExcel
+	if ownWorkbook {
+		workbook = excel.NewWorkbook()
+		defer workbook.Close()
+	}
+
+	// Writers are created per CI class as classes appear, plus one for relationships
+	getWriter := func(class string) (recordWriter, error) {
+		if w, exists := writers[class]; exists {
+			return w, nil
+		}
+
+		var w recordWriter
+		var err error
+		filename := fmt.Sprintf("%s-%s%s", fileBase, class, fileExt)
+		if workbook != nil {
+			filename = run.output
+			w, err = workbook.AddSheet(class)
+		} else {
+			w, err = newRecordWriter(format, filename, class)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create writer for %s: %w", class, err)
+		}
+		if w, err = projectWriter(w, run.columns, class); err != nil {
+			w.Close()
+			return nil, err
+		}
+
+		headers, err := tableHeaders(run.columns, class)
+		if err != nil {
+			return nil, err
+		}
+		if err := w.SetHeaders(headers); err != nil {
+			return nil, fmt.Errorf("failed to set headers for %s: %w", class, err)
+		}
+
+		writers[class] = w
+		filenames[class] = filename
+		classes = append(classes, class)
+		return w, nil
+	}
+
+	defer func() {
+		for _, w := range writers {
+			w.Close()
+		}
+	}()
+
+	// Generate data in batches
+	recordsGenerated := 0
+	totalBatches := int(math.Ceil(float64(run.count) / float64(batchSize)))
+
+	fmt.Printf("Generating %d configuration items in %d batches of %d...\n", run.count, totalBatches, batchSize)
+
+	// The next batch is generated while this one is written; when the run is stopped, the
+	// last batch holds the records completed until then
+	batches, stop := bg.Batches(ctx, run.count, batchSize)
+	defer stop()
+	for batch := range batches {
+		if batch.Err != nil && ctx.Err() == nil {
+			return fmt.Errorf("failed to generate batch: %w", batch.Err)
+		}
+
+		// Route each record to the file of its class
+		for _, record := range batch.Records {
+			var class string
+			switch r := record.(type) {
+			case *generator.CMDBCIRecord:
+				// The services of the run come with the first batch, on top of the CIs requested
+				class = r.Class
+				if !strings.HasPrefix(class, "cmdb_ci_service") {
+					recordsGenerated++
+				}
+			case *generator.CIRelationshipRecord:
+				class = "cmdb_rel_ci"
+			default:
+				return fmt.Errorf("unexpected record type %T in cmdb batch", record)
+			}
+
+			w, err := getWriter(class)
+			if err != nil {
+				return err
+			}
+			if err := w.WriteRecord(record); err != nil {
+				return fmt.Errorf("failed to write %s record: %w", class, err)
+			}
+			counts[class]++
+		}
+
+		fmt.Printf("Progress: %d/%d CIs (%.0f%%)\n", recordsGenerated, run.count, float64(recordsGenerated)/float64(run.count)*100)
+	}
+
+	// Save the Excel workbook if needed
+	if ownWorkbook {
+		if err := workbook.SaveToFile(run.output); err != nil {
+			return fmt.Errorf("failed to save Excel file: %w", err)
+		}
+	}
+
+	reportGenerated(ctx, recordsGenerated, run.count, "configuration items", time.Since(startTime))
+	sort.Strings(classes)
+	for _, class := range classes {
+		fmt.Printf("- %d %s records written to %s\n", counts[class], class, filenames[class])
+	}
+
+	return partial(ctx, recordsGenerated, run.count)
+}
+
+// generateMasterDataOutput writes users, groups, memberships, accounts and contacts to a
+// file each, or to sheets of one workbook, plus the reference data file. Tables
+// generated later by bg refer to the new records. A shared workbook is saved by the
+// caller.
+func generateMasterDataOutput(ctx context.Context, bg *generator.BulkGenerator, run tableRun, format outputFormat, workbook *excel.Workbook, startTime time.Time) error {
+	// Get the base filename without extension
+	fileExt := filepath.Ext(run.output)
+	fileBase := strings.TrimSuffix(run.output, fileExt)
+
+	fmt.Printf("Generating master data for %d users...\n", run.count)
+	md := bg.GenerateMasterData(run.count)
+
+	tables := []struct {
+		name    string
+		records []interface{}
+	}{
+		{"sys_user", toRecords(md.Users)},
+		{"sys_user_group", toRecords(md.Groups)},
+		{"sys_user_grmember", toRecords(md.GroupMembers)},
+		{"customer_account", toRecords(md.Accounts)},
+		{"customer_contact", toRecords(md.Contacts)},
+	}
+
+	// Excel output is a single workbook with a sheet per table
+	ownWorkbook := workbook == nil && format == formatExcel
+	if ownWorkbook {
+		workbook = excel.NewWorkbook()
+		defer workbook.Close()
+	}
+
+	for _, table := range tables {
+		var w recordWriter
+		var err error
+		filename := fmt.Sprintf("%s-%s%s", fileBase, table.name, fileExt)
+		if workbook != nil {
+			filename = run.output
+			w, err = workbook.AddSheet(table.name)
+		} else {
+			w, err = newRecordWriter(format, filename, table.name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create writer for %s: %w", table.name, err)
+		}
+		if w, err = projectWriter(w, run.columns, table.name); err != nil {
+			w.Close()
+			return err
+		}
+		headers, err := tableHeaders(run.columns, table.name)
+		if err != nil {
+			w.Close()
+			return err
+		}
+		if err := w.SetHeaders(headers); err != nil {
+			w.Close()
+			return fmt.Errorf("failed to set headers for %s: %w", table.name, err)
+		}
+		for _, record := range table.records {
+			if err := w.WriteRecord(record); err != nil {
+				w.Close()
+				return fmt.Errorf("failed to write %s record: %w", table.name, err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("failed to close %s writer: %w", table.name, err)
+		}
+
+		fmt.Printf("- %d %s records written to %s\n", len(table.records), table.name, filename)
+	}
+
+	// Save the Excel workbook if needed
+	if ownWorkbook {
+		if err := workbook.SaveToFile(run.output); err != nil {
+			return fmt.Errorf("failed to save Excel file: %w", err)
+		}
+	}
+
+	// Write the reference data that ticket runs consume with --reference-data
+	referenceData := md.ReferenceData(bg.ReferenceData)
+	referenceFilename := referenceOut
+	if referenceFilename == "" {
+		referenceFilename = fileBase + "-reference.json"
+	}
+	if err := models.SaveReferenceData(referenceData, referenceFilename); err != nil {
+		return err
+	}
+	fmt.Printf("Reference data written to %s (use with --reference-data)\n", referenceFilename)
+	bg.ReferenceData = referenceData
+
+	elapsed := time.Since(startTime)
+	fmt.Printf("Data generation complete! Generated master data in %v\n", elapsed)
+
+	return nil
+}
+
+// runCheckpoint saves the progress of a table run after each batch
+type runCheckpoint struct {
+	*checkpoint.Checkpoint
+	filename string
+
+	// Appendable outputs report their size; workbooks are journaled instead
+	output  interface{ Offset() (int64, error) }
+	journal *checkpoint.Journal
+}
+
+// startCheckpoint starts checkpointing a table run written through w to output. A
+// resumed workbook is first rebuilt from the journal of the records written before.
+func startCheckpoint(run tableRun, format outputFormat, output, w recordWriter) (*runCheckpoint, error) {
+	c := &runCheckpoint{Checkpoint: run.resume, filename: checkpoint.Path(run.output)}
+	if c.Checkpoint == nil {
+		c.Checkpoint = &checkpoint.Checkpoint{
+			Table:      run.table,
+			Output:     run.output,
+			Columns:    run.spec,
+			References: referenceMode,
+			Count:      run.count,
+			RunID:      runID,
+			RunAt:      runStarted,
+			Seed:       seed,
+		}
+	}
+
+	if format != formatExcel {
+		offsetWriter, ok := output.(interface{ Offset() (int64, error) })
+		if !ok {
+			return nil, fmt.Errorf("%s output cannot be checkpointed", getFormatName(format))
+		}
+		c.output = offsetWriter
+		// Computed {row} columns carry on after the rows in the file
+		if projected, ok := w.(*projectedWriter); ok {
+			projected.rows = c.Written
+		}
+		return c, nil
+	}
+
+	c.Journal = checkpoint.JournalPath(run.output)
+	if run.resume != nil {
+		recordType, err := generator.TableRecordType(run.table)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("Rebuilding the %d records written before from %s...\n", c.Written, c.Journal)
+		replayed := 0
+		err = checkpoint.Replay(c.Journal, c.JournalOffset, recordType, batchSize, func(records []interface{}) error {
+			replayed += len(records)
+			return w.WriteRecords(records)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to rebuild %s: %w", run.output, err)
+		}
+		if replayed != c.Written {
+			return nil, fmt.Errorf("failed to rebuild %s: the journal has %d of the %d records written", run.output, replayed, c.Written)
+		}
+	}
+	journal, err := checkpoint.OpenJournal(c.Journal, c.JournalOffset)
+	if err != nil {
+		return nil, err
+	}
+	c.journal = journal
+	return c, nil
+}
+
+// save records a batch written to the output, bringing the records written to written
+func (c *runCheckpoint) save(batch generator.Batch, written int) error {
+	var err error
+	if c.journal != nil {
+		if err := c.journal.Append(batch.Records); err != nil {
+			return err
+		}
+		c.JournalOffset, err = c.journal.Offset()
+	} else {
+		c.Offset, err = c.output.Offset()
+	}
+	if err != nil {
+		return err
+	}
+
+	c.Written = written
+	c.Batches++
+	c.Numbering = batch.Counters
+	return c.Save(c.filename)
+}
+
+// finish removes the checkpoint of a complete run, or tells how to resume a stopped one
+func (c *runCheckpoint) finish(ctx context.Context) error {
+	if err := c.close(); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		fmt.Printf("Progress saved to %s; run again with --resume to continue\n", c.filename)
+		return nil
+	}
+	return c.Remove(c.filename)
+}
+
+// close closes the journal, if any
+func (c *runCheckpoint) close() error {
+	if c.journal == nil {
+		return nil
+	}
+	err := c.journal.Close()
+	c.journal = nil
+	return err
+}
+
+// toRecords converts a slice of typed records to the generic form the writers accept
+func toRecords[T any](items []*T) []interface{} {
+	records := make([]interface{}, len(items))
+	for i, item := range items {
+		records[i] = item
+	}
+	return records
+}
+
+// tableHeaders returns the column labels of the named table, including the display
+// columns of reference fields when references are written as both sys_id and display value
+func tableHeaders(spec *models.ColumnSpec, table string) ([]string, error) {
+	projection, err := tableProjection(spec, table)
+	if err != nil {
+		return nil, err
+	}
+	if projection != nil {
+		columns, err := models.RecordColumns(projection.Type, models.ReferenceMode(referenceMode))
+		if err != nil {
+			return nil, err
+		}
+		return models.ColumnLabels(columns), nil
+	}
+
+	columns, err := generator.TableColumns(table, models.ReferenceMode(referenceMode))
+	if err != nil {
+		return nil, err
+	}
+	return models.ColumnLabels(columns), nil
+}
+
+// parseColumns parses the column spec of a table run and checks it against the tables
+// it writes, so unknown fields are reported before anything is generated
+func parseColumns(run *tableRun, columnSpec string) error {
+	if columnSpec == "" {
+		return nil
+	}
+
+	spec, err := models.ParseColumnSpec(columnSpec)
+	if err != nil {
+		return fmt.Errorf("invalid columns of %s: %w", run.table, err)
+	}
+	run.columns = spec
+	run.spec = columnSpec
+
+	for _, table := range recordTables(run.table) {
+		if _, err := tableProjection(spec, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableProjection returns the projection of the named table's records through a column
+// spec, or nil without one
+func tableProjection(spec *models.ColumnSpec, table string) (*models.Projection, error) {
+	if spec == nil {
+		return nil, nil
+	}
+	recordType, err := generator.TableRecordType(table)
+	if err != nil {
+		return nil, err
+	}
+	projection, err := spec.Projection(recordType, runID, runStarted)
+	if err != nil {
+		return nil, fmt.Errorf("invalid columns for %s: %w", table, err)
+	}
+	return projection, nil
+}
+
+// projectedWriter writes records through a column projection, numbering them for the
+// {row} placeholder
+type projectedWriter struct {
+	recordWriter
+	projection *models.Projection
+	rows       int
+}
+
+// projectWriter wraps a writer of the named table's records in the projection of a
+// column spec, or returns it as is without one
+func projectWriter(w recordWriter, spec *models.ColumnSpec, table string) (recordWriter, error) {
+	projection, err := tableProjection(spec, table)
+	if err != nil || projection == nil {
+		return w, err
+	}
+	return &projectedWriter{recordWriter: w, projection: projection}, nil
+}
+
+// WriteRecord writes the projection of a record
+func (w *projectedWriter) WriteRecord(record interface{}) error {
+	w.rows++
+	projected, err := w.projection.Record(record, w.rows)
+	if err != nil {
+		return err
+	}
+	return w.recordWriter.WriteRecord(projected)
+}
+
+// WriteRecords writes the projections of records
+func (w *projectedWriter) WriteRecords(records []interface{}) error {
+	projected, err := w.project(records)
+	if err != nil {
+		return err
+	}
+	return w.recordWriter.WriteRecords(projected)
+}
+
+// WriteRecordsContext writes the projections of records to a writer that stops when ctx
+// is done, such as the loader
+func (w *projectedWriter) WriteRecordsContext(ctx context.Context, records []interface{}) error {
+	projected, err := w.project(records)
+	if err != nil {
+		return err
+	}
+	if cw, ok := w.recordWriter.(contextWriter); ok {
+		return cw.WriteRecordsContext(ctx, projected)
+	}
+	return w.recordWriter.WriteRecords(projected)
+}
+
+// project returns the projections of records
+func (w *projectedWriter) project(records []interface{}) ([]interface{}, error) {
+	projected := make([]interface{}, len(records))
+	for i, record := range records {
+		w.rows++
+		var err error
+		if projected[i], err = w.projection.Record(record, w.rows); err != nil {
+			return nil, err
+		}
+	}
+	return projected, nil
+}
+
+// outputFormat is the file format records are written in
+type outputFormat string
+
+// Output formats, selected by the extension of the output file
+const (
+	formatExcel   outputFormat = "excel"
+	formatCSV     outputFormat = "csv"
+	formatJSON    outputFormat = "json"
+	formatNDJSON  outputFormat = "ndjson"
+	formatXML     outputFormat = "xml"
+	formatParquet outputFormat = "parquet"
+	formatSQL     outputFormat = "sql"
+	formatSQLite  outputFormat = "sqlite"
+)
+
+// recordWriter is the contract shared by the CSV, Excel, JSON, XML, Parquet and SQL writers
+type recordWriter interface {
+	SetHeaders([]string) error
+	SetReferenceMode(models.ReferenceMode)
+	WriteRecord(interface{}) error
+	WriteRecords([]interface{}) error
+	Close() error
+}
+
+// contextWriter is implemented by writers whose writes stop when a context is done
+type contextWriter interface {
+	WriteRecordsContext(ctx context.Context, records []interface{}) error
+}
+
+// detectOutputFormat picks the output format from the file extension, defaulting to Excel
+func detectOutputFormat(filename string) outputFormat {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return formatCSV
+	case ".json":
+		return formatJSON
+	case ".ndjson", ".jsonl":
+		return formatNDJSON
+	case ".xml":
+		return formatXML
+	case ".parquet":
+		return formatParquet
+	case ".sql":
+		return formatSQL
+	case ".db", ".sqlite", ".sqlite3":
+		return formatSQLite
+	default:
+		return formatExcel
+	}
+}
+
+// newRecordWriter creates a writer of the given format for records of the named table.
+// Excel writers are kept in memory and must be saved with SaveToFile; the others stream
+// to filename.
+func newRecordWriter(format outputFormat, filename, table string) (recordWriter, error) {
+	switch format {
+	case formatCSV:
+		w, err := csv.NewWriter(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CSV writer: %w", err)
+		}
+		return w, nil
+	case formatJSON:
+		w, err := json.NewWriter(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create JSON writer: %w", err)
+		}
+		return w, nil
+	case formatNDJSON:
+		w, err := json.NewLinesWriter(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create NDJSON writer: %w", err)
+		}
+		return w, nil
+	case formatXML:
+		w, err := xml.NewWriter(filename, generator.ServiceNowTable(table))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create XML writer: %w", err)
+		}
+		return w, nil
+	case formatParquet:
+		// Each batch becomes a row group
+		w, err := parquet.NewWriter(filename, batchSize, compression)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Parquet writer: %w", err)
+		}
+		return w, nil
+	case formatSQL:
+		dialect, err := sql.ParseDialect(sqlDialect)
+		if err != nil {
+			return nil, err
+		}
+		w, err := sql.NewWriter(filename, generator.ServiceNowTable(table), dialect, batchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SQL writer: %w", err)
+		}
+		if err := w.SetCopy(sqlCopy); err != nil {
+			w.Close()
+			return nil, err
+		}
+		return w, nil
+	case formatSQLite:
+		w, err := sql.NewSQLiteWriter(filename, generator.ServiceNowTable(table), batchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SQLite writer: %w", err)
+		}
+		return w, nil
+	default:
+		return excel.NewWriter(table), nil
+	}
+}
+
+// appendRecordWriter opens an existing CSV or NDJSON output to add records to after
+// offset, dropping anything written after it
+func appendRecordWriter(format outputFormat, filename string, offset int64) (recordWriter, error) {
+	switch format {
+	case formatCSV:
+		w, err := csv.NewAppendWriter(filename, offset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open CSV writer: %w", err)
+		}
+		return w, nil
+	case formatNDJSON:
+		w, err := json.NewLinesAppendWriter(filename, offset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open NDJSON writer: %w", err)
+		}
+		return w, nil
+	default:
+		return nil, fmt.Errorf("%s output cannot be appended to", getFormatName(format))
+	}
+}
+
+func getFormatName(format outputFormat) string {
+	switch format {
+	case formatCSV:
+		return "CSV"
+	case formatJSON:
+		return "JSON"
+	case formatNDJSON:
+		return "NDJSON"
+	case formatXML:
+		return "XML"
+	case formatParquet:
+		return "Parquet"
+	case formatSQL:
+		return "SQL"
+	case formatSQLite:
+		return "SQLite"
+	default:
+		return "Excel"
+	}
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
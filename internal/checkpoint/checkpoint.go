@@ -0,0 +1,200 @@
+package checkpoint
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"time"
+)
+
+// Checkpoint is the progress of a run, saved next to its output after each batch so an
+// interrupted run can be resumed where it stopped
+type Checkpoint struct {
+	Table      string    `json:"table"`
+	Output     string    `json:"output"`
+	Columns    string    `json:"columns,omitempty"`
+	References string    `json:"references"`
+	Count      int       `json:"count"`
+	Written    int       `json:"written"`
+	Batches    int       `json:"batches"`
+	RunID      string    `json:"run_id"`
+	RunAt      time.Time `json:"run_at"`
+	Updated    time.Time `json:"updated"`
+
+	// Seed is the seed of the run, if any. Seeded records draw from a source derived from
+	// the seed and their number, so it is all the random state needed.
+	Seed int64 `json:"seed,omitempty"`
+
+	// Numbering holds the next record number of each table
+	Numbering map[string]int64 `json:"numbering"`
+
+	// Offset is the size of the output after the last record written. Outputs that
+	// cannot be appended to are rebuilt from the journal, up to JournalOffset.
+	Offset        int64  `json:"offset,omitempty"`
+	Journal       string `json:"journal,omitempty"`
+	JournalOffset int64  `json:"journal_offset,omitempty"`
+}
+
+// Path returns the checkpoint file of an output file
+func Path(output string) string {
+	return output + ".checkpoint.json"
+}
+
+// JournalPath returns the journal file of an output file
+func JournalPath(output string) string {
+	return output + ".journal.ndjson"
+}
+
+// Load reads a checkpoint
+func Load(filename string) (*Checkpoint, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("no checkpoint %s to resume from", filename)
+		}
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var c Checkpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", filename, err)
+	}
+	return &c, nil
+}
+
+// Save writes the checkpoint. It is written to a temporary file first, so a crash while
+// saving leaves the previous checkpoint in place.
+func (c *Checkpoint) Save(filename string) error {
+	c.Updated = time.Now().UTC()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the checkpoint and its journal once the run is complete
+func (c *Checkpoint) Remove(filename string) error {
+	if c.Journal != "" {
+		if err := os.Remove(c.Journal); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove journal: %w", err)
+		}
+	}
+	if err := os.Remove(filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Journal is a newline-delimited JSON file of the records written, used to rebuild
+// outputs that cannot be appended to, such as workbooks
+type Journal struct {
+	file   *os.File
+	writer *bufio.Writer
+	offset int64
+}
+
+// OpenJournal opens a journal for appending after offset. Anything after offset was
+// written after the last checkpoint and is dropped; offset 0 starts a new journal.
+func OpenJournal(filename string, offset int64) (*Journal, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to truncate journal: %w", err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to seek journal: %w", err)
+	}
+	return &Journal{file: file, writer: bufio.NewWriter(file), offset: offset}, nil
+}
+
+// Append adds records to the journal
+func (j *Journal) Append(records []interface{}) error {
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode journal record: %w", err)
+		}
+		n, err := j.writer.Write(append(data, '\n'))
+		j.offset += int64(n)
+		if err != nil {
+			return fmt.Errorf("failed to write journal: %w", err)
+		}
+	}
+	return nil
+}
+
+// Offset flushes the journal and returns its size
+func (j *Journal) Offset() (int64, error) {
+	if err := j.writer.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to flush journal: %w", err)
+	}
+	return j.offset, nil
+}
+
+// Close flushes and closes the journal
+func (j *Journal) Close() error {
+	if err := j.writer.Flush(); err != nil {
+		j.file.Close()
+		return fmt.Errorf("failed to flush journal: %w", err)
+	}
+	return j.file.Close()
+}
+
+// Replay reads the records of a journal up to offset as pointers to recordType, passing
+// them to fn in chunks of up to size records
+func Replay(filename string, offset int64, recordType reflect.Type, size int, fn func(records []interface{}) error) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	if recordType.Kind() == reflect.Ptr {
+		recordType = recordType.Elem()
+	}
+	if size <= 0 {
+		size = 1000
+	}
+
+	decoder := json.NewDecoder(io.LimitReader(file, offset))
+	var records []interface{}
+	read := 0 // records read before the chunk
+	for {
+		record := reflect.New(recordType).Interface()
+		if err := decoder.Decode(record); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("failed to read journal record %d: %w", read+len(records)+1, err)
+		}
+		records = append(records, record)
+		if len(records) == size {
+			if err := fn(records); err != nil {
+				return err
+			}
+			read += len(records)
+			records = nil
+		}
+	}
+	if len(records) > 0 {
+		return fn(records)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+type journalRecord struct {
+	Number string                `json:"number"`
+	Caller models.ReferenceValue `json:"caller"`
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "incidents.csv")
+	filename := Path(output)
+
+	if _, err := Load(filename); err == nil || !strings.Contains(err.Error(), "no checkpoint") {
+		t.Errorf("Expected an error for a missing checkpoint, got %v", err)
+	}
+
+	saved := &Checkpoint{
+		Table:     "incident",
+		Output:    output,
+		Count:     100,
+		Written:   40,
+		RunID:     "run-1",
+		RunAt:     time.Date(2026, 3, 1, 8, 30, 0, 0, time.UTC),
+		Seed:      42,
+		Numbering: map[string]int64{"incident": 10041},
+		Offset:    1234,
+	}
+	if err := saved.Save(filename); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	loaded, err := Load(filename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	saved.Updated = loaded.Updated
+	if !reflect.DeepEqual(loaded, saved) {
+		t.Errorf("Expected the saved checkpoint %+v, got %+v", saved, loaded)
+	}
+	if _, err := os.Stat(filename + ".tmp"); !os.IsNotExist(err) {
+		t.Error("Expected the temporary file to be renamed")
+	}
+
+	if err := loaded.Remove(filename); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Error("Expected the checkpoint to be removed")
+	}
+}
+
+func TestJournal(t *testing.T) {
+	filename := JournalPath(filepath.Join(t.TempDir(), "incidents.xlsx"))
+	record := func(number string) *journalRecord {
+		return &journalRecord{Number: number, Caller: models.ReferenceValue{SysID: "u1", DisplayValue: "Beth Anglin"}}
+	}
+
+	journal, err := OpenJournal(filename, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := journal.Append([]interface{}{record("INC1"), record("INC2"), record("INC3")}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	offset, err := journal.Offset()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Records appended after the last checkpoint are dropped when the journal is reopened
+	if err := journal.Append([]interface{}{record("lost")}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	journal.Close()
+
+	journal, err = OpenJournal(filename, offset)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := journal.Append([]interface{}{record("INC4")}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	offset, _ = journal.Offset()
+	journal.Close()
+
+	var numbers []string
+	var chunks int
+	err = Replay(filename, offset, reflect.TypeOf(&journalRecord{}), 3, func(records []interface{}) error {
+		chunks++
+		for _, r := range records {
+			replayed := r.(*journalRecord)
+			if replayed.Caller.DisplayValue != "Beth Anglin" {
+				t.Errorf("Expected references to be replayed, got %+v", replayed.Caller)
+			}
+			numbers = append(numbers, replayed.Number)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []string{"INC1", "INC2", "INC3", "INC4"}; !reflect.DeepEqual(numbers, expected) {
+		t.Errorf("Expected records %v, got %v", expected, numbers)
+	}
+	if chunks != 2 {
+		t.Errorf("Expected 2 chunks of up to 3 records, got %d", chunks)
+	}
+
+	// A corrupt record is reported by its position in the journal, not in its chunk
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	file.WriteString("{\"number\": \n")
+	file.Close()
+	info, _ := os.Stat(filename)
+	err = Replay(filename, info.Size(), reflect.TypeOf(&journalRecord{}), 3, func([]interface{}) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "failed to read journal record 5") {
+		t.Errorf("Expected record 5 to be reported, got %v", err)
+	}
+}
@@ -1,796 +1,1260 @@
-package generator
-
-import (
-	"fmt"
-	"math/rand"
-	"strconv"
-	"strings"
-	"sync"
-	"time"
-
-	"github.com/brianvoe/gofakeit/v6"
-	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/llm"
-	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
-)
-
-// BulkGenerator represents the bulk data generator
-type BulkGenerator struct {
-	RecordCount      int
-	BatchSize        int
-	TableName        string
-	ClosedPercentage int
-	SplitOutput      bool
-	LLMClient        *llm.OpenRouterClient
-	ReferenceData    *models.ReferenceData
-	ChoiceValues     *models.ChoiceValues
-}
-
-// IncidentRecord represents an incident record
-type IncidentRecord struct {
-	Caller            string `json:"caller"`
-	Category          string `json:"category"`
-	Subcategory       string `json:"subcategory"`
-	Service           string `json:"service"`
-	ServiceOffering   string `json:"service_offering"`
-	ConfigurationItem string `json:"configuration_item"`
-	ShortDescription  string `json:"short_description"`
-	Description       string `json:"description"`
-	Channel           string `json:"channel"`
-	Opened            string `json:"opened"`
-	IncidentState     string `json:"incident_state"`
-	Impact            int    `json:"impact"`
-	Urgency           int    `json:"urgency"`
-	Priority          string `json:"priority"`
-	AssignmentGroup   string `json:"assignment_group"`
-	AssignedTo        string `json:"assigned_to"`
-	ResolutionCode    string `json:"resolution_code"`
-	ResolutionNotes   string `json:"resolution_notes"`
-}
-
-// CaseRecord represents a CSM case record
-type CaseRecord struct {
-	Number                        string `json:"number"`
-	ContactType                   string `json:"contact_type"`
-	Account                       string `json:"account"`
-	Contact                       string `json:"contact"`
-	Consumer                      string `json:"consumer"`
-	RequestingServiceOrganization string `json:"requesting_service_organization"`
-	Product                       string `json:"product"`
-	Asset                         string `json:"asset"`
-	InstallBase                   string `json:"install_base"`
-	PartnerContact                string `json:"partner_contact"`
-	Parent                        string `json:"parent"`
-	ShortDescription              string `json:"short_description"`
-	NeedsAttention                string `json:"needs_attention"`
-	OpenedAt                      string `json:"opened_at"`
-	Priority                      int    `json:"priority"`
-	AssignmentGroup               string `json:"assignment_group"`
-	AssignedTo                    string `json:"assigned_to"`
-	ServiceOrganization           string `json:"service_organization"`
-	Contract                      string `json:"contract"`
-	Entitlement                   string `json:"entitlement"`
-	Partner                       string `json:"partner"`
-	State                         string `json:"state"`
-	ResolvedBy                    string `json:"resolved_by,omitempty"`
-	ResolvedAt                    string `json:"resolved_at,omitempty"`
-	ClosedBy                      string `json:"closed_by,omitempty"`
-	ClosedAt                      string `json:"closed_at,omitempty"`
-	ResolutionCode                string `json:"resolution_code,omitempty"`
-	Cause                         string `json:"cause,omitempty"`
-	CloseCode                     string `json:"close_code"`
-	CloseNotes                    string `json:"close_notes"`
-	NotesToComments               string `json:"notes_to_comments,omitempty"`
-}
-
-// HRCaseRecord represents an HR case record
-type HRCaseRecord struct {
-	Number           string `json:"number"`
-	ShortDescription string `json:"short_description"`
-	Description      string `json:"description"`
-	OpenedFor        string `json:"opened_for"`
-	HRService        string `json:"hr_service"`
-	SubjectPerson    string `json:"subject_person"`
-	AssignmentGroup  string `json:"assignment_group"`
-	HRServiceType    string `json:"hr_service_type"`
-	DueDate          string `json:"due_date"`
-	OpenedBy         string `json:"opened_by"`
-	State            string `json:"state"`
-	Priority         int    `json:"priority"`
-	OpenedAt         string `json:"opened_at"`
-	AssignedTo       string `json:"assigned_to,omitempty"`
-	ResolvedBy       string `json:"resolved_by,omitempty"`
-	ResolvedAt       string `json:"resolved_at,omitempty"`
-	ClosedBy         string `json:"closed_by,omitempty"`
-	ClosedAt         string `json:"closed_at,omitempty"`
-	CloseCode        string `json:"close_code,omitempty"`
-	CloseNotes       string `json:"close_notes,omitempty"`
-}
-
-// ChangeRequestRecord represents a change request record
-type ChangeRequestRecord struct {
-	Number             string `json:"number"`
-	ShortDescription   string `json:"short_description"`
-	Description        string `json:"description"`
-	RequestedBy        string `json:"requested_by"`
-	Category           string `json:"category"`
-	BusinessService    string `json:"business_service"`
-	ConfigurationItem  string `json:"configuration_item"`
-	Priority           int    `json:"priority"`
-	Risk               string `json:"risk"`
-	Impact             int    `json:"impact"`
-	AssignmentGroup    string `json:"assignment_group"`
-	AssignedTo         string `json:"assigned_to,omitempty"`
-	Justification      string `json:"justification"`
-	ImplementationPlan string `json:"implementation_plan"`
-	RiskImpactAnalysis string `json:"risk_impact_analysis"`
-	BackoutPlan        string `json:"backout_plan"`
-	TestPlan           string `json:"test_plan"`
-	StartDate          string `json:"start_date"`
-	EndDate            string `json:"end_date"`
-	State              string `json:"state"`
-	OpenedAt           string `json:"opened_at"`
-	OpenedBy           string `json:"opened_by"`
-	CloseCode          string `json:"close_code,omitempty"`
-	CloseNotes         string `json:"close_notes,omitempty"`
-}
-
-// KnowledgeArticleRecord represents a knowledge article record
-type KnowledgeArticleRecord struct {
-	Number           string `json:"number"`
-	ShortDescription string `json:"short_description"`
-	Text             string `json:"text"`
-	KnowledgeBase    string `json:"knowledge_base"`
-	Category         string `json:"category"`
-	ValidTo          string `json:"valid_to"`
-	WorkflowState    string `json:"workflow_state"`
-	Published        string `json:"published"`
-	Author           string `json:"author"`
-	Active           string `json:"active"`
-	Meta             string `json:"meta,omitempty"`
-	CreatedOn        string `json:"created_on"`
-	UpdatedOn        string `json:"updated_on"`
-}
-
-// NewBulkGenerator creates a new bulk generator instance
-func NewBulkGenerator(config Config) *BulkGenerator {
-	return &BulkGenerator{
-		RecordCount:      config.RecordCount,
-		BatchSize:        config.BatchSize,
-		TableName:        config.TableName,
-		ClosedPercentage: config.ClosedPercentage,
-		SplitOutput:      config.SplitOutput,
-		LLMClient:        llm.NewOpenRouterClient(config.APIKey, config.Model),
-		ReferenceData:    models.GetReferenceData(),
-		ChoiceValues:     models.GetChoiceValues(),
-	}
-}
-
-// Config represents the configuration for the bulk generator
-type Config struct {
-	RecordCount      int
-	BatchSize        int
-	TableName        string
-	ClosedPercentage int
-	SplitOutput      bool
-	APIKey           string
-	Model            string
-}
-
-// GenerateBatch generates a batch of records
-func (bg *BulkGenerator) GenerateBatch(batchSize int) ([]interface{}, error) {
-	fmt.Printf("Generating batch of %d records with concurrent processing...\n", batchSize)
-
-	// Create a channel to collect results
-	results := make(chan interface{}, batchSize)
-	var wg sync.WaitGroup
-
-	// Limit concurrency to avoid overwhelming the API
-	concurrency := 10
-	semaphore := make(chan struct{}, concurrency)
-
-	// Generate records concurrently
-	for i := 0; i < batchSize; i++ {
-		wg.Add(1)
-		go func(index int) {
-			defer wg.Done()
-
-			// Acquire semaphore
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			var record interface{}
-			var err error
-
-			switch bg.TableName {
-			case "incident":
-				record, err = bg.generateIncidentRecord(index)
-			case "case":
-				record, err = bg.generateCaseRecord(index)
-			case "hr_case":
-				record, err = bg.generateHRCaseRecord(index)
-			case "change_request":
-				record, err = bg.generateChangeRequestRecord(index)
-			case "knowledge_article":
-				record, err = bg.generateKnowledgeArticleRecord(index)
-			default:
-				err = fmt.Errorf("unsupported table type: %s", bg.TableName)
-			}
-
-			if err != nil {
-				fmt.Printf("Error generating record %d: %v\n", index, err)
-				// Create a minimal error record
-				if bg.TableName == "incident" {
-					record = &IncidentRecord{
-						ShortDescription: "Error generating incident record",
-						Description:      fmt.Sprintf("Error: %v", err),
-					}
-				} else {
-					record = &CaseRecord{
-						Number:           fmt.Sprintf("ERROR-%d", index),
-						ShortDescription: "Error generating case record",
-					}
-				}
-			}
-
-			results <- record
-		}(i)
-	}
-
-	// Close results channel when all goroutines are done
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	// Collect all results
-	var records []interface{}
-	for record := range results {
-		records = append(records, record)
-	}
-
-	return records, nil
-}
-
-// generateIncidentRecord generates a single incident record
-func (bg *BulkGenerator) generateIncidentRecord(index int) (*IncidentRecord, error) {
-	// Get random values
-	caller := bg.ReferenceData.GetRandomReference("sys_user")
-	category := bg.ChoiceValues.GetRandomChoice("category").(string)
-	subcategory := bg.ChoiceValues.GetRandomSubcategory(category)
-	businessService := bg.ReferenceData.GetRandomReference("cmdb_ci_service")
-	ci := bg.ReferenceData.GetRandomReference("cmdb_ci")
-	contactType := bg.ChoiceValues.GetRandomChoice("contact_type").(string)
-
-	// Generate opened_at in ServiceNow format
-	openedAt := bg.generateRandomOpenedAt()
-
-	// Get state
-	stateObj := bg.ChoiceValues.GetRandomChoice("state").(*models.ChoiceValue)
-	state := stateObj.Display
-
-	// Get impact and urgency (numeric values)
-	impactObj := bg.ChoiceValues.GetRandomChoice("impact").(*models.ChoiceValue)
-	impact := impactObj.Value
-
-	urgencyObj := bg.ChoiceValues.GetRandomChoice("urgency").(*models.ChoiceValue)
-	urgency := urgencyObj.Value
-
-	// Don't set priority - let ServiceNow calculate it
-	priority := ""
-
-	// Get assignment data
-	assignmentGroup := bg.ReferenceData.GetRandomReference("sys_user_group")
-	assignedTo := bg.ReferenceData.GetRandomReference("sys_user")
-
-	// Generate descriptions using LLM
-	descriptions, err := bg.LLMClient.GenerateIncidentDescriptions(category, subcategory)
-	if err != nil {
-		// Use fallback descriptions
-		descriptions = &llm.DescriptionResponse{
-			ShortDescription: fmt.Sprintf("%s - %s issue", category, subcategory),
-			Description:      fmt.Sprintf("Incident regarding %s - %s", category, subcategory),
-		}
-	}
-
-	// Generate close notes if the incident is closed or resolved
-	var closeCode, closeNotes string
-	if state == "Resolved" || state == "Closed" {
-		closeCode = bg.ChoiceValues.GetRandomChoice("close_code").(string)
-
-		notes, err := bg.LLMClient.GenerateCloseNotes(descriptions.ShortDescription, descriptions.Description, closeCode)
-		if err != nil {
-			closeNotes = fmt.Sprintf("Incident closed with code: %s", closeCode)
-		} else {
-			closeNotes = notes
-		}
-	}
-
-	return &IncidentRecord{
-		Caller:            caller.DisplayValue,
-		Category:          category,
-		Subcategory:       subcategory,
-		Service:           businessService.DisplayValue,
-		ServiceOffering:   "",
-		ConfigurationItem: ci.DisplayValue,
-		ShortDescription:  descriptions.ShortDescription,
-		Description:       descriptions.Description,
-		Channel:           contactType,
-		Opened:            openedAt,
-		IncidentState:     state,
-		Impact:            impact,
-		Urgency:           urgency,
-		Priority:          priority,
-		AssignmentGroup:   assignmentGroup.DisplayValue,
-		AssignedTo:        assignedTo.DisplayValue,
-		ResolutionCode:    closeCode,
-		ResolutionNotes:   closeNotes,
-	}, nil
-}
-
-// generateCaseRecord generates a single case record
-func (bg *BulkGenerator) generateCaseRecord(index int) (*CaseRecord, error) {
-	// Generate case number
-	timestamp := time.Now().Unix()
-	caseNumber := fmt.Sprintf("CS%s%04d", strconv.FormatInt(timestamp, 10)[3:], index)
-
-	// Get random account and contact
-	account := bg.ReferenceData.GetRandomReference("account")
-
-	// Find contacts for this account
-	var contact *models.ReferenceValue
-	for _, c := range bg.ReferenceData.Contact {
-		if accountID, exists := c.Extra["account"]; exists && accountID == account.SysID {
-			contact = &c
-			break
-		}
-	}
-	if contact == nil {
-		contact = bg.ReferenceData.GetRandomReference("contact")
-	}
-
-	// Get case details
-	caseType := bg.ChoiceValues.GetRandomChoice("case_type").(string)
-	category := bg.ChoiceValues.GetRandomChoice("case_category").(string)
-	subcategory := bg.ChoiceValues.GetRandomCaseSubcategory(category)
-	contactType := bg.ChoiceValues.GetRandomChoice("contact_type").(string)
-
-	// Determine if case should be closed
-	shouldBeClosed := rand.Float64()*100 < float64(bg.ClosedPercentage)
-
-	var stateObj *models.ChoiceValue
-	if shouldBeClosed {
-		// Get closed states
-		closedStates := []*models.ChoiceValue{}
-		for _, s := range bg.ChoiceValues.CaseState {
-			if s.Display == "Resolved" || s.Display == "Closed" {
-				closedStates = append(closedStates, &s)
-			}
-		}
-		if len(closedStates) > 0 {
-			stateObj = closedStates[rand.Intn(len(closedStates))]
-		} else {
-			stateObj = &bg.ChoiceValues.CaseState[rand.Intn(len(bg.ChoiceValues.CaseState))]
-		}
-	} else {
-		// Get open states
-		openStates := []*models.ChoiceValue{}
-		for _, s := range bg.ChoiceValues.CaseState {
-			if s.Display != "Resolved" && s.Display != "Closed" {
-				openStates = append(openStates, &s)
-			}
-		}
-		if len(openStates) > 0 {
-			stateObj = openStates[rand.Intn(len(openStates))]
-		} else {
-			stateObj = &bg.ChoiceValues.CaseState[rand.Intn(len(bg.ChoiceValues.CaseState))]
-		}
-	}
-
-	// Get assignment data
-	priority := rand.Intn(5) + 1
-	assignmentGroup := bg.ReferenceData.GetRandomReference("sys_user_group")
-	assignedTo := bg.ReferenceData.GetRandomReference("sys_user")
-
-	// Generate descriptions using LLM
-	descriptions, err := bg.LLMClient.GenerateCaseDescriptions(category, subcategory, account.DisplayValue, caseType)
-	if err != nil {
-		// Use fallback descriptions
-		descriptions = &llm.DescriptionResponse{
-			ShortDescription: fmt.Sprintf("%s: %s - %s issue", account.DisplayValue, category, subcategory),
-			Description:      fmt.Sprintf("%s case from %s regarding %s - %s", caseType, account.DisplayValue, category, subcategory),
-		}
-	}
-
-	// Generate additional fields using gofakeit
-	consumer := gofakeit.Name()
-	requestingServiceOrg := gofakeit.Company() + " " + gofakeit.BuzzWord()
-	product := gofakeit.ProductName()
-	asset := strings.ToUpper(gofakeit.LetterN(8))
-	installBase := strings.ToUpper(gofakeit.LetterN(10))
-	partnerContact := gofakeit.Name()
-	parent := ""
-	if rand.Float64() > 0.8 {
-		parent = fmt.Sprintf("CS%07d", rand.Intn(9999999))
-	}
-	needsAttention := "false"
-	if rand.Float64() > 0.7 {
-		needsAttention = "true"
-	}
-	openedAt := bg.generateRandomOpenedAt()
-	serviceOrganization := gofakeit.Company() + " Services"
-	contract := fmt.Sprintf("CNTR%07d", rand.Intn(9999999))
-
-	entitlements := []string{
-		"24/7 Support", "Business Hours Support", "Premium Support",
-		"Standard Warranty", "Extended Warranty", "10-year product warranty on inverters",
-	}
-	entitlement := entitlements[rand.Intn(len(entitlements))]
-	partner := gofakeit.Company() + " Partners"
-
-	// Generate close notes if the case is closed or resolved
-	var closeCode, closeNotes string
-	if stateObj.Display == "Resolved" || stateObj.Display == "Closed" {
-		closeCode = bg.ChoiceValues.GetRandomChoice("case_close_code").(string)
-
-		notes, err := bg.LLMClient.GenerateCloseNotes(descriptions.ShortDescription, descriptions.Description, closeCode)
-		if err != nil {
-			closeNotes = fmt.Sprintf("Case closed with code: %s. The customer's request was addressed according to standard procedures.", closeCode)
-		} else {
-			closeNotes = notes
-		}
-	}
-
-	record := &CaseRecord{
-		Number:                        caseNumber,
-		ContactType:                   contactType,
-		Account:                       account.DisplayValue,
-		Contact:                       contact.DisplayValue,
-		Consumer:                      consumer,
-		RequestingServiceOrganization: requestingServiceOrg,
-		Product:                       product,
-		Asset:                         asset,
-		InstallBase:                   installBase,
-		PartnerContact:                partnerContact,
-		Parent:                        parent,
-		ShortDescription:              descriptions.ShortDescription,
-		NeedsAttention:                needsAttention,
-		OpenedAt:                      openedAt,
-		Priority:                      priority,
-		AssignmentGroup:               assignmentGroup.DisplayValue,
-		AssignedTo:                    assignedTo.DisplayValue,
-		ServiceOrganization:           serviceOrganization,
-		Contract:                      contract,
-		Entitlement:                   entitlement,
-		Partner:                       partner,
-		State:                         stateObj.Display,
-		CloseCode:                     closeCode,
-		CloseNotes:                    closeNotes,
-	}
-
-	// Add resolution information for closed cases
-	if shouldBeClosed {
-		resolvedBy := bg.ReferenceData.GetRandomReference("sys_user").DisplayValue
-		closedBy := bg.ReferenceData.GetRandomReference("sys_user").DisplayValue
-
-		// Generate dates for resolved_at and closed_at
-		now := time.Now()
-		resolvedAt := now.AddDate(0, 0, -rand.Intn(7))     // Random time in the last week
-		closedAt := resolvedAt.AddDate(0, 0, rand.Intn(2)) // 0-2 days after resolved
-
-		resolutionCodes := []string{
-			"Fixed by Vendor", "Fixed by Customer", "Fixed by Support",
-			"Workaround Provided", "Configuration Change", "Software Update", "Hardware Replacement",
-		}
-		resolutionCode := resolutionCodes[rand.Intn(len(resolutionCodes))]
-
-		causes := []string{
-			"User Error", "Software Bug", "Hardware Failure", "Network Issue",
-			"Configuration Error", "Third-party Integration", "Environmental Factor",
-		}
-		cause := causes[rand.Intn(len(causes))]
-
-		record.ResolvedBy = resolvedBy
-		record.ResolvedAt = resolvedAt.Format("2006-01-02")
-		record.ClosedBy = closedBy
-		record.ClosedAt = closedAt.Format("2006-01-02")
-		record.ResolutionCode = resolutionCode
-		record.Cause = cause
-		record.NotesToComments = "false"
-		if rand.Float64() > 0.5 {
-			record.NotesToComments = "true"
-		}
-	}
-
-	return record, nil
-}
-
-// generateHRCaseRecord generates a single HR case record
-func (bg *BulkGenerator) generateHRCaseRecord(index int) (*HRCaseRecord, error) {
-	// Generate HR case number
-	timestamp := time.Now().Unix()
-	hrNumber := fmt.Sprintf("HRC%s%04d", strconv.FormatInt(timestamp, 10)[3:], index)
-
-	// Get random users
-	openedFor := bg.ReferenceData.GetRandomReference("sys_user")
-	subjectPerson := bg.ReferenceData.GetRandomReference("sys_user")
-	openedBy := bg.ReferenceData.GetRandomReference("sys_user")
-	assignmentGroup := bg.ReferenceData.GetRandomReference("sys_user_group")
-
-	// HR service types
-	hrServiceTypes := []string{
-		"employee_relations", "benefits", "payroll", "recruitment",
-		"performance_management", "training", "compliance", "onboarding",
-	}
-	hrServiceType := hrServiceTypes[rand.Intn(len(hrServiceTypes))]
-
-	// Generate HR-specific categories and issues
-	hrCategories := []string{
-		"Benefits", "Payroll", "Time Off", "Performance", "Training",
-		"Compliance", "Employee Relations", "Onboarding", "Offboarding",
-	}
-	category := hrCategories[rand.Intn(len(hrCategories))]
-
-	// Generate descriptions using LLM
-	descriptions, err := bg.LLMClient.GenerateIncidentDescriptions(category, hrServiceType)
-	if err != nil {
-		descriptions = &llm.DescriptionResponse{
-			ShortDescription: fmt.Sprintf("HR %s - %s request", category, hrServiceType),
-			Description:      fmt.Sprintf("HR case regarding %s - %s for employee assistance", category, hrServiceType),
-		}
-	}
-
-	// Generate dates
-	openedAt := bg.generateRandomOpenedAt()
-	dueDate := time.Now().AddDate(0, 0, rand.Intn(14)+1) // 1-14 days from now
-
-	// Determine state and priority
-	priority := rand.Intn(4) + 1
-	states := []string{"New", "In Progress", "Awaiting Info", "Resolved", "Closed"}
-	state := states[rand.Intn(len(states))]
-
-	record := &HRCaseRecord{
-		Number:           hrNumber,
-		ShortDescription: descriptions.ShortDescription,
-		Description:      descriptions.Description,
-		OpenedFor:        openedFor.DisplayValue,
-		HRService:        "HR Services",
-		SubjectPerson:    subjectPerson.DisplayValue,
-		AssignmentGroup:  assignmentGroup.DisplayValue,
-		HRServiceType:    hrServiceType,
-		DueDate:          dueDate.Format("2006-01-02"),
-		OpenedBy:         openedBy.DisplayValue,
-		State:            state,
-		Priority:         priority,
-		OpenedAt:         openedAt,
-	}
-
-	// Add resolution info for closed cases
-	if state == "Resolved" || state == "Closed" {
-		resolvedBy := bg.ReferenceData.GetRandomReference("sys_user").DisplayValue
-		closedBy := bg.ReferenceData.GetRandomReference("sys_user").DisplayValue
-
-		now := time.Now()
-		resolvedAt := now.AddDate(0, 0, -rand.Intn(7))
-		closedAt := resolvedAt.AddDate(0, 0, rand.Intn(2))
-
-		closeCodes := []string{
-			"Resolved", "Closed Complete", "Closed Incomplete",
-			"Cancelled", "Duplicate", "Resolved by Caller",
-		}
-		closeCode := closeCodes[rand.Intn(len(closeCodes))]
-
-		closeNotes, err := bg.LLMClient.GenerateCloseNotes(descriptions.ShortDescription, descriptions.Description, closeCode)
-		if err != nil {
-			closeNotes = fmt.Sprintf("HR case resolved with code: %s", closeCode)
-		}
-
-		record.AssignedTo = resolvedBy
-		record.ResolvedBy = resolvedBy
-		record.ResolvedAt = resolvedAt.Format("2006-01-02")
-		record.ClosedBy = closedBy
-		record.ClosedAt = closedAt.Format("2006-01-02")
-		record.CloseCode = closeCode
-		record.CloseNotes = closeNotes
-	}
-
-	return record, nil
-}
-
-// generateChangeRequestRecord generates a single change request record
-func (bg *BulkGenerator) generateChangeRequestRecord(index int) (*ChangeRequestRecord, error) {
-	// Generate change request number
-	timestamp := time.Now().Unix()
-	crNumber := fmt.Sprintf("CHG%s%04d", strconv.FormatInt(timestamp, 10)[3:], index)
-
-	// Get random values
-	requestedBy := bg.ReferenceData.GetRandomReference("sys_user")
-	businessService := bg.ReferenceData.GetRandomReference("cmdb_ci_service")
-	ci := bg.ReferenceData.GetRandomReference("cmdb_ci")
-	assignmentGroup := bg.ReferenceData.GetRandomReference("sys_user_group")
-	assignedTo := bg.ReferenceData.GetRandomReference("sys_user")
-
-	// Change categories
-	categories := []string{
-		"Software", "Hardware", "Network", "Security", "Database",
-		"Application", "Infrastructure", "Emergency", "Standard", "Normal",
-	}
-	category := categories[rand.Intn(len(categories))]
-
-	// Risk levels
-	risks := []string{"Low", "Medium", "High", "Very High"}
-	risk := risks[rand.Intn(len(risks))]
-
-	// Generate priority and impact
-	priority := rand.Intn(4) + 1
-	impact := rand.Intn(4) + 1
-
-	// Generate descriptions using LLM
-	descriptions, err := bg.LLMClient.GenerateIncidentDescriptions(category, "Change Request")
-	if err != nil {
-		descriptions = &llm.DescriptionResponse{
-			ShortDescription: fmt.Sprintf("%s change request for %s", category, ci.DisplayValue),
-			Description:      fmt.Sprintf("Change request to modify %s configuration for %s", category, businessService.DisplayValue),
-		}
-	}
-
-	// Generate detailed plans using LLM
-	justificationPrompt := fmt.Sprintf("Write a business justification for a %s change request affecting %s. Include business value and expected benefits.", category, businessService.DisplayValue)
-	justification, err := bg.LLMClient.GenerateText(justificationPrompt, 500)
-	if err != nil {
-		justification = fmt.Sprintf("Business justification for %s change to improve system performance and reliability.", category)
-	}
-
-	implementationPrompt := fmt.Sprintf("Create an implementation plan for a %s change request. Include specific steps, timing, and ownership.", category)
-	implementationPlan, err := bg.LLMClient.GenerateText(implementationPrompt, 800)
-	if err != nil {
-		implementationPlan = fmt.Sprintf("Implementation plan for %s change with step-by-step procedures.", category)
-	}
-
-	riskPrompt := fmt.Sprintf("Analyze risks and impacts for a %s change request. Include mitigation strategies.", category)
-	riskAnalysis, err := bg.LLMClient.GenerateText(riskPrompt, 600)
-	if err != nil {
-		riskAnalysis = fmt.Sprintf("Risk analysis for %s change with identified mitigation strategies.", category)
-	}
-
-	backoutPrompt := fmt.Sprintf("Create a backout plan for a %s change request. Include specific rollback steps.", category)
-	backoutPlan, err := bg.LLMClient.GenerateText(backoutPrompt, 500)
-	if err != nil {
-		backoutPlan = fmt.Sprintf("Backout plan for %s change with rollback procedures.", category)
-	}
-
-	testPrompt := fmt.Sprintf("Develop a test plan for a %s change request. Include test cases and success criteria.", category)
-	testPlan, err := bg.LLMClient.GenerateText(testPrompt, 600)
-	if err != nil {
-		testPlan = fmt.Sprintf("Test plan for %s change with validation procedures.", category)
-	}
-
-	// Generate dates
-	openedAt := bg.generateRandomOpenedAt()
-	startDate := time.Now().AddDate(0, 0, rand.Intn(30)+1) // 1-30 days from now
-	endDate := startDate.AddDate(0, 0, rand.Intn(7)+1)     // 1-7 days after start
-
-	// Change states
-	states := []string{"New", "Assess", "Authorize", "Scheduled", "Implement", "Review", "Closed"}
-	state := states[rand.Intn(len(states))]
-
-	record := &ChangeRequestRecord{
-		Number:             crNumber,
-		ShortDescription:   descriptions.ShortDescription,
-		Description:        descriptions.Description,
-		RequestedBy:        requestedBy.DisplayValue,
-		Category:           category,
-		BusinessService:    businessService.DisplayValue,
-		ConfigurationItem:  ci.DisplayValue,
-		Priority:           priority,
-		Risk:               risk,
-		Impact:             impact,
-		AssignmentGroup:    assignmentGroup.DisplayValue,
-		AssignedTo:         assignedTo.DisplayValue,
-		Justification:      justification,
-		ImplementationPlan: implementationPlan,
-		RiskImpactAnalysis: riskAnalysis,
-		BackoutPlan:        backoutPlan,
-		TestPlan:           testPlan,
-		StartDate:          startDate.Format("2006-01-02"),
-		EndDate:            endDate.Format("2006-01-02"),
-		State:              state,
-		OpenedAt:           openedAt,
-		OpenedBy:           requestedBy.DisplayValue,
-	}
-
-	// Add close info for closed changes
-	if state == "Closed" {
-		closeCodes := []string{
-			"Successful", "Successful with Issues", "Unsuccessful",
-			"Cancelled", "Backed Out", "Partially Successful",
-		}
-		closeCode := closeCodes[rand.Intn(len(closeCodes))]
-
-		closeNotes, err := bg.LLMClient.GenerateCloseNotes(descriptions.ShortDescription, descriptions.Description, closeCode)
-		if err != nil {
-			closeNotes = fmt.Sprintf("Change request completed with status: %s", closeCode)
-		}
-
-		record.CloseCode = closeCode
-		record.CloseNotes = closeNotes
-	}
-
-	return record, nil
-}
-
-// generateKnowledgeArticleRecord generates a single knowledge article record
-func (bg *BulkGenerator) generateKnowledgeArticleRecord(index int) (*KnowledgeArticleRecord, error) {
-	// Generate KB number
-	timestamp := time.Now().Unix()
-	kbNumber := fmt.Sprintf("KB%s%04d", strconv.FormatInt(timestamp, 10)[3:], index)
-
-	// Knowledge categories
-	categories := []string{
-		"IT Services", "Hardware", "Software", "Network", "Security",
-		"Troubleshooting", "How-To", "FAQ", "Best Practices", "Procedures",
-	}
-	category := categories[rand.Intn(len(categories))]
-
-	// Generate article content using LLM
-	titlePrompt := fmt.Sprintf("Create a knowledge article title for %s. Make it concise and solution-oriented.", category)
-	title, err := bg.LLMClient.GenerateText(titlePrompt, 100)
-	if err != nil {
-		title = fmt.Sprintf("How to resolve %s issues", category)
-	}
-
-	contentPrompt := fmt.Sprintf("Create a comprehensive knowledge base article about %s. Include sections for Problem Description, Symptoms, Cause, Resolution Steps, Prevention, and Related Information. Format with HTML headings and lists.", category)
-	content, err := bg.LLMClient.GenerateText(contentPrompt, 2000)
-	if err != nil {
-		content = fmt.Sprintf(`<h2>Problem Description</h2>
-<p>This article covers common %s issues and their resolutions.</p>
-<h2>Symptoms</h2>
-<ul><li>Common symptoms related to %s</li></ul>
-<h2>Resolution Steps</h2>
-<ol><li>Step 1: Identify the issue</li><li>Step 2: Apply the solution</li><li>Step 3: Verify the fix</li></ol>`, category, category)
-	}
-
-	// Generate keywords
-	keywordsPrompt := fmt.Sprintf("Generate 5-7 relevant technical keywords for a knowledge article about %s. Return only keywords separated by commas.", category)
-	keywords, err := bg.LLMClient.GenerateText(keywordsPrompt, 100)
-	if err != nil {
-		keywords = fmt.Sprintf("%s, troubleshooting, resolution, guide", strings.ToLower(category))
-	}
-
-	// Get author
-	author := bg.ReferenceData.GetRandomReference("sys_user")
-
-	// Generate dates
-	createdOn := bg.generateRandomOpenedAt()
-	updatedOn := time.Now().Format("2006-01-02 15:04:05")
-	published := time.Now().Format("2006-01-02 15:04:05")
-	validTo := time.Now().AddDate(2, 0, 0).Format("2006-01-02") // Valid for 2 years
-
-	return &KnowledgeArticleRecord{
-		Number:           kbNumber,
-		ShortDescription: title,
-		Text:             content,
-		KnowledgeBase:    "IT Knowledge Base",
-		Category:         category,
-		ValidTo:          validTo,
-		WorkflowState:    "published",
-		Published:        published,
-		Author:           author.DisplayValue,
-		Active:           "true",
-		Meta:             keywords,
-		CreatedOn:        createdOn,
-		UpdatedOn:        updatedOn,
-	}, nil
-}
-
-// generateRandomOpenedAt generates a random opened_at date/time in ServiceNow format
-func (bg *BulkGenerator) generateRandomOpenedAt() string {
-	now := time.Now()
-	oneYearAgo := now.AddDate(-1, 0, 0)
-
-	// Generate random timestamp between one year ago and now
-	randomTime := oneYearAgo.Unix() + rand.Int63n(now.Unix()-oneYearAgo.Unix())
-	randomDate := time.Unix(randomTime, 0)
-
-	// Format as YYYY-MM-DD HH:MM:SS for ServiceNow
-	return randomDate.Format("2006-01-02 15:04:05")
-}
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/llm"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+// BulkGenerator represents the bulk data generator
+type BulkGenerator struct {
+	RecordCount      int
+	BatchSize        int
+	TableName        string
+	ClosedPercentage int
+	SplitOutput      bool
+	LLMClient        *llm.OpenRouterClient
+	TextProvider     TextProvider // replaces LLMClient for text when set
+	TextFallback     bool         // template text in place of text the provider fails to write
+	Log              io.Writer    // progress messages, standard output when nil
+	ReferenceData    *models.ReferenceData
+	ChoiceValues     *models.ChoiceValues
+	Numbering        *Numbering
+	Workers          int
+	Seed             int64
+	ErrorPolicy      ErrorPolicy
+	Distributions    map[string]Distribution
+	Prompts          map[string]string
+	Rules            *models.RuleSet // applied to each record after its table generator
+	Now              time.Time       // the time generated dates are relative to, in UTC
+
+	// cmdbSequence and cmdbBatches keep CMDB names unique across batches, and cmdbCIs
+	// counts the CIs spread over the services of cmdbTopology, generated once per run
+	cmdbSequence int64
+	cmdbBatches  int64
+	cmdbCIs      int64
+	cmdbOnce     sync.Once
+	cmdbTopology *models.Topology
+}
+
+// IncidentRecord represents an incident record
+type IncidentRecord struct {
+	SysID             string                `json:"sys_id" label:"Sys ID"`
+	Number            string                `json:"number" label:"Number"`
+	Caller            models.ReferenceValue `json:"caller" xml:"caller_id" label:"Caller"`
+	Category          string                `json:"category" label:"Category"`
+	Subcategory       string                `json:"subcategory" label:"Subcategory"`
+	Service           models.ReferenceValue `json:"service" xml:"business_service" label:"Service"`
+	ServiceOffering   string                `json:"service_offering" label:"Service offering"`
+	ConfigurationItem models.ReferenceValue `json:"configuration_item" xml:"cmdb_ci" label:"Configuration item"`
+	ShortDescription  string                `json:"short_description" label:"Short description"`
+	Description       string                `json:"description" label:"Description"`
+	Channel           string                `json:"channel" xml:"contact_type" choice:"contact_type" label:"Channel"`
+	Opened            string                `json:"opened" xml:"opened_at" type:"timestamp" label:"Opened"`
+	IncidentState     string                `json:"incident_state" choice:"incident_state" label:"Incident State"`
+	Impact            int                   `json:"impact" label:"Impact"`
+	Urgency           int                   `json:"urgency" label:"Urgency"`
+	Priority          string                `json:"priority" type:"int" label:"Priority"`
+	AssignmentGroup   models.ReferenceValue `json:"assignment_group" label:"Assignment group"`
+	AssignedTo        models.ReferenceValue `json:"assigned_to" label:"Assigned to"`
+	ResolutionCode    string                `json:"resolution_code" xml:"close_code" label:"Resolution code"`
+	ResolutionNotes   string                `json:"resolution_notes" xml:"close_notes" label:"Resolution notes"`
+}
+
+// CaseRecord represents a CSM case record
+type CaseRecord struct {
+	SysID                         string                `json:"sys_id" label:"Sys ID"`
+	Number                        string                `json:"number" label:"Number"`
+	ContactType                   string                `json:"contact_type" choice:"contact_type" label:"Channel"`
+	Account                       models.ReferenceValue `json:"account" label:"Account"`
+	Contact                       models.ReferenceValue `json:"contact" label:"Contact"`
+	Consumer                      string                `json:"consumer" label:"Consumer"`
+	RequestingServiceOrganization string                `json:"requesting_service_organization" label:"Requesting Service Organization"`
+	Product                       string                `json:"product" label:"Product"`
+	Asset                         string                `json:"asset" label:"Asset"`
+	InstallBase                   string                `json:"install_base" label:"Install Base"`
+	PartnerContact                string                `json:"partner_contact" label:"Partner Contact"`
+	Parent                        string                `json:"parent" label:"Parent"`
+	ShortDescription              string                `json:"short_description" label:"Short description"`
+	NeedsAttention                string                `json:"needs_attention" type:"boolean" label:"Needs attention"`
+	OpenedAt                      string                `json:"opened_at" type:"timestamp" label:"Opened"`
+	Priority                      int                   `json:"priority" label:"Priority"`
+	AssignmentGroup               models.ReferenceValue `json:"assignment_group" label:"Assignment group"`
+	AssignedTo                    models.ReferenceValue `json:"assigned_to" label:"Assigned to"`
+	ServiceOrganization           string                `json:"service_organization" label:"Service Organization"`
+	Contract                      string                `json:"contract" label:"Contract"`
+	Entitlement                   string                `json:"entitlement" label:"Entitlement"`
+	Partner                       string                `json:"partner" label:"Partner"`
+	State                         string                `json:"state" choice:"case_state" label:"State"`
+	ResolvedBy                    models.ReferenceValue `json:"resolved_by,omitempty" label:"Resolved by"`
+	ResolvedAt                    string                `json:"resolved_at,omitempty" type:"timestamp" label:"Resolved at"`
+	ClosedBy                      models.ReferenceValue `json:"closed_by,omitempty" label:"Closed by"`
+	ClosedAt                      string                `json:"closed_at,omitempty" type:"timestamp" label:"Closed at"`
+	ResolutionCode                string                `json:"resolution_code,omitempty" label:"Resolution code"`
+	Cause                         string                `json:"cause,omitempty" label:"Cause"`
+	CloseCode                     string                `json:"close_code" label:"Close code"`
+	CloseNotes                    string                `json:"close_notes" label:"Close notes"`
+	NotesToComments               string                `json:"notes_to_comments,omitempty" label:"Notes to comments"`
+}
+
+// HRCaseRecord represents an HR case record
+type HRCaseRecord struct {
+	SysID            string                `json:"sys_id" label:"Sys ID"`
+	Number           string                `json:"number" label:"Number"`
+	ShortDescription string                `json:"short_description" label:"Short description"`
+	Description      string                `json:"description" label:"Description"`
+	OpenedFor        models.ReferenceValue `json:"opened_for" label:"Opened for"`
+	HRService        string                `json:"hr_service" label:"HR service"`
+	SubjectPerson    models.ReferenceValue `json:"subject_person" label:"Subject person"`
+	AssignmentGroup  models.ReferenceValue `json:"assignment_group" label:"Assignment group"`
+	HRServiceType    string                `json:"hr_service_type" xml:"-" label:"HR service type"`
+	DueDate          string                `json:"due_date" type:"date" label:"Due date"`
+	OpenedBy         models.ReferenceValue `json:"opened_by" label:"Opened by"`
+	State            string                `json:"state" choice:"hr_state" label:"State"`
+	Priority         int                   `json:"priority" label:"Priority"`
+	OpenedAt         string                `json:"opened_at" type:"timestamp" label:"Opened"`
+	AssignedTo       models.ReferenceValue `json:"assigned_to,omitempty" label:"Assigned to"`
+	ResolvedBy       models.ReferenceValue `json:"resolved_by,omitempty" label:"Resolved by"`
+	ResolvedAt       string                `json:"resolved_at,omitempty" type:"timestamp" label:"Resolved at"`
+	ClosedBy         models.ReferenceValue `json:"closed_by,omitempty" label:"Closed by"`
+	ClosedAt         string                `json:"closed_at,omitempty" type:"timestamp" label:"Closed at"`
+	CloseCode        string                `json:"close_code,omitempty" label:"Close code"`
+	CloseNotes       string                `json:"close_notes,omitempty" label:"Close notes"`
+}
+
+// ChangeRequestRecord represents a change request record
+type ChangeRequestRecord struct {
+	SysID              string                `json:"sys_id" label:"Sys ID"`
+	Number             string                `json:"number" label:"Number"`
+	ShortDescription   string                `json:"short_description" label:"Short description"`
+	Description        string                `json:"description" label:"Description"`
+	RequestedBy        models.ReferenceValue `json:"requested_by" label:"Requested by"`
+	Category           string                `json:"category" label:"Category"`
+	BusinessService    models.ReferenceValue `json:"business_service" label:"Business service"`
+	ConfigurationItem  models.ReferenceValue `json:"configuration_item" xml:"cmdb_ci" label:"Configuration item"`
+	Priority           int                   `json:"priority" label:"Priority"`
+	Risk               string                `json:"risk" choice:"change_risk" label:"Risk"`
+	Impact             int                   `json:"impact" label:"Impact"`
+	AssignmentGroup    models.ReferenceValue `json:"assignment_group" label:"Assignment group"`
+	AssignedTo         models.ReferenceValue `json:"assigned_to,omitempty" label:"Assigned to"`
+	Justification      string                `json:"justification" label:"Justification"`
+	ImplementationPlan string                `json:"implementation_plan" label:"Implementation plan"`
+	RiskImpactAnalysis string                `json:"risk_impact_analysis" label:"Risk and impact analysis"`
+	BackoutPlan        string                `json:"backout_plan" label:"Backout plan"`
+	TestPlan           string                `json:"test_plan" label:"Test plan"`
+	StartDate          string                `json:"start_date" type:"date" label:"Planned start date"`
+	EndDate            string                `json:"end_date" type:"date" label:"Planned end date"`
+	State              string                `json:"state" choice:"change_state" label:"State"`
+	OpenedAt           string                `json:"opened_at" type:"timestamp" label:"Opened"`
+	OpenedBy           models.ReferenceValue `json:"opened_by" label:"Opened by"`
+	CloseCode          string                `json:"close_code,omitempty" label:"Close code"`
+	CloseNotes         string                `json:"close_notes,omitempty" label:"Close notes"`
+}
+
+// KnowledgeArticleRecord represents a knowledge article record
+type KnowledgeArticleRecord struct {
+	SysID            string                `json:"sys_id" label:"Sys ID"`
+	Number           string                `json:"number" label:"Number"`
+	ShortDescription string                `json:"short_description" label:"Short description"`
+	Text             string                `json:"text" label:"Article body"`
+	KnowledgeBase    string                `json:"knowledge_base" xml:"kb_knowledge_base" label:"Knowledge base"`
+	Category         string                `json:"category" xml:"kb_category" label:"Category"`
+	ValidTo          string                `json:"valid_to" type:"date" label:"Valid to"`
+	WorkflowState    string                `json:"workflow_state" label:"Workflow"`
+	Published        string                `json:"published" type:"timestamp" label:"Published"`
+	Author           models.ReferenceValue `json:"author" label:"Author"`
+	Active           string                `json:"active" type:"boolean" label:"Active"`
+	Meta             string                `json:"meta,omitempty" label:"Meta"`
+	CreatedOn        string                `json:"created_on" xml:"sys_created_on" type:"timestamp" label:"Created"`
+	UpdatedOn        string                `json:"updated_on" xml:"sys_updated_on" type:"timestamp" label:"Updated"`
+}
+
+// serviceNowTables maps the table names used on the command line to ServiceNow tables
+var serviceNowTables = map[string]string{
+	"incident":          "incident",
+	"case":              "sn_customerservice_case",
+	"hr_case":           "sn_hr_core_case",
+	"change_request":    "change_request",
+	"knowledge_article": "kb_knowledge",
+}
+
+// ServiceNowTable returns the ServiceNow table that records of the named table belong in.
+// Names that already are ServiceNow tables, such as CI classes, are returned unchanged.
+func ServiceNowTable(name string) string {
+	if table, ok := serviceNowTables[name]; ok {
+		return table
+	}
+	return name
+}
+
+// generatedTables are the tables that can be generated
+var generatedTables = []string{"incident", "case", "hr_case", "change_request", "knowledge_article", "cmdb", "master_data"}
+
+// Tables returns the names of the tables that can be generated
+func Tables() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	return append([]string(nil), generatedTables...)
+}
+
+// tableRecords maps the tables written by cmdb and master_data to the type of their
+// records, which define their columns. Generated tables define theirs with RecordType.
+var tableRecords = map[string]reflect.Type{
+	"cmdb_ci":           reflect.TypeOf(CMDBCIRecord{}),
+	"cmdb_rel_ci":       reflect.TypeOf(CIRelationshipRecord{}),
+	"sys_user":          reflect.TypeOf(UserRecord{}),
+	"sys_user_group":    reflect.TypeOf(GroupRecord{}),
+	"sys_user_grmember": reflect.TypeOf(GroupMemberRecord{}),
+	"customer_account":  reflect.TypeOf(AccountRecord{}),
+	"customer_contact":  reflect.TypeOf(ContactRecord{}),
+}
+
+// TableRecordType returns the type of the records of the named table. CI classes share
+// the records of cmdb_ci.
+func TableRecordType(name string) (reflect.Type, error) {
+	if table, ok := lookupTable(name); ok {
+		return table.RecordType(), nil
+	}
+	recordType, ok := tableRecords[name]
+	if !ok && strings.HasPrefix(name, "cmdb_ci_") {
+		recordType, ok = tableRecords["cmdb_ci"]
+	}
+	if !ok {
+		return nil, fmt.Errorf("no columns defined for table %s", name)
+	}
+	return recordType, nil
+}
+
+// TableColumns returns the columns written for the records of the named table, as
+// defined by the tags of the record fields
+func TableColumns(name string, mode models.ReferenceMode) ([]models.Column, error) {
+	recordType, err := TableRecordType(name)
+	if err != nil {
+		return nil, err
+	}
+	return models.RecordColumns(recordType, mode)
+}
+
+// NewBulkGenerator creates a new bulk generator instance
+func NewBulkGenerator(config Config) *BulkGenerator {
+	referenceData := config.ReferenceData
+	if referenceData == nil {
+		referenceData = models.GetReferenceData()
+	}
+	numbering := config.Numbering
+	if numbering == nil {
+		numbering = NewNumbering()
+	}
+	client := llm.NewOpenRouterClient(config.APIKey, config.Model)
+	if config.TextEngine == TextTemplate {
+		client.APIKey = ""
+	}
+	if config.Temperature != nil {
+		client.Temperature = *config.Temperature
+	}
+	now := config.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	// Dates are written without a zone and read back as UTC
+	now = now.UTC()
+
+	return &BulkGenerator{
+		RecordCount:      config.RecordCount,
+		BatchSize:        config.BatchSize,
+		TableName:        config.TableName,
+		ClosedPercentage: config.ClosedPercentage,
+		SplitOutput:      config.SplitOutput,
+		LLMClient:        client,
+		TextProvider:     textEngines[config.TextEngine],
+		TextFallback:     config.TextFallback,
+		Log:              config.Log,
+		ReferenceData:    referenceData,
+		ChoiceValues:     models.GetChoiceValues(),
+		Numbering:        numbering,
+		Workers:          config.Workers,
+		Seed:             config.Seed,
+		ErrorPolicy:      config.ErrorPolicy,
+		Distributions:    config.Distributions,
+		Prompts:          config.Prompts,
+		Rules:            config.Rules,
+		Now:              now,
+	}
+}
+
+// ForTable returns a generator of another table that shares the LLM client, reference
+// data, numbering and settings of bg, so the tables of a run draw from one pool
+func (bg *BulkGenerator) ForTable(table string, closedPercentage int) *BulkGenerator {
+	return &BulkGenerator{
+		RecordCount:      bg.RecordCount,
+		BatchSize:        bg.BatchSize,
+		TableName:        table,
+		ClosedPercentage: closedPercentage,
+		SplitOutput:      bg.SplitOutput,
+		LLMClient:        bg.LLMClient,
+		TextProvider:     bg.TextProvider,
+		TextFallback:     bg.TextFallback,
+		Log:              bg.Log,
+		ReferenceData:    bg.ReferenceData,
+		ChoiceValues:     bg.ChoiceValues,
+		Numbering:        bg.Numbering,
+		Workers:          bg.Workers,
+		Seed:             bg.Seed,
+		ErrorPolicy:      bg.ErrorPolicy,
+		Distributions:    bg.Distributions,
+		Prompts:          bg.Prompts,
+		Rules:            bg.Rules,
+		Now:              bg.Now,
+	}
+}
+
+// Config represents the configuration for the bulk generator
+type Config struct {
+	RecordCount      int
+	BatchSize        int
+	TableName        string
+	ClosedPercentage int
+	SplitOutput      bool
+	APIKey           string
+	Model            string
+	// ReferenceData overrides the built-in reference data when set
+	ReferenceData *models.ReferenceData
+	// Numbering overrides the default record numbering when set
+	Numbering *Numbering
+	// TextEngine selects how text fields are written, TextLLM by default, or names an
+	// engine added with RegisterTextEngine
+	TextEngine string
+	// TextFallback writes template text in place of text the provider fails to write;
+	// by default the record fails and the error policy decides
+	TextFallback bool
+	// Temperature overrides the sampling temperature of the LLM when set
+	Temperature *float64
+	// Workers is the number of records generated at once; by default 10 when text
+	// comes from the LLM and one per CPU otherwise
+	Workers int
+	// Seed, when set, seeds the random values of each record from the seed and the
+	// record's number, so runs with the same seed and settings pick the same values
+	// whatever the number of workers, and a resumed run picks the values the stopped
+	// run would have. Text written by the LLM is not repeatable.
+	Seed int64
+	// Now is the time generated dates are relative to, the time the generator is
+	// created by default. Dates are generated in UTC, whatever its zone. Seeded runs
+	// repeat their dates when it is set.
+	Now time.Time
+	// ErrorPolicy decides what happens to records that cannot be generated; by default
+	// the batch fails
+	ErrorPolicy ErrorPolicy
+	// Distributions replace the default distributions of the same names
+	Distributions map[string]Distribution
+	// Log receives progress messages, standard output by default
+	Log io.Writer
+	// Prompts replace the default LLM prompts of the same names
+	Prompts map[string]string
+	// Rules set, compute and check fields of the generated records, parsed with
+	// ParseRules
+	Rules *models.RuleSet
+}
+
+// Validate checks the configuration, so a run that cannot work is rejected before
+// anything is generated
+func (c Config) Validate() error {
+	if tables := Tables(); !containsString(tables, c.TableName) {
+		return fmt.Errorf("unsupported table type: %s (known tables: %s)", c.TableName, strings.Join(tables, ", "))
+	}
+	if c.ClosedPercentage < 0 || c.ClosedPercentage > 100 {
+		return fmt.Errorf("closed percentage %d is not between 0 and 100", c.ClosedPercentage)
+	}
+	if c.Workers < 0 {
+		return fmt.Errorf("workers %d is negative", c.Workers)
+	}
+	referenceData := c.ReferenceData
+	if referenceData == nil {
+		referenceData = models.GetReferenceData()
+	} else if err := referenceData.Validate(); err != nil {
+		return fmt.Errorf("invalid reference data: %w", err)
+	}
+	if err := c.Rules.CheckReferences(recordTypes(), referenceData); err != nil {
+		return fmt.Errorf("invalid rules: %w", err)
+	}
+	if _, err := ParseErrorPolicy(c.ErrorPolicy.String()); err != nil {
+		return err
+	}
+	if c.TextEngine != "" && !containsString(TextEngines(), c.TextEngine) {
+		return fmt.Errorf("unknown text engine %s (known engines: %s)", c.TextEngine, strings.Join(TextEngines(), ", "))
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Text engines
+const (
+	TextLLM      = "llm"      // text from the LLM
+	TextTemplate = "template" // text from templates only, without LLM calls
+)
+
+// GenerateBatch generates a batch of records. When ctx is done, no new records are
+// started and the records completed so far are returned, in order, with the error of ctx.
+// Records that fail are handled by the error policy: unless it skips them or writes
+// placeholders, the batch fails with a *BatchError.
+func (bg *BulkGenerator) GenerateBatch(ctx context.Context, batchSize int) ([]interface{}, error) {
+	records, _, err := bg.generateBatch(ctx, batchSize)
+	return records, err
+}
+
+// generateBatch generates a batch of records, also returning the failures the error
+// policy tolerated
+func (bg *BulkGenerator) generateBatch(ctx context.Context, batchSize int) ([]interface{}, []*RecordError, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// CMDB batches are generated as a whole so relationships stay within the batch
+	if bg.TableName == "cmdb" {
+		bg.logf("Generating batch of %d configuration items with relationships...\n", batchSize)
+		records, err := bg.generateCMDBBatch(batchSize)
+		return records, nil, err
+	}
+
+	table, ok := lookupTable(bg.TableName)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported table type: %s", bg.TableName)
+	}
+	rules := bg.Rules.For(bg.TableName, table.RecordType(), bg.ReferenceData)
+
+	workers := bg.workers()
+	if workers > batchSize {
+		workers = batchSize
+	}
+	bg.logf("Generating batch of %d records with %d workers...\n", batchSize, workers)
+
+	// Numbers are reserved up front so they follow the order of the records
+	numbers := bg.Numbering.Reserve(bg.TableName, batchSize)
+
+	// A record failing under a policy that does not tolerate it stops the batch
+	batchCtx, fail := context.WithCancel(ctx)
+	defer fail()
+
+	// A fixed pool of workers takes record indexes from the jobs channel and stores
+	// each record at its index, so records are returned in order
+	records := make([]interface{}, batchSize)
+	done := make([]bool, batchSize) // records generated, skipped or replaced in time
+	var failures []*RecordError
+	var mu sync.Mutex
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each worker reseeds its own source for every record it generates
+			rng := rand.New(rand.NewSource(1))
+			for index := range jobs {
+				rng.Seed(bg.recordSeed(numbers[index]))
+				record, failure := bg.generateRecord(batchCtx, table, rules, rng, index, numbers[index])
+				// Records finished after cancellation may have fallback text in place of
+				// cancelled LLM calls, so they are dropped
+				if batchCtx.Err() != nil {
+					continue
+				}
+				if failure != nil {
+					mu.Lock()
+					failures = append(failures, failure)
+					mu.Unlock()
+					if !bg.ErrorPolicy.tolerates() {
+						fail()
+						continue
+					}
+				}
+				records[index] = record
+				done[index] = true
+			}
+		}()
+	}
+
+dispatch:
+	for i := 0; i < batchSize; i++ {
+		select {
+		case jobs <- i:
+		case <-batchCtx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if len(failures) > 0 && !bg.ErrorPolicy.tolerates() {
+		return nil, nil, newBatchError(failures)
+	}
+
+	// A batch cut short by ctx keeps the records done before the first one that is not,
+	// and gives the numbers of the others back, so a resumed run generates them again
+	if ctx.Err() != nil {
+		kept := 0
+		for kept < batchSize && done[kept] {
+			kept++
+		}
+		bg.Numbering.Release(bg.TableName, batchSize-kept)
+		records = records[:kept]
+		finished := failures[:0]
+		for _, failure := range failures {
+			if failure.Index < kept {
+				finished = append(finished, failure)
+			}
+		}
+		failures = finished
+	}
+
+	// Skipped records are left out
+	completed := records[:0]
+	for _, record := range records {
+		if record != nil {
+			completed = append(completed, record)
+		}
+	}
+	if len(failures) > 0 {
+		failures = newBatchError(failures).Errors
+	}
+	return completed, failures, ctx.Err()
+}
+
+// logf prints a progress message
+func (bg *BulkGenerator) logf(format string, args ...interface{}) {
+	if bg.Log == nil {
+		fmt.Printf(format, args...)
+		return
+	}
+	fmt.Fprintf(bg.Log, format, args...)
+}
+
+// recordSeed returns the seed of the random source of the record with the given number,
+// derived from the seed of the run so the values a record draws from it are repeatable
+// whichever worker generates it
+func (bg *BulkGenerator) recordSeed(number string) int64 {
+	return bg.derivedSeed(number + "/record")
+}
+
+// source returns a random source for the named part of a run, such as a CMDB batch,
+// seeded from the seed of the run when it has one
+func (bg *BulkGenerator) source(name string) *rand.Rand {
+	return rand.New(rand.NewSource(bg.derivedSeed(name)))
+}
+
+// derivedSeed derives a seed for the named part of a run from the seed of the run, or
+// returns a random seed when the run is not seeded
+func (bg *BulkGenerator) derivedSeed(name string) int64 {
+	if bg.Seed == 0 {
+		return rand.Int63()
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%s", bg.Seed, name)
+	return int64(h.Sum64())
+}
+
+// workers returns the number of records generated at once. LLM calls are limited to 10
+// at a time to avoid overwhelming the API; without them generation is CPU-bound.
+func (bg *BulkGenerator) workers() int {
+	switch {
+	case bg.Workers > 0:
+		return bg.Workers
+	case bg.LLMClient != nil && bg.LLMClient.APIKey != "":
+		return 10
+	default:
+		return runtime.NumCPU()
+	}
+}
+
+// generateRecord generates the record at index of a batch with the given number and
+// applies the rules to it, retrying as the error policy allows. A record that still fails is replaced by a
+// placeholder under the placeholder policy, and reported otherwise.
+func (bg *BulkGenerator) generateRecord(ctx context.Context, table TableGenerator, rules *models.RecordRules, rng *rand.Rand, index int, number string) (interface{}, *RecordError) {
+	failure := &RecordError{Table: bg.TableName, Index: index, Number: number}
+	for failure.Attempts < bg.ErrorPolicy.attempts() && ctx.Err() == nil {
+		failure.Attempts++
+		record, err := safeGenerate(ctx, table, rules, rng, Request{Index: index, Number: number, Generator: bg})
+		if err == nil {
+			return record, nil
+		}
+		failure.Err = err
+	}
+	if failure.Err == nil {
+		// Cancelled before the first attempt
+		return nil, nil
+	}
+
+	switch bg.ErrorPolicy.Action {
+	case OnErrorSkip:
+		bg.logf("Skipping record: %v\n", failure)
+	case OnErrorPlaceholder:
+		bg.logf("Writing a placeholder record: %v\n", failure)
+		record, err := placeholderRecord(rng, bg.TableName, failure)
+		if err != nil {
+			failure.Err = err
+			return nil, failure
+		}
+		return record, failure
+	}
+	return nil, failure
+}
+
+// safeGenerate generates a record and applies the rules to it, turning a panic of the
+// generator into an error
+func safeGenerate(ctx context.Context, table TableGenerator, rules *models.RecordRules, rng *rand.Rand, req Request) (record interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	record, err = table.Generate(ctx, rng, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := rules.Apply(record, rng); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Batch is a batch of generated records, or the error that stopped generation
+type Batch struct {
+	Number  int // 1-based number of the batch
+	Total   int // number of batches
+	Records []interface{}
+	Err     error
+
+	// Counters are the numbering counters right after the batch was generated, before
+	// the next batch reserves its numbers
+	Counters map[string]int64
+
+	// Failures are the records that failed, indexed by their position in the run: those
+	// the error policy skipped or replaced by placeholders, or those in Err
+	Failures []*RecordError
+}
+
+// Batches generates count records in batches of batchSize in the background, sending
+// each batch on the returned channel. The next batch is generated while the caller
+// handles the current one. The channel is closed after the last batch or a batch with
+// an error; when ctx is done, that batch holds the records completed until then. Call
+// stop once done reading, so generation ends if the caller gives up early.
+func (bg *BulkGenerator) Batches(ctx context.Context, count, batchSize int) (batches <-chan Batch, stop func()) {
+	if batchSize <= 0 {
+		batchSize = count
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			cancel()
+			close(stopped)
+		})
+	}
+
+	// The services of a CMDB run are sized by its count, not by its first batch
+	if bg.TableName == "cmdb" {
+		bg.cmdbServices(count)
+	}
+
+	out := make(chan Batch)
+	go func() {
+		defer close(out)
+		total := (count + batchSize - 1) / batchSize
+		for number, generated := 1, 0; generated < count; number++ {
+			size := batchSize
+			if count-generated < size {
+				size = count - generated
+			}
+			bg.logf("Generating batch %d/%d (%d records)...\n", number, total, size)
+
+			records, failures, err := bg.generateBatch(ctx, size)
+			var batchErr *BatchError
+			if errors.As(err, &batchErr) {
+				failures = batchErr.Errors
+			}
+			for _, failure := range failures {
+				failure.Index += generated
+			}
+			batch := Batch{Number: number, Total: total, Records: records, Err: err, Counters: bg.Numbering.Counters(), Failures: failures}
+			select {
+			case out <- batch:
+			case <-stopped:
+				return
+			}
+			if err != nil {
+				return
+			}
+			generated += size
+		}
+	}()
+	return out, stop
+}
+
+// generateIncidentRecord generates a single incident record
+func (bg *BulkGenerator) generateIncidentRecord(ctx context.Context, rng *rand.Rand, number string) (*IncidentRecord, error) {
+	// Get random values
+	caller := bg.ReferenceData.GetRandomReference(rng, "sys_user")
+	category := bg.ChoiceValues.GetRandomChoice(rng, "category").(string)
+	subcategory := bg.ChoiceValues.GetRandomSubcategory(rng, category)
+	businessService := bg.ReferenceData.GetRandomReference(rng, "cmdb_ci_service")
+	ci := bg.ReferenceData.GetRandomRelatedCI(rng, businessService.SysID)
+	contactType := bg.ChoiceValues.GetRandomChoice(rng, "contact_type").(string)
+
+	// Generate opened_at in ServiceNow format
+	openedAt := bg.generateRandomOpenedAt(rng)
+
+	// Get state
+	stateObj := bg.ChoiceValues.GetRandomChoice(rng, "state").(*models.ChoiceValue)
+	state := stateObj.Display
+
+	// Get impact and urgency (numeric values)
+	impactObj := bg.ChoiceValues.GetRandomChoice(rng, "impact").(*models.ChoiceValue)
+	impact := impactObj.Value
+
+	urgencyObj := bg.ChoiceValues.GetRandomChoice(rng, "urgency").(*models.ChoiceValue)
+	urgency := urgencyObj.Value
+
+	// Don't set priority - let ServiceNow calculate it
+	priority := ""
+
+	// Route to the group that handles the category and assign one of its members
+	assignmentGroup := bg.ReferenceData.GetGroupForCategory(rng, category)
+	assignedTo, err := bg.ReferenceData.GetRandomUserInGroup(rng, assignmentGroup.SysID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign the record: %w", err)
+	}
+
+	// Generate descriptions using LLM
+	descriptions, err := bg.text().GenerateIncidentDescriptions(ctx, category, subcategory)
+	if err != nil {
+		if err := bg.textFailed(ctx, err); err != nil {
+			return nil, err
+		}
+		// Use fallback descriptions
+		descriptions = &llm.DescriptionResponse{
+			ShortDescription: fmt.Sprintf("%s - %s issue", category, subcategory),
+			Description:      fmt.Sprintf("Incident regarding %s - %s", category, subcategory),
+		}
+	}
+
+	// Generate close notes if the incident is closed or resolved
+	var closeCode, closeNotes string
+	if state == "Resolved" || state == "Closed" {
+		closeCode = bg.ChoiceValues.GetRandomChoice(rng, "close_code").(string)
+
+		notes, err := bg.text().GenerateCloseNotes(ctx, descriptions.ShortDescription, descriptions.Description, closeCode)
+		if err != nil {
+			if err := bg.textFailed(ctx, err); err != nil {
+				return nil, err
+			}
+			closeNotes = fmt.Sprintf("Incident closed with code: %s", closeCode)
+		} else {
+			closeNotes = notes
+		}
+	}
+
+	return &IncidentRecord{
+		SysID:             models.NewSysID(rng),
+		Number:            number,
+		Caller:            *caller,
+		Category:          category,
+		Subcategory:       subcategory,
+		Service:           *businessService,
+		ServiceOffering:   "",
+		ConfigurationItem: *ci,
+		ShortDescription:  descriptions.ShortDescription,
+		Description:       descriptions.Description,
+		Channel:           contactType,
+		Opened:            openedAt,
+		IncidentState:     state,
+		Impact:            impact,
+		Urgency:           urgency,
+		Priority:          priority,
+		AssignmentGroup:   *assignmentGroup,
+		AssignedTo:        *assignedTo,
+		ResolutionCode:    closeCode,
+		ResolutionNotes:   closeNotes,
+	}, nil
+}
+
+// generateCaseRecord generates a single case record
+func (bg *BulkGenerator) generateCaseRecord(ctx context.Context, rng *rand.Rand, number string) (*CaseRecord, error) {
+	// Get random account and contact
+	account := bg.ReferenceData.GetRandomReference(rng, "account")
+
+	// Find contacts for this account
+	var contact *models.ReferenceValue
+	for _, c := range bg.ReferenceData.Contact {
+		if accountID, exists := c.Extra["account"]; exists && accountID == account.SysID {
+			contact = &c
+			break
+		}
+	}
+	if contact == nil {
+		contact = bg.ReferenceData.GetRandomReference(rng, "contact")
+	}
+
+	// Get case details
+	caseType := bg.ChoiceValues.GetRandomChoice(rng, "case_type").(string)
+	category := bg.ChoiceValues.GetRandomChoice(rng, "case_category").(string)
+	subcategory := bg.ChoiceValues.GetRandomCaseSubcategory(rng, category)
+	contactType := bg.ChoiceValues.GetRandomChoice(rng, "contact_type").(string)
+
+	// Determine if case should be closed
+	shouldBeClosed := rng.Float64()*100 < float64(bg.ClosedPercentage)
+
+	var stateObj *models.ChoiceValue
+	if shouldBeClosed {
+		// Get closed states
+		closedStates := []*models.ChoiceValue{}
+		for _, s := range bg.ChoiceValues.CaseState {
+			if s.Display == "Resolved" || s.Display == "Closed" {
+				closedStates = append(closedStates, &s)
+			}
+		}
+		if len(closedStates) > 0 {
+			stateObj = closedStates[rng.Intn(len(closedStates))]
+		} else {
+			stateObj = &bg.ChoiceValues.CaseState[rng.Intn(len(bg.ChoiceValues.CaseState))]
+		}
+	} else {
+		// Get open states
+		openStates := []*models.ChoiceValue{}
+		for _, s := range bg.ChoiceValues.CaseState {
+			if s.Display != "Resolved" && s.Display != "Closed" {
+				openStates = append(openStates, &s)
+			}
+		}
+		if len(openStates) > 0 {
+			stateObj = openStates[rng.Intn(len(openStates))]
+		} else {
+			stateObj = &bg.ChoiceValues.CaseState[rng.Intn(len(bg.ChoiceValues.CaseState))]
+		}
+	}
+
+	// Get assignment data
+	priority := rng.Intn(5) + 1
+	assignmentGroup := bg.ReferenceData.GetGroupForCategory(rng, category)
+	assignedTo, err := bg.ReferenceData.GetRandomUserInGroup(rng, assignmentGroup.SysID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign the record: %w", err)
+	}
+
+	// Generate descriptions using LLM
+	descriptions, err := bg.text().GenerateCaseDescriptions(ctx, category, subcategory, account.DisplayValue, caseType)
+	if err != nil {
+		if err := bg.textFailed(ctx, err); err != nil {
+			return nil, err
+		}
+		// Use fallback descriptions
+		descriptions = &llm.DescriptionResponse{
+			ShortDescription: fmt.Sprintf("%s: %s - %s issue", account.DisplayValue, category, subcategory),
+			Description:      fmt.Sprintf("%s case from %s regarding %s - %s", caseType, account.DisplayValue, category, subcategory),
+		}
+	}
+
+	// Generate additional fields using gofakeit, drawing from the record's source
+	fake := &gofakeit.Faker{Rand: rng}
+	consumer := fake.Name()
+	requestingServiceOrg := fake.Company() + " " + fake.BuzzWord()
+	product := fake.ProductName()
+	asset := strings.ToUpper(fake.LetterN(8))
+	installBase := strings.ToUpper(fake.LetterN(10))
+	partnerContact := fake.Name()
+	parent := ""
+	if rng.Float64() > 0.8 {
+		parent = fmt.Sprintf("CS%07d", rng.Intn(9999999))
+	}
+	needsAttention := "false"
+	if rng.Float64() > 0.7 {
+		needsAttention = "true"
+	}
+	openedAt := bg.generateRandomOpenedAt(rng)
+	serviceOrganization := fake.Company() + " Services"
+	contract := fmt.Sprintf("CNTR%07d", rng.Intn(9999999))
+
+	entitlement := bg.pick(rng, "case_entitlement")
+	partner := fake.Company() + " Partners"
+
+	// Generate close notes if the case is closed or resolved
+	var closeCode, closeNotes string
+	if stateObj.Display == "Resolved" || stateObj.Display == "Closed" {
+		closeCode = bg.ChoiceValues.GetRandomChoice(rng, "case_close_code").(string)
+
+		notes, err := bg.text().GenerateCloseNotes(ctx, descriptions.ShortDescription, descriptions.Description, closeCode)
+		if err != nil {
+			if err := bg.textFailed(ctx, err); err != nil {
+				return nil, err
+			}
+			closeNotes = fmt.Sprintf("Case closed with code: %s. The customer's request was addressed according to standard procedures.", closeCode)
+		} else {
+			closeNotes = notes
+		}
+	}
+
+	record := &CaseRecord{
+		SysID:                         models.NewSysID(rng),
+		Number:                        number,
+		ContactType:                   contactType,
+		Account:                       *account,
+		Contact:                       *contact,
+		Consumer:                      consumer,
+		RequestingServiceOrganization: requestingServiceOrg,
+		Product:                       product,
+		Asset:                         asset,
+		InstallBase:                   installBase,
+		PartnerContact:                partnerContact,
+		Parent:                        parent,
+		ShortDescription:              descriptions.ShortDescription,
+		NeedsAttention:                needsAttention,
+		OpenedAt:                      openedAt,
+		Priority:                      priority,
+		AssignmentGroup:               *assignmentGroup,
+		AssignedTo:                    *assignedTo,
+		ServiceOrganization:           serviceOrganization,
+		Contract:                      contract,
+		Entitlement:                   entitlement,
+		Partner:                       partner,
+		State:                         stateObj.Display,
+		CloseCode:                     closeCode,
+		CloseNotes:                    closeNotes,
+	}
+
+	// Add resolution information for closed cases
+	if shouldBeClosed {
+		resolvedBy := *assignedTo
+		closedBy := *bg.ReferenceData.GetRandomReference(rng, "sys_user")
+
+		// Generate dates for resolved_at and closed_at
+		resolvedAt := bg.Now.AddDate(0, 0, -rng.Intn(7))  // Random time in the last week
+		closedAt := resolvedAt.AddDate(0, 0, rng.Intn(2)) // 0-2 days after resolved
+
+		resolutionCode := bg.pick(rng, "case_resolution_code")
+		cause := bg.pick(rng, "case_cause")
+
+		record.ResolvedBy = resolvedBy
+		record.ResolvedAt = resolvedAt.Format("2006-01-02")
+		record.ClosedBy = closedBy
+		record.ClosedAt = closedAt.Format("2006-01-02")
+		record.ResolutionCode = resolutionCode
+		record.Cause = cause
+		record.NotesToComments = "false"
+		if rng.Float64() > 0.5 {
+			record.NotesToComments = "true"
+		}
+	}
+
+	return record, nil
+}
+
+// generateHRCaseRecord generates a single HR case record
+func (bg *BulkGenerator) generateHRCaseRecord(ctx context.Context, rng *rand.Rand, number string) (*HRCaseRecord, error) {
+	// Get random users
+	openedFor := bg.ReferenceData.GetRandomReference(rng, "sys_user")
+	subjectPerson := bg.ReferenceData.GetRandomReference(rng, "sys_user")
+	openedBy := bg.ReferenceData.GetRandomReference(rng, "sys_user")
+
+	// HR service type and category
+	hrServiceType := bg.pick(rng, "hr_service_type")
+	category := bg.pick(rng, "hr_category")
+	assignmentGroup := bg.ReferenceData.GetGroupForCategory(rng, category)
+	assignedTo, err := bg.ReferenceData.GetRandomUserInGroup(rng, assignmentGroup.SysID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign the record: %w", err)
+	}
+
+	// Generate descriptions using LLM
+	descriptions, err := bg.text().GenerateIncidentDescriptions(ctx, category, hrServiceType)
+	if err != nil {
+		if err := bg.textFailed(ctx, err); err != nil {
+			return nil, err
+		}
+		descriptions = &llm.DescriptionResponse{
+			ShortDescription: fmt.Sprintf("HR %s - %s request", category, hrServiceType),
+			Description:      fmt.Sprintf("HR case regarding %s - %s for employee assistance", category, hrServiceType),
+		}
+	}
+
+	// Generate dates
+	openedAt := bg.generateRandomOpenedAt(rng)
+	dueDate := bg.Now.AddDate(0, 0, rng.Intn(14)+1) // 1-14 days from now
+
+	// Determine state and priority
+	priority := rng.Intn(4) + 1
+	state := bg.pick(rng, "hr_state")
+
+	record := &HRCaseRecord{
+		SysID:            models.NewSysID(rng),
+		Number:           number,
+		ShortDescription: descriptions.ShortDescription,
+		Description:      descriptions.Description,
+		OpenedFor:        *openedFor,
+		HRService:        "HR Services",
+		SubjectPerson:    *subjectPerson,
+		AssignmentGroup:  *assignmentGroup,
+		HRServiceType:    hrServiceType,
+		DueDate:          dueDate.Format("2006-01-02"),
+		OpenedBy:         *openedBy,
+		State:            state,
+		Priority:         priority,
+		OpenedAt:         openedAt,
+		AssignedTo:       *assignedTo,
+	}
+
+	// Add resolution info for closed cases
+	if state == "Resolved" || state == "Closed" {
+		resolvedBy := *assignedTo
+		closedBy := *bg.ReferenceData.GetRandomReference(rng, "sys_user")
+
+		resolvedAt := bg.Now.AddDate(0, 0, -rng.Intn(7))
+		closedAt := resolvedAt.AddDate(0, 0, rng.Intn(2))
+
+		closeCode := bg.pick(rng, "hr_close_code")
+
+		closeNotes, err := bg.text().GenerateCloseNotes(ctx, descriptions.ShortDescription, descriptions.Description, closeCode)
+		if err != nil {
+			if err := bg.textFailed(ctx, err); err != nil {
+				return nil, err
+			}
+			closeNotes = fmt.Sprintf("HR case resolved with code: %s", closeCode)
+		}
+
+		record.ResolvedBy = resolvedBy
+		record.ResolvedAt = resolvedAt.Format("2006-01-02")
+		record.ClosedBy = closedBy
+		record.ClosedAt = closedAt.Format("2006-01-02")
+		record.CloseCode = closeCode
+		record.CloseNotes = closeNotes
+	}
+
+	return record, nil
+}
+
+// generateChangeRequestRecord generates a single change request record
+func (bg *BulkGenerator) generateChangeRequestRecord(ctx context.Context, rng *rand.Rand, number string) (*ChangeRequestRecord, error) {
+	// Get random values
+	requestedBy := bg.ReferenceData.GetRandomReference(rng, "sys_user")
+	businessService := bg.ReferenceData.GetRandomReference(rng, "cmdb_ci_service")
+	ci := bg.ReferenceData.GetRandomRelatedCI(rng, businessService.SysID)
+
+	// Change category
+	category := bg.pick(rng, "change_category")
+	assignmentGroup := bg.ReferenceData.GetGroupForCategory(rng, category)
+	assignedTo, err := bg.ReferenceData.GetRandomUserInGroup(rng, assignmentGroup.SysID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign the record: %w", err)
+	}
+
+	// Risk level
+	risk := bg.pick(rng, "change_risk")
+
+	// Generate priority and impact
+	priority := rng.Intn(4) + 1
+	impact := rng.Intn(4) + 1
+
+	// Generate descriptions using LLM
+	descriptions, err := bg.text().GenerateIncidentDescriptions(ctx, category, "Change Request")
+	if err != nil {
+		if err := bg.textFailed(ctx, err); err != nil {
+			return nil, err
+		}
+		descriptions = &llm.DescriptionResponse{
+			ShortDescription: fmt.Sprintf("%s change request for %s", category, ci.DisplayValue),
+			Description:      fmt.Sprintf("Change request to modify %s configuration for %s", category, businessService.DisplayValue),
+		}
+	}
+
+	// Generate detailed plans using LLM
+	justificationPrompt := bg.prompt("change_justification", "category", category, "service", businessService.DisplayValue)
+	justification, err := bg.text().GenerateText(ctx, justificationPrompt, 500)
+	if err != nil {
+		if err := bg.textFailed(ctx, err); err != nil {
+			return nil, err
+		}
+		justification = fmt.Sprintf("Business justification for %s change to improve system performance and reliability.", category)
+	}
+
+	implementationPrompt := bg.prompt("change_implementation_plan", "category", category, "service", businessService.DisplayValue)
+	implementationPlan, err := bg.text().GenerateText(ctx, implementationPrompt, 800)
+	if err != nil {
+		if err := bg.textFailed(ctx, err); err != nil {
+			return nil, err
+		}
+		implementationPlan = fmt.Sprintf("Implementation plan for %s change with step-by-step procedures.", category)
+	}
+
+	riskPrompt := bg.prompt("change_risk_analysis", "category", category, "service", businessService.DisplayValue)
+	riskAnalysis, err := bg.text().GenerateText(ctx, riskPrompt, 600)
+	if err != nil {
+		if err := bg.textFailed(ctx, err); err != nil {
+			return nil, err
+		}
+		riskAnalysis = fmt.Sprintf("Risk analysis for %s change with identified mitigation strategies.", category)
+	}
+
+	backoutPrompt := bg.prompt("change_backout_plan", "category", category, "service", businessService.DisplayValue)
+	backoutPlan, err := bg.text().GenerateText(ctx, backoutPrompt, 500)
+	if err != nil {
+		if err := bg.textFailed(ctx, err); err != nil {
+			return nil, err
+		}
+		backoutPlan = fmt.Sprintf("Backout plan for %s change with rollback procedures.", category)
+	}
+
+	testPrompt := bg.prompt("change_test_plan", "category", category, "service", businessService.DisplayValue)
+	testPlan, err := bg.text().GenerateText(ctx, testPrompt, 600)
+	if err != nil {
+		if err := bg.textFailed(ctx, err); err != nil {
+			return nil, err
+		}
+		testPlan = fmt.Sprintf("Test plan for %s change with validation procedures.", category)
+	}
+
+	// Generate dates
+	openedAt := bg.generateRandomOpenedAt(rng)
+	startDate := bg.Now.AddDate(0, 0, rng.Intn(30)+1) // 1-30 days from now
+	endDate := startDate.AddDate(0, 0, rng.Intn(7)+1) // 1-7 days after start
+
+	// Change state
+	state := bg.pick(rng, "change_state")
+
+	record := &ChangeRequestRecord{
+		SysID:              models.NewSysID(rng),
+		Number:             number,
+		ShortDescription:   descriptions.ShortDescription,
+		Description:        descriptions.Description,
+		RequestedBy:        *requestedBy,
+		Category:           category,
+		BusinessService:    *businessService,
+		ConfigurationItem:  *ci,
+		Priority:           priority,
+		Risk:               risk,
+		Impact:             impact,
+		AssignmentGroup:    *assignmentGroup,
+		AssignedTo:         *assignedTo,
+		Justification:      justification,
+		ImplementationPlan: implementationPlan,
+		RiskImpactAnalysis: riskAnalysis,
+		BackoutPlan:        backoutPlan,
+		TestPlan:           testPlan,
+		StartDate:          startDate.Format("2006-01-02"),
+		EndDate:            endDate.Format("2006-01-02"),
+		State:              state,
+		OpenedAt:           openedAt,
+		OpenedBy:           *requestedBy,
+	}
+
+	// Add close info for closed changes
+	if state == "Closed" {
+		closeCode := bg.pick(rng, "change_close_code")
+
+		closeNotes, err := bg.text().GenerateCloseNotes(ctx, descriptions.ShortDescription, descriptions.Description, closeCode)
+		if err != nil {
+			if err := bg.textFailed(ctx, err); err != nil {
+				return nil, err
+			}
+			closeNotes = fmt.Sprintf("Change request completed with status: %s", closeCode)
+		}
+
+		record.CloseCode = closeCode
+		record.CloseNotes = closeNotes
+	}
+
+	return record, nil
+}
+
+// generateKnowledgeArticleRecord generates a single knowledge article record
+func (bg *BulkGenerator) generateKnowledgeArticleRecord(ctx context.Context, rng *rand.Rand, number string) (*KnowledgeArticleRecord, error) {
+	// Knowledge category
+	category := bg.pick(rng, "knowledge_category")
+
+	// Generate article content using LLM
+	titlePrompt := bg.prompt("knowledge_title", "category", category)
+	title, err := bg.text().GenerateText(ctx, titlePrompt, 100)
+	if err != nil {
+		if err := bg.textFailed(ctx, err); err != nil {
+			return nil, err
+		}
+		title = fmt.Sprintf("How to resolve %s issues", category)
+	}
+
+	contentPrompt := bg.prompt("knowledge_content", "category", category)
+	content, err := bg.text().GenerateText(ctx, contentPrompt, 2000)
+	if err != nil {
+		if err := bg.textFailed(ctx, err); err != nil {
+			return nil, err
+		}
+		content = fmt.Sprintf(`<h2>Problem Description</h2>
+<p>This article covers common %s issues and their resolutions.</p>
+<h2>Symptoms</h2>
+<ul><li>Common symptoms related to %s</li></ul>
+<h2>Resolution Steps</h2>
+<ol><li>Step 1: Identify the issue</li><li>Step 2: Apply the solution</li><li>Step 3: Verify the fix</li></ol>`, category, category)
+	}
+
+	// Generate keywords
+	keywordsPrompt := bg.prompt("knowledge_keywords", "category", category)
+	keywords, err := bg.text().GenerateText(ctx, keywordsPrompt, 100)
+	if err != nil {
+		if err := bg.textFailed(ctx, err); err != nil {
+			return nil, err
+		}
+		keywords = fmt.Sprintf("%s, troubleshooting, resolution, guide", strings.ToLower(category))
+	}
+
+	// Get author
+	author := bg.ReferenceData.GetRandomReference(rng, "sys_user")
+
+	// Generate dates
+	createdOn := bg.generateRandomOpenedAt(rng)
+	updatedOn := bg.Now.Format("2006-01-02 15:04:05")
+	published := bg.Now.Format("2006-01-02 15:04:05")
+	validTo := bg.Now.AddDate(2, 0, 0).Format("2006-01-02") // Valid for 2 years
+
+	return &KnowledgeArticleRecord{
+		SysID:            models.NewSysID(rng),
+		Number:           number,
+		ShortDescription: title,
+		Text:             content,
+		KnowledgeBase:    "IT Knowledge Base",
+		Category:         category,
+		ValidTo:          validTo,
+		WorkflowState:    "published",
+		Published:        published,
+		Author:           *author,
+		Active:           "true",
+		Meta:             keywords,
+		CreatedOn:        createdOn,
+		UpdatedOn:        updatedOn,
+	}, nil
+}
+
+// generateRandomOpenedAt generates a random opened_at date/time in ServiceNow format
+func (bg *BulkGenerator) generateRandomOpenedAt(rng *rand.Rand) string {
+	oneYearAgo := bg.Now.AddDate(-1, 0, 0)
+
+	// Generate random timestamp between one year ago and now
+	randomTime := oneYearAgo.Unix() + rng.Int63n(bg.Now.Unix()-oneYearAgo.Unix())
+	randomDate := time.Unix(randomTime, 0).In(bg.Now.Location())
+
+	// Format as YYYY-MM-DD HH:MM:SS for ServiceNow
+	return randomDate.Format("2006-01-02 15:04:05")
+}
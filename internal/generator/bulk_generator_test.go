@@ -1,446 +1,880 @@
-package generator
-
-import (
-	"strings"
-	"testing"
-)
-
-func TestNewBulkGenerator(t *testing.T) {
-	config := Config{
-		RecordCount:      100,
-		BatchSize:        10,
-		TableName:        "incident",
-		ClosedPercentage: 30,
-		SplitOutput:      false,
-		APIKey:           "test-key",
-		Model:            "test-model",
-	}
-
-	bg := NewBulkGenerator(config)
-
-	if bg.RecordCount != 100 {
-		t.Errorf("Expected RecordCount 100, got %d", bg.RecordCount)
-	}
-	if bg.BatchSize != 10 {
-		t.Errorf("Expected BatchSize 10, got %d", bg.BatchSize)
-	}
-	if bg.TableName != "incident" {
-		t.Errorf("Expected TableName 'incident', got %s", bg.TableName)
-	}
-	if bg.ClosedPercentage != 30 {
-		t.Errorf("Expected ClosedPercentage 30, got %d", bg.ClosedPercentage)
-	}
-	if bg.LLMClient == nil {
-		t.Error("Expected LLMClient to be initialized")
-	}
-	if bg.ReferenceData == nil {
-		t.Error("Expected ReferenceData to be initialized")
-	}
-	if bg.ChoiceValues == nil {
-		t.Error("Expected ChoiceValues to be initialized")
-	}
-}
-
-func TestGenerateIncidentRecord(t *testing.T) {
-	bg := createTestBulkGenerator("incident")
-
-	record, err := bg.generateIncidentRecord(1)
-	if err != nil {
-		t.Fatalf("Failed to generate incident record: %v", err)
-	}
-
-	// Validate required fields
-	if record.Caller == "" {
-		t.Error("Caller should not be empty")
-	}
-	if record.Category == "" {
-		t.Error("Category should not be empty")
-	}
-	if record.ShortDescription == "" {
-		t.Error("ShortDescription should not be empty")
-	}
-	if record.Description == "" {
-		t.Error("Description should not be empty")
-	}
-	if record.Opened == "" {
-		t.Error("Opened should not be empty")
-	}
-	if record.IncidentState == "" {
-		t.Error("IncidentState should not be empty")
-	}
-	if record.Impact < 1 || record.Impact > 4 {
-		t.Errorf("Impact should be between 1-4, got %d", record.Impact)
-	}
-	if record.Urgency < 1 || record.Urgency > 4 {
-		t.Errorf("Urgency should be between 1-4, got %d", record.Urgency)
-	}
-
-	// Validate date format (YYYY-MM-DD HH:MM:SS)
-	if !isValidDateFormat(record.Opened) {
-		t.Errorf("Invalid date format for Opened: %s", record.Opened)
-	}
-}
-
-func TestGenerateCaseRecord(t *testing.T) {
-	bg := createTestBulkGenerator("case")
-
-	record, err := bg.generateCaseRecord(1)
-	if err != nil {
-		t.Fatalf("Failed to generate case record: %v", err)
-	}
-
-	// Validate required fields
-	if record.Number == "" {
-		t.Error("Number should not be empty")
-	}
-	if !strings.HasPrefix(record.Number, "CS") {
-		t.Errorf("Case number should start with 'CS', got %s", record.Number)
-	}
-	if record.Account == "" {
-		t.Error("Account should not be empty")
-	}
-	if record.Contact == "" {
-		t.Error("Contact should not be empty")
-	}
-	if record.ShortDescription == "" {
-		t.Error("ShortDescription should not be empty")
-	}
-	if record.OpenedAt == "" {
-		t.Error("OpenedAt should not be empty")
-	}
-	if record.Priority < 1 || record.Priority > 5 {
-		t.Errorf("Priority should be between 1-5, got %d", record.Priority)
-	}
-	if record.State == "" {
-		t.Error("State should not be empty")
-	}
-
-	// Validate date format
-	if !isValidDateFormat(record.OpenedAt) {
-		t.Errorf("Invalid date format for OpenedAt: %s", record.OpenedAt)
-	}
-}
-
-func TestGenerateHRCaseRecord(t *testing.T) {
-	bg := createTestBulkGenerator("hr_case")
-
-	record, err := bg.generateHRCaseRecord(1)
-	if err != nil {
-		t.Fatalf("Failed to generate HR case record: %v", err)
-	}
-
-	// Validate required fields
-	if record.Number == "" {
-		t.Error("Number should not be empty")
-	}
-	if !strings.HasPrefix(record.Number, "HRC") {
-		t.Errorf("HR case number should start with 'HRC', got %s", record.Number)
-	}
-	if record.ShortDescription == "" {
-		t.Error("ShortDescription should not be empty")
-	}
-	if record.Description == "" {
-		t.Error("Description should not be empty")
-	}
-	if record.OpenedFor == "" {
-		t.Error("OpenedFor should not be empty")
-	}
-	if record.HRService == "" {
-		t.Error("HRService should not be empty")
-	}
-	if record.HRServiceType == "" {
-		t.Error("HRServiceType should not be empty")
-	}
-	if record.DueDate == "" {
-		t.Error("DueDate should not be empty")
-	}
-	if record.Priority < 1 || record.Priority > 4 {
-		t.Errorf("Priority should be between 1-4, got %d", record.Priority)
-	}
-
-	// Validate HR service types
-	validServiceTypes := []string{
-		"employee_relations", "benefits", "payroll", "recruitment",
-		"performance_management", "training", "compliance", "onboarding",
-	}
-	if !contains(validServiceTypes, record.HRServiceType) {
-		t.Errorf("Invalid HR service type: %s", record.HRServiceType)
-	}
-}
-
-func TestGenerateChangeRequestRecord(t *testing.T) {
-	bg := createTestBulkGenerator("change_request")
-
-	record, err := bg.generateChangeRequestRecord(1)
-	if err != nil {
-		t.Fatalf("Failed to generate change request record: %v", err)
-	}
-
-	// Validate required fields
-	if record.Number == "" {
-		t.Error("Number should not be empty")
-	}
-	if !strings.HasPrefix(record.Number, "CHG") {
-		t.Errorf("Change request number should start with 'CHG', got %s", record.Number)
-	}
-	if record.ShortDescription == "" {
-		t.Error("ShortDescription should not be empty")
-	}
-	if record.Description == "" {
-		t.Error("Description should not be empty")
-	}
-	if record.RequestedBy == "" {
-		t.Error("RequestedBy should not be empty")
-	}
-	if record.Category == "" {
-		t.Error("Category should not be empty")
-	}
-	if record.BusinessService == "" {
-		t.Error("BusinessService should not be empty")
-	}
-	if record.ConfigurationItem == "" {
-		t.Error("ConfigurationItem should not be empty")
-	}
-	if record.Priority < 1 || record.Priority > 4 {
-		t.Errorf("Priority should be between 1-4, got %d", record.Priority)
-	}
-	if record.Impact < 1 || record.Impact > 4 {
-		t.Errorf("Impact should be between 1-4, got %d", record.Impact)
-	}
-	if record.Risk == "" {
-		t.Error("Risk should not be empty")
-	}
-	if record.Justification == "" {
-		t.Error("Justification should not be empty")
-	}
-	if record.ImplementationPlan == "" {
-		t.Error("ImplementationPlan should not be empty")
-	}
-	if record.RiskImpactAnalysis == "" {
-		t.Error("RiskImpactAnalysis should not be empty")
-	}
-	if record.BackoutPlan == "" {
-		t.Error("BackoutPlan should not be empty")
-	}
-	if record.TestPlan == "" {
-		t.Error("TestPlan should not be empty")
-	}
-	if record.StartDate == "" {
-		t.Error("StartDate should not be empty")
-	}
-	if record.EndDate == "" {
-		t.Error("EndDate should not be empty")
-	}
-
-	// Validate risk levels
-	validRisks := []string{"Low", "Medium", "High", "Very High"}
-	if !contains(validRisks, record.Risk) {
-		t.Errorf("Invalid risk level: %s", record.Risk)
-	}
-
-	// Validate categories
-	validCategories := []string{
-		"Software", "Hardware", "Network", "Security", "Database",
-		"Application", "Infrastructure", "Emergency", "Standard", "Normal",
-	}
-	if !contains(validCategories, record.Category) {
-		t.Errorf("Invalid category: %s", record.Category)
-	}
-}
-
-func TestGenerateKnowledgeArticleRecord(t *testing.T) {
-	bg := createTestBulkGenerator("knowledge_article")
-
-	record, err := bg.generateKnowledgeArticleRecord(1)
-	if err != nil {
-		t.Fatalf("Failed to generate knowledge article record: %v", err)
-	}
-
-	// Validate required fields
-	if record.Number == "" {
-		t.Error("Number should not be empty")
-	}
-	if !strings.HasPrefix(record.Number, "KB") {
-		t.Errorf("Knowledge article number should start with 'KB', got %s", record.Number)
-	}
-	if record.ShortDescription == "" {
-		t.Error("ShortDescription should not be empty")
-	}
-	if record.Text == "" {
-		t.Error("Text should not be empty")
-	}
-	if record.KnowledgeBase == "" {
-		t.Error("KnowledgeBase should not be empty")
-	}
-	if record.Category == "" {
-		t.Error("Category should not be empty")
-	}
-	if record.ValidTo == "" {
-		t.Error("ValidTo should not be empty")
-	}
-	if record.WorkflowState != "published" {
-		t.Errorf("WorkflowState should be 'published', got %s", record.WorkflowState)
-	}
-	if record.Published == "" {
-		t.Error("Published should not be empty")
-	}
-	if record.Author == "" {
-		t.Error("Author should not be empty")
-	}
-	if record.Active != "true" {
-		t.Errorf("Active should be 'true', got %s", record.Active)
-	}
-	if record.CreatedOn == "" {
-		t.Error("CreatedOn should not be empty")
-	}
-	if record.UpdatedOn == "" {
-		t.Error("UpdatedOn should not be empty")
-	}
-
-	// Validate categories
-	validCategories := []string{
-		"IT Services", "Hardware", "Software", "Network", "Security",
-		"Troubleshooting", "How-To", "FAQ", "Best Practices", "Procedures",
-	}
-	if !contains(validCategories, record.Category) {
-		t.Errorf("Invalid category: %s", record.Category)
-	}
-
-	// Validate HTML content (fallback may not have HTML)
-	if record.Text == "" {
-		t.Error("Text should not be empty")
-	}
-}
-
-func TestGenerateBatch(t *testing.T) {
-	bg := createTestBulkGenerator("incident")
-
-	batchSize := 5
-	records, err := bg.GenerateBatch(batchSize)
-	if err != nil {
-		t.Fatalf("Failed to generate batch: %v", err)
-	}
-
-	if len(records) != batchSize {
-		t.Errorf("Expected %d records, got %d", batchSize, len(records))
-	}
-
-	// Validate all records are of correct type
-	for i, record := range records {
-		if _, ok := record.(*IncidentRecord); !ok {
-			t.Errorf("Record %d is not an IncidentRecord", i)
-		}
-	}
-}
-
-func TestGenerateBatchUnsupportedTable(t *testing.T) {
-	bg := createTestBulkGenerator("unsupported_table")
-
-	records, err := bg.GenerateBatch(1)
-
-	// Should return records with error records, not a direct error
-	if err != nil {
-		t.Fatalf("Unexpected error: %v", err)
-	}
-
-	if len(records) != 1 {
-		t.Errorf("Expected 1 record, got %d", len(records))
-	}
-
-	// The record should be an error record (CaseRecord with error info)
-	if record, ok := records[0].(*CaseRecord); ok {
-		if !strings.Contains(record.Number, "ERROR") {
-			t.Errorf("Expected error record number to contain 'ERROR', got %s", record.Number)
-		}
-	} else {
-		t.Error("Expected error record to be a CaseRecord")
-	}
-}
-
-func TestGenerateRandomOpenedAt(t *testing.T) {
-	bg := createTestBulkGenerator("incident")
-
-	openedAt := bg.generateRandomOpenedAt()
-
-	if openedAt == "" {
-		t.Error("OpenedAt should not be empty")
-	}
-
-	if !isValidDateFormat(openedAt) {
-		t.Errorf("Invalid date format: %s", openedAt)
-	}
-}
-
-// Helper functions
-
-func createTestBulkGenerator(tableName string) *BulkGenerator {
-	config := Config{
-		RecordCount:      10,
-		BatchSize:        5,
-		TableName:        tableName,
-		ClosedPercentage: 30,
-		SplitOutput:      false,
-		APIKey:           "", // Empty API key for testing (will use fallback)
-		Model:            "test-model",
-	}
-
-	return NewBulkGenerator(config)
-}
-
-func isValidDateFormat(dateStr string) bool {
-	// Check if date matches YYYY-MM-DD HH:MM:SS format
-	if len(dateStr) != 19 {
-		return false
-	}
-	if dateStr[4] != '-' || dateStr[7] != '-' || dateStr[10] != ' ' || dateStr[13] != ':' || dateStr[16] != ':' {
-		return false
-	}
-	return true
-}
-
-func contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
-		}
-	}
-	return false
-}
-
-// Benchmark tests
-
-func BenchmarkGenerateIncidentRecord(b *testing.B) {
-	bg := createTestBulkGenerator("incident")
-
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_, err := bg.generateIncidentRecord(i)
-		if err != nil {
-			b.Fatalf("Failed to generate incident record: %v", err)
-		}
-	}
-}
-
-func BenchmarkGenerateCaseRecord(b *testing.B) {
-	bg := createTestBulkGenerator("case")
-
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_, err := bg.generateCaseRecord(i)
-		if err != nil {
-			b.Fatalf("Failed to generate case record: %v", err)
-		}
-	}
-}
-
-func BenchmarkGenerateBatch(b *testing.B) {
-	bg := createTestBulkGenerator("incident")
-
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_, err := bg.GenerateBatch(10)
-		if err != nil {
-			b.Fatalf("Failed to generate batch: %v", err)
-		}
-	}
-}
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+// newRand returns a random source for a record, seeded from the shared source
+func newRand() *rand.Rand {
+	return rand.New(rand.NewSource(rand.Int63()))
+}
+
+func TestNewBulkGenerator(t *testing.T) {
+	config := Config{
+		RecordCount:      100,
+		BatchSize:        10,
+		TableName:        "incident",
+		ClosedPercentage: 30,
+		SplitOutput:      false,
+		APIKey:           "test-key",
+		Model:            "test-model",
+	}
+
+	bg := NewBulkGenerator(config)
+
+	if bg.RecordCount != 100 {
+		t.Errorf("Expected RecordCount 100, got %d", bg.RecordCount)
+	}
+	if bg.BatchSize != 10 {
+		t.Errorf("Expected BatchSize 10, got %d", bg.BatchSize)
+	}
+	if bg.TableName != "incident" {
+		t.Errorf("Expected TableName 'incident', got %s", bg.TableName)
+	}
+	if bg.ClosedPercentage != 30 {
+		t.Errorf("Expected ClosedPercentage 30, got %d", bg.ClosedPercentage)
+	}
+	if bg.LLMClient == nil {
+		t.Error("Expected LLMClient to be initialized")
+	}
+	if bg.ReferenceData == nil {
+		t.Error("Expected ReferenceData to be initialized")
+	}
+	if bg.ChoiceValues == nil {
+		t.Error("Expected ChoiceValues to be initialized")
+	}
+}
+
+func TestGenerateIncidentRecord(t *testing.T) {
+	bg := createTestBulkGenerator("incident")
+
+	record, err := bg.generateIncidentRecord(context.Background(), newRand(), bg.Numbering.Next("incident"))
+	if err != nil {
+		t.Fatalf("Failed to generate incident record: %v", err)
+	}
+
+	// Validate required fields
+	if record.Caller.DisplayValue == "" {
+		t.Error("Caller should not be empty")
+	}
+	if record.Category == "" {
+		t.Error("Category should not be empty")
+	}
+	if record.ShortDescription == "" {
+		t.Error("ShortDescription should not be empty")
+	}
+	if record.Description == "" {
+		t.Error("Description should not be empty")
+	}
+	if record.Opened == "" {
+		t.Error("Opened should not be empty")
+	}
+	if record.IncidentState == "" {
+		t.Error("IncidentState should not be empty")
+	}
+	if record.Impact < 1 || record.Impact > 4 {
+		t.Errorf("Impact should be between 1-4, got %d", record.Impact)
+	}
+	if record.Urgency < 1 || record.Urgency > 4 {
+		t.Errorf("Urgency should be between 1-4, got %d", record.Urgency)
+	}
+
+	// Validate date format (YYYY-MM-DD HH:MM:SS)
+	if !isValidDateFormat(record.Opened) {
+		t.Errorf("Invalid date format for Opened: %s", record.Opened)
+	}
+}
+
+func TestGenerateCaseRecord(t *testing.T) {
+	bg := createTestBulkGenerator("case")
+
+	record, err := bg.generateCaseRecord(context.Background(), newRand(), bg.Numbering.Next("case"))
+	if err != nil {
+		t.Fatalf("Failed to generate case record: %v", err)
+	}
+
+	// Validate required fields
+	if record.Number == "" {
+		t.Error("Number should not be empty")
+	}
+	if !strings.HasPrefix(record.Number, "CS") {
+		t.Errorf("Case number should start with 'CS', got %s", record.Number)
+	}
+	if record.Account.DisplayValue == "" {
+		t.Error("Account should not be empty")
+	}
+	if record.Contact.DisplayValue == "" {
+		t.Error("Contact should not be empty")
+	}
+	if record.ShortDescription == "" {
+		t.Error("ShortDescription should not be empty")
+	}
+	if record.OpenedAt == "" {
+		t.Error("OpenedAt should not be empty")
+	}
+	if record.Priority < 1 || record.Priority > 5 {
+		t.Errorf("Priority should be between 1-5, got %d", record.Priority)
+	}
+	if record.State == "" {
+		t.Error("State should not be empty")
+	}
+
+	// Validate date format
+	if !isValidDateFormat(record.OpenedAt) {
+		t.Errorf("Invalid date format for OpenedAt: %s", record.OpenedAt)
+	}
+}
+
+func TestGenerateHRCaseRecord(t *testing.T) {
+	bg := createTestBulkGenerator("hr_case")
+
+	record, err := bg.generateHRCaseRecord(context.Background(), newRand(), bg.Numbering.Next("hr_case"))
+	if err != nil {
+		t.Fatalf("Failed to generate HR case record: %v", err)
+	}
+
+	// Validate required fields
+	if record.Number == "" {
+		t.Error("Number should not be empty")
+	}
+	if !strings.HasPrefix(record.Number, "HRC") {
+		t.Errorf("HR case number should start with 'HRC', got %s", record.Number)
+	}
+	if record.ShortDescription == "" {
+		t.Error("ShortDescription should not be empty")
+	}
+	if record.Description == "" {
+		t.Error("Description should not be empty")
+	}
+	if record.OpenedFor.DisplayValue == "" {
+		t.Error("OpenedFor should not be empty")
+	}
+	if record.HRService == "" {
+		t.Error("HRService should not be empty")
+	}
+	if record.HRServiceType == "" {
+		t.Error("HRServiceType should not be empty")
+	}
+	if record.DueDate == "" {
+		t.Error("DueDate should not be empty")
+	}
+	if record.Priority < 1 || record.Priority > 4 {
+		t.Errorf("Priority should be between 1-4, got %d", record.Priority)
+	}
+
+	// Validate HR service types
+	validServiceTypes := []string{
+		"employee_relations", "benefits", "payroll", "recruitment",
+		"performance_management", "training", "compliance", "onboarding",
+	}
+	if !contains(validServiceTypes, record.HRServiceType) {
+		t.Errorf("Invalid HR service type: %s", record.HRServiceType)
+	}
+}
+
+func TestGenerateChangeRequestRecord(t *testing.T) {
+	bg := createTestBulkGenerator("change_request")
+
+	record, err := bg.generateChangeRequestRecord(context.Background(), newRand(), bg.Numbering.Next("change_request"))
+	if err != nil {
+		t.Fatalf("Failed to generate change request record: %v", err)
+	}
+
+	// Validate required fields
+	if record.Number == "" {
+		t.Error("Number should not be empty")
+	}
+	if !strings.HasPrefix(record.Number, "CHG") {
+		t.Errorf("Change request number should start with 'CHG', got %s", record.Number)
+	}
+	if record.ShortDescription == "" {
+		t.Error("ShortDescription should not be empty")
+	}
+	if record.Description == "" {
+		t.Error("Description should not be empty")
+	}
+	if record.RequestedBy.DisplayValue == "" {
+		t.Error("RequestedBy should not be empty")
+	}
+	if record.Category == "" {
+		t.Error("Category should not be empty")
+	}
+	if record.BusinessService.DisplayValue == "" {
+		t.Error("BusinessService should not be empty")
+	}
+	if record.ConfigurationItem.DisplayValue == "" {
+		t.Error("ConfigurationItem should not be empty")
+	}
+	if record.Priority < 1 || record.Priority > 4 {
+		t.Errorf("Priority should be between 1-4, got %d", record.Priority)
+	}
+	if record.Impact < 1 || record.Impact > 4 {
+		t.Errorf("Impact should be between 1-4, got %d", record.Impact)
+	}
+	if record.Risk == "" {
+		t.Error("Risk should not be empty")
+	}
+	if record.Justification == "" {
+		t.Error("Justification should not be empty")
+	}
+	if record.ImplementationPlan == "" {
+		t.Error("ImplementationPlan should not be empty")
+	}
+	if record.RiskImpactAnalysis == "" {
+		t.Error("RiskImpactAnalysis should not be empty")
+	}
+	if record.BackoutPlan == "" {
+		t.Error("BackoutPlan should not be empty")
+	}
+	if record.TestPlan == "" {
+		t.Error("TestPlan should not be empty")
+	}
+	if record.StartDate == "" {
+		t.Error("StartDate should not be empty")
+	}
+	if record.EndDate == "" {
+		t.Error("EndDate should not be empty")
+	}
+
+	// Validate risk levels
+	validRisks := []string{"Low", "Medium", "High", "Very High"}
+	if !contains(validRisks, record.Risk) {
+		t.Errorf("Invalid risk level: %s", record.Risk)
+	}
+
+	// Validate categories
+	validCategories := []string{
+		"Software", "Hardware", "Network", "Security", "Database",
+		"Application", "Infrastructure", "Emergency", "Standard", "Normal",
+	}
+	if !contains(validCategories, record.Category) {
+		t.Errorf("Invalid category: %s", record.Category)
+	}
+}
+
+func TestGenerateKnowledgeArticleRecord(t *testing.T) {
+	bg := createTestBulkGenerator("knowledge_article")
+
+	record, err := bg.generateKnowledgeArticleRecord(context.Background(), newRand(), bg.Numbering.Next("knowledge_article"))
+	if err != nil {
+		t.Fatalf("Failed to generate knowledge article record: %v", err)
+	}
+
+	// Validate required fields
+	if record.Number == "" {
+		t.Error("Number should not be empty")
+	}
+	if !strings.HasPrefix(record.Number, "KB") {
+		t.Errorf("Knowledge article number should start with 'KB', got %s", record.Number)
+	}
+	if record.ShortDescription == "" {
+		t.Error("ShortDescription should not be empty")
+	}
+	if record.Text == "" {
+		t.Error("Text should not be empty")
+	}
+	if record.KnowledgeBase == "" {
+		t.Error("KnowledgeBase should not be empty")
+	}
+	if record.Category == "" {
+		t.Error("Category should not be empty")
+	}
+	if record.ValidTo == "" {
+		t.Error("ValidTo should not be empty")
+	}
+	if record.WorkflowState != "published" {
+		t.Errorf("WorkflowState should be 'published', got %s", record.WorkflowState)
+	}
+	if record.Published == "" {
+		t.Error("Published should not be empty")
+	}
+	if record.Author.DisplayValue == "" {
+		t.Error("Author should not be empty")
+	}
+	if record.Active != "true" {
+		t.Errorf("Active should be 'true', got %s", record.Active)
+	}
+	if record.CreatedOn == "" {
+		t.Error("CreatedOn should not be empty")
+	}
+	if record.UpdatedOn == "" {
+		t.Error("UpdatedOn should not be empty")
+	}
+
+	// Validate categories
+	validCategories := []string{
+		"IT Services", "Hardware", "Software", "Network", "Security",
+		"Troubleshooting", "How-To", "FAQ", "Best Practices", "Procedures",
+	}
+	if !contains(validCategories, record.Category) {
+		t.Errorf("Invalid category: %s", record.Category)
+	}
+
+	// Validate HTML content (fallback may not have HTML)
+	if record.Text == "" {
+		t.Error("Text should not be empty")
+	}
+}
+
+func TestGenerateBatch(t *testing.T) {
+	bg := createTestBulkGenerator("incident")
+
+	batchSize := 5
+	records, err := bg.GenerateBatch(context.Background(), batchSize)
+	if err != nil {
+		t.Fatalf("Failed to generate batch: %v", err)
+	}
+
+	if len(records) != batchSize {
+		t.Errorf("Expected %d records, got %d", batchSize, len(records))
+	}
+
+	// Validate all records are of correct type
+	for i, record := range records {
+		if _, ok := record.(*IncidentRecord); !ok {
+			t.Errorf("Record %d is not an IncidentRecord", i)
+		}
+	}
+}
+
+func TestGenerateBatchWorkers(t *testing.T) {
+	bg := createTestBulkGenerator("case")
+	bg.Workers = 3
+
+	records, err := bg.GenerateBatch(context.Background(), 50)
+	if err != nil {
+		t.Fatalf("Failed to generate batch: %v", err)
+	}
+	if len(records) != 50 {
+		t.Errorf("Expected 50 records, got %d", len(records))
+	}
+
+	if workers := createTestBulkGenerator("case").workers(); workers != runtime.NumCPU() {
+		t.Errorf("Expected one worker per CPU without the LLM, got %d", workers)
+	}
+	bg.Workers = 0
+	bg.LLMClient.APIKey = "key"
+	if workers := bg.workers(); workers != 10 {
+		t.Errorf("Expected 10 workers with the LLM, got %d", workers)
+	}
+}
+
+func TestBatches(t *testing.T) {
+	bg := createTestBulkGenerator("incident")
+
+	batches, stop := bg.Batches(context.Background(), 12, 5)
+	defer stop()
+	var sizes []int
+	for batch := range batches {
+		if batch.Err != nil {
+			t.Fatalf("Unexpected error: %v", batch.Err)
+		}
+		if batch.Number != len(sizes)+1 || batch.Total != 3 {
+			t.Errorf("Expected batch %d of 3, got %d of %d", len(sizes)+1, batch.Number, batch.Total)
+		}
+		sizes = append(sizes, len(batch.Records))
+	}
+	if !reflect.DeepEqual(sizes, []int{5, 5, 2}) {
+		t.Errorf("Expected batches of 5, 5 and 2 records, got %v", sizes)
+	}
+
+	// Stopping early ends generation
+	batches, stop = bg.Batches(context.Background(), 100, 5)
+	<-batches
+	stop()
+	for range batches {
+	}
+}
+
+func TestBatchesCancelled(t *testing.T) {
+	bg := createTestBulkGenerator("incident")
+	ctx, cancel := context.WithCancel(context.Background())
+
+	batches, stop := bg.Batches(ctx, 100, 5)
+	defer stop()
+	first := <-batches
+	if first.Err != nil || len(first.Records) != 5 {
+		t.Fatalf("Expected a full first batch, got %d records and error %v", len(first.Records), first.Err)
+	}
+	cancel()
+
+	// The batch being generated when ctx is done reports the cancellation
+	var last Batch
+	for batch := range batches {
+		last = batch
+	}
+	if !errors.Is(last.Err, context.Canceled) {
+		t.Errorf("Expected the last batch to report the cancellation, got %v", last.Err)
+	}
+
+	records, err := bg.GenerateBatch(ctx, 5)
+	if !errors.Is(err, context.Canceled) || len(records) != 0 {
+		t.Errorf("Expected no records from a cancelled context, got %d and error %v", len(records), err)
+	}
+}
+
+func TestGenerateBatchUnsupportedTable(t *testing.T) {
+	bg := createTestBulkGenerator("unsupported_table")
+
+	// Unsupported tables fail instead of producing records of another table
+	records, err := bg.GenerateBatch(context.Background(), 1)
+	if err == nil || !strings.Contains(err.Error(), "unsupported table type") {
+		t.Errorf("Expected an unsupported table error, got %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("Expected no records, got %d", len(records))
+	}
+
+	// and are rejected before generation starts
+	if err := (Config{TableName: "unsupported_table"}).Validate(); err == nil {
+		t.Error("Expected the configuration of an unsupported table to be invalid")
+	}
+	if err := (Config{TableName: "incident"}).Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// as is reference data with memberships of missing users
+	referenceData := models.GetReferenceData()
+	referenceData.SysUserGrmember = append(referenceData.SysUserGrmember, models.GroupMember{Group: referenceData.SysUserGroup[0].SysID, User: "gone"})
+	if err := (Config{TableName: "incident", ReferenceData: referenceData}).Validate(); err == nil || !strings.Contains(err.Error(), "unknown user gone") {
+		t.Errorf("Expected a dangling membership to be invalid, got %v", err)
+	}
+}
+
+func TestForTable(t *testing.T) {
+	bg := createTestBulkGenerator("incident")
+	other := bg.ForTable("case", 0)
+
+	if other.TableName != "case" || other.ClosedPercentage != 0 {
+		t.Errorf("Expected a case generator without closed records, got %s with %d%%", other.TableName, other.ClosedPercentage)
+	}
+	if other.LLMClient != bg.LLMClient || other.ReferenceData != bg.ReferenceData || other.Numbering != bg.Numbering {
+		t.Error("Expected the table generator to share the client, reference data and numbering")
+	}
+
+	records, err := other.GenerateBatch(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("Failed to generate batch: %v", err)
+	}
+	if _, ok := records[0].(*CaseRecord); !ok {
+		t.Errorf("Expected a CaseRecord, got %T", records[0])
+	}
+}
+
+func TestSeed(t *testing.T) {
+	bg := createTestBulkGenerator("hr_case")
+	generate := func() string {
+		record, err := bg.generateHRCaseRecord(context.Background(), rand.New(rand.NewSource(42)), "HRC0001001")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return record.SysID + record.HRServiceType + record.State + record.OpenedFor.DisplayValue + record.OpenedAt
+	}
+	if first, second := generate(), generate(); first != second {
+		t.Errorf("Expected the same values from the same seed, got %q and %q", first, second)
+	}
+}
+
+func TestSeededBatches(t *testing.T) {
+	sysIDs := func(records []interface{}) []string {
+		ids := make([]string, len(records))
+		for i, record := range records {
+			ids[i] = record.(*IncidentRecord).SysID
+		}
+		return ids
+	}
+
+	bg := NewBulkGenerator(Config{TableName: "incident", Workers: 1, Seed: 42})
+	first, err := bg.GenerateBatch(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	counters := bg.Numbering.Counters()
+	second, err := bg.GenerateBatch(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// A generator resumed after the first batch picks the values of the second
+	resumed := NewBulkGenerator(Config{TableName: "incident", Workers: 1, Seed: 42})
+	resumed.Numbering.Restore(counters)
+	records, err := resumed.GenerateBatch(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(sysIDs(records), sysIDs(second)) {
+		t.Errorf("Expected the resumed batch to match the second batch, got %v and %v", sysIDs(records), sysIDs(second))
+	}
+	if reflect.DeepEqual(sysIDs(first), sysIDs(second)) {
+		t.Error("Expected batches to draw different values")
+	}
+}
+
+func TestSeededWorkers(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	generate := func(table string, workers int) string {
+		bg := NewBulkGenerator(Config{TableName: table, ClosedPercentage: 40, Workers: workers, Seed: 11, Now: now, TextEngine: TextTemplate, Log: io.Discard})
+		records, err := bg.GenerateBatch(context.Background(), 40)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var values []string
+		for _, record := range records {
+			values = append(values, fmt.Sprintf("%+v", record))
+		}
+		return strings.Join(values, "\n")
+	}
+
+	// Records repeat with the seed however the workers interleave them
+	for _, table := range []string{"incident", "case", "hr_case", "change_request", "knowledge_article"} {
+		first, second := generate(table, 4), generate(table, 4)
+		if first != second {
+			t.Errorf("Expected the same %s records from two seeded runs with 4 workers", table)
+		}
+		if one := generate(table, 1); one != first {
+			t.Errorf("Expected the same %s records with 1 and 4 workers", table)
+		}
+	}
+}
+
+func TestSeededResumeMidBatch(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	newGenerator := func() *BulkGenerator {
+		return NewBulkGenerator(Config{TableName: "incident", Workers: 4, Seed: 9, Now: now, TextEngine: TextTemplate, Log: io.Discard})
+	}
+	run := func(bg *BulkGenerator, ctx context.Context, count int) ([]string, Batch) {
+		batches, stop := bg.Batches(ctx, count, 10)
+		defer stop()
+		var values []string
+		var last Batch
+		for batch := range batches {
+			for _, record := range batch.Records {
+				values = append(values, fmt.Sprintf("%+v", record))
+			}
+			last = batch
+		}
+		return values, last
+	}
+	expected, _ := run(newGenerator(), context.Background(), 30)
+
+	// INC0010013 stops the run once INC0010016 is done, leaving a hole in the batch
+	incidents, _ := lookupTable("incident")
+	t.Cleanup(func() { replaceTableGenerator(incidents) })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	later := make(chan struct{})
+	replaceTableGenerator(FuncTable{
+		TableName: "incident",
+		Record:    IncidentRecord{},
+		GenerateFunc: func(ctx context.Context, rng *rand.Rand, req Request) (interface{}, error) {
+			switch req.Number {
+			case "INC0010013":
+				<-later
+				cancel()
+				return nil, ctx.Err()
+			case "INC0010016":
+				defer close(later)
+			}
+			return incidents.Generate(ctx, rng, req)
+		},
+	})
+	stopped, last := run(newGenerator(), ctx, 30)
+	if !errors.Is(last.Err, context.Canceled) {
+		t.Fatalf("Expected the run to be stopped, got %v", last.Err)
+	}
+	if len(stopped) < 10 || len(stopped) > 12 {
+		t.Fatalf("Expected the records before INC0010013 only, got %d", len(stopped))
+	}
+	if next := last.Counters["incident"]; next != 10001+int64(len(stopped)) {
+		t.Errorf("Expected the counters to end at the last record written, got %d after %d records", next, len(stopped))
+	}
+
+	// The resumed run carries on with the numbers and values of the uninterrupted one
+	replaceTableGenerator(incidents)
+	resumed := newGenerator()
+	resumed.Numbering.Restore(last.Counters)
+	rest, _ := run(resumed, context.Background(), 30-len(stopped))
+	if got := append(stopped, rest...); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected the stopped and resumed run to match the uninterrupted one")
+	}
+}
+
+func TestDatesInUTC(t *testing.T) {
+	now := time.Date(2026, 3, 1, 8, 30, 0, 0, time.FixedZone("UTC+5", 5*3600))
+	for _, table := range []string{"knowledge_article", "incident"} {
+		bg := NewBulkGenerator(Config{TableName: table, TextEngine: TextTemplate, Now: now})
+		records, err := bg.GenerateBatch(context.Background(), 5)
+		if err != nil {
+			t.Fatalf("Failed to generate %s records: %v", table, err)
+		}
+		for _, record := range records {
+			// Dates are read back as UTC, so they must be written in UTC
+			var value string
+			switch r := record.(type) {
+			case *KnowledgeArticleRecord:
+				value = r.Published
+			case *IncidentRecord:
+				value = r.Opened
+			}
+			written, err := models.ParseTime(value)
+			if err != nil || written.After(now) || written.Before(now.AddDate(-1, 0, 0)) {
+				t.Errorf("Expected a %s date up to %s, got %s (%v)", table, now.UTC(), value, err)
+			}
+			if article, ok := record.(*KnowledgeArticleRecord); ok && article.Published != "2026-03-01 03:30:00" {
+				t.Errorf("Expected the article to be published at 2026-03-01 03:30:00 UTC, got %s", article.Published)
+			}
+		}
+	}
+}
+
+func TestTableColumns(t *testing.T) {
+	columns, err := TableColumns("incident", models.ReferenceDisplay)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{
+		"Sys ID", "Number", "Caller", "Category", "Subcategory", "Service", "Service offering",
+		"Configuration item", "Short description", "Description", "Channel",
+		"Opened", "Incident State", "Impact", "Urgency", "Priority",
+		"Assignment group", "Assigned to", "Resolution code", "Resolution notes",
+	}
+	if labels := models.ColumnLabels(columns); !reflect.DeepEqual(labels, expected) {
+		t.Errorf("Expected incident labels %v, got %v", expected, labels)
+	}
+
+	// Every field of every table has a column with a label of its own
+	for table, recordType := range tableRecords {
+		columns, err := TableColumns(table, models.ReferenceBoth)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", table, err)
+			continue
+		}
+		fields := 0
+		for _, col := range columns {
+			if !col.Display {
+				fields++
+				if _, ok := recordType.Field(col.Field).Tag.Lookup("label"); !ok {
+					t.Errorf("Field %s of %s has no label tag", recordType.Field(col.Field).Name, table)
+				}
+			}
+		}
+		if fields != recordType.NumField() {
+			t.Errorf("Expected %d columns for %s, got %d", recordType.NumField(), table, fields)
+		}
+	}
+
+	if columns, err := TableColumns("cmdb_ci_linux_server", models.ReferenceDisplay); err != nil || columns[2].Name != "sys_class_name" {
+		t.Errorf("Expected CI classes to have the cmdb_ci columns, got %v (%v)", columns, err)
+	}
+	if _, err := TableColumns("problem", models.ReferenceDisplay); err == nil {
+		t.Error("Expected an error for a table without columns")
+	}
+}
+
+func TestAssignedToIsMemberOfAssignmentGroup(t *testing.T) {
+	bg := createTestBulkGenerator("incident")
+	rd := bg.ReferenceData
+
+	for i := 0; i < 10; i++ {
+		incident, err := bg.generateIncidentRecord(context.Background(), newRand(), bg.Numbering.Next("incident"))
+		if err != nil {
+			t.Fatalf("Failed to generate incident record: %v", err)
+		}
+		assertGroupMember(t, rd, incident.AssignmentGroup, incident.AssignedTo)
+
+		caseRecord, err := bg.generateCaseRecord(context.Background(), newRand(), bg.Numbering.Next("case"))
+		if err != nil {
+			t.Fatalf("Failed to generate case record: %v", err)
+		}
+		assertGroupMember(t, rd, caseRecord.AssignmentGroup, caseRecord.AssignedTo)
+
+		change, err := bg.generateChangeRequestRecord(context.Background(), newRand(), bg.Numbering.Next("change_request"))
+		if err != nil {
+			t.Fatalf("Failed to generate change request record: %v", err)
+		}
+		assertGroupMember(t, rd, change.AssignmentGroup, change.AssignedTo)
+
+		hrCase, err := bg.generateHRCaseRecord(context.Background(), newRand(), bg.Numbering.Next("hr_case"))
+		if err != nil {
+			t.Fatalf("Failed to generate HR case record: %v", err)
+		}
+		// Open HR cases are assigned too
+		assertGroupMember(t, rd, hrCase.AssignmentGroup, hrCase.AssignedTo)
+	}
+}
+
+func TestIncidentRoutedByCategory(t *testing.T) {
+	bg := createTestBulkGenerator("incident")
+
+	for i := 0; i < 20; i++ {
+		record, err := bg.generateIncidentRecord(context.Background(), newRand(), bg.Numbering.Next("incident"))
+		if err != nil {
+			t.Fatalf("Failed to generate incident record: %v", err)
+		}
+		if record.Category == "Network" && record.AssignmentGroup.DisplayValue != "Network" {
+			t.Errorf("Expected Network incidents to be routed to the Network group, got %s", record.AssignmentGroup)
+		}
+		if record.Category == "Database" && record.AssignmentGroup.DisplayValue != "NY DB" {
+			t.Errorf("Expected Database incidents to be routed to the NY DB group, got %s", record.AssignmentGroup)
+		}
+	}
+}
+
+func TestIncidentCIRelatedToService(t *testing.T) {
+	rd := models.GetReferenceData()
+	rd.ApplyTopology(models.GenerateTopology(newRand(), 3, 30))
+
+	config := Config{
+		RecordCount:   10,
+		BatchSize:     5,
+		TableName:     "incident",
+		Model:         "test-model",
+		ReferenceData: rd,
+	}
+	bg := NewBulkGenerator(config)
+
+	for i := 0; i < 10; i++ {
+		record, err := bg.generateIncidentRecord(context.Background(), newRand(), bg.Numbering.Next("incident"))
+		if err != nil {
+			t.Fatalf("Failed to generate incident record: %v", err)
+		}
+
+		// The CI must be one the service depends on
+		related := make(map[string]bool)
+		rng := newRand()
+		for j := 0; j < 200; j++ {
+			related[rd.GetRandomRelatedCI(rng, record.Service.SysID).SysID] = true
+		}
+		if !related[record.ConfigurationItem.SysID] {
+			t.Errorf("CI %s is not related to service %s", record.ConfigurationItem, record.Service)
+		}
+	}
+}
+
+func TestRecordsHaveUniqueSysIDs(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, table := range []string{"incident", "case", "hr_case", "change_request", "knowledge_article"} {
+		bg := createTestBulkGenerator(table)
+		records, err := bg.GenerateBatch(context.Background(), 5)
+		if err != nil {
+			t.Fatalf("Failed to generate %s batch: %v", table, err)
+		}
+		for _, record := range records {
+			sysID := reflect.ValueOf(record).Elem().FieldByName("SysID").String()
+			if len(sysID) != 32 {
+				t.Errorf("Expected a 32 character sys_id on %s record, got %q", table, sysID)
+			}
+			if seen[sysID] {
+				t.Errorf("Duplicate sys_id %s", sysID)
+			}
+			seen[sysID] = true
+		}
+	}
+}
+
+func TestGenerateRandomOpenedAt(t *testing.T) {
+	bg := createTestBulkGenerator("incident")
+
+	openedAt := bg.generateRandomOpenedAt(newRand())
+
+	if openedAt == "" {
+		t.Error("OpenedAt should not be empty")
+	}
+
+	if !isValidDateFormat(openedAt) {
+		t.Errorf("Invalid date format: %s", openedAt)
+	}
+}
+
+// Helper functions
+
+func createTestBulkGenerator(tableName string) *BulkGenerator {
+	config := Config{
+		RecordCount:      10,
+		BatchSize:        5,
+		TableName:        tableName,
+		ClosedPercentage: 30,
+		SplitOutput:      false,
+		APIKey:           "", // Empty API key for testing (will use fallback)
+		Model:            "test-model",
+	}
+
+	return NewBulkGenerator(config)
+}
+
+func assertGroupMember(t *testing.T, rd *models.ReferenceData, group, user models.ReferenceValue) {
+	t.Helper()
+
+	if !rd.IsGroupMember(group.SysID, user.SysID) {
+		t.Errorf("Expected %s to be a member of assignment group %s", user, group)
+	}
+}
+
+func isValidDateFormat(dateStr string) bool {
+	// Check if date matches YYYY-MM-DD HH:MM:SS format
+	if len(dateStr) != 19 {
+		return false
+	}
+	if dateStr[4] != '-' || dateStr[7] != '-' || dateStr[10] != ' ' || dateStr[13] != ':' || dateStr[16] != ':' {
+		return false
+	}
+	return true
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
+
+// Benchmark tests
+
+func BenchmarkGenerateIncidentRecord(b *testing.B) {
+	bg := createTestBulkGenerator("incident")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := bg.generateIncidentRecord(context.Background(), newRand(), bg.Numbering.Next("incident"))
+		if err != nil {
+			b.Fatalf("Failed to generate incident record: %v", err)
+		}
+	}
+}
+
+func BenchmarkGenerateCaseRecord(b *testing.B) {
+	bg := createTestBulkGenerator("case")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := bg.generateCaseRecord(context.Background(), newRand(), bg.Numbering.Next("case"))
+		if err != nil {
+			b.Fatalf("Failed to generate case record: %v", err)
+		}
+	}
+}
+
+func BenchmarkGenerateBatch(b *testing.B) {
+	bg := createTestBulkGenerator("incident")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := bg.GenerateBatch(context.Background(), 10)
+		if err != nil {
+			b.Fatalf("Failed to generate batch: %v", err)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+// CMDBCIRecord represents a configuration item record of any class
+type CMDBCIRecord struct {
+	SysID             string `json:"sys_id" label:"Sys ID"`
+	Name              string `json:"name" label:"Name"`
+	Class             string `json:"sys_class_name" label:"Class"`
+	FQDN              string `json:"fqdn" label:"Fully qualified domain name"`
+	IPAddress         string `json:"ip_address" label:"IP Address"`
+	OS                string `json:"os" label:"Operating System"`
+	OSVersion         string `json:"os_version" label:"OS Version"`
+	Version           string `json:"version" label:"Version"`
+	TCPPort           string `json:"tcp_port" type:"int" label:"TCP port(s)"`
+	SerialNumber      string `json:"serial_number" label:"Serial number"`
+	Manufacturer      string `json:"manufacturer" label:"Manufacturer"`
+	ModelID           string `json:"model_id" label:"Model ID"`
+	Location          string `json:"location" label:"Location"`
+	Environment       string `json:"environment" label:"Environment"`
+	OperationalStatus string `json:"operational_status" label:"Operational status"`
+	InstallStatus     string `json:"install_status" label:"Install Status"`
+	SupportGroup      string `json:"support_group" label:"Support group"`
+}
+
+// CIRelationshipRecord represents a cmdb_rel_ci record
+type CIRelationshipRecord struct {
+	Parent     string `json:"parent" label:"Parent"`
+	ParentName string `json:"parent_name" xml:"-" label:"Parent name"`
+	Type       string `json:"type" label:"Type"`
+	Child      string `json:"child" label:"Child"`
+	ChildName  string `json:"child_name" xml:"-" label:"Child name"`
+}
+
+// dataCenters are the locations CIs are placed in, with the site code used in hostnames
+var dataCenters = [][2]string{
+	{"nyc", "New York DC"},
+	{"lon", "London DC"},
+	{"fra", "Frankfurt DC"},
+	{"sin", "Singapore DC"},
+	{"sdg", "San Diego DC"},
+}
+
+// ciProfile describes the hardware and software a CI of a given class may have
+type ciProfile struct {
+	hostPrefix    string
+	operatingSys  [][2]string // OS name and version
+	versions      []string
+	ports         []string
+	manufacturers [][2]string // manufacturer and model
+	hasSerial     bool
+	supportGroup  string
+}
+
+// ciProfiles holds the profile of each CI class generated in cmdb mode
+var ciProfiles = map[string]ciProfile{
+	"cmdb_ci_linux_server": {
+		hostPrefix:    "lnx",
+		operatingSys:  [][2]string{{"Linux Red Hat", "8.6"}, {"Linux Red Hat", "9.2"}, {"Linux Ubuntu", "22.04"}, {"Linux SuSE", "15 SP4"}},
+		manufacturers: [][2]string{{"Dell Inc.", "PowerEdge R740"}, {"Dell Inc.", "PowerEdge R650"}, {"HPE", "ProLiant DL380 Gen10"}, {"Lenovo", "ThinkSystem SR650"}},
+		hasSerial:     true,
+		supportGroup:  "Hardware",
+	},
+	"cmdb_ci_win_server": {
+		hostPrefix:    "win",
+		operatingSys:  [][2]string{{"Windows 2019 Standard", "10.0.17763"}, {"Windows 2022 Datacenter", "10.0.20348"}, {"Windows 2016 Standard", "10.0.14393"}},
+		manufacturers: [][2]string{{"Dell Inc.", "PowerEdge R740"}, {"HPE", "ProLiant DL360 Gen10"}, {"VMware, Inc.", "VMware Virtual Platform"}},
+		hasSerial:     true,
+		supportGroup:  "Hardware",
+	},
+	"cmdb_ci_db_ora_instance": {
+		hostPrefix:   "ora",
+		versions:     []string{"19c", "21c", "12.2"},
+		ports:        []string{"1521"},
+		supportGroup: "NY DB",
+	},
+	"cmdb_ci_db_mssql_instance": {
+		hostPrefix:   "sql",
+		versions:     []string{"SQL Server 2019", "SQL Server 2022", "SQL Server 2017"},
+		ports:        []string{"1433"},
+		supportGroup: "NY DB",
+	},
+	"cmdb_ci_db_mysql_instance": {
+		hostPrefix:   "mys",
+		versions:     []string{"8.0.35", "5.7.44"},
+		ports:        []string{"3306"},
+		supportGroup: "NY DB",
+	},
+	"cmdb_ci_appl": {
+		hostPrefix:   "app",
+		versions:     []string{"1.4.2", "2.0.1", "3.7.0", "5.2.11"},
+		ports:        []string{"443", "8080", "8443"},
+		supportGroup: "Application Development",
+	},
+	"cmdb_ci_lb_bigip": {
+		hostPrefix:    "lb",
+		operatingSys:  [][2]string{{"TMOS", "16.1.3"}, {"TMOS", "17.1.0"}},
+		manufacturers: [][2]string{{"F5 Networks", "BIG-IP i5800"}, {"F5 Networks", "BIG-IP i7800"}},
+		hasSerial:     true,
+		supportGroup:  "Network",
+	},
+	"cmdb_ci_ip_switch": {
+		hostPrefix:    "sw",
+		operatingSys:  [][2]string{{"IOS XE", "17.6.4"}, {"NX-OS", "9.3(10)"}, {"Junos", "22.4R2"}},
+		manufacturers: [][2]string{{"Cisco Systems", "Catalyst 9300"}, {"Cisco Systems", "Nexus 93180YC-EX"}, {"Juniper Networks", "EX4300"}},
+		hasSerial:     true,
+		supportGroup:  "Network",
+	},
+	"cmdb_ci_ip_router": {
+		hostPrefix:    "rtr",
+		operatingSys:  [][2]string{{"IOS XE", "17.9.3"}, {"Junos", "21.4R3"}},
+		manufacturers: [][2]string{{"Cisco Systems", "ASR 1001-X"}, {"Juniper Networks", "MX204"}},
+		hasSerial:     true,
+		supportGroup:  "Network",
+	},
+}
+
+// cmdbServices returns the services of the run, generated once so that every CMDB batch
+// relates its CIs to the same services. The run has a service for every ten of its count
+// CIs, and at least one.
+func (bg *BulkGenerator) cmdbServices(count int) *models.Topology {
+	bg.cmdbOnce.Do(func() {
+		serviceCount := count / 10
+		if serviceCount < 1 {
+			serviceCount = 1
+		}
+		bg.cmdbTopology = models.GenerateServices(bg.source("cmdb/services"), serviceCount)
+	})
+	return bg.cmdbTopology
+}
+
+// generateCMDBBatch generates a batch of configuration items and the cmdb_rel_ci
+// relationships that relate them to the services of the run. The batch contains
+// batchSize CIs followed by the relationships; the first batch also carries the services
+// and their relationships, between its CIs and its relationships.
+func (bg *BulkGenerator) generateCMDBBatch(batchSize int) ([]interface{}, error) {
+	services := bg.cmdbServices(batchSize)
+	batchNumber := atomic.AddInt64(&bg.cmdbBatches, 1)
+	first := atomic.AddInt64(&bg.cmdbCIs, int64(batchSize)) - int64(batchSize)
+	rng := bg.source(fmt.Sprintf("cmdb/%d", batchNumber))
+	topology := services.GenerateCIs(rng, batchSize, int(first))
+
+	names := make(map[string]string)
+	var records []interface{}
+
+	for _, ci := range topology.CIs {
+		record := bg.generateCMDBCIRecord(rng, ci)
+		names[record.SysID] = record.Name
+		records = append(records, record)
+	}
+
+	for _, s := range append(append([]models.TopologyCI{}, services.Services...), services.ApplicationServices...) {
+		names[s.SysID] = s.Name
+		if batchNumber > 1 {
+			continue
+		}
+		records = append(records, &CMDBCIRecord{
+			SysID:             s.SysID,
+			Name:              s.Name,
+			Class:             s.Class,
+			Environment:       "Production",
+			OperationalStatus: "Operational",
+			InstallStatus:     "Installed",
+			SupportGroup:      "Service Desk",
+		})
+	}
+
+	relationships := topology.Relationships
+	if batchNumber == 1 {
+		relationships = append(append([]models.CIRelationship{}, services.Relationships...), relationships...)
+	}
+	for _, rel := range relationships {
+		records = append(records, &CIRelationshipRecord{
+			Parent:     rel.Parent,
+			ParentName: names[rel.Parent],
+			Type:       rel.Type,
+			Child:      rel.Child,
+			ChildName:  names[rel.Child],
+		})
+	}
+
+	return records, nil
+}
+
+// generateCMDBCIRecord fills in the attributes of a configuration item of the CI's class,
+// drawing them from rng
+func (bg *BulkGenerator) generateCMDBCIRecord(rng *rand.Rand, ci models.TopologyCI) *CMDBCIRecord {
+	profile := ciProfiles[ci.Class]
+
+	site := dataCenters[rng.Intn(len(dataCenters))]
+
+	environments := []string{"Production", "Production", "Production", "Test", "Development"}
+	environment := environments[rng.Intn(len(environments))]
+
+	name := fmt.Sprintf("%s-%s-%05d", site[0], profile.hostPrefix, atomic.AddInt64(&bg.cmdbSequence, 1))
+	record := &CMDBCIRecord{
+		SysID:             ci.SysID,
+		Name:              name,
+		Class:             ci.Class,
+		FQDN:              name + ".corp.example.com",
+		IPAddress:         fmt.Sprintf("10.%d.%d.%d", rng.Intn(256), rng.Intn(256), rng.Intn(254)+1),
+		Location:          site[1],
+		Environment:       environment,
+		OperationalStatus: "Operational",
+		InstallStatus:     "Installed",
+		SupportGroup:      profile.supportGroup,
+	}
+
+	if len(profile.operatingSys) > 0 {
+		osInfo := profile.operatingSys[rng.Intn(len(profile.operatingSys))]
+		record.OS = osInfo[0]
+		record.OSVersion = osInfo[1]
+	}
+	if len(profile.versions) > 0 {
+		record.Version = profile.versions[rng.Intn(len(profile.versions))]
+	}
+	if len(profile.ports) > 0 {
+		record.TCPPort = profile.ports[rng.Intn(len(profile.ports))]
+	}
+	if len(profile.manufacturers) > 0 {
+		m := profile.manufacturers[rng.Intn(len(profile.manufacturers))]
+		record.Manufacturer = m[0]
+		record.ModelID = m[1]
+	}
+	if profile.hasSerial {
+		fake := &gofakeit.Faker{Rand: rng}
+		record.SerialNumber = strings.ToUpper(fake.LetterN(3)) + fake.DigitN(7)
+	}
+
+	return record
+}
@@ -0,0 +1,128 @@
+package generator
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestGenerateCMDBBatch(t *testing.T) {
+	bg := createTestBulkGenerator("cmdb")
+
+	records, err := bg.GenerateBatch(context.Background(), 20)
+	if err != nil {
+		t.Fatalf("Failed to generate CMDB batch: %v", err)
+	}
+
+	cis := make(map[string]*CMDBCIRecord)
+	var classCIs int
+	var relationships []*CIRelationshipRecord
+	for _, record := range records {
+		switch r := record.(type) {
+		case *CMDBCIRecord:
+			cis[r.SysID] = r
+			if !strings.HasPrefix(r.Class, "cmdb_ci_service") {
+				classCIs++
+			}
+		case *CIRelationshipRecord:
+			relationships = append(relationships, r)
+		default:
+			t.Fatalf("Unexpected record type %T", record)
+		}
+	}
+
+	if classCIs != 20 {
+		t.Errorf("Expected 20 configuration items, got %d", classCIs)
+	}
+	if len(relationships) == 0 {
+		t.Fatal("Expected relationships to be generated")
+	}
+
+	validTypes := []string{"Depends on::Used by", "Runs on::Runs", "Hosted on::Hosts"}
+	for _, rel := range relationships {
+		if cis[rel.Parent] == nil || cis[rel.Child] == nil {
+			t.Errorf("Relationship %s -> %s references a CI outside the batch", rel.ParentName, rel.ChildName)
+		}
+		if !contains(validTypes, rel.Type) {
+			t.Errorf("Invalid relationship type: %s", rel.Type)
+		}
+	}
+
+	for _, ci := range cis {
+		if ci.Name == "" || len(ci.SysID) != 32 {
+			t.Errorf("CI is missing its name or sys_id: %+v", ci)
+		}
+		if strings.HasSuffix(ci.Class, "_server") {
+			if ci.IPAddress == "" || ci.OS == "" || ci.SerialNumber == "" {
+				t.Errorf("Server %s is missing IP, OS or serial number", ci.Name)
+			}
+		}
+	}
+}
+
+func TestCMDBNamesUniqueAcrossBatches(t *testing.T) {
+	bg := createTestBulkGenerator("cmdb")
+
+	names := make(map[string]bool)
+	for batch := 0; batch < 3; batch++ {
+		records, err := bg.GenerateBatch(context.Background(), 10)
+		if err != nil {
+			t.Fatalf("Failed to generate CMDB batch: %v", err)
+		}
+		for _, record := range records {
+			if ci, ok := record.(*CMDBCIRecord); ok {
+				if names[ci.Name] {
+					t.Errorf("Duplicate CI name %s", ci.Name)
+				}
+				names[ci.Name] = true
+			}
+		}
+	}
+}
+
+func TestCMDBServicesOncePerRun(t *testing.T) {
+	bg := createTestBulkGenerator("cmdb")
+
+	batches, stop := bg.Batches(context.Background(), 30, 10)
+	defer stop()
+	known := make(map[string]bool)
+	var classCIs int
+	var services []string
+	var relationships []*CIRelationshipRecord
+	for batch := range batches {
+		if batch.Err != nil {
+			t.Fatalf("Failed to generate CMDB batch: %v", batch.Err)
+		}
+		for _, record := range batch.Records {
+			switch r := record.(type) {
+			case *CMDBCIRecord:
+				known[r.SysID] = true
+				if strings.HasPrefix(r.Class, "cmdb_ci_service") {
+					services = append(services, r.Name)
+				} else {
+					classCIs++
+				}
+			case *CIRelationshipRecord:
+				relationships = append(relationships, r)
+			}
+		}
+	}
+
+	// One service and application service for every ten CIs of the run, written once
+	if classCIs != 30 {
+		t.Errorf("Expected 30 configuration items, got %d", classCIs)
+	}
+	if len(services) != 6 {
+		t.Errorf("Expected 3 services with their application services, got %v", services)
+	}
+	for _, name := range services {
+		if strings.Contains(name, "(") {
+			t.Errorf("Expected services to keep their names, got %s", name)
+		}
+	}
+	for _, rel := range relationships {
+		if !known[rel.Parent] || !known[rel.Child] {
+			t.Errorf("Relationship %s -> %s references a CI that was not written", rel.ParentName, rel.ChildName)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Distribution is a set of values picked at random in proportion to their weights
+type Distribution struct {
+	Values  []string
+	Weights []float64
+}
+
+// Uniform returns a distribution picking each value equally often
+func Uniform(values ...string) Distribution {
+	weights := make([]float64, len(values))
+	for i := range weights {
+		weights[i] = 1
+	}
+	return Distribution{Values: values, Weights: weights}
+}
+
+// Validate checks that the distribution has values with positive weights
+func (d Distribution) Validate() error {
+	if len(d.Values) == 0 {
+		return fmt.Errorf("has no values")
+	}
+	if len(d.Weights) != len(d.Values) {
+		return fmt.Errorf("has %d weights for %d values", len(d.Weights), len(d.Values))
+	}
+	for i, weight := range d.Weights {
+		if weight <= 0 {
+			return fmt.Errorf("weight %v of %q is not positive", weight, d.Values[i])
+		}
+	}
+	return nil
+}
+
+// Pick returns a value drawn from rng, in proportion to the weights
+func (d Distribution) Pick(rng *rand.Rand) string {
+	var total float64
+	for _, weight := range d.Weights {
+		total += weight
+	}
+	r := rng.Float64() * total
+	for i, weight := range d.Weights {
+		if r < weight {
+			return d.Values[i]
+		}
+		r -= weight
+	}
+	return d.Values[len(d.Values)-1]
+}
+
+// defaultDistributions are the values generators pick from unless a profile replaces them
+var defaultDistributions = map[string]Distribution{
+	"case_entitlement": Uniform(
+		"24/7 Support", "Business Hours Support", "Premium Support",
+		"Standard Warranty", "Extended Warranty", "10-year product warranty on inverters",
+	),
+	"case_resolution_code": Uniform(
+		"Fixed by Vendor", "Fixed by Customer", "Fixed by Support",
+		"Workaround Provided", "Configuration Change", "Software Update", "Hardware Replacement",
+	),
+	"case_cause": Uniform(
+		"User Error", "Software Bug", "Hardware Failure", "Network Issue",
+		"Configuration Error", "Third-party Integration", "Environmental Factor",
+	),
+	"hr_service_type": Uniform(
+		"employee_relations", "benefits", "payroll", "recruitment",
+		"performance_management", "training", "compliance", "onboarding",
+	),
+	"hr_category": Uniform(
+		"Benefits", "Payroll", "Time Off", "Performance", "Training",
+		"Compliance", "Employee Relations", "Onboarding", "Offboarding",
+	),
+	"hr_state": Uniform("New", "In Progress", "Awaiting Info", "Resolved", "Closed"),
+	"hr_close_code": Uniform(
+		"Resolved", "Closed Complete", "Closed Incomplete",
+		"Cancelled", "Duplicate", "Resolved by Caller",
+	),
+	"change_category": Uniform(
+		"Software", "Hardware", "Network", "Security", "Database",
+		"Application", "Infrastructure", "Emergency", "Standard", "Normal",
+	),
+	"change_risk":  Uniform("Low", "Medium", "High", "Very High"),
+	"change_state": Uniform("New", "Assess", "Authorize", "Scheduled", "Implement", "Review", "Closed"),
+	"change_close_code": Uniform(
+		"Successful", "Successful with Issues", "Unsuccessful",
+		"Cancelled", "Backed Out", "Partially Successful",
+	),
+	"knowledge_category": Uniform(
+		"IT Services", "Hardware", "Software", "Network", "Security",
+		"Troubleshooting", "How-To", "FAQ", "Best Practices", "Procedures",
+	),
+}
+
+// DistributionNames returns the names of the distributions a profile can replace
+func DistributionNames() []string {
+	names := make([]string, 0, len(defaultDistributions))
+	for name := range defaultDistributions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// prompt is an LLM prompt template and the placeholders it may use
+type prompt struct {
+	text         string
+	placeholders []string
+}
+
+// defaultPrompts are the LLM prompts of long text fields unless a profile replaces them.
+// Placeholders such as {category} are replaced by values of the record.
+var defaultPrompts = map[string]prompt{
+	"change_justification": {
+		"Write a business justification for a {category} change request affecting {service}. Include business value and expected benefits.",
+		[]string{"category", "service"},
+	},
+	"change_implementation_plan": {
+		"Create an implementation plan for a {category} change request. Include specific steps, timing, and ownership.",
+		[]string{"category", "service"},
+	},
+	"change_risk_analysis": {
+		"Analyze risks and impacts for a {category} change request. Include mitigation strategies.",
+		[]string{"category", "service"},
+	},
+	"change_backout_plan": {
+		"Create a backout plan for a {category} change request. Include specific rollback steps.",
+		[]string{"category", "service"},
+	},
+	"change_test_plan": {
+		"Develop a test plan for a {category} change request. Include test cases and success criteria.",
+		[]string{"category", "service"},
+	},
+	"knowledge_title": {
+		"Create a knowledge article title for {category}. Make it concise and solution-oriented.",
+		[]string{"category"},
+	},
+	"knowledge_content": {
+		"Create a comprehensive knowledge base article about {category}. Include sections for Problem Description, Symptoms, Cause, Resolution Steps, Prevention, and Related Information. Format with HTML headings and lists.",
+		[]string{"category"},
+	},
+	"knowledge_keywords": {
+		"Generate 5-7 relevant technical keywords for a knowledge article about {category}. Return only keywords separated by commas.",
+		[]string{"category"},
+	},
+}
+
+// PromptNames returns the names of the prompts a profile can replace
+func PromptNames() []string {
+	names := make([]string, 0, len(defaultPrompts))
+	for name := range defaultPrompts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidatePrompt checks that a prompt replacing the named one only uses its placeholders
+func ValidatePrompt(name, text string) error {
+	p, ok := defaultPrompts[name]
+	if !ok {
+		return fmt.Errorf("unknown prompt %s (known prompts: %s)", name, strings.Join(PromptNames(), ", "))
+	}
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("prompt %s is empty", name)
+	}
+	for rest := text; ; {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			return nil
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return fmt.Errorf("prompt %s has an unclosed {", name)
+		}
+		placeholder := rest[start+1 : start+end]
+		known := false
+		for _, allowed := range p.placeholders {
+			known = known || placeholder == allowed
+		}
+		if !known {
+			return fmt.Errorf("prompt %s uses unknown placeholder {%s} (allowed: {%s})", name, placeholder, strings.Join(p.placeholders, "}, {"))
+		}
+		rest = rest[start+end+1:]
+	}
+}
+
+// pick returns a value of the named distribution drawn from rng
+func (bg *BulkGenerator) pick(rng *rand.Rand, name string) string {
+	if d, ok := bg.Distributions[name]; ok {
+		return d.Pick(rng)
+	}
+	return defaultDistributions[name].Pick(rng)
+}
+
+// prompt returns the named prompt with its placeholders replaced by values, given as
+// placeholder and value pairs
+func (bg *BulkGenerator) prompt(name string, values ...string) string {
+	text, ok := bg.Prompts[name]
+	if !ok {
+		text = defaultPrompts[name].text
+	}
+	var pairs []string
+	for i := 0; i+1 < len(values); i += 2 {
+		pairs = append(pairs, "{"+values[i]+"}", values[i+1])
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
@@ -0,0 +1,80 @@
+package generator
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDistributionPick(t *testing.T) {
+	d := Distribution{Values: []string{"Low", "High"}, Weights: []float64{9, 1}}
+	rng := newRand()
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[d.Pick(rng)]++
+	}
+	if counts["Low"] < 8500 || counts["High"] < 500 {
+		t.Errorf("Expected about 9 Low for each High, got %v", counts)
+	}
+
+	if err := d.Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := (Distribution{}).Validate(); err == nil {
+		t.Error("Expected an error for a distribution without values")
+	}
+	if err := (Distribution{Values: []string{"Low"}, Weights: []float64{0}}).Validate(); err == nil {
+		t.Error("Expected an error for a weight of 0")
+	}
+}
+
+func TestConfiguredDistributionsAndPrompts(t *testing.T) {
+	bg := NewBulkGenerator(Config{
+		TableName:     "change_request",
+		Distributions: map[string]Distribution{"change_risk": Uniform("Very Low")},
+		Prompts:       map[string]string{"change_test_plan": "Test the {category} change to {service}"},
+	})
+
+	record, err := bg.generateChangeRequestRecord(context.Background(), newRand(), bg.Numbering.Next("change_request"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if record.Risk != "Very Low" {
+		t.Errorf("Expected the configured risk, got %s", record.Risk)
+	}
+	if !strings.Contains(record.TestPlan, "Test the "+record.Category+" change to "+record.BusinessService.DisplayValue) {
+		t.Errorf("Expected the test plan to be written from the configured prompt, got %q", record.TestPlan)
+	}
+
+	// Other distributions keep their defaults
+	found := false
+	for _, state := range defaultDistributions["change_state"].Values {
+		found = found || state == record.State
+	}
+	if !found {
+		t.Errorf("Expected a default change state, got %s", record.State)
+	}
+}
+
+func TestValidatePrompt(t *testing.T) {
+	if err := ValidatePrompt("knowledge_title", "A title about {category}"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	for name, text := range map[string]string{
+		"knowledge_name":      "A title",
+		"knowledge_title":     " ",
+		"knowledge_content":   "About {service}",
+		"change_backout_plan": "Back out {category",
+	} {
+		if err := ValidatePrompt(name, text); err == nil {
+			t.Errorf("Expected an error for prompt %s %q", name, text)
+		}
+	}
+}
+
+func TestTemplateTextEngine(t *testing.T) {
+	bg := NewBulkGenerator(Config{TableName: "incident", APIKey: "key", TextEngine: TextTemplate})
+	if bg.LLMClient.APIKey != "" {
+		t.Error("Expected the template engine to make no LLM calls")
+	}
+}
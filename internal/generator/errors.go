@@ -0,0 +1,152 @@
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+// Error policy actions
+const (
+	OnErrorFailFast    = "fail-fast"   // stop at the first record that fails
+	OnErrorSkip        = "skip"        // leave failed records out of the batch
+	OnErrorRetry       = "retry"       // generate failed records again, then stop
+	OnErrorPlaceholder = "placeholder" // write a placeholder record of the table instead
+)
+
+// defaultRetries is the number of retries of "retry" without a count
+const defaultRetries = 3
+
+// ErrorPolicy decides what happens when a record cannot be generated. The zero value
+// fails fast.
+type ErrorPolicy struct {
+	Action  string
+	Retries int // retries of a failed record with OnErrorRetry
+}
+
+// ParseErrorPolicy parses an error policy: fail-fast, skip, placeholder, or retry with
+// an optional count, as "retry 5" or "retry=5"
+func ParseErrorPolicy(value string) (ErrorPolicy, error) {
+	fields := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return r == ' ' || r == '='
+	})
+	if len(fields) == 0 {
+		return ErrorPolicy{Action: OnErrorFailFast}, nil
+	}
+
+	policy := ErrorPolicy{Action: fields[0]}
+	switch {
+	case policy.Action == OnErrorRetry && len(fields) <= 2:
+		policy.Retries = defaultRetries
+		if len(fields) == 2 {
+			retries, err := strconv.Atoi(fields[1])
+			if err != nil || retries < 1 {
+				return ErrorPolicy{}, fmt.Errorf("invalid error policy %q: the retries must be a positive number", value)
+			}
+			policy.Retries = retries
+		}
+	case len(fields) > 1:
+		return ErrorPolicy{}, fmt.Errorf("invalid error policy %q: only retry takes a count", value)
+	case policy.Action != OnErrorFailFast && policy.Action != OnErrorSkip && policy.Action != OnErrorPlaceholder:
+		return ErrorPolicy{}, fmt.Errorf("unknown error policy %q (use %s, %s, %s=N or %s)", value, OnErrorFailFast, OnErrorSkip, OnErrorRetry, OnErrorPlaceholder)
+	}
+	return policy, nil
+}
+
+// String returns the policy as ParseErrorPolicy reads it
+func (p ErrorPolicy) String() string {
+	switch p.Action {
+	case "":
+		return OnErrorFailFast
+	case OnErrorRetry:
+		return fmt.Sprintf("%s %d", OnErrorRetry, p.Retries)
+	default:
+		return p.Action
+	}
+}
+
+// attempts returns how many times a record is generated before it counts as failed
+func (p ErrorPolicy) attempts() int {
+	if p.Action == OnErrorRetry {
+		return 1 + p.Retries
+	}
+	return 1
+}
+
+// tolerates reports whether the batch goes on when a record fails
+func (p ErrorPolicy) tolerates() bool {
+	return p.Action == OnErrorSkip || p.Action == OnErrorPlaceholder
+}
+
+// RecordError is the failure to generate a record
+type RecordError struct {
+	Table    string
+	Index    int // 0-based position of the record in its batch, or in the run for Batches
+	Number   string
+	Attempts int
+	Err      error
+}
+
+func (e *RecordError) Error() string {
+	message := fmt.Sprintf("failed to generate %s record %d (%s)", e.Table, e.Index, e.Number)
+	if e.Attempts > 1 {
+		message += fmt.Sprintf(" after %d attempts", e.Attempts)
+	}
+	return fmt.Sprintf("%s: %v", message, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError holds the records of a batch that failed, in order
+type BatchError struct {
+	Errors []*RecordError
+}
+
+func newBatchError(errs []*RecordError) *BatchError {
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Index < errs[j].Index })
+	return &BatchError{Errors: errs}
+}
+
+func (e *BatchError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	return fmt.Sprintf("%d records failed, the first: %v", len(e.Errors), e.Errors[0])
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// placeholderRecord returns a record of the table standing in for one that failed, with
+// its number and the error in its descriptions. Its sys_id is drawn from rng.
+func placeholderRecord(rng *rand.Rand, table string, failure *RecordError) (interface{}, error) {
+	recordType, err := TableRecordType(table)
+	if err != nil {
+		return nil, err
+	}
+	record := reflect.New(recordType)
+	fields := map[string]string{
+		"SysID":            models.NewSysID(rng),
+		"Number":           failure.Number,
+		"ShortDescription": fmt.Sprintf("Error generating %s record", table),
+		"Description":      fmt.Sprintf("Error: %v", failure.Err),
+	}
+	for name, value := range fields {
+		if field := record.Elem().FieldByName(name); field.IsValid() && field.Kind() == reflect.String {
+			field.SetString(value)
+		}
+	}
+	return record.Interface(), nil
+}
@@ -0,0 +1,213 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/llm"
+)
+
+// failIncidents makes incident generation fail for the given record numbers, failing
+// each number only on its first attempts when times is positive
+func failIncidents(t *testing.T, times int, numbers ...string) {
+	t.Helper()
+	incidents, _ := lookupTable("incident")
+	t.Cleanup(func() { replaceTableGenerator(incidents) })
+
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+	replaceTableGenerator(FuncTable{
+		TableName: "incident",
+		Record:    IncidentRecord{},
+		GenerateFunc: func(ctx context.Context, rng *rand.Rand, req Request) (interface{}, error) {
+			mu.Lock()
+			attempts[req.Number]++
+			attempt := attempts[req.Number]
+			mu.Unlock()
+			for _, failing := range numbers {
+				if req.Number == failing && (times <= 0 || attempt <= times) {
+					if req.Number == "INC0010004" {
+						panic("no caller")
+					}
+					return nil, fmt.Errorf("no caller for %s", req.Number)
+				}
+			}
+			return incidents.Generate(ctx, rng, req)
+		},
+	})
+}
+
+func TestParseErrorPolicy(t *testing.T) {
+	valid := map[string]ErrorPolicy{
+		"":            {Action: OnErrorFailFast},
+		"fail-fast":   {Action: OnErrorFailFast},
+		"skip":        {Action: OnErrorSkip},
+		"placeholder": {Action: OnErrorPlaceholder},
+		"retry":       {Action: OnErrorRetry, Retries: 3},
+		"retry 5":     {Action: OnErrorRetry, Retries: 5},
+		"retry=2":     {Action: OnErrorRetry, Retries: 2},
+	}
+	for value, expected := range valid {
+		policy, err := ParseErrorPolicy(value)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", value, err)
+		} else if policy != expected {
+			t.Errorf("Expected %+v for %q, got %+v", expected, value, policy)
+		}
+	}
+
+	for _, value := range []string{"ignore", "retry 0", "retry x", "skip 2", "retry 1 2"} {
+		if _, err := ParseErrorPolicy(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+func TestErrorPolicyFailFast(t *testing.T) {
+	failIncidents(t, 0, "INC0010002", "INC0010004")
+	bg := NewBulkGenerator(Config{TableName: "incident", Workers: 1})
+
+	records, err := bg.GenerateBatch(context.Background(), 5)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Expected a batch error, got %v", err)
+	}
+	if records != nil {
+		t.Errorf("Expected no records from a failed batch, got %d", len(records))
+	}
+	// One worker stops at the first failure
+	if len(batchErr.Errors) != 1 || batchErr.Errors[0].Index != 1 || batchErr.Errors[0].Number != "INC0010002" {
+		t.Errorf("Expected record 1 to fail, got %v", batchErr)
+	}
+	if !strings.Contains(err.Error(), "no caller for INC0010002") {
+		t.Errorf("Expected the cause in the error, got %v", err)
+	}
+}
+
+func TestErrorPolicySkip(t *testing.T) {
+	failIncidents(t, 0, "INC0010002", "INC0010004")
+	bg := NewBulkGenerator(Config{TableName: "incident", Workers: 2, ErrorPolicy: ErrorPolicy{Action: OnErrorSkip}})
+
+	batches, stop := bg.Batches(context.Background(), 6, 3)
+	defer stop()
+	var numbers []string
+	var failed []int
+	for batch := range batches {
+		if batch.Err != nil {
+			t.Fatalf("Unexpected error: %v", batch.Err)
+		}
+		for _, record := range batch.Records {
+			numbers = append(numbers, record.(*IncidentRecord).Number)
+		}
+		for _, failure := range batch.Failures {
+			failed = append(failed, failure.Index)
+		}
+	}
+
+	if strings.Join(numbers, ",") != "INC0010001,INC0010003,INC0010005,INC0010006" {
+		t.Errorf("Expected the failed records to be skipped, got %v", numbers)
+	}
+	// Failures are indexed by their position in the run; a panic counts as a failure
+	if fmt.Sprint(failed) != "[1 3]" {
+		t.Errorf("Expected records 1 and 3 to fail, got %v", failed)
+	}
+}
+
+func TestErrorPolicyRetry(t *testing.T) {
+	failIncidents(t, 2, "INC0010002")
+
+	bg := NewBulkGenerator(Config{TableName: "incident", ErrorPolicy: ErrorPolicy{Action: OnErrorRetry, Retries: 2}})
+	records, err := bg.GenerateBatch(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(records) != 3 || records[1].(*IncidentRecord).Number != "INC0010002" {
+		t.Errorf("Expected the record to succeed on its third attempt, got %v", records)
+	}
+
+	failIncidents(t, 0, "INC0010005")
+	_, err = bg.GenerateBatch(context.Background(), 3)
+	var recordErr *RecordError
+	if !errors.As(err, &recordErr) || recordErr.Attempts != 3 {
+		t.Errorf("Expected the record to fail after 3 attempts, got %v", err)
+	}
+}
+
+func TestErrorPolicyPlaceholder(t *testing.T) {
+	failIncidents(t, 0, "INC0010002")
+	bg := NewBulkGenerator(Config{TableName: "incident", ErrorPolicy: ErrorPolicy{Action: OnErrorPlaceholder}})
+
+	records, err := bg.GenerateBatch(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	placeholder, ok := records[1].(*IncidentRecord)
+	if !ok {
+		t.Fatalf("Expected a placeholder incident, got %T", records[1])
+	}
+	if placeholder.Number != "INC0010002" || len(placeholder.SysID) != 32 || !strings.Contains(placeholder.Description, "no caller") {
+		t.Errorf("Expected a numbered placeholder with the error, got %+v", placeholder)
+	}
+}
+
+// failingText is a text provider whose incident descriptions fail, only on the first
+// calls when times is positive
+type failingText struct {
+	*llm.OpenRouterClient
+	mu    sync.Mutex
+	calls int
+	times int
+}
+
+func (f *failingText) GenerateIncidentDescriptions(ctx context.Context, category, subcategory string) (*llm.DescriptionResponse, error) {
+	f.mu.Lock()
+	f.calls++
+	failing := f.times <= 0 || f.calls <= f.times
+	f.mu.Unlock()
+	if failing {
+		return nil, fmt.Errorf("rate limited")
+	}
+	return f.OpenRouterClient.GenerateIncidentDescriptions(ctx, category, subcategory)
+}
+
+func TestTextProviderErrors(t *testing.T) {
+	newGenerator := func(provider *failingText, policy ErrorPolicy, fallback bool) *BulkGenerator {
+		bg := NewBulkGenerator(Config{TableName: "incident", Workers: 1, ErrorPolicy: policy, TextFallback: fallback, Log: io.Discard})
+		bg.TextProvider = provider
+		return bg
+	}
+
+	// Retries ask the provider again
+	provider := &failingText{OpenRouterClient: llm.NewOpenRouterClient("", ""), times: 2}
+	records, err := newGenerator(provider, ErrorPolicy{Action: OnErrorRetry, Retries: 2}, false).GenerateBatch(context.Background(), 1)
+	if err != nil || len(records) != 1 || provider.calls != 3 {
+		t.Errorf("Expected the record to succeed on its third attempt, got %d records after %d calls and %v", len(records), provider.calls, err)
+	}
+	provider = &failingText{OpenRouterClient: llm.NewOpenRouterClient("", "")}
+	_, err = newGenerator(provider, ErrorPolicy{Action: OnErrorRetry, Retries: 2}, false).GenerateBatch(context.Background(), 1)
+	var recordErr *RecordError
+	if !errors.As(err, &recordErr) || recordErr.Attempts != 3 || !strings.Contains(err.Error(), "rate limited") {
+		t.Errorf("Expected the record to fail after 3 attempts, got %v", err)
+	}
+
+	// Skipped records are left out
+	records, err = newGenerator(provider, ErrorPolicy{Action: OnErrorSkip}, false).GenerateBatch(context.Background(), 3)
+	if err != nil || len(records) != 0 {
+		t.Errorf("Expected every record to be skipped, got %d records and %v", len(records), err)
+	}
+
+	// Template text is written only when chosen
+	records, err = newGenerator(provider, ErrorPolicy{}, true).GenerateBatch(context.Background(), 3)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("Expected template text in place of the failed text, got %d records and %v", len(records), err)
+	}
+	if incident := records[0].(*IncidentRecord); !strings.HasSuffix(incident.ShortDescription, " issue") {
+		t.Errorf("Expected a template short description, got %q", incident.ShortDescription)
+	}
+}
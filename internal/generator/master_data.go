@@ -0,0 +1,355 @@
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+// UserRecord represents a sys_user record
+type UserRecord struct {
+	SysID       string `json:"sys_id" label:"Sys ID"`
+	UserName    string `json:"user_name" label:"User ID"`
+	FirstName   string `json:"first_name" label:"First name"`
+	LastName    string `json:"last_name" label:"Last name"`
+	Email       string `json:"email" label:"Email"`
+	Title       string `json:"title" label:"Title"`
+	Department  string `json:"department" label:"Department"`
+	Location    string `json:"location" label:"Location"`
+	Manager     string `json:"manager" label:"Manager"`
+	ManagerName string `json:"manager_name" xml:"-" label:"Manager name"`
+	Phone       string `json:"phone" label:"Business phone"`
+	Active      string `json:"active" type:"boolean" label:"Active"`
+}
+
+// GroupRecord represents a sys_user_group record
+type GroupRecord struct {
+	SysID       string `json:"sys_id" label:"Sys ID"`
+	Name        string `json:"name" label:"Name"`
+	Description string `json:"description" label:"Description"`
+	Manager     string `json:"manager" label:"Manager"`
+	ManagerName string `json:"manager_name" xml:"-" label:"Manager name"`
+	Email       string `json:"email" xml:"email" label:"Group email"`
+	Type        string `json:"type" label:"Type"`
+}
+
+// GroupMemberRecord represents a sys_user_grmember record
+type GroupMemberRecord struct {
+	Group     string `json:"group" label:"Group"`
+	GroupName string `json:"group_name" xml:"-" label:"Group name"`
+	User      string `json:"user" label:"User"`
+	UserName  string `json:"user_name" xml:"-" label:"User ID"`
+}
+
+// AccountRecord represents a customer_account record
+type AccountRecord struct {
+	SysID    string `json:"sys_id" label:"Sys ID"`
+	Number   string `json:"number" label:"Number"`
+	Name     string `json:"name" label:"Name"`
+	Phone    string `json:"phone" label:"Phone"`
+	Website  string `json:"website" label:"Website"`
+	Street   string `json:"street" label:"Street"`
+	City     string `json:"city" label:"City"`
+	Country  string `json:"country" label:"Country"`
+	Customer string `json:"customer" type:"boolean" label:"Customer"`
+}
+
+// ContactRecord represents a customer_contact record
+type ContactRecord struct {
+	SysID       string `json:"sys_id" label:"Sys ID"`
+	FirstName   string `json:"first_name" label:"First name"`
+	LastName    string `json:"last_name" label:"Last name"`
+	Email       string `json:"email" label:"Email"`
+	Phone       string `json:"phone" label:"Business phone"`
+	Title       string `json:"title" label:"Title"`
+	Account     string `json:"account" label:"Account"`
+	AccountName string `json:"account_name" xml:"-" label:"Account name"`
+}
+
+// MasterData holds a self-consistent set of users, groups and customer accounts
+type MasterData struct {
+	Users        []*UserRecord
+	Groups       []*GroupRecord
+	GroupMembers []*GroupMemberRecord
+	Accounts     []*AccountRecord
+	Contacts     []*ContactRecord
+
+	// routingRules maps categories to the sys_id of the generated group handling them
+	routingRules map[string]string
+	memberships  map[[2]string]bool
+
+	// rng and fake draw the random values of the master data
+	rng  *rand.Rand
+	fake *gofakeit.Faker
+}
+
+// departments are the departments users are spread over, with the groups they staff
+var departments = []struct {
+	Name   string
+	Groups []string
+}{
+	{"IT", []string{"Service Desk", "Network", "Hardware", "Application Development", "NY DB", "Capacity Mgmt", "Change Management", "Problem Analyzers"}},
+	{"Security", []string{"Security Operations"}},
+	{"Customer Support", []string{"Customer Service", "Technical Support"}},
+	{"Sales", []string{"Account Management"}},
+	{"Human Resources", []string{"HR Services"}},
+	{"Finance", nil},
+	{"Operations", nil},
+	{"Legal", nil},
+}
+
+// userLocations are the locations users are based in
+var userLocations = []string{
+	"New York", "San Diego", "London", "Frankfurt", "Singapore", "Sydney", "Toronto", "Chicago",
+}
+
+// GenerateMasterData generates the given number of users, the groups they belong to,
+// and customer accounts with their contacts. Managers form a single hierarchy rooted
+// at the first user, and every group has at least one member.
+func (bg *BulkGenerator) GenerateMasterData(userCount int) *MasterData {
+	rng := bg.source("master_data")
+	md := &MasterData{
+		routingRules: make(map[string]string),
+		memberships:  make(map[[2]string]bool),
+		rng:          rng,
+		fake:         &gofakeit.Faker{Rand: rng},
+	}
+	userNames := make(map[string]int)
+
+	// Users: a CEO, a head per department, then staff reporting to a manager in their department
+	managers := make(map[string][]*UserRecord)
+	var staffed []string
+	for i := 0; i < userCount; i++ {
+		user := md.generateUserRecord(userNames)
+
+		switch {
+		case i == 0:
+			user.Title = "Chief Executive Officer"
+			user.Department = "Executive"
+		case i <= len(departments):
+			dept := departments[i-1].Name
+			user.Title = "Head of " + dept
+			user.Department = dept
+			user.Manager, user.ManagerName = md.Users[0].SysID, md.Users[0].displayName()
+			managers[dept] = append(managers[dept], user)
+			staffed = append(staffed, dept)
+		default:
+			dept := staffed[md.rng.Intn(len(staffed))]
+			manager := managers[dept][md.rng.Intn(len(managers[dept]))]
+			user.Department = dept
+			user.Manager, user.ManagerName = manager.SysID, manager.displayName()
+			// Roughly one in eight staff members leads a team of their own
+			if md.rng.Intn(8) == 0 {
+				managers[dept] = append(managers[dept], user)
+			}
+		}
+
+		md.Users = append(md.Users, user)
+	}
+
+	md.generateGroups(userCount, managers)
+	md.generateAccounts(userCount / 10)
+
+	return md
+}
+
+// generateUserRecord generates a user with a unique user name and email
+func (md *MasterData) generateUserRecord(userNames map[string]int) *UserRecord {
+	firstName := md.fake.FirstName()
+	lastName := md.fake.LastName()
+
+	userName := uniqueName(userNames, firstName, lastName)
+
+	return &UserRecord{
+		SysID:     models.NewSysID(md.rng),
+		UserName:  userName,
+		FirstName: firstName,
+		LastName:  lastName,
+		Email:     userName + "@example.com",
+		Title:     md.fake.JobTitle(),
+		Location:  userLocations[md.rng.Intn(len(userLocations))],
+		Phone:     md.fake.Phone(),
+		Active:    "true",
+	}
+}
+
+// generateGroups creates the assignment groups of each department, managed by the
+// department's managers and staffed by its users
+func (md *MasterData) generateGroups(userCount int, managers map[string][]*UserRecord) {
+	// Larger organisations get regional copies of every group
+	regions := []string{""}
+	if userCount >= 500 {
+		regions = []string{" - AMER", " - EMEA", " - APAC"}
+	}
+
+	defaults := models.GetReferenceData()
+	byDepartment := make(map[string][]*GroupRecord)
+
+	for _, dept := range departments {
+		if len(managers[dept.Name]) == 0 {
+			continue
+		}
+		for _, base := range dept.Groups {
+			for r, region := range regions {
+				manager := managers[dept.Name][md.rng.Intn(len(managers[dept.Name]))]
+				group := &GroupRecord{
+					SysID:       models.NewSysID(md.rng),
+					Name:        base + region,
+					Description: fmt.Sprintf("%s team in the %s department", base+region, dept.Name),
+					Manager:     manager.SysID,
+					ManagerName: manager.displayName(),
+					Email:       strings.ToLower(strings.ReplaceAll(base, " ", "-")) + strings.ToLower(strings.ReplaceAll(region, " - ", "-")) + "@example.com",
+					Type:        "itil",
+				}
+				md.Groups = append(md.Groups, group)
+				byDepartment[dept.Name] = append(byDepartment[dept.Name], group)
+				md.addMember(group, manager)
+
+				// Route categories the built-in data sends to this group to the first region's copy
+				if r == 0 {
+					for category, groupID := range defaults.RoutingRules {
+						if g := defaults.GetReferenceBySysID("sys_user_group", groupID); g != nil && g.DisplayValue == base {
+							md.routingRules[category] = group.SysID
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// Staff join one or two groups of their department
+	for _, user := range md.Users {
+		groups := byDepartment[user.Department]
+		if len(groups) == 0 {
+			continue
+		}
+		for n := md.rng.Intn(2) + 1; n > 0; n-- {
+			md.addMember(groups[md.rng.Intn(len(groups))], user)
+		}
+	}
+}
+
+// addMember adds the user to the group unless they already belong to it
+func (md *MasterData) addMember(group *GroupRecord, user *UserRecord) {
+	key := [2]string{group.SysID, user.SysID}
+	if md.memberships[key] {
+		return
+	}
+	md.memberships[key] = true
+	md.GroupMembers = append(md.GroupMembers, &GroupMemberRecord{
+		Group:     group.SysID,
+		GroupName: group.Name,
+		User:      user.SysID,
+		UserName:  user.UserName,
+	})
+}
+
+// generateAccounts creates customer accounts with one to five contacts each
+func (md *MasterData) generateAccounts(accountCount int) {
+	if accountCount < 1 {
+		accountCount = 1
+	}
+	accountNames := make(map[string]bool)
+
+	for i := 0; i < accountCount; i++ {
+		name := md.fake.Company()
+		for accountNames[name] {
+			name = md.fake.Company() + " " + md.fake.CompanySuffix()
+		}
+		accountNames[name] = true
+
+		domain := strings.ToLower(sanitizeName(name)) + ".example.com"
+		account := &AccountRecord{
+			SysID:    models.NewSysID(md.rng),
+			Number:   fmt.Sprintf("ACC%07d", i+1),
+			Name:     name,
+			Phone:    md.fake.Phone(),
+			Website:  "https://www." + domain,
+			Street:   md.fake.Street(),
+			City:     md.fake.City(),
+			Country:  md.fake.Country(),
+			Customer: "true",
+		}
+		md.Accounts = append(md.Accounts, account)
+
+		contactNames := make(map[string]int)
+		for n := md.rng.Intn(5) + 1; n > 0; n-- {
+			firstName := md.fake.FirstName()
+			lastName := md.fake.LastName()
+			local := uniqueName(contactNames, firstName, lastName)
+
+			md.Contacts = append(md.Contacts, &ContactRecord{
+				SysID:       models.NewSysID(md.rng),
+				FirstName:   firstName,
+				LastName:    lastName,
+				Email:       local + "@" + domain,
+				Phone:       md.fake.Phone(),
+				Title:       md.fake.JobTitle(),
+				Account:     account.SysID,
+				AccountName: account.Name,
+			})
+		}
+	}
+}
+
+// ReferenceData converts the master data into reference data that ticket runs can use.
+// CMDB data is taken from base, since master data does not include it.
+func (md *MasterData) ReferenceData(base *models.ReferenceData) *models.ReferenceData {
+	rd := &models.ReferenceData{
+		CmdbCiService: base.CmdbCiService,
+		CmdbCi:        base.CmdbCi,
+		CmdbRelCi:     base.CmdbRelCi,
+		RoutingRules:  md.routingRules,
+	}
+
+	for _, u := range md.Users {
+		rd.SysUser = append(rd.SysUser, models.ReferenceValue{SysID: u.SysID, DisplayValue: u.displayName()})
+	}
+	for _, g := range md.Groups {
+		rd.SysUserGroup = append(rd.SysUserGroup, models.ReferenceValue{SysID: g.SysID, DisplayValue: g.Name})
+	}
+	for _, m := range md.GroupMembers {
+		rd.SysUserGrmember = append(rd.SysUserGrmember, models.GroupMember{Group: m.Group, User: m.User})
+	}
+	for _, a := range md.Accounts {
+		rd.Account = append(rd.Account, models.ReferenceValue{SysID: a.SysID, DisplayValue: a.Name})
+	}
+	for _, c := range md.Contacts {
+		rd.Contact = append(rd.Contact, models.ReferenceValue{
+			SysID:        c.SysID,
+			DisplayValue: c.FirstName + " " + c.LastName,
+			Extra:        map[string]string{"account": c.Account},
+		})
+	}
+
+	return rd
+}
+
+// displayName returns the name ServiceNow displays for the user
+func (u *UserRecord) displayName() string {
+	return u.FirstName + " " + u.LastName
+}
+
+// uniqueName builds a first.last name, numbering repeats so every name handed out is unique
+func uniqueName(seen map[string]int, firstName, lastName string) string {
+	base := strings.ToLower(sanitizeName(firstName) + "." + sanitizeName(lastName))
+	seen[base]++
+	if seen[base] == 1 {
+		return base
+	}
+	return fmt.Sprintf("%s%d", base, seen[base])
+}
+
+// sanitizeName strips everything but letters and digits from a name
+func sanitizeName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
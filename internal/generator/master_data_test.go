@@ -0,0 +1,121 @@
+package generator
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+func TestGenerateMasterData(t *testing.T) {
+	bg := createTestBulkGenerator("master_data")
+
+	md := bg.GenerateMasterData(200)
+	if len(md.Users) != 200 {
+		t.Fatalf("Expected 200 users, got %d", len(md.Users))
+	}
+
+	userNames := make(map[string]bool)
+	emails := make(map[string]bool)
+	users := make(map[string]*UserRecord)
+	for _, u := range md.Users {
+		if userNames[u.UserName] {
+			t.Errorf("Duplicate user name %s", u.UserName)
+		}
+		if emails[u.Email] {
+			t.Errorf("Duplicate email %s", u.Email)
+		}
+		userNames[u.UserName] = true
+		emails[u.Email] = true
+		users[u.SysID] = u
+	}
+
+	// Every manager chain ends at the first user
+	for _, u := range md.Users {
+		seen := map[string]bool{}
+		current := u
+		for current.Manager != "" {
+			if seen[current.SysID] {
+				t.Fatalf("Manager cycle involving %s", u.UserName)
+			}
+			seen[current.SysID] = true
+			manager, ok := users[current.Manager]
+			if !ok {
+				t.Fatalf("User %s has unknown manager %s", current.UserName, current.Manager)
+			}
+			current = manager
+		}
+		if current != md.Users[0] {
+			t.Errorf("Manager chain of %s ends at %s, expected %s", u.UserName, current.UserName, md.Users[0].UserName)
+		}
+	}
+
+	members := make(map[string]int)
+	for _, m := range md.GroupMembers {
+		if _, ok := users[m.User]; !ok {
+			t.Errorf("Group member %s is not a generated user", m.User)
+		}
+		members[m.Group]++
+	}
+	for _, g := range md.Groups {
+		if members[g.SysID] == 0 {
+			t.Errorf("Group %s has no members", g.Name)
+		}
+	}
+
+	accounts := make(map[string]bool)
+	for _, a := range md.Accounts {
+		accounts[a.SysID] = true
+	}
+	if len(md.Contacts) < len(md.Accounts) {
+		t.Errorf("Expected at least one contact per account, got %d contacts for %d accounts", len(md.Contacts), len(md.Accounts))
+	}
+	for _, c := range md.Contacts {
+		if !accounts[c.Account] {
+			t.Errorf("Contact %s %s references unknown account %s", c.FirstName, c.LastName, c.Account)
+		}
+	}
+}
+
+func TestGenerateMasterDataSmall(t *testing.T) {
+	bg := createTestBulkGenerator("master_data")
+
+	for _, count := range []int{1, 3, 12} {
+		md := bg.GenerateMasterData(count)
+		if len(md.Users) != count {
+			t.Errorf("Expected %d users, got %d", count, len(md.Users))
+		}
+	}
+}
+
+func TestMasterDataReferenceDataDrivesTickets(t *testing.T) {
+	bg := createTestBulkGenerator("master_data")
+	md := bg.GenerateMasterData(100)
+
+	filename := filepath.Join(t.TempDir(), "reference.json")
+	if err := models.SaveReferenceData(md.ReferenceData(bg.ReferenceData), filename); err != nil {
+		t.Fatalf("Failed to save reference data: %v", err)
+	}
+	rd, err := models.LoadReferenceData(filename)
+	if err != nil {
+		t.Fatalf("Failed to load reference data: %v", err)
+	}
+
+	incidents := NewBulkGenerator(Config{TableName: "incident", ClosedPercentage: 30, ReferenceData: rd})
+	records, err := incidents.GenerateBatch(context.Background(), 20)
+	if err != nil {
+		t.Fatalf("Failed to generate batch: %v", err)
+	}
+
+	groups := make(map[string]bool)
+	for _, g := range md.Groups {
+		groups[g.Name] = true
+	}
+	for _, record := range records {
+		incident := record.(*IncidentRecord)
+		if !groups[incident.AssignmentGroup.DisplayValue] {
+			t.Errorf("Assignment group %q is not a generated group", incident.AssignmentGroup)
+		}
+	}
+}
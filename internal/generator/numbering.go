@@ -0,0 +1,167 @@
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// NumberFormat describes how the record numbers of a table are built, e.g. INC0010001
+type NumberFormat struct {
+	Prefix string
+	Start  int64
+	Digits int
+}
+
+// defaultNumberFormats mirrors the out-of-the-box ServiceNow number maintenance settings
+var defaultNumberFormats = map[string]NumberFormat{
+	"incident":          {Prefix: "INC", Start: 10001, Digits: 7},
+	"case":              {Prefix: "CS", Start: 1001, Digits: 7},
+	"hr_case":           {Prefix: "HRC", Start: 1001, Digits: 7},
+	"change_request":    {Prefix: "CHG", Start: 30001, Digits: 7},
+	"knowledge_article": {Prefix: "KB", Start: 10001, Digits: 7},
+}
+
+// Numbering hands out sequential record numbers per table. It is safe for concurrent
+// use, so numbers stay unique across goroutines, batches and split outputs.
+type Numbering struct {
+	mu      sync.Mutex
+	formats map[string]NumberFormat
+	next    map[string]int64
+}
+
+// NewNumbering creates a numbering service using the default format of each table
+func NewNumbering() *Numbering {
+	n := &Numbering{
+		formats: make(map[string]NumberFormat),
+		next:    make(map[string]int64),
+	}
+	registry.RLock()
+	defer registry.RUnlock()
+	for table, format := range defaultNumberFormats {
+		n.formats[table] = format
+		n.next[table] = format.Start
+	}
+	return n
+}
+
+// Format returns the number format of the table
+func (n *Numbering) Format(table string) NumberFormat {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.format(table)
+}
+
+// SetFormat changes the number format of the table and restarts it at the format's start
+func (n *Numbering) SetFormat(table string, format NumberFormat) error {
+	if format.Digits < 1 {
+		return fmt.Errorf("number format for %s must have at least one digit", table)
+	}
+	if format.Start < 0 {
+		return fmt.Errorf("number format for %s must start at a non-negative value", table)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.formats[table] = format
+	n.next[table] = format.Start
+	return nil
+}
+
+// ContinueFrom makes the table's numbering continue after an existing record number,
+// so imported records don't clash with those already in the instance
+func (n *Numbering) ContinueFrom(table, lastNumber string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	format := n.format(table)
+	if !strings.HasPrefix(lastNumber, format.Prefix) {
+		return fmt.Errorf("last number %q does not match the %s number format %s", lastNumber, table, format.Example())
+	}
+	last, err := strconv.ParseInt(strings.TrimPrefix(lastNumber, format.Prefix), 10, 64)
+	if err != nil {
+		return fmt.Errorf("last number %q does not match the %s number format %s", lastNumber, table, format.Example())
+	}
+
+	if last+1 > n.next[table] {
+		n.next[table] = last + 1
+	}
+	return nil
+}
+
+// Next returns the next number of the table
+func (n *Numbering) Next(table string) string {
+	return n.Reserve(table, 1)[0]
+}
+
+// Reserve returns the next count numbers of the table in order, so a batch generated
+// concurrently can number its records by position
+func (n *Numbering) Reserve(table string, count int) []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	format := n.format(table)
+	value, ok := n.next[table]
+	if !ok {
+		value = format.Start
+	}
+	n.next[table] = value + int64(count)
+
+	numbers := make([]string, count)
+	for i := range numbers {
+		numbers[i] = format.Number(value + int64(i))
+	}
+	return numbers
+}
+
+// Release gives back the last count numbers reserved for the table, so they are handed
+// out again. No numbers of the table may have been reserved since.
+func (n *Numbering) Release(table string, count int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.next[table] -= int64(count)
+}
+
+// Counters returns the next number value of each table, to save the progress of a run
+func (n *Numbering) Counters() map[string]int64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	counters := make(map[string]int64, len(n.next))
+	for table, value := range n.next {
+		counters[table] = value
+	}
+	return counters
+}
+
+// Restore sets the next number values saved by Counters, so a resumed run carries on
+// numbering where it stopped
+func (n *Numbering) Restore(counters map[string]int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for table, value := range counters {
+		n.next[table] = value
+	}
+}
+
+// format returns the table's format, falling back to an upper-case prefix of its name
+func (n *Numbering) format(table string) NumberFormat {
+	if format, ok := n.formats[table]; ok {
+		return format
+	}
+	format := NumberFormat{Prefix: strings.ToUpper(table), Start: 1, Digits: 7}
+	n.formats[table] = format
+	return format
+}
+
+// Example returns the first number the format produces
+func (f NumberFormat) Example() string {
+	return f.Number(f.Start)
+}
+
+// Number returns the record number of value
+func (f NumberFormat) Number(value int64) string {
+	return fmt.Sprintf("%s%0*d", f.Prefix, f.Digits, value)
+}
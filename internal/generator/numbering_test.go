@@ -0,0 +1,157 @@
+package generator
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestNumberingDefaults(t *testing.T) {
+	n := NewNumbering()
+
+	if got := n.Next("incident"); got != "INC0010001" {
+		t.Errorf("Expected first incident number INC0010001, got %s", got)
+	}
+	if got := n.Next("incident"); got != "INC0010002" {
+		t.Errorf("Expected second incident number INC0010002, got %s", got)
+	}
+	if got := n.Next("change_request"); got != "CHG0030001" {
+		t.Errorf("Expected first change number CHG0030001, got %s", got)
+	}
+}
+
+func TestNumberingCustomFormat(t *testing.T) {
+	n := NewNumbering()
+
+	if err := n.SetFormat("case", NumberFormat{Prefix: "CASE", Start: 5, Digits: 4}); err != nil {
+		t.Fatalf("Failed to set format: %v", err)
+	}
+	if got := n.Next("case"); got != "CASE0005" {
+		t.Errorf("Expected CASE0005, got %s", got)
+	}
+
+	if err := n.SetFormat("case", NumberFormat{Prefix: "CS", Digits: 0}); err == nil {
+		t.Error("Expected an error for a format without digits")
+	}
+}
+
+func TestNumberingReserve(t *testing.T) {
+	n := NewNumbering()
+
+	numbers := n.Reserve("hr_case", 3)
+	if !reflect.DeepEqual(numbers, []string{"HRC0001001", "HRC0001002", "HRC0001003"}) {
+		t.Errorf("Expected three consecutive numbers, got %v", numbers)
+	}
+	if got := n.Next("hr_case"); got != "HRC0001004" {
+		t.Errorf("Expected numbering to continue after the reserved numbers, got %s", got)
+	}
+}
+
+func TestNumberingRestore(t *testing.T) {
+	n := NewNumbering()
+	n.Reserve("incident", 5)
+	counters := n.Counters()
+	n.Next("incident")
+
+	restored := NewNumbering()
+	restored.Restore(counters)
+	if got := restored.Next("incident"); got != "INC0010006" {
+		t.Errorf("Expected numbering to carry on after the saved counters, got %s", got)
+	}
+	if got := restored.Next("case"); got != "CS0001001" {
+		t.Errorf("Expected other tables to keep their counters, got %s", got)
+	}
+}
+
+func TestNumberingContinueFrom(t *testing.T) {
+	n := NewNumbering()
+
+	if err := n.ContinueFrom("incident", "INC0012345"); err != nil {
+		t.Fatalf("Failed to continue numbering: %v", err)
+	}
+	if got := n.Next("incident"); got != "INC0012346" {
+		t.Errorf("Expected INC0012346, got %s", got)
+	}
+
+	// A last number below the current position must not cause repeats
+	if err := n.ContinueFrom("incident", "INC0000010"); err != nil {
+		t.Fatalf("Failed to continue numbering: %v", err)
+	}
+	if got := n.Next("incident"); got != "INC0012347" {
+		t.Errorf("Expected INC0012347, got %s", got)
+	}
+
+	for _, invalid := range []string{"CHG0012345", "INC12AB", ""} {
+		if err := n.ContinueFrom("incident", invalid); err == nil {
+			t.Errorf("Expected an error for last number %q", invalid)
+		}
+	}
+}
+
+func TestNumberingConcurrentUnique(t *testing.T) {
+	n := NewNumbering()
+
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				number := n.Next("incident")
+				mu.Lock()
+				if seen[number] {
+					t.Errorf("Duplicate number %s", number)
+				}
+				seen[number] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != 5000 {
+		t.Errorf("Expected 5000 unique numbers, got %d", len(seen))
+	}
+}
+
+func TestRecordNumbersUniqueAcrossBatches(t *testing.T) {
+	bg := createTestBulkGenerator("incident")
+
+	seen := make(map[string]bool)
+	for batch := 0; batch < 3; batch++ {
+		records, err := bg.GenerateBatch(context.Background(), 20)
+		if err != nil {
+			t.Fatalf("Failed to generate batch: %v", err)
+		}
+		for _, record := range records {
+			number := record.(*IncidentRecord).Number
+			if seen[number] {
+				t.Errorf("Duplicate incident number %s", number)
+			}
+			seen[number] = true
+		}
+	}
+}
+
+func TestGenerateBatchInOrder(t *testing.T) {
+	bg := createTestBulkGenerator("case")
+	bg.Workers = 8
+
+	var previous string
+	for batch := 0; batch < 2; batch++ {
+		records, err := bg.GenerateBatch(context.Background(), 200)
+		if err != nil {
+			t.Fatalf("Failed to generate batch: %v", err)
+		}
+		for i, record := range records {
+			number := record.(*CaseRecord).Number
+			if number <= previous {
+				t.Fatalf("Expected record %d of batch %d to follow %s, got %s", i, batch, previous, number)
+			}
+			previous = number
+		}
+	}
+}
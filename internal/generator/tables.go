@@ -0,0 +1,217 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+// TableGenerator generates the records of a table. The ticket tables are table
+// generators, and more are added with RegisterTableGenerator.
+type TableGenerator interface {
+	// Name returns the table name used on the command line
+	Name() string
+	// RecordType returns the struct type of the records, whose field tags define the
+	// columns of the table
+	RecordType() reflect.Type
+	// Generate generates the record of a request. rng is seeded for the record, so the
+	// values drawn from it repeat with the seed of the run.
+	Generate(ctx context.Context, rng *rand.Rand, req Request) (interface{}, error)
+	// Closed reports whether a record is closed, which decides its output with --split
+	Closed(record interface{}) bool
+}
+
+// Request is a record for a TableGenerator to generate
+type Request struct {
+	Index  int    // 0-based position of the record in its batch
+	Number string // number reserved for the record
+
+	// Generator holds the settings, reference data, choices and text provider of the run
+	Generator *BulkGenerator
+}
+
+// Numbered is implemented by table generators with a number format of their own
+type Numbered interface {
+	NumberFormat() NumberFormat
+}
+
+// FuncTable is a TableGenerator made of functions
+type FuncTable struct {
+	TableName    string
+	Record       interface{} // a record, or a pointer to one, of the type generated
+	Format       NumberFormat
+	GenerateFunc func(ctx context.Context, rng *rand.Rand, req Request) (interface{}, error)
+	ClosedFunc   func(record interface{}) bool // records are never closed without it
+}
+
+func (t FuncTable) Name() string {
+	return t.TableName
+}
+
+func (t FuncTable) RecordType() reflect.Type {
+	recordType := reflect.TypeOf(t.Record)
+	for recordType != nil && recordType.Kind() == reflect.Ptr {
+		recordType = recordType.Elem()
+	}
+	return recordType
+}
+
+func (t FuncTable) Generate(ctx context.Context, rng *rand.Rand, req Request) (interface{}, error) {
+	return t.GenerateFunc(ctx, rng, req)
+}
+
+func (t FuncTable) Closed(record interface{}) bool {
+	return t.ClosedFunc != nil && t.ClosedFunc(record)
+}
+
+func (t FuncTable) NumberFormat() NumberFormat {
+	return t.Format
+}
+
+// tableGenerators are the generators of the tables generated record by record
+var tableGenerators = map[string]TableGenerator{}
+
+// registry guards the tables added with RegisterTableGenerator: generatedTables,
+// tableGenerators and defaultNumberFormats
+var registry sync.RWMutex
+
+// lookupTable returns the generator of the named table
+func lookupTable(name string) (TableGenerator, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	table, ok := tableGenerators[name]
+	return table, ok
+}
+
+func init() {
+	for _, table := range []TableGenerator{
+		FuncTable{
+			TableName: "incident",
+			Record:    IncidentRecord{},
+			GenerateFunc: func(ctx context.Context, rng *rand.Rand, req Request) (interface{}, error) {
+				return req.Generator.generateIncidentRecord(ctx, rng, req.Number)
+			},
+			ClosedFunc: func(record interface{}) bool {
+				state := record.(*IncidentRecord).IncidentState
+				return state == "Resolved" || state == "Closed"
+			},
+		},
+		FuncTable{
+			TableName: "case",
+			Record:    CaseRecord{},
+			GenerateFunc: func(ctx context.Context, rng *rand.Rand, req Request) (interface{}, error) {
+				return req.Generator.generateCaseRecord(ctx, rng, req.Number)
+			},
+			ClosedFunc: func(record interface{}) bool {
+				state := record.(*CaseRecord).State
+				return state == "Resolved" || state == "Closed"
+			},
+		},
+		FuncTable{
+			TableName: "hr_case",
+			Record:    HRCaseRecord{},
+			GenerateFunc: func(ctx context.Context, rng *rand.Rand, req Request) (interface{}, error) {
+				return req.Generator.generateHRCaseRecord(ctx, rng, req.Number)
+			},
+			ClosedFunc: func(record interface{}) bool {
+				state := record.(*HRCaseRecord).State
+				return state == "Resolved" || state == "Closed"
+			},
+		},
+		FuncTable{
+			TableName: "change_request",
+			Record:    ChangeRequestRecord{},
+			GenerateFunc: func(ctx context.Context, rng *rand.Rand, req Request) (interface{}, error) {
+				return req.Generator.generateChangeRequestRecord(ctx, rng, req.Number)
+			},
+			ClosedFunc: func(record interface{}) bool {
+				return record.(*ChangeRequestRecord).State == "Closed"
+			},
+		},
+		FuncTable{
+			TableName: "knowledge_article",
+			Record:    KnowledgeArticleRecord{},
+			GenerateFunc: func(ctx context.Context, rng *rand.Rand, req Request) (interface{}, error) {
+				return req.Generator.generateKnowledgeArticleRecord(ctx, rng, req.Number)
+			},
+			// Knowledge articles are considered closed when published
+			ClosedFunc: func(record interface{}) bool {
+				return record.(*KnowledgeArticleRecord).WorkflowState == "published"
+			},
+		},
+	} {
+		tableGenerators[table.Name()] = table
+	}
+}
+
+// RegisterTableGenerator adds the table of a table generator. The table is numbered with
+// the format of generators implementing Numbered, unless it has no digits. Tables are
+// registered before generating, typically from init.
+func RegisterTableGenerator(table TableGenerator) error {
+	if table == nil {
+		return fmt.Errorf("a table needs a generator")
+	}
+	name := table.Name()
+	recordType := table.RecordType()
+	switch {
+	case name == "":
+		return fmt.Errorf("a table needs a name")
+	case tableRecords[name] != nil:
+		return fmt.Errorf("table %s is already registered", name)
+	case recordType == nil || recordType.Kind() != reflect.Struct:
+		return fmt.Errorf("records of table %s must be structs, not %v", name, recordType)
+	}
+	if funcTable, ok := table.(FuncTable); ok && funcTable.GenerateFunc == nil {
+		return fmt.Errorf("table %s has no generator", name)
+	}
+	if _, err := models.RecordColumns(recordType, models.ReferenceDisplay); err != nil {
+		return fmt.Errorf("invalid records of table %s: %w", name, err)
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	if containsString(generatedTables, name) {
+		return fmt.Errorf("table %s is already registered", name)
+	}
+	generatedTables = append(generatedTables, name)
+	tableGenerators[name] = table
+	if numbered, ok := table.(Numbered); ok && numbered.NumberFormat().Digits > 0 {
+		defaultNumberFormats[name] = numbered.NumberFormat()
+	}
+	return nil
+}
+
+// RecordClosed reports whether a record of the named table is closed or resolved.
+// Records of tables without a table generator are open.
+func RecordClosed(table string, record interface{}) bool {
+	generator, ok := lookupTable(table)
+	return ok && generator.Closed(record)
+}
+
+// ParseRules parses rules, checking the tables and fields they name against the
+// records of the generated tables
+func ParseRules(rules []models.Rule) (*models.RuleSet, error) {
+	set, err := models.ParseRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	if err := set.Check(recordTypes()); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// recordTypes returns the record types of the tables generated record by record
+func recordTypes() map[string]reflect.Type {
+	registry.RLock()
+	defer registry.RUnlock()
+	types := make(map[string]reflect.Type, len(tableGenerators))
+	for name, table := range tableGenerators {
+		types[name] = table.RecordType()
+	}
+	return types
+}
@@ -0,0 +1,208 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+type gadgetRecord struct {
+	Number string `json:"number" label:"Number"`
+	Weight int    `json:"weight" label:"Weight"`
+	State  string `json:"state" label:"State"`
+}
+
+// gadgetTable generates gadgets, implementing TableGenerator without FuncTable
+type gadgetTable struct{}
+
+func (gadgetTable) Name() string { return "u_gadget" }
+
+func (gadgetTable) RecordType() reflect.Type { return reflect.TypeOf(gadgetRecord{}) }
+
+func (gadgetTable) Generate(ctx context.Context, rng *rand.Rand, req Request) (interface{}, error) {
+	state := "Open"
+	if rng.Intn(100) < req.Generator.ClosedPercentage {
+		state = "Retired"
+	}
+	return &gadgetRecord{Number: req.Number, Weight: rng.Intn(1000), State: state}, nil
+}
+
+func (gadgetTable) Closed(record interface{}) bool {
+	return record.(*gadgetRecord).State == "Retired"
+}
+
+func (gadgetTable) NumberFormat() NumberFormat {
+	return NumberFormat{Prefix: "GDG", Start: 1, Digits: 5}
+}
+
+// unregisterTableGenerator removes a table added with RegisterTableGenerator, so tests
+// can register their tables again
+func unregisterTableGenerator(name string) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(tableGenerators, name)
+	delete(defaultNumberFormats, name)
+	for i, table := range generatedTables {
+		if table == name {
+			generatedTables = append(generatedTables[:i:i], generatedTables[i+1:]...)
+			break
+		}
+	}
+}
+
+// replaceTableGenerator replaces the generator of a registered table
+func replaceTableGenerator(table TableGenerator) {
+	registry.Lock()
+	defer registry.Unlock()
+	tableGenerators[table.Name()] = table
+}
+
+func TestRegisterTableGenerator(t *testing.T) {
+	if err := RegisterTableGenerator(gadgetTable{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { unregisterTableGenerator("u_gadget") })
+	if err := RegisterTableGenerator(gadgetTable{}); err == nil {
+		t.Error("Expected an error registering a table twice")
+	}
+	if err := RegisterTableGenerator(FuncTable{TableName: "u_broken", Record: "not a struct"}); err == nil {
+		t.Error("Expected an error for records that are not structs")
+	}
+	if err := (Config{TableName: "u_gadget"}).Validate(); err != nil {
+		t.Errorf("Expected the registered table to be valid, got %v", err)
+	}
+
+	columns, err := TableColumns("u_gadget", models.ReferenceDisplay)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(columns) != 3 || columns[1].Name != "weight" {
+		t.Errorf("Expected the columns of the gadget record, got %+v", columns)
+	}
+
+	// Values drawn from the record's source repeat with the seed, whatever the workers
+	weights := func(workers int) string {
+		bg := NewBulkGenerator(Config{TableName: "u_gadget", ClosedPercentage: 50, Seed: 7, Workers: workers})
+		records, err := bg.GenerateBatch(context.Background(), 20)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var values []string
+		closed := 0
+		for _, record := range records {
+			gadget := record.(*gadgetRecord)
+			values = append(values, fmt.Sprintf("%s=%d", gadget.Number, gadget.Weight))
+			if RecordClosed("u_gadget", record) {
+				closed++
+			}
+		}
+		if closed == 0 || closed == len(records) {
+			t.Errorf("Expected open and retired gadgets, got %d of %d retired", closed, len(records))
+		}
+		return strings.Join(values, ",")
+	}
+	one, three := weights(1), weights(3)
+	if !strings.HasPrefix(one, "GDG00001=") {
+		t.Errorf("Expected gadgets numbered with the table's format, got %s", one)
+	}
+	if one != three {
+		t.Errorf("Expected the same gadgets with 1 and 3 workers, got %s and %s", one, three)
+	}
+}
+
+func TestRecordClosed(t *testing.T) {
+	tests := []struct {
+		table  string
+		record interface{}
+		closed bool
+	}{
+		{"incident", &IncidentRecord{IncidentState: "Resolved"}, true},
+		{"incident", &IncidentRecord{IncidentState: "In Progress"}, false},
+		{"case", &CaseRecord{State: "Closed"}, true},
+		{"hr_case", &HRCaseRecord{State: "Awaiting Info"}, false},
+		{"change_request", &ChangeRequestRecord{State: "Closed"}, true},
+		{"change_request", &ChangeRequestRecord{State: "Resolved"}, false},
+		{"knowledge_article", &KnowledgeArticleRecord{WorkflowState: "published"}, true},
+		{"sys_user", &UserRecord{}, false},
+	}
+	for _, tt := range tests {
+		if closed := RecordClosed(tt.table, tt.record); closed != tt.closed {
+			t.Errorf("Expected %s record %+v closed to be %v, got %v", tt.table, tt.record, tt.closed, closed)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	if _, err := ParseRules([]models.Rule{{Table: "incident", Set: map[string]string{"hold_reason": "x"}}}); err == nil {
+		t.Error("Expected an error for a field incidents do not have")
+	}
+
+	rules, err := ParseRules([]models.Rule{
+		{When: "category == Network", Set: map[string]string{"assignment_group": "Capacity Mgmt", "short_description": "[NET] {short_description}"}},
+		{Table: "change_request", Set: map[string]string{"risk": "High"}},
+		{When: "incident_state == Closed", Require: []string{"close_notes"}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	bg := NewBulkGenerator(Config{TableName: "incident", Rules: rules, TextEngine: TextTemplate})
+	records, err := bg.GenerateBatch(context.Background(), 50)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	network := 0
+	for _, record := range records {
+		incident := record.(*IncidentRecord)
+		if incident.Category != "Network" {
+			continue
+		}
+		network++
+		if incident.AssignmentGroup.DisplayValue != "Capacity Mgmt" || incident.AssignmentGroup.SysID == "" {
+			t.Errorf("Expected network incidents to be routed to Capacity Mgmt, got %+v", incident.AssignmentGroup)
+		}
+		assertGroupMember(t, bg.ReferenceData, incident.AssignmentGroup, incident.AssignedTo)
+		if !strings.HasPrefix(incident.ShortDescription, "[NET] ") {
+			t.Errorf("Expected a computed short description, got %q", incident.ShortDescription)
+		}
+	}
+	if network == 0 {
+		t.Error("Expected network incidents")
+	}
+
+	// Rules of a table apply to its records only
+	change, err := bg.ForTable("change_request", 0).GenerateBatch(context.Background(), 5)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, record := range change {
+		if risk := record.(*ChangeRequestRecord).Risk; risk != "High" {
+			t.Errorf("Expected high risk changes, got %s", risk)
+		}
+	}
+
+	// Groups missing from the reference data are rejected before generating
+	unknown, err := ParseRules([]models.Rule{{Set: map[string]string{"assignment_group": "Facilities"}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := (Config{TableName: "incident", Rules: unknown}).Validate(); err == nil || !strings.Contains(err.Error(), "no reference data matches") {
+		t.Errorf("Expected an unknown group to be invalid, got %v", err)
+	}
+
+	// Records breaking a rule fail, and the error policy handles them
+	strict, err := ParseRules([]models.Rule{{Check: "category == Nothing"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	bg = NewBulkGenerator(Config{TableName: "incident", Rules: strict, TextEngine: TextTemplate, ErrorPolicy: ErrorPolicy{Action: OnErrorSkip}, Log: io.Discard})
+	records, err = bg.GenerateBatch(context.Background(), 5)
+	if err != nil || len(records) != 0 {
+		t.Errorf("Expected every record to be skipped, got %d records and %v", len(records), err)
+	}
+}
@@ -0,0 +1,64 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/llm"
+)
+
+// TextProvider writes the text fields of records. The OpenRouter client is the provider
+// of the llm and template engines; others are added with RegisterTextEngine.
+type TextProvider interface {
+	GenerateText(ctx context.Context, prompt string, maxLength int) (string, error)
+	GenerateIncidentDescriptions(ctx context.Context, category, subcategory string) (*llm.DescriptionResponse, error)
+	GenerateCaseDescriptions(ctx context.Context, category, subcategory, accountName, caseType string) (*llm.DescriptionResponse, error)
+	GenerateCloseNotes(ctx context.Context, shortDescription, description, closeCode string) (string, error)
+}
+
+// textEngines are the registered text providers by engine name
+var textEngines = map[string]TextProvider{}
+
+// RegisterTextEngine registers a text provider under a name Config.TextEngine can
+// select. Engines are registered before generating, typically from init.
+func RegisterTextEngine(name string, provider TextProvider) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("a text engine needs a name")
+	case provider == nil:
+		return fmt.Errorf("text engine %s has no provider", name)
+	case name == TextLLM || name == TextTemplate || textEngines[name] != nil:
+		return fmt.Errorf("text engine %s is already registered", name)
+	}
+	textEngines[name] = provider
+	return nil
+}
+
+// TextEngines returns the names of the text engines
+func TextEngines() []string {
+	names := []string{TextLLM, TextTemplate}
+	registered := make([]string, 0, len(textEngines))
+	for name := range textEngines {
+		registered = append(registered, name)
+	}
+	sort.Strings(registered)
+	return append(names, registered...)
+}
+
+// text returns the provider of the generator's text fields
+func (bg *BulkGenerator) text() TextProvider {
+	if bg.TextProvider != nil {
+		return bg.TextProvider
+	}
+	return bg.LLMClient
+}
+
+// textFailed returns the error of a text field the provider failed to write, or nil
+// when the generator falls back to template text. Cancelled runs always fail.
+func (bg *BulkGenerator) textFailed(ctx context.Context, err error) error {
+	if bg.TextFallback && ctx.Err() == nil {
+		return nil
+	}
+	return fmt.Errorf("failed to generate text: %w", err)
+}
@@ -0,0 +1,43 @@
+package models
+
+import "reflect"
+
+// choiceLists maps the values of the "choice" struct tag to the values ServiceNow stores
+// for the labels of the choice list
+var choiceLists = map[string]map[string]string{
+	"incident_state": {
+		"New": "1", "In Progress": "2", "On Hold": "3",
+		"Resolved": "6", "Closed": "7", "Canceled": "8",
+	},
+	"case_state": {
+		"New": "1", "In Progress": "10", "On Hold": "18", "Awaiting Customer": "18",
+		"Resolved": "6", "Closed": "3", "Canceled": "7",
+	},
+	"hr_state": {
+		"New": "10", "In Progress": "18", "Awaiting Info": "24",
+		"Resolved": "3", "Closed": "3",
+	},
+	"change_state": {
+		"New": "-5", "Assess": "-4", "Authorize": "-3", "Scheduled": "-2",
+		"Implement": "-1", "Review": "0", "Closed": "3", "Canceled": "4",
+	},
+	"change_risk": {
+		"Very High": "1", "High": "2", "Medium": "3", "Moderate": "3", "Low": "4",
+	},
+	"contact_type": {
+		"Email": "email", "Phone": "phone", "Self-service": "self-service",
+		"Walk-in": "walk-in", "Chat": "chat", "Automated": "automated",
+		"Virtual Agent": "virtual_agent", "Social Media": "social",
+	},
+}
+
+// StoredChoice returns the value ServiceNow stores for a label of the choice list the
+// field names in its "choice" tag, for writers that load records as stored values.
+// Fields without the tag, and labels the list does not have, such as values of custom
+// distributions, are returned as they are.
+func StoredChoice(field reflect.StructField, label string) string {
+	if value, ok := choiceLists[field.Tag.Get("choice")][label]; ok {
+		return value
+	}
+	return label
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStoredChoice(t *testing.T) {
+	type record struct {
+		State   string `json:"state" choice:"change_state"`
+		Channel string `json:"contact_type" choice:"contact_type"`
+		Notes   string `json:"notes"`
+	}
+	recordType := reflect.TypeOf(record{})
+	for _, test := range []struct {
+		field, label, want string
+	}{
+		{"State", "Implement", "-1"},
+		{"State", "-1", "-1"},
+		{"Channel", "Walk-in", "walk-in"},
+		{"Channel", "Carrier pigeon", "Carrier pigeon"},
+		{"Notes", "Closed", "Closed"},
+	} {
+		field, _ := recordType.FieldByName(test.field)
+		if got := StoredChoice(field, test.label); got != test.want {
+			t.Errorf("Expected %q for %s %q, got %q", test.want, test.field, test.label, got)
+		}
+	}
+	// Selected and renamed columns keep their choice list
+	spec, err := ParseColumnSpec("state:u_state, *")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	projection, err := spec.Projection(recordType, "run-1", time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	renamed, selected := projection.Type.Field(0), projection.Type.Field(1)
+	if StoredChoice(renamed, "Implement") != "-1" || StoredChoice(selected, "Walk-in") != "walk-in" {
+		t.Errorf("Expected the choice tags to be kept, got %q and %q", renamed.Tag, selected.Tag)
+	}
+}
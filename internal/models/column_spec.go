@@ -0,0 +1,377 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Placeholders of computed columns, besides the names of record fields
+const (
+	PlaceholderRunID = "run_id" // identifier of the generation run
+	PlaceholderRunAt = "run_at" // date/time the generation run started
+	PlaceholderRow   = "row"    // 1-based number of the record in its output
+)
+
+// ColumnSpec selects, orders and renames the columns of output and adds constant and
+// computed columns. It is parsed from a comma separated list of entries, written in
+// this order:
+//
+//   - *            every field not listed by another entry, in table order
+//   - field        a field, by its name or ServiceNow element name
+//   - field:name   a field, written under another name
+//   - name=value   a column with a constant value, in which {run_id}, {run_at}, {row}
+//     and {field} are replaced by the run, the record number or a record field
+type ColumnSpec struct {
+	entries []columnEntry
+}
+
+// columnEntry is an entry of a column spec
+type columnEntry struct {
+	field    string      // selected field, or "*" for the remaining ones
+	name     string      // output name; empty keeps the names of the field
+	template []valuePart // value of a constant or computed column
+	computed bool
+}
+
+// valuePart is a literal text or a placeholder of a column value template
+type valuePart struct {
+	text        string
+	placeholder bool
+}
+
+// ParseColumnSpec parses a column spec such as
+// "number,caller_id:Caller,short_description,u_source=datagen,u_batch_id={run_id}"
+func ParseColumnSpec(spec string) (*ColumnSpec, error) {
+	s := &ColumnSpec{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			return nil, fmt.Errorf("empty entry in column spec %q", spec)
+		}
+
+		if name, value, ok := strings.Cut(entry, "="); ok {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return nil, fmt.Errorf("column %q has no name", entry)
+			}
+			template, err := parseTemplate(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of column %s: %w", name, err)
+			}
+			s.entries = append(s.entries, columnEntry{name: name, template: template, computed: true})
+			continue
+		}
+
+		field, name, renamed := strings.Cut(entry, ":")
+		field, name = strings.TrimSpace(field), strings.TrimSpace(name)
+		if field == "" || (renamed && name == "") {
+			return nil, fmt.Errorf("invalid column %q", entry)
+		}
+		if field == "*" && renamed {
+			return nil, fmt.Errorf("* cannot be renamed")
+		}
+		s.entries = append(s.entries, columnEntry{field: field, name: name})
+	}
+	return s, nil
+}
+
+// parseTemplate splits a column value into literal text and {placeholders}. Braces
+// are written literally as {{ and }}.
+func parseTemplate(value string) ([]valuePart, error) {
+	var parts []valuePart
+	var text strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '{' && strings.HasPrefix(value[i:], "{{"), c == '}' && strings.HasPrefix(value[i:], "}}"):
+			text.WriteByte(c)
+			i++
+		case c == '{':
+			end := strings.IndexByte(value[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed { in %q", value)
+			}
+			name := strings.TrimSpace(value[i+1 : i+end])
+			if name == "" {
+				return nil, fmt.Errorf("empty placeholder in %q", value)
+			}
+			if text.Len() > 0 {
+				parts = append(parts, valuePart{text: text.String()})
+				text.Reset()
+			}
+			parts = append(parts, valuePart{text: name, placeholder: true})
+			i += end
+		case c == '}':
+			return nil, fmt.Errorf("unexpected } in %q", value)
+		default:
+			text.WriteByte(c)
+		}
+	}
+	if text.Len() > 0 {
+		parts = append(parts, valuePart{text: text.String()})
+	}
+	return parts, nil
+}
+
+// Projection converts records into records holding the columns of a column spec. The
+// projected records are structs of a type built from the spec, tagged like record
+// types, so every writer handles them as it handles the records of a table.
+type Projection struct {
+	Type       reflect.Type // type of the projected records
+	recordType reflect.Type
+	columns    []projectedColumn
+	runID      string
+	runAt      string
+}
+
+// projectedColumn is a column of a projection: a copied record field, or a constant or
+// computed value
+type projectedColumn struct {
+	field    int // index of the copied record field, or -1
+	template []valuePart
+	fields   map[string]int // record fields of the template placeholders
+}
+
+// Projection returns the projection of records of a type through the spec. Fields and
+// placeholders the record type does not have are errors, as are columns with the same
+// name.
+func (s *ColumnSpec) Projection(recordType reflect.Type, runID string, runAt time.Time) (*Projection, error) {
+	if recordType.Kind() == reflect.Ptr {
+		recordType = recordType.Elem()
+	}
+	if recordType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unsupported record type %s", recordType)
+	}
+
+	p := &Projection{recordType: recordType, runID: runID, runAt: runAt.UTC().Format("2006-01-02 15:04:05")}
+
+	selected := make(map[int]bool)
+	for _, entry := range s.entries {
+		if entry.field != "" && entry.field != "*" {
+			i, err := lookupField(recordType, entry.field)
+			if err != nil {
+				return nil, err
+			}
+			if selected[i] {
+				return nil, fmt.Errorf("field %s is selected more than once", entry.field)
+			}
+			selected[i] = true
+		}
+	}
+
+	var fields []reflect.StructField
+	names := make(map[string]bool)
+	labels := make(map[string]bool)
+	addColumn := func(name string, column projectedColumn, fieldType reflect.Type, tag string) error {
+		label := reflect.StructTag(tag).Get("label")
+		if label == "" {
+			label = defaultLabel(name)
+		}
+		if names[name] || (label != "-" && labels[label]) {
+			return fmt.Errorf("column %s appears more than once", name)
+		}
+		names[name] = true
+		labels[label] = true
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Column%d", len(fields)),
+			Type: fieldType,
+			Tag:  reflect.StructTag(tag),
+		})
+		p.columns = append(p.columns, column)
+		return nil
+	}
+
+	for _, entry := range s.entries {
+		switch {
+		case entry.computed:
+			column := projectedColumn{field: -1, template: entry.template, fields: make(map[string]int)}
+			for _, part := range entry.template {
+				if !part.placeholder {
+					continue
+				}
+				switch part.text {
+				case PlaceholderRunID, PlaceholderRunAt, PlaceholderRow:
+				default:
+					i, err := lookupField(recordType, part.text)
+					if err != nil {
+						return nil, fmt.Errorf("column %s: %w", entry.name, err)
+					}
+					column.fields[part.text] = i
+				}
+			}
+			tag := tagOf("json", entry.name) + " " + tagOf("xml", entry.name) + " " + tagOf("label", entry.name)
+			if valueType := p.templateType(entry.template); valueType != "" {
+				tag += " " + tagOf("type", valueType)
+			}
+			if err := addColumn(entry.name, column, reflect.TypeOf(""), tag); err != nil {
+				return nil, err
+			}
+		case entry.field == "*":
+			for i := 0; i < recordType.NumField(); i++ {
+				field := recordType.Field(i)
+				if selected[i] || !field.IsExported() || fieldName(field) == "-" {
+					continue
+				}
+				if err := addColumn(fieldName(field), projectedColumn{field: i}, field.Type, fieldTag(field, "")); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			i, _ := lookupField(recordType, entry.field)
+			field := recordType.Field(i)
+			name := entry.name
+			if name == "" {
+				name = fieldName(field)
+			}
+			if err := addColumn(name, projectedColumn{field: i}, field.Type, fieldTag(field, entry.name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("column spec selects no columns of %s", recordType.Name())
+	}
+
+	p.Type = reflect.StructOf(fields)
+	if _, err := RecordColumns(p.Type, ReferenceBoth); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Record returns the projection of a record, which is the row-th record of its output
+func (p *Projection) Record(record interface{}, row int) (interface{}, error) {
+	v := reflect.ValueOf(record)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Type() != p.recordType {
+		return nil, fmt.Errorf("cannot project record type %T, the projection is of %s", record, p.recordType)
+	}
+
+	projected := reflect.New(p.Type)
+	out := projected.Elem()
+	for i, column := range p.columns {
+		if column.field >= 0 {
+			out.Field(i).Set(v.Field(column.field))
+			continue
+		}
+
+		var value strings.Builder
+		for _, part := range column.template {
+			switch {
+			case !part.placeholder:
+				value.WriteString(part.text)
+			case part.text == PlaceholderRunID:
+				value.WriteString(p.runID)
+			case part.text == PlaceholderRunAt:
+				value.WriteString(p.runAt)
+			case part.text == PlaceholderRow:
+				value.WriteString(strconv.Itoa(row))
+			default:
+				value.WriteString(textValue(v.Field(column.fields[part.text]).Interface()))
+			}
+		}
+		out.Field(i).SetString(value.String())
+	}
+	return projected.Interface(), nil
+}
+
+// templateType returns the type tag of a column whose value is a single placeholder of
+// a typed value, such as {row} or {opened_at}, or "" for a string column
+func (p *Projection) templateType(template []valuePart) string {
+	if len(template) != 1 || !template[0].placeholder {
+		return ""
+	}
+	switch template[0].text {
+	case PlaceholderRow:
+		return "int"
+	case PlaceholderRunAt:
+		return "timestamp"
+	case PlaceholderRunID:
+		return ""
+	}
+	i, err := lookupField(p.recordType, template[0].text)
+	if err != nil {
+		return ""
+	}
+	valueType, err := FieldValueType(p.recordType.Field(i))
+	if err != nil {
+		return ""
+	}
+	for tag, t := range valueTypeTags {
+		if t == valueType {
+			return tag
+		}
+	}
+	return ""
+}
+
+// lookupField returns the index of the field of a record type with the given json name
+// or ServiceNow element name
+func lookupField(recordType reflect.Type, name string) (int, error) {
+	for i := 0; i < recordType.NumField(); i++ {
+		field := recordType.Field(i)
+		if !field.IsExported() || fieldName(field) == "-" {
+			continue
+		}
+		if fieldName(field) == name || strings.Split(field.Tag.Get("xml"), ",")[0] == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown field %s of %s", name, recordType.Name())
+}
+
+// fieldName returns the name of a field, taken from its json tag
+func fieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		name = field.Name
+	}
+	return name
+}
+
+// fieldTag returns the tag of a copied field. A renamed field gets the new name as its
+// json and element name and as its label; otherwise the tags are kept, except order,
+// as the spec orders the columns.
+func fieldTag(field reflect.StructField, rename string) string {
+	var tags []string
+	if rename != "" {
+		options := ""
+		if _, rest, ok := strings.Cut(field.Tag.Get("json"), ","); ok {
+			options = "," + rest
+		}
+		tags = append(tags, tagOf("json", rename+options), tagOf("xml", rename), tagOf("label", rename))
+	} else {
+		for _, key := range []string{"json", "xml", "label"} {
+			if value, ok := field.Tag.Lookup(key); ok {
+				tags = append(tags, tagOf(key, value))
+			}
+		}
+	}
+	for _, key := range []string{"type", "choice"} {
+		if value, ok := field.Tag.Lookup(key); ok {
+			tags = append(tags, tagOf(key, value))
+		}
+	}
+	return strings.Join(tags, " ")
+}
+
+// tagOf returns a struct tag entry
+func tagOf(key, value string) string {
+	return key + ":" + strconv.Quote(value)
+}
+
+// textValue returns a record value as text; references give their display value, or
+// their sys_id without one
+func textValue(value interface{}) string {
+	if ref, ok := value.(ReferenceValue); ok {
+		if ref.DisplayValue != "" {
+			return ref.DisplayValue
+		}
+		return ref.SysID
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type specRecord struct {
+	Number   string         `json:"number" label:"Number"`
+	Caller   ReferenceValue `json:"caller" xml:"caller_id" label:"Caller"`
+	Opened   string         `json:"opened" xml:"opened_at" type:"timestamp" label:"Opened"`
+	Priority int            `json:"priority,omitempty" label:"Priority" order:"1"`
+	State    string         `json:"state" label:"State"`
+}
+
+func TestParseColumnSpecInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"number,,state",
+		"number:",
+		":Number",
+		"*:all",
+		"=datagen",
+		"u_key={number",
+		"u_key=}",
+		"u_key={}",
+	} {
+		if _, err := ParseColumnSpec(spec); err == nil {
+			t.Errorf("Expected an error for column spec %q", spec)
+		}
+	}
+}
+
+func TestProjection(t *testing.T) {
+	spec, err := ParseColumnSpec("caller_id:u_caller, number, opened_at, u_source=datagen, u_batch_id={run_id}, u_key={number}-{row}, u_row={row}, u_when={opened}, u_braces={{x}}")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	runAt := time.Date(2026, 3, 1, 8, 30, 0, 0, time.UTC)
+	projection, err := spec.Projection(reflect.TypeOf(&specRecord{}), "run-1", runAt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	columns, err := RecordColumns(projection.Type, ReferenceBoth)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	labels := []string{"u_caller", "u_caller.display", "Number", "Opened", "u_source", "u_batch_id", "u_key", "u_row", "u_when", "u_braces"}
+	if got := ColumnLabels(columns); !reflect.DeepEqual(got, labels) {
+		t.Errorf("Expected labels %v, got %v", labels, got)
+	}
+	types := map[string]ValueType{"u_caller": ValueReference, "Opened": ValueTimestamp, "u_source": ValueString, "u_key": ValueString, "u_row": ValueInt, "u_when": ValueTimestamp}
+	for _, col := range columns {
+		if expected, ok := types[col.Label]; ok && col.Type != expected {
+			t.Errorf("Expected column %s to have type %v, got %v", col.Label, expected, col.Type)
+		}
+	}
+
+	renamed, _ := projection.Type.FieldByName("Column0")
+	if renamed.Tag.Get("json") != "u_caller" || renamed.Tag.Get("xml") != "u_caller" {
+		t.Errorf("Expected a renamed field to be renamed in all formats, got tag %q", renamed.Tag)
+	}
+	opened, _ := projection.Type.FieldByName("Column2")
+	if opened.Tag.Get("xml") != "opened_at" {
+		t.Errorf("Expected a selected field to keep its element name, got tag %q", opened.Tag)
+	}
+
+	record := &specRecord{
+		Number: "INC0010001",
+		Caller: ReferenceValue{SysID: "u1", DisplayValue: "Beth Anglin"},
+		Opened: "2026-02-27 10:00:00",
+	}
+	projected, err := projection.Record(record, 7)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	v := reflect.ValueOf(projected).Elem()
+	values := make([]interface{}, len(columns))
+	for i, col := range columns {
+		values[i] = col.Value(v, ReferenceBoth)
+	}
+	expected := []interface{}{"u1", "Beth Anglin", "INC0010001", "2026-02-27 10:00:00", "datagen", "run-1", "INC0010001-7", "7", "2026-02-27 10:00:00", "{x}"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected values %v, got %v", expected, values)
+	}
+
+	if _, err := projection.Record(struct{ Number string }{"INC0010001"}, 1); err == nil {
+		t.Error("Expected an error projecting a record of another type")
+	}
+}
+
+func TestProjectionAll(t *testing.T) {
+	spec, err := ParseColumnSpec("state:Status,*,u_run_at={run_at}")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	projection, err := spec.Projection(reflect.TypeOf(specRecord{}), "run-1", time.Date(2026, 3, 1, 8, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The spec orders the columns, so order tags no longer move them
+	columns, err := RecordColumns(projection.Type, ReferenceDisplay)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	labels := []string{"Status", "Number", "Caller", "Opened", "Priority", "u_run_at"}
+	if got := ColumnLabels(columns); !reflect.DeepEqual(got, labels) {
+		t.Errorf("Expected labels %v, got %v", labels, got)
+	}
+	priority, _ := projection.Type.FieldByName("Column4")
+	if priority.Tag.Get("json") != "priority,omitempty" || priority.Type.Kind() != reflect.Int {
+		t.Errorf("Expected the priority field to be copied as is, got %s %q", priority.Type, priority.Tag)
+	}
+
+	projected, err := projection.Record(specRecord{State: "New", Priority: 2}, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	v := reflect.ValueOf(projected).Elem()
+	if got := v.Field(0).Interface(); got != "New" {
+		t.Errorf("Expected the renamed state first, got %v", got)
+	}
+	if got := v.Field(4).Interface(); got != 2 {
+		t.Errorf("Expected priority 2, got %v", got)
+	}
+	if got := v.Field(5).Interface(); got != "2026-03-01 08:30:00" {
+		t.Errorf("Expected the run start time, got %v", got)
+	}
+}
+
+func TestProjectionInvalid(t *testing.T) {
+	tests := map[string]string{
+		"number,category":        "unknown field category",
+		"number,u_key={missing}": "unknown field missing",
+		"number,number":          "selected more than once",
+		"number,caller:number":   "appears more than once",
+		"*,state=Closed":         "appears more than once",
+		"caller:Number,number":   "appears more than once",
+	}
+	for spec, message := range tests {
+		s, err := ParseColumnSpec(spec)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing %q: %v", spec, err)
+		}
+		_, err = s.Projection(reflect.TypeOf(specRecord{}), "run-1", time.Now())
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing %q for %q, got %v", message, spec, err)
+		}
+	}
+}
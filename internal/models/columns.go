@@ -0,0 +1,150 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Column is a column of tabular output, derived from a field of a record type
+type Column struct {
+	Field   int       // index of the record field holding the value
+	Name    string    // field name, taken from the json tag
+	Label   string    // header label, taken from the label tag
+	Type    ValueType // type of the values
+	Display bool      // the display value column of a reference in both mode
+}
+
+// columnCache holds the columns derived for each record type and reference mode
+var columnCache sync.Map
+
+type columnKey struct {
+	recordType reflect.Type
+	mode       ReferenceMode
+}
+
+// RecordColumns returns the columns of a record type, derived from the tags of its fields:
+//
+//   - json names the column, and fields tagged json:"-" are left out
+//   - label is the header label, and fields tagged label:"-" are left out; without
+//     one the label is made from the name
+//   - order moves the column to the given 1-based position; other columns keep the
+//     position of their field
+//
+// In both mode every reference field is followed by a "<label>.display" column holding
+// its display value.
+func RecordColumns(recordType reflect.Type, mode ReferenceMode) ([]Column, error) {
+	if recordType.Kind() == reflect.Ptr {
+		recordType = recordType.Elem()
+	}
+	if recordType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unsupported record type %s", recordType)
+	}
+
+	key := columnKey{recordType, mode}
+	if columns, ok := columnCache.Load(key); ok {
+		return columns.([]Column), nil
+	}
+
+	type orderedColumn struct {
+		Column
+		order int
+		moved bool
+	}
+	var ordered []orderedColumn
+	labels := make(map[string]string)
+
+	for i := 0; i < recordType.NumField(); i++ {
+		field := recordType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		label := field.Tag.Get("label")
+		if name == "-" || label == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if label == "" {
+			label = defaultLabel(name)
+		}
+		if other, exists := labels[label]; exists {
+			return nil, fmt.Errorf("fields %s and %s of %s have the same label %q", other, field.Name, recordType, label)
+		}
+		labels[label] = field.Name
+
+		valueType, err := FieldValueType(field)
+		if err != nil {
+			return nil, err
+		}
+		order := len(ordered) + 1
+		tag, moved := field.Tag.Lookup("order")
+		if moved {
+			if order, err = strconv.Atoi(tag); err != nil || order < 1 {
+				return nil, fmt.Errorf("invalid order %q on field %s", tag, field.Name)
+			}
+		}
+		ordered = append(ordered, orderedColumn{Column{Field: i, Name: name, Label: label, Type: valueType}, order, moved})
+	}
+
+	// Columns moved by an order tag go before the column already at that position
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].order != ordered[j].order {
+			return ordered[i].order < ordered[j].order
+		}
+		return ordered[i].moved && !ordered[j].moved
+	})
+
+	var columns []Column
+	for _, col := range ordered {
+		columns = append(columns, col.Column)
+		if col.Type == ValueReference && mode == ReferenceBoth {
+			display := col.Column
+			display.Name += ".display"
+			display.Label += ".display"
+			display.Display = true
+			columns = append(columns, display)
+		}
+	}
+
+	columnCache.Store(key, columns)
+	return columns, nil
+}
+
+// ColumnLabels returns the header labels of columns
+func ColumnLabels(columns []Column) []string {
+	labels := make([]string, len(columns))
+	for i, col := range columns {
+		labels[i] = col.Label
+	}
+	return labels
+}
+
+// Value returns the value of the column in a record, which must be a struct of the type
+// the column was derived from. References give the value of the reference mode.
+func (c Column) Value(record reflect.Value, mode ReferenceMode) interface{} {
+	value := record.Field(c.Field).Interface()
+	if ref, ok := value.(ReferenceValue); ok {
+		columns := ref.Columns(mode)
+		if c.Display {
+			return columns[1]
+		}
+		return columns[0]
+	}
+	return value
+}
+
+// defaultLabel makes a label from a field name, such as "Short description" from
+// "short_description"
+func defaultLabel(name string) string {
+	label := strings.ReplaceAll(name, "_", " ")
+	if label == "" {
+		return label
+	}
+	return strings.ToUpper(label[:1]) + label[1:]
+}
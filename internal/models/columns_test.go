@@ -0,0 +1,86 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRecordColumns(t *testing.T) {
+	type record struct {
+		Number   string         `json:"number"`
+		Caller   ReferenceValue `json:"caller" label:"Caller"`
+		Opened   string         `json:"opened_at,omitempty" type:"timestamp" label:"Opened"`
+		Internal string         `json:"internal" label:"-"`
+		Skipped  string         `json:"-"`
+		State    string         `json:"state" label:"State"`
+		hidden   string
+	}
+
+	columns, err := RecordColumns(reflect.TypeOf(&record{}), ReferenceSysID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []Column{
+		{Field: 0, Name: "number", Label: "Number", Type: ValueString},
+		{Field: 1, Name: "caller", Label: "Caller", Type: ValueReference},
+		{Field: 2, Name: "opened_at", Label: "Opened", Type: ValueTimestamp},
+		{Field: 5, Name: "state", Label: "State", Type: ValueString},
+	}
+	if !reflect.DeepEqual(columns, expected) {
+		t.Errorf("Expected columns %+v, got %+v", expected, columns)
+	}
+
+	columns, err = RecordColumns(reflect.TypeOf(record{}), ReferenceBoth)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	labels := []string{"Number", "Caller", "Caller.display", "Opened", "State"}
+	if got := ColumnLabels(columns); !reflect.DeepEqual(got, labels) {
+		t.Errorf("Expected labels %v in both mode, got %v", labels, got)
+	}
+
+	v := reflect.ValueOf(record{Caller: ReferenceValue{SysID: "u1", DisplayValue: "Beth Anglin"}})
+	if got := columns[1].Value(v, ReferenceBoth); got != "u1" {
+		t.Errorf("Expected the sys_id in the reference column, got %v", got)
+	}
+	if got := columns[2].Value(v, ReferenceBoth); got != "Beth Anglin" {
+		t.Errorf("Expected the display value in the display column, got %v", got)
+	}
+}
+
+func TestRecordColumnsOrder(t *testing.T) {
+	type record struct {
+		A string `json:"a"`
+		B string `json:"b"`
+		C string `json:"c" order:"1"`
+		D string `json:"d"`
+	}
+
+	columns, err := RecordColumns(reflect.TypeOf(record{}), ReferenceDisplay)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var names []string
+	for _, col := range columns {
+		names = append(names, col.Name)
+	}
+	if expected := []string{"c", "a", "b", "d"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected column order %v, got %v", expected, names)
+	}
+}
+
+func TestRecordColumnsInvalid(t *testing.T) {
+	type duplicate struct {
+		Opened string `label:"Opened"`
+		Closed string `label:"Opened"`
+	}
+	type badOrder struct {
+		Number string `order:"first"`
+	}
+
+	for _, recordType := range []reflect.Type{reflect.TypeOf(duplicate{}), reflect.TypeOf(badOrder{}), reflect.TypeOf("")} {
+		if _, err := RecordColumns(recordType, ReferenceDisplay); err == nil {
+			t.Errorf("Expected an error for %s", recordType)
+		}
+	}
+}
@@ -1,247 +1,652 @@
-package models
-
-import (
-	"math/rand"
-	"time"
-)
-
-// ReferenceValue represents a reference value with sys_id and display_value
-type ReferenceValue struct {
-	SysID        string `json:"sys_id"`
-	DisplayValue string `json:"display_value"`
-	Extra        map[string]string `json:"extra,omitempty"`
-}
-
-// ChoiceValue represents a choice value with numeric value and display text
-type ChoiceValue struct {
-	Value   int    `json:"value"`
-	Display string `json:"display"`
-}
-
-// ReferenceData contains all the hard-coded reference data
-type ReferenceData struct {
-	SysUserGroup    []ReferenceValue
-	Account         []ReferenceValue
-	Contact         []ReferenceValue
-	SysUser         []ReferenceValue
-	CmdbCiService   []ReferenceValue
-	CmdbCi          []ReferenceValue
-}
-
-// ChoiceValues contains all the hard-coded choice values
-type ChoiceValues struct {
-	Category        []string
-	CaseCategory    []string
-	Subcategory     map[string][]string
-	CaseSubcategory map[string][]string
-	CloseCode       []string
-	CaseCloseCode   []string
-	ContactType     []string
-	State           []ChoiceValue
-	CaseState       []ChoiceValue
-	CaseType        []string
-	Impact          []ChoiceValue
-	Urgency         []ChoiceValue
-}
-
-// GetReferenceData returns the hard-coded reference data
-func GetReferenceData() *ReferenceData {
-	return &ReferenceData{
-		SysUserGroup: []ReferenceValue{
-			{SysID: "8a5055c9c61122780043563ef53438e3", DisplayValue: "Hardware"},
-			{SysID: "3ccb62b67fb30210674d91fadc8665c2", DisplayValue: "MFA Exempted User Group"},
-			{SysID: "0c4e7b573b331300ad3cc9bb34efc461", DisplayValue: "Problem Analyzers"},
-			{SysID: "5f74727dc0a8010e01efe33a251993f9", DisplayValue: "NY DB"},
-			{SysID: "a715cd759f2002002920bde8132e7018", DisplayValue: "Change Management"},
-			{SysID: "36c741fa731313005754660c4cf6a70d", DisplayValue: "Openspace"},
-			{SysID: "0a52d3dcd7011200f2d224837e6103f2", DisplayValue: "Application Development"},
-			{SysID: "aaccc971c0a8001500fe1ff4302de101", DisplayValue: "Capacity Mgmt"},
-			{SysID: "287ebd7da9fe198100f92cc8d1d2154e", DisplayValue: "Network"},
-			{SysID: "aacb62e2c0a80015007f67f752c2b12c", DisplayValue: "Project Mgmt"},
-			{SysID: "b1ff30ac0a0a0b2c00aad0c66d673aa8", DisplayValue: "Customer Service"},
-			{SysID: "b2ff30ac0a0a0b2c00aad0c66d673aa9", DisplayValue: "Technical Support"},
-			{SysID: "b3ff30ac0a0a0b2c00aad0c66d673aa0", DisplayValue: "Account Management"},
-		},
-		Account: []ReferenceValue{
-			{SysID: "c1c1c1c1c0a8016400b98a06818d5c11", DisplayValue: "Acme Corporation"},
-			{SysID: "c2c2c2c2c0a8016400b98a06818d5c22", DisplayValue: "Globex Industries"},
-			{SysID: "c3c3c3c3c0a8016400b98a06818d5c33", DisplayValue: "Initech Technologies"},
-			{SysID: "c4c4c4c4c0a8016400b98a06818d5c44", DisplayValue: "Umbrella Corporation"},
-			{SysID: "c5c5c5c5c0a8016400b98a06818d5c55", DisplayValue: "Stark Industries"},
-			{SysID: "c6c6c6c6c0a8016400b98a06818d5c66", DisplayValue: "Wayne Enterprises"},
-			{SysID: "c7c7c7c7c0a8016400b98a06818d5c77", DisplayValue: "Cyberdyne Systems"},
-			{SysID: "c8c8c8c8c0a8016400b98a06818d5c88", DisplayValue: "Massive Dynamic"},
-			{SysID: "c9c9c9c9c0a8016400b98a06818d5c99", DisplayValue: "Soylent Corp"},
-			{SysID: "c0c0c0c0c0a8016400b98a06818d5c00", DisplayValue: "Weyland-Yutani Corp"},
-		},
-		Contact: []ReferenceValue{
-			{SysID: "d1d1d1d1c0a8016400b98a06818d5d11", DisplayValue: "John Smith", Extra: map[string]string{"account": "c1c1c1c1c0a8016400b98a06818d5c11"}},
-			{SysID: "d2d2d2d2c0a8016400b98a06818d5d22", DisplayValue: "Jane Doe", Extra: map[string]string{"account": "c1c1c1c1c0a8016400b98a06818d5c11"}},
-			{SysID: "d3d3d3d3c0a8016400b98a06818d5d33", DisplayValue: "Robert Johnson", Extra: map[string]string{"account": "c2c2c2c2c0a8016400b98a06818d5c22"}},
-			{SysID: "d4d4d4d4c0a8016400b98a06818d5d44", DisplayValue: "Emily Williams", Extra: map[string]string{"account": "c2c2c2c2c0a8016400b98a06818d5c22"}},
-			{SysID: "d5d5d5d5c0a8016400b98a06818d5d55", DisplayValue: "Michael Brown", Extra: map[string]string{"account": "c3c3c3c3c0a8016400b98a06818d5c33"}},
-			{SysID: "d6d6d6d6c0a8016400b98a06818d5d66", DisplayValue: "Sarah Miller", Extra: map[string]string{"account": "c4c4c4c4c0a8016400b98a06818d5c44"}},
-			{SysID: "d7d7d7d7c0a8016400b98a06818d5d77", DisplayValue: "David Wilson", Extra: map[string]string{"account": "c5c5c5c5c0a8016400b98a06818d5c55"}},
-			{SysID: "d8d8d8d8c0a8016400b98a06818d5d88", DisplayValue: "Jennifer Taylor", Extra: map[string]string{"account": "c6c6c6c6c0a8016400b98a06818d5c66"}},
-			{SysID: "d9d9d9d9c0a8016400b98a06818d5d99", DisplayValue: "Thomas Anderson", Extra: map[string]string{"account": "c7c7c7c7c0a8016400b98a06818d5c77"}},
-			{SysID: "d0d0d0d0c0a8016400b98a06818d5d00", DisplayValue: "Lisa Martinez", Extra: map[string]string{"account": "c8c8c8c8c0a8016400b98a06818d5c88"}},
-		},
-		SysUser: []ReferenceValue{
-			{SysID: "5137153cc611227c000bbd1bd8cd2005", DisplayValue: "Fred Luddy"},
-			{SysID: "f8588956937002002dcef157b67ffb98", DisplayValue: "Change Manager"},
-			{SysID: "5137153cc611227c000bbd1bd8cd2007", DisplayValue: "David Loo"},
-			{SysID: "1832fbe1d701120035ae23c7ce610369", DisplayValue: "Manifah Masood"},
-			{SysID: "62526fa1d701120035ae23c7ce6103c6", DisplayValue: "Guillermo Frohlich"},
-			{SysID: "f298d2d2c611227b0106c6be7f154bc8", DisplayValue: "Bow Ruggeri"},
-			{SysID: "38cb3f173b331300ad3cc9bb34efc4d6", DisplayValue: "Problem Coordinator B"},
-			{SysID: "73ab3f173b331300ad3cc9bb34efc4df", DisplayValue: "Problem Coordinator A"},
-			{SysID: "7e3bbb173b331300ad3cc9bb34efc4a8", DisplayValue: "Problem Task Analyst A"},
-			{SysID: "681b365ec0a80164000fb0b05854a0cd", DisplayValue: "ITIL User"},
-		},
-		CmdbCiService: []ReferenceValue{
-			{SysID: "451047c6c0a8016400de0ae6df9b9d76", DisplayValue: "Bond Trading"},
-			{SysID: "26e540d80a0a0bb400660482030d04d8", DisplayValue: "SAP Payroll"},
-			{SysID: "d278f28f933a31003b4bb095e57ffb8a", DisplayValue: "Jobvite Enterprise Recruitment Services"},
-			{SysID: "2fd0eab90a0a0bb40061cf732d32967c", DisplayValue: "PeopleSoft Governance"},
-			{SysID: "26e46e5b0a0a0bb4005d1146846c429c", DisplayValue: "SAP Controlling"},
-		},
-		CmdbCi: []ReferenceValue{
-			{SysID: "3a6b9e16c0a8ce0100e154dd7e6353c2", DisplayValue: "SAP LoadBal01"},
-			{SysID: "3a27f1520a0a0bb400ecd6ff7afcf036", DisplayValue: "PS Apache02"},
-			{SysID: "55c3578bc0a8010e0117f727897d0011", DisplayValue: "bond_trade_ny"},
-		},
-	}
-}
-
-// GetChoiceValues returns the hard-coded choice values
-func GetChoiceValues() *ChoiceValues {
-	return &ChoiceValues{
-		Category:     []string{"Network", "Hardware", "Software", "Database", "Security", "Email", "Telephony", "Authentication", "Storage", "Web"},
-		CaseCategory: []string{"Account", "Billing", "Product", "Service", "Technical", "Order", "Shipping", "Returns", "Warranty", "General"},
-		Subcategory: map[string][]string{
-			"Network":  {"Connectivity", "VPN", "Wireless", "DNS", "DHCP"},
-			"Hardware": {"Desktop", "Laptop", "Printer", "Mobile Device", "Server"},
-			"Software": {"Operating System", "Application", "Update", "License", "Installation"},
-			"Database": {"Performance", "Backup", "Recovery", "Query", "Permissions"},
-			"Security": {"Access", "Virus", "Firewall", "Encryption", "Policy"},
-		},
-		CaseSubcategory: map[string][]string{
-			"Account":   {"Access", "Creation", "Modification", "Deletion", "Permissions"},
-			"Billing":   {"Invoice", "Payment", "Refund", "Subscription", "Pricing"},
-			"Product":   {"Defect", "Feature Request", "Documentation", "Compatibility", "Installation"},
-			"Service":   {"Availability", "Quality", "Modification", "Cancellation", "Upgrade"},
-			"Technical": {"Error", "Performance", "Configuration", "Integration", "Security"},
-		},
-		CloseCode: []string{
-			"Known error", "Resolved by problem", "User error", "No resolution provided",
-			"Resolved by request", "Resolved by caller", "Solution provided", "Duplicate",
-		},
-		CaseCloseCode: []string{
-			"Solved (Permanently)", "Solved (Work Around)", "Solved (Knowledge Article)",
-			"Not Solved (Not Reproducible)", "Not Solved (Too Costly)", "Not Solved (Not Supported)",
-		},
-		ContactType: []string{"Email", "Phone", "Self-service", "Walk-in", "Chat", "Automated", "Virtual Agent", "Social Media"},
-		State: []ChoiceValue{
-			{Value: 1, Display: "New"},
-			{Value: 2, Display: "In Progress"},
-			{Value: 3, Display: "On Hold"},
-			{Value: 4, Display: "Resolved"},
-			{Value: 5, Display: "Closed"},
-			{Value: 6, Display: "Canceled"},
-		},
-		CaseState: []ChoiceValue{
-			{Value: 1, Display: "New"},
-			{Value: 2, Display: "In Progress"},
-			{Value: 3, Display: "On Hold"},
-			{Value: 4, Display: "Awaiting Customer"},
-			{Value: 5, Display: "Resolved"},
-			{Value: 6, Display: "Closed"},
-			{Value: 7, Display: "Canceled"},
-		},
-		CaseType: []string{"Question", "Issue", "Feature Request", "Complaint", "Compliment", "Service Request", "Order", "Return"},
-		Impact: []ChoiceValue{
-			{Value: 1, Display: "High"},
-			{Value: 2, Display: "Medium"},
-			{Value: 3, Display: "Low"},
-		},
-		Urgency: []ChoiceValue{
-			{Value: 1, Display: "High"},
-			{Value: 2, Display: "Medium"},
-			{Value: 3, Display: "Low"},
-		},
-	}
-}
-
-// GetRandomReference returns a random reference value from the specified table
-func (rd *ReferenceData) GetRandomReference(table string) *ReferenceValue {
-	rand.Seed(time.Now().UnixNano())
-	
-	switch table {
-	case "sys_user_group":
-		return &rd.SysUserGroup[rand.Intn(len(rd.SysUserGroup))]
-	case "account":
-		return &rd.Account[rand.Intn(len(rd.Account))]
-	case "contact":
-		return &rd.Contact[rand.Intn(len(rd.Contact))]
-	case "sys_user":
-		return &rd.SysUser[rand.Intn(len(rd.SysUser))]
-	case "cmdb_ci_service":
-		return &rd.CmdbCiService[rand.Intn(len(rd.CmdbCiService))]
-	case "cmdb_ci":
-		return &rd.CmdbCi[rand.Intn(len(rd.CmdbCi))]
-	default:
-		return &ReferenceValue{SysID: "unknown", DisplayValue: "Unknown"}
-	}
-}
-
-// GetRandomChoice returns a random choice value from the specified field
-func (cv *ChoiceValues) GetRandomChoice(field string) interface{} {
-	rand.Seed(time.Now().UnixNano())
-	
-	switch field {
-	case "category":
-		return cv.Category[rand.Intn(len(cv.Category))]
-	case "case_category":
-		return cv.CaseCategory[rand.Intn(len(cv.CaseCategory))]
-	case "close_code":
-		return cv.CloseCode[rand.Intn(len(cv.CloseCode))]
-	case "case_close_code":
-		return cv.CaseCloseCode[rand.Intn(len(cv.CaseCloseCode))]
-	case "contact_type":
-		return cv.ContactType[rand.Intn(len(cv.ContactType))]
-	case "state":
-		return &cv.State[rand.Intn(len(cv.State))]
-	case "case_state":
-		return &cv.CaseState[rand.Intn(len(cv.CaseState))]
-	case "case_type":
-		return cv.CaseType[rand.Intn(len(cv.CaseType))]
-	case "impact":
-		return &cv.Impact[rand.Intn(len(cv.Impact))]
-	case "urgency":
-		return &cv.Urgency[rand.Intn(len(cv.Urgency))]
-	default:
-		return "Unknown"
-	}
-}
-
-// GetRandomSubcategory returns a random subcategory based on the category
-func (cv *ChoiceValues) GetRandomSubcategory(category string) string {
-	rand.Seed(time.Now().UnixNano())
-	
-	subcategories, exists := cv.Subcategory[category]
-	if !exists || len(subcategories) == 0 {
-		return ""
-	}
-	return subcategories[rand.Intn(len(subcategories))]
-}
-
-// GetRandomCaseSubcategory returns a random case subcategory based on the category
-func (cv *ChoiceValues) GetRandomCaseSubcategory(category string) string {
-	rand.Seed(time.Now().UnixNano())
-	
-	subcategories, exists := cv.CaseSubcategory[category]
-	if !exists || len(subcategories) == 0 {
-		return ""
-	}
-	return subcategories[rand.Intn(len(subcategories))]
-}
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ReferenceValue represents a reference value with sys_id and display_value
+type ReferenceValue struct {
+	SysID        string            `json:"sys_id"`
+	DisplayValue string            `json:"display_value"`
+	Extra        map[string]string `json:"extra,omitempty"`
+}
+
+// String returns the display value, which is how references are written by default
+func (r ReferenceValue) String() string {
+	return r.DisplayValue
+}
+
+// ElementName returns the ServiceNow element name of a struct field: its xml tag, or its
+// json tag when it has none. Fields tagged "-" get an empty name.
+func ElementName(field reflect.StructField) string {
+	tag, ok := field.Tag.Lookup("xml")
+	if !ok {
+		tag = field.Tag.Get("json")
+	}
+	name := strings.Split(tag, ",")[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// ReferenceMode selects how reference fields are written to output files
+type ReferenceMode string
+
+// Reference modes
+const (
+	ReferenceDisplay ReferenceMode = "display"
+	ReferenceSysID   ReferenceMode = "sys_id"
+	ReferenceBoth    ReferenceMode = "both"
+)
+
+// ParseReferenceMode parses a reference mode, defaulting to display values
+func ParseReferenceMode(mode string) (ReferenceMode, error) {
+	switch ReferenceMode(mode) {
+	case "", ReferenceDisplay:
+		return ReferenceDisplay, nil
+	case ReferenceSysID, ReferenceBoth:
+		return ReferenceMode(mode), nil
+	default:
+		return "", fmt.Errorf("invalid reference mode %q (expected display, sys_id or both)", mode)
+	}
+}
+
+// Columns returns the values written for the reference in the given mode. In both mode
+// the sys_id comes first, followed by the display value.
+func (r ReferenceValue) Columns(mode ReferenceMode) []string {
+	switch mode {
+	case ReferenceSysID:
+		return []string{r.SysID}
+	case ReferenceBoth:
+		return []string{r.SysID, r.DisplayValue}
+	default:
+		return []string{r.DisplayValue}
+	}
+}
+
+// ChoiceValue represents a choice value with numeric value and display text
+type ChoiceValue struct {
+	Value   int    `json:"value"`
+	Display string `json:"display"`
+}
+
+// GroupMember represents a sys_user_grmember record linking a user to a group
+type GroupMember struct {
+	Group string `json:"group"`
+	User  string `json:"user"`
+}
+
+// ReferenceData contains all the hard-coded reference data
+type ReferenceData struct {
+	SysUserGroup    []ReferenceValue `json:"sys_user_group"`
+	Account         []ReferenceValue `json:"customer_account"`
+	Contact         []ReferenceValue `json:"customer_contact"`
+	SysUser         []ReferenceValue `json:"sys_user"`
+	CmdbCiService   []ReferenceValue `json:"cmdb_ci_service"`
+	CmdbCi          []ReferenceValue `json:"cmdb_ci"`
+	CmdbRelCi       []CIRelationship `json:"cmdb_rel_ci"`
+	SysUserGrmember []GroupMember    `json:"sys_user_grmember"`
+	// RoutingRules maps a category to the sys_id of the group that handles it
+	RoutingRules map[string]string `json:"routing_rules"`
+
+	// indexes are built from the tables when first used, and again by ApplyTopology, so
+	// tables changed otherwise after that are not seen by the lookups
+	indexOnce sync.Once
+	indexes   *referenceIndexes
+}
+
+// referenceIndexes are the lookups of reference data built once for a run
+type referenceIndexes struct {
+	// bySysID maps a table and sys_id to its reference value
+	bySysID map[string]map[string]*ReferenceValue
+	// children maps a CI or service to the CIs it depends on
+	children map[string][]string
+	// related maps each service to the CIs it depends on, directly or through other CIs
+	related map[string][]*ReferenceValue
+	// members maps a group to its users, and staffed lists the groups with members
+	members map[string][]*ReferenceValue
+	staffed []*ReferenceValue
+}
+
+// referenceTables are the tables of reference values by name
+func (rd *ReferenceData) referenceTables() map[string][]ReferenceValue {
+	return map[string][]ReferenceValue{
+		"sys_user_group":  rd.SysUserGroup,
+		"account":         rd.Account,
+		"contact":         rd.Contact,
+		"sys_user":        rd.SysUser,
+		"cmdb_ci_service": rd.CmdbCiService,
+		"cmdb_ci":         rd.CmdbCi,
+	}
+}
+
+// index returns the lookups of the reference data, building them on first use
+func (rd *ReferenceData) index() *referenceIndexes {
+	rd.indexOnce.Do(func() {
+		if rd.indexes == nil {
+			rd.indexes = rd.buildIndexes()
+		}
+	})
+	return rd.indexes
+}
+
+// buildIndexes builds the lookups of the reference data from its tables
+func (rd *ReferenceData) buildIndexes() *referenceIndexes {
+	idx := &referenceIndexes{
+		bySysID:  make(map[string]map[string]*ReferenceValue),
+		children: make(map[string][]string),
+		related:  make(map[string][]*ReferenceValue),
+		members:  make(map[string][]*ReferenceValue),
+	}
+	for table, values := range rd.referenceTables() {
+		bySysID := make(map[string]*ReferenceValue, len(values))
+		for i := range values {
+			// The first of values sharing a sys_id wins, as with a linear search
+			if _, exists := bySysID[values[i].SysID]; !exists {
+				bySysID[values[i].SysID] = &values[i]
+			}
+		}
+		idx.bySysID[table] = bySysID
+	}
+	for _, m := range rd.SysUserGrmember {
+		if user := idx.bySysID["sys_user"][m.User]; user != nil {
+			idx.members[m.Group] = append(idx.members[m.Group], user)
+		}
+	}
+	for i := range rd.SysUserGroup {
+		if len(idx.members[rd.SysUserGroup[i].SysID]) > 0 {
+			idx.staffed = append(idx.staffed, &rd.SysUserGroup[i])
+		}
+	}
+	for _, rel := range rd.CmdbRelCi {
+		idx.children[rel.Parent] = append(idx.children[rel.Parent], rel.Child)
+	}
+	for _, service := range rd.CmdbCiService {
+		if _, exists := idx.related[service.SysID]; !exists {
+			idx.related[service.SysID] = rd.relatedCIs(idx, service.SysID)
+		}
+	}
+	return idx
+}
+
+// ChoiceValues contains all the hard-coded choice values
+type ChoiceValues struct {
+	Category        []string
+	CaseCategory    []string
+	Subcategory     map[string][]string
+	CaseSubcategory map[string][]string
+	CloseCode       []string
+	CaseCloseCode   []string
+	ContactType     []string
+	State           []ChoiceValue
+	CaseState       []ChoiceValue
+	CaseType        []string
+	Impact          []ChoiceValue
+	Urgency         []ChoiceValue
+}
+
+// GetReferenceData returns the hard-coded reference data
+func GetReferenceData() *ReferenceData {
+	return &ReferenceData{
+		SysUserGroup: []ReferenceValue{
+			{SysID: "8a5055c9c61122780043563ef53438e3", DisplayValue: "Hardware"},
+			{SysID: "3ccb62b67fb30210674d91fadc8665c2", DisplayValue: "MFA Exempted User Group"},
+			{SysID: "0c4e7b573b331300ad3cc9bb34efc461", DisplayValue: "Problem Analyzers"},
+			{SysID: "5f74727dc0a8010e01efe33a251993f9", DisplayValue: "NY DB"},
+			{SysID: "a715cd759f2002002920bde8132e7018", DisplayValue: "Change Management"},
+			{SysID: "36c741fa731313005754660c4cf6a70d", DisplayValue: "Openspace"},
+			{SysID: "0a52d3dcd7011200f2d224837e6103f2", DisplayValue: "Application Development"},
+			{SysID: "aaccc971c0a8001500fe1ff4302de101", DisplayValue: "Capacity Mgmt"},
+			{SysID: "287ebd7da9fe198100f92cc8d1d2154e", DisplayValue: "Network"},
+			{SysID: "aacb62e2c0a80015007f67f752c2b12c", DisplayValue: "Project Mgmt"},
+			{SysID: "b1ff30ac0a0a0b2c00aad0c66d673aa8", DisplayValue: "Customer Service"},
+			{SysID: "b2ff30ac0a0a0b2c00aad0c66d673aa9", DisplayValue: "Technical Support"},
+			{SysID: "b3ff30ac0a0a0b2c00aad0c66d673aa0", DisplayValue: "Account Management"},
+			{SysID: "d625dccec0a8016700a222a0f7900d06", DisplayValue: "Service Desk"},
+			{SysID: "e4ff30ac0a0a0b2c00aad0c66d673ab1", DisplayValue: "Security Operations"},
+			{SysID: "e5ff30ac0a0a0b2c00aad0c66d673ab2", DisplayValue: "HR Services"},
+		},
+		Account: []ReferenceValue{
+			{SysID: "c1c1c1c1c0a8016400b98a06818d5c11", DisplayValue: "Acme Corporation"},
+			{SysID: "c2c2c2c2c0a8016400b98a06818d5c22", DisplayValue: "Globex Industries"},
+			{SysID: "c3c3c3c3c0a8016400b98a06818d5c33", DisplayValue: "Initech Technologies"},
+			{SysID: "c4c4c4c4c0a8016400b98a06818d5c44", DisplayValue: "Umbrella Corporation"},
+			{SysID: "c5c5c5c5c0a8016400b98a06818d5c55", DisplayValue: "Stark Industries"},
+			{SysID: "c6c6c6c6c0a8016400b98a06818d5c66", DisplayValue: "Wayne Enterprises"},
+			{SysID: "c7c7c7c7c0a8016400b98a06818d5c77", DisplayValue: "Cyberdyne Systems"},
+			{SysID: "c8c8c8c8c0a8016400b98a06818d5c88", DisplayValue: "Massive Dynamic"},
+			{SysID: "c9c9c9c9c0a8016400b98a06818d5c99", DisplayValue: "Soylent Corp"},
+			{SysID: "c0c0c0c0c0a8016400b98a06818d5c00", DisplayValue: "Weyland-Yutani Corp"},
+		},
+		Contact: []ReferenceValue{
+			{SysID: "d1d1d1d1c0a8016400b98a06818d5d11", DisplayValue: "John Smith", Extra: map[string]string{"account": "c1c1c1c1c0a8016400b98a06818d5c11"}},
+			{SysID: "d2d2d2d2c0a8016400b98a06818d5d22", DisplayValue: "Jane Doe", Extra: map[string]string{"account": "c1c1c1c1c0a8016400b98a06818d5c11"}},
+			{SysID: "d3d3d3d3c0a8016400b98a06818d5d33", DisplayValue: "Robert Johnson", Extra: map[string]string{"account": "c2c2c2c2c0a8016400b98a06818d5c22"}},
+			{SysID: "d4d4d4d4c0a8016400b98a06818d5d44", DisplayValue: "Emily Williams", Extra: map[string]string{"account": "c2c2c2c2c0a8016400b98a06818d5c22"}},
+			{SysID: "d5d5d5d5c0a8016400b98a06818d5d55", DisplayValue: "Michael Brown", Extra: map[string]string{"account": "c3c3c3c3c0a8016400b98a06818d5c33"}},
+			{SysID: "d6d6d6d6c0a8016400b98a06818d5d66", DisplayValue: "Sarah Miller", Extra: map[string]string{"account": "c4c4c4c4c0a8016400b98a06818d5c44"}},
+			{SysID: "d7d7d7d7c0a8016400b98a06818d5d77", DisplayValue: "David Wilson", Extra: map[string]string{"account": "c5c5c5c5c0a8016400b98a06818d5c55"}},
+			{SysID: "d8d8d8d8c0a8016400b98a06818d5d88", DisplayValue: "Jennifer Taylor", Extra: map[string]string{"account": "c6c6c6c6c0a8016400b98a06818d5c66"}},
+			{SysID: "d9d9d9d9c0a8016400b98a06818d5d99", DisplayValue: "Thomas Anderson", Extra: map[string]string{"account": "c7c7c7c7c0a8016400b98a06818d5c77"}},
+			{SysID: "d0d0d0d0c0a8016400b98a06818d5d00", DisplayValue: "Lisa Martinez", Extra: map[string]string{"account": "c8c8c8c8c0a8016400b98a06818d5c88"}},
+		},
+		SysUser: []ReferenceValue{
+			{SysID: "5137153cc611227c000bbd1bd8cd2005", DisplayValue: "Fred Luddy"},
+			{SysID: "f8588956937002002dcef157b67ffb98", DisplayValue: "Change Manager"},
+			{SysID: "5137153cc611227c000bbd1bd8cd2007", DisplayValue: "David Loo"},
+			{SysID: "1832fbe1d701120035ae23c7ce610369", DisplayValue: "Manifah Masood"},
+			{SysID: "62526fa1d701120035ae23c7ce6103c6", DisplayValue: "Guillermo Frohlich"},
+			{SysID: "f298d2d2c611227b0106c6be7f154bc8", DisplayValue: "Bow Ruggeri"},
+			{SysID: "38cb3f173b331300ad3cc9bb34efc4d6", DisplayValue: "Problem Coordinator B"},
+			{SysID: "73ab3f173b331300ad3cc9bb34efc4df", DisplayValue: "Problem Coordinator A"},
+			{SysID: "7e3bbb173b331300ad3cc9bb34efc4a8", DisplayValue: "Problem Task Analyst A"},
+			{SysID: "681b365ec0a80164000fb0b05854a0cd", DisplayValue: "ITIL User"},
+			{SysID: "46d44a23a9fe19810012d100cca80666", DisplayValue: "Beth Anglin"},
+			{SysID: "46c6f9efa9fe198101ddf5eed9adf6e7", DisplayValue: "Don Goodliffe"},
+			{SysID: "46b87022a9fe198101a78787e40d7547", DisplayValue: "Luke Wilson"},
+			{SysID: "5137153cc611227c000bbd1bd8cd2006", DisplayValue: "Bud Richman"},
+			{SysID: "a8f98bb0eb32010045e1a5115206fe3a", DisplayValue: "Abel Tuter"},
+		},
+		CmdbCiService: []ReferenceValue{
+			{SysID: "451047c6c0a8016400de0ae6df9b9d76", DisplayValue: "Bond Trading"},
+			{SysID: "26e540d80a0a0bb400660482030d04d8", DisplayValue: "SAP Payroll"},
+			{SysID: "d278f28f933a31003b4bb095e57ffb8a", DisplayValue: "Jobvite Enterprise Recruitment Services"},
+			{SysID: "2fd0eab90a0a0bb40061cf732d32967c", DisplayValue: "PeopleSoft Governance"},
+			{SysID: "26e46e5b0a0a0bb4005d1146846c429c", DisplayValue: "SAP Controlling"},
+		},
+		CmdbCi: []ReferenceValue{
+			{SysID: "3a6b9e16c0a8ce0100e154dd7e6353c2", DisplayValue: "SAP LoadBal01"},
+			{SysID: "3a27f1520a0a0bb400ecd6ff7afcf036", DisplayValue: "PS Apache02"},
+			{SysID: "55c3578bc0a8010e0117f727897d0011", DisplayValue: "bond_trade_ny"},
+		},
+		CmdbRelCi: []CIRelationship{
+			{Parent: "451047c6c0a8016400de0ae6df9b9d76", Child: "55c3578bc0a8010e0117f727897d0011", Type: RelDependsOn},
+			{Parent: "26e540d80a0a0bb400660482030d04d8", Child: "3a6b9e16c0a8ce0100e154dd7e6353c2", Type: RelDependsOn},
+			{Parent: "26e46e5b0a0a0bb4005d1146846c429c", Child: "3a6b9e16c0a8ce0100e154dd7e6353c2", Type: RelDependsOn},
+			{Parent: "2fd0eab90a0a0bb40061cf732d32967c", Child: "3a27f1520a0a0bb400ecd6ff7afcf036", Type: RelDependsOn},
+			{Parent: "d278f28f933a31003b4bb095e57ffb8a", Child: "3a27f1520a0a0bb400ecd6ff7afcf036", Type: RelDependsOn},
+		},
+		SysUserGrmember: []GroupMember{
+			// Hardware
+			{Group: "8a5055c9c61122780043563ef53438e3", User: "5137153cc611227c000bbd1bd8cd2007"},
+			{Group: "8a5055c9c61122780043563ef53438e3", User: "f298d2d2c611227b0106c6be7f154bc8"},
+			// MFA Exempted User Group
+			{Group: "3ccb62b67fb30210674d91fadc8665c2", User: "5137153cc611227c000bbd1bd8cd2005"},
+			// Problem Analyzers
+			{Group: "0c4e7b573b331300ad3cc9bb34efc461", User: "38cb3f173b331300ad3cc9bb34efc4d6"},
+			{Group: "0c4e7b573b331300ad3cc9bb34efc461", User: "73ab3f173b331300ad3cc9bb34efc4df"},
+			{Group: "0c4e7b573b331300ad3cc9bb34efc461", User: "7e3bbb173b331300ad3cc9bb34efc4a8"},
+			// NY DB
+			{Group: "5f74727dc0a8010e01efe33a251993f9", User: "1832fbe1d701120035ae23c7ce610369"},
+			{Group: "5f74727dc0a8010e01efe33a251993f9", User: "62526fa1d701120035ae23c7ce6103c6"},
+			// Change Management
+			{Group: "a715cd759f2002002920bde8132e7018", User: "f8588956937002002dcef157b67ffb98"},
+			{Group: "a715cd759f2002002920bde8132e7018", User: "681b365ec0a80164000fb0b05854a0cd"},
+			// Application Development
+			{Group: "0a52d3dcd7011200f2d224837e6103f2", User: "62526fa1d701120035ae23c7ce6103c6"},
+			{Group: "0a52d3dcd7011200f2d224837e6103f2", User: "f298d2d2c611227b0106c6be7f154bc8"},
+			// Capacity Mgmt
+			{Group: "aaccc971c0a8001500fe1ff4302de101", User: "5137153cc611227c000bbd1bd8cd2007"},
+			// Network
+			{Group: "287ebd7da9fe198100f92cc8d1d2154e", User: "1832fbe1d701120035ae23c7ce610369"},
+			{Group: "287ebd7da9fe198100f92cc8d1d2154e", User: "5137153cc611227c000bbd1bd8cd2006"},
+			// Project Mgmt
+			{Group: "aacb62e2c0a80015007f67f752c2b12c", User: "f8588956937002002dcef157b67ffb98"},
+			// Customer Service
+			{Group: "b1ff30ac0a0a0b2c00aad0c66d673aa8", User: "46d44a23a9fe19810012d100cca80666"},
+			{Group: "b1ff30ac0a0a0b2c00aad0c66d673aa8", User: "46b87022a9fe198101a78787e40d7547"},
+			// Technical Support
+			{Group: "b2ff30ac0a0a0b2c00aad0c66d673aa9", User: "46c6f9efa9fe198101ddf5eed9adf6e7"},
+			{Group: "b2ff30ac0a0a0b2c00aad0c66d673aa9", User: "46b87022a9fe198101a78787e40d7547"},
+			// Account Management
+			{Group: "b3ff30ac0a0a0b2c00aad0c66d673aa0", User: "46d44a23a9fe19810012d100cca80666"},
+			// Service Desk
+			{Group: "d625dccec0a8016700a222a0f7900d06", User: "681b365ec0a80164000fb0b05854a0cd"},
+			{Group: "d625dccec0a8016700a222a0f7900d06", User: "46c6f9efa9fe198101ddf5eed9adf6e7"},
+			// Security Operations
+			{Group: "e4ff30ac0a0a0b2c00aad0c66d673ab1", User: "5137153cc611227c000bbd1bd8cd2006"},
+			{Group: "e4ff30ac0a0a0b2c00aad0c66d673ab1", User: "7e3bbb173b331300ad3cc9bb34efc4a8"},
+			// HR Services
+			{Group: "e5ff30ac0a0a0b2c00aad0c66d673ab2", User: "a8f98bb0eb32010045e1a5115206fe3a"},
+			{Group: "e5ff30ac0a0a0b2c00aad0c66d673ab2", User: "46d44a23a9fe19810012d100cca80666"},
+		},
+		RoutingRules: map[string]string{
+			// Incident and change categories
+			"Network":        "287ebd7da9fe198100f92cc8d1d2154e",
+			"Telephony":      "287ebd7da9fe198100f92cc8d1d2154e",
+			"Hardware":       "8a5055c9c61122780043563ef53438e3",
+			"Software":       "0a52d3dcd7011200f2d224837e6103f2",
+			"Application":    "0a52d3dcd7011200f2d224837e6103f2",
+			"Web":            "0a52d3dcd7011200f2d224837e6103f2",
+			"Database":       "5f74727dc0a8010e01efe33a251993f9",
+			"Security":       "e4ff30ac0a0a0b2c00aad0c66d673ab1",
+			"Authentication": "e4ff30ac0a0a0b2c00aad0c66d673ab1",
+			"Storage":        "aaccc971c0a8001500fe1ff4302de101",
+			"Infrastructure": "aaccc971c0a8001500fe1ff4302de101",
+			"Email":          "d625dccec0a8016700a222a0f7900d06",
+			"Emergency":      "a715cd759f2002002920bde8132e7018",
+			"Standard":       "a715cd759f2002002920bde8132e7018",
+			"Normal":         "a715cd759f2002002920bde8132e7018",
+			// Case categories
+			"Account":   "b3ff30ac0a0a0b2c00aad0c66d673aa0",
+			"Billing":   "b3ff30ac0a0a0b2c00aad0c66d673aa0",
+			"Product":   "b2ff30ac0a0a0b2c00aad0c66d673aa9",
+			"Technical": "b2ff30ac0a0a0b2c00aad0c66d673aa9",
+			"Service":   "b1ff30ac0a0a0b2c00aad0c66d673aa8",
+			"Order":     "b1ff30ac0a0a0b2c00aad0c66d673aa8",
+			"Shipping":  "b1ff30ac0a0a0b2c00aad0c66d673aa8",
+			"Returns":   "b1ff30ac0a0a0b2c00aad0c66d673aa8",
+			"Warranty":  "b1ff30ac0a0a0b2c00aad0c66d673aa8",
+			"General":   "b1ff30ac0a0a0b2c00aad0c66d673aa8",
+			// HR categories
+			"Benefits":           "e5ff30ac0a0a0b2c00aad0c66d673ab2",
+			"Payroll":            "e5ff30ac0a0a0b2c00aad0c66d673ab2",
+			"Time Off":           "e5ff30ac0a0a0b2c00aad0c66d673ab2",
+			"Performance":        "e5ff30ac0a0a0b2c00aad0c66d673ab2",
+			"Training":           "e5ff30ac0a0a0b2c00aad0c66d673ab2",
+			"Compliance":         "e5ff30ac0a0a0b2c00aad0c66d673ab2",
+			"Employee Relations": "e5ff30ac0a0a0b2c00aad0c66d673ab2",
+			"Onboarding":         "e5ff30ac0a0a0b2c00aad0c66d673ab2",
+			"Offboarding":        "e5ff30ac0a0a0b2c00aad0c66d673ab2",
+		},
+	}
+}
+
+// LoadReferenceData reads reference data from a JSON file, such as one written by
+// the master_data mode. Tables missing from the file keep their built-in values.
+func LoadReferenceData(filename string) (*ReferenceData, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reference data file: %w", err)
+	}
+
+	var loaded ReferenceData
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return nil, fmt.Errorf("failed to parse reference data file: %w", err)
+	}
+
+	rd := GetReferenceData()
+	if len(loaded.SysUser) > 0 {
+		rd.SysUser = loaded.SysUser
+	}
+	if len(loaded.SysUserGroup) > 0 {
+		rd.SysUserGroup = loaded.SysUserGroup
+		rd.SysUserGrmember = loaded.SysUserGrmember
+		rd.RoutingRules = loaded.RoutingRules
+	}
+	if len(loaded.Account) > 0 {
+		rd.Account = loaded.Account
+		rd.Contact = loaded.Contact
+	}
+	if len(loaded.CmdbCiService) > 0 {
+		rd.CmdbCiService = loaded.CmdbCiService
+		rd.CmdbCi = loaded.CmdbCi
+		rd.CmdbRelCi = loaded.CmdbRelCi
+	}
+	if err := rd.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid reference data file %s: %w", filename, err)
+	}
+
+	return rd, nil
+}
+
+// SaveReferenceData writes reference data to a JSON file
+func SaveReferenceData(rd *ReferenceData, filename string) error {
+	data, err := json.MarshalIndent(rd, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal reference data: %w", err)
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write reference data file: %w", err)
+	}
+	return nil
+}
+
+// GetChoiceValues returns the hard-coded choice values
+func GetChoiceValues() *ChoiceValues {
+	return &ChoiceValues{
+		Category:     []string{"Network", "Hardware", "Software", "Database", "Security", "Email", "Telephony", "Authentication", "Storage", "Web"},
+		CaseCategory: []string{"Account", "Billing", "Product", "Service", "Technical", "Order", "Shipping", "Returns", "Warranty", "General"},
+		Subcategory: map[string][]string{
+			"Network":  {"Connectivity", "VPN", "Wireless", "DNS", "DHCP"},
+			"Hardware": {"Desktop", "Laptop", "Printer", "Mobile Device", "Server"},
+			"Software": {"Operating System", "Application", "Update", "License", "Installation"},
+			"Database": {"Performance", "Backup", "Recovery", "Query", "Permissions"},
+			"Security": {"Access", "Virus", "Firewall", "Encryption", "Policy"},
+		},
+		CaseSubcategory: map[string][]string{
+			"Account":   {"Access", "Creation", "Modification", "Deletion", "Permissions"},
+			"Billing":   {"Invoice", "Payment", "Refund", "Subscription", "Pricing"},
+			"Product":   {"Defect", "Feature Request", "Documentation", "Compatibility", "Installation"},
+			"Service":   {"Availability", "Quality", "Modification", "Cancellation", "Upgrade"},
+			"Technical": {"Error", "Performance", "Configuration", "Integration", "Security"},
+		},
+		CloseCode: []string{
+			"Known error", "Resolved by problem", "User error", "No resolution provided",
+			"Resolved by request", "Resolved by caller", "Solution provided", "Duplicate",
+		},
+		CaseCloseCode: []string{
+			"Solved (Permanently)", "Solved (Work Around)", "Solved (Knowledge Article)",
+			"Not Solved (Not Reproducible)", "Not Solved (Too Costly)", "Not Solved (Not Supported)",
+		},
+		ContactType: []string{"Email", "Phone", "Self-service", "Walk-in", "Chat", "Automated", "Virtual Agent", "Social Media"},
+		State: []ChoiceValue{
+			{Value: 1, Display: "New"},
+			{Value: 2, Display: "In Progress"},
+			{Value: 3, Display: "On Hold"},
+			{Value: 4, Display: "Resolved"},
+			{Value: 5, Display: "Closed"},
+			{Value: 6, Display: "Canceled"},
+		},
+		CaseState: []ChoiceValue{
+			{Value: 1, Display: "New"},
+			{Value: 2, Display: "In Progress"},
+			{Value: 3, Display: "On Hold"},
+			{Value: 4, Display: "Awaiting Customer"},
+			{Value: 5, Display: "Resolved"},
+			{Value: 6, Display: "Closed"},
+			{Value: 7, Display: "Canceled"},
+		},
+		CaseType: []string{"Question", "Issue", "Feature Request", "Complaint", "Compliment", "Service Request", "Order", "Return"},
+		Impact: []ChoiceValue{
+			{Value: 1, Display: "High"},
+			{Value: 2, Display: "Medium"},
+			{Value: 3, Display: "Low"},
+		},
+		Urgency: []ChoiceValue{
+			{Value: 1, Display: "High"},
+			{Value: 2, Display: "Medium"},
+			{Value: 3, Display: "Low"},
+		},
+	}
+}
+
+// NewSysID returns a random 32 character hexadecimal sys_id drawn from rng
+func NewSysID(rng *rand.Rand) string {
+	const hexDigits = "0123456789abcdef"
+	b := make([]byte, 32)
+	for i := range b {
+		b[i] = hexDigits[rng.Intn(len(hexDigits))]
+	}
+	return string(b)
+}
+
+// GetRandomReference returns a random reference value from the specified table
+func (rd *ReferenceData) GetRandomReference(rng *rand.Rand, table string) *ReferenceValue {
+	switch table {
+	case "sys_user_group":
+		return &rd.SysUserGroup[rng.Intn(len(rd.SysUserGroup))]
+	case "account":
+		return &rd.Account[rng.Intn(len(rd.Account))]
+	case "contact":
+		return &rd.Contact[rng.Intn(len(rd.Contact))]
+	case "sys_user":
+		return &rd.SysUser[rng.Intn(len(rd.SysUser))]
+	case "cmdb_ci_service":
+		return &rd.CmdbCiService[rng.Intn(len(rd.CmdbCiService))]
+	case "cmdb_ci":
+		return &rd.CmdbCi[rng.Intn(len(rd.CmdbCi))]
+	default:
+		return &ReferenceValue{SysID: "unknown", DisplayValue: "Unknown"}
+	}
+}
+
+// GetReferenceBySysID looks up a reference value by sys_id in the specified table
+func (rd *ReferenceData) GetReferenceBySysID(table, sysID string) *ReferenceValue {
+	return rd.index().bySysID[table][sysID]
+}
+
+// FindReference returns the reference with the given sys_id or display value, looking in
+// groups, users, accounts, contacts, services and CIs in that order
+func (rd *ReferenceData) FindReference(value string) *ReferenceValue {
+	for _, values := range [][]ReferenceValue{rd.SysUserGroup, rd.SysUser, rd.Account, rd.Contact, rd.CmdbCiService, rd.CmdbCi} {
+		for i := range values {
+			if values[i].SysID == value || strings.EqualFold(values[i].DisplayValue, value) {
+				return &values[i]
+			}
+		}
+	}
+	return nil
+}
+
+// GetGroupForCategory returns the assignment group the routing rules map the category to.
+// Categories without a rule are routed to a random group that has members.
+func (rd *ReferenceData) GetGroupForCategory(rng *rand.Rand, category string) *ReferenceValue {
+	if groupID, exists := rd.RoutingRules[category]; exists {
+		if group := rd.GetReferenceBySysID("sys_user_group", groupID); group != nil {
+			return group
+		}
+	}
+
+	staffed := rd.index().staffed
+	if len(staffed) == 0 {
+		return rd.GetRandomReference(rng, "sys_user_group")
+	}
+	return staffed[rng.Intn(len(staffed))]
+}
+
+// GetGroupMembers returns the sys_ids of the users that belong to the group
+func (rd *ReferenceData) GetGroupMembers(groupSysID string) []string {
+	var members []string
+	for _, user := range rd.index().members[groupSysID] {
+		members = append(members, user.SysID)
+	}
+	return members
+}
+
+// IsGroupMember reports whether the user belongs to the group
+func (rd *ReferenceData) IsGroupMember(groupSysID, userSysID string) bool {
+	for _, user := range rd.index().members[groupSysID] {
+		if user.SysID == userSysID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRandomUserInGroup returns a random member of the group. A group without members
+// is an error, rather than a user outside the group.
+func (rd *ReferenceData) GetRandomUserInGroup(rng *rand.Rand, groupSysID string) (*ReferenceValue, error) {
+	members := rd.index().members[groupSysID]
+	if len(members) == 0 {
+		name := groupSysID
+		if group := rd.GetReferenceBySysID("sys_user_group", groupSysID); group != nil {
+			name = group.DisplayValue
+		}
+		return nil, fmt.Errorf("group %s has no members", name)
+	}
+	return members[rng.Intn(len(members))], nil
+}
+
+// Validate checks that the group memberships name groups and users of the reference
+// data, and that the routing rules route to groups with members, since records are
+// assigned to a member of their group
+func (rd *ReferenceData) Validate() error {
+	idx := rd.index()
+	var problems []string
+	for i, m := range rd.SysUserGrmember {
+		if idx.bySysID["sys_user_group"][m.Group] == nil {
+			problems = append(problems, fmt.Sprintf("sys_user_grmember %d: unknown group %s", i+1, m.Group))
+		}
+		if idx.bySysID["sys_user"][m.User] == nil {
+			problems = append(problems, fmt.Sprintf("sys_user_grmember %d: unknown user %s", i+1, m.User))
+		}
+	}
+	if len(rd.SysUserGroup) > 0 && len(idx.staffed) == 0 {
+		problems = append(problems, "sys_user_grmember: no group has members")
+	}
+	categories := make([]string, 0, len(rd.RoutingRules))
+	for category := range rd.RoutingRules {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		groupID := rd.RoutingRules[category]
+		if group := idx.bySysID["sys_user_group"][groupID]; group == nil {
+			problems = append(problems, fmt.Sprintf("routing_rules.%s: unknown group %s", category, groupID))
+		} else if len(idx.members[groupID]) == 0 {
+			problems = append(problems, fmt.Sprintf("routing_rules.%s: group %s has no members", category, group.DisplayValue))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// GetRandomChoice returns a random choice value from the specified field
+func (cv *ChoiceValues) GetRandomChoice(rng *rand.Rand, field string) interface{} {
+	switch field {
+	case "category":
+		return cv.Category[rng.Intn(len(cv.Category))]
+	case "case_category":
+		return cv.CaseCategory[rng.Intn(len(cv.CaseCategory))]
+	case "close_code":
+		return cv.CloseCode[rng.Intn(len(cv.CloseCode))]
+	case "case_close_code":
+		return cv.CaseCloseCode[rng.Intn(len(cv.CaseCloseCode))]
+	case "contact_type":
+		return cv.ContactType[rng.Intn(len(cv.ContactType))]
+	case "state":
+		return &cv.State[rng.Intn(len(cv.State))]
+	case "case_state":
+		return &cv.CaseState[rng.Intn(len(cv.CaseState))]
+	case "case_type":
+		return cv.CaseType[rng.Intn(len(cv.CaseType))]
+	case "impact":
+		return &cv.Impact[rng.Intn(len(cv.Impact))]
+	case "urgency":
+		return &cv.Urgency[rng.Intn(len(cv.Urgency))]
+	default:
+		return "Unknown"
+	}
+}
+
+// GetRandomSubcategory returns a random subcategory based on the category
+func (cv *ChoiceValues) GetRandomSubcategory(rng *rand.Rand, category string) string {
+	subcategories, exists := cv.Subcategory[category]
+	if !exists || len(subcategories) == 0 {
+		return ""
+	}
+	return subcategories[rng.Intn(len(subcategories))]
+}
+
+// GetRandomCaseSubcategory returns a random case subcategory based on the category
+func (cv *ChoiceValues) GetRandomCaseSubcategory(rng *rand.Rand, category string) string {
+	subcategories, exists := cv.CaseSubcategory[category]
+	if !exists || len(subcategories) == 0 {
+		return ""
+	}
+	return subcategories[rng.Intn(len(subcategories))]
+}
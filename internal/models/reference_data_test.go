@@ -0,0 +1,136 @@
+package models
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseReferenceMode(t *testing.T) {
+	for input, expected := range map[string]ReferenceMode{
+		"":        ReferenceDisplay,
+		"display": ReferenceDisplay,
+		"sys_id":  ReferenceSysID,
+		"both":    ReferenceBoth,
+	} {
+		mode, err := ParseReferenceMode(input)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", input, err)
+		}
+		if mode != expected {
+			t.Errorf("Expected %q for %q, got %q", expected, input, mode)
+		}
+	}
+
+	if _, err := ParseReferenceMode("value"); err == nil {
+		t.Error("Expected an error for an unknown reference mode")
+	}
+}
+
+func TestNewSysID(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := NewSysID(rng)
+		if len(id) != 32 {
+			t.Fatalf("Expected a 32 character sys_id, got %q", id)
+		}
+		if seen[id] {
+			t.Fatalf("Duplicate sys_id %s", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestNewSysIDSeeded(t *testing.T) {
+	first, second := rand.New(rand.NewSource(7)), rand.New(rand.NewSource(7))
+	if a, b := NewSysID(first), NewSysID(second); a != b {
+		t.Errorf("Expected the same sys_id from sources with the same seed, got %s and %s", a, b)
+	}
+}
+
+func TestGroupMemberships(t *testing.T) {
+	rd := GetReferenceData()
+	if err := rd.Validate(); err != nil {
+		t.Fatalf("Expected the built-in reference data to be valid, got %v", err)
+	}
+
+	// NY DB has two members, and routed groups always have members
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		user, err := rd.GetRandomUserInGroup(rng, "5f74727dc0a8010e01efe33a251993f9")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !rd.IsGroupMember("5f74727dc0a8010e01efe33a251993f9", user.SysID) {
+			t.Fatalf("Expected a member of NY DB, got %s", user.DisplayValue)
+		}
+		group := rd.GetGroupForCategory(rng, "Unrouted")
+		if len(rd.GetGroupMembers(group.SysID)) == 0 {
+			t.Fatalf("Expected a group with members for an unrouted category, got %s", group.DisplayValue)
+		}
+	}
+}
+
+func TestLoadReferenceDataDanglingMemberships(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "reference.json")
+	data := `{
+  "sys_user": [{"sys_id": "u1", "display_value": "Ada"}],
+  "sys_user_group": [{"sys_id": "g1", "display_value": "Ops"}],
+  "sys_user_grmember": [{"group": "g1", "user": "u1"}, {"group": "g1", "user": "u2"}, {"group": "g2", "user": "u1"}]
+}`
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write reference data: %v", err)
+	}
+	_, err := LoadReferenceData(filename)
+	if err == nil || !strings.Contains(err.Error(), "sys_user_grmember 2: unknown user u2") || !strings.Contains(err.Error(), "sys_user_grmember 3: unknown group g2") {
+		t.Errorf("Expected the dangling memberships to be reported, got %v", err)
+	}
+}
+
+func TestLoadReferenceDataRoutingRules(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "reference.json")
+	data := `{
+  "sys_user": [{"sys_id": "u1", "display_value": "Ada"}],
+  "sys_user_group": [{"sys_id": "g1", "display_value": "Ops"}, {"sys_id": "g2", "display_value": "Night Shift"}],
+  "sys_user_grmember": [{"group": "g1", "user": "u1"}],
+  "routing_rules": {"Network": "g1", "Hardware": "g2", "Email": "g3"}
+}`
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write reference data: %v", err)
+	}
+	_, err := LoadReferenceData(filename)
+	if err == nil || !strings.Contains(err.Error(), "routing_rules.Email: unknown group g3\n  routing_rules.Hardware: group Night Shift has no members") {
+		t.Errorf("Expected the routing rules without members to be reported, got %v", err)
+	}
+
+	// Records are never assigned to users outside their group
+	rd := &ReferenceData{
+		SysUserGroup: []ReferenceValue{{SysID: "g2", DisplayValue: "Night Shift"}},
+		SysUser:      []ReferenceValue{{SysID: "u1", DisplayValue: "Ada"}},
+	}
+	if user, err := rd.GetRandomUserInGroup(rand.New(rand.NewSource(1)), "g2"); err == nil {
+		t.Errorf("Expected an error for a group without members, got %+v", user)
+	}
+	if err := rd.Validate(); err == nil || !strings.Contains(err.Error(), "no group has members") {
+		t.Errorf("Expected reference data without memberships to be reported, got %v", err)
+	}
+}
+
+func TestElementName(t *testing.T) {
+	type record struct {
+		Item     string `json:"configuration_item" xml:"cmdb_ci"`
+		State    string `json:"state,omitempty"`
+		Internal string `json:"internal" xml:"-"`
+		Notes    string
+	}
+	recordType := reflect.TypeOf(record{})
+	for i, expected := range []string{"cmdb_ci", "state", "", "notes"} {
+		if name := ElementName(recordType.Field(i)); name != expected {
+			t.Errorf("Expected %q for %s, got %q", expected, recordType.Field(i).Name, name)
+		}
+	}
+}
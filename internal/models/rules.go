@@ -0,0 +1,731 @@
+package models
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Rule sets, computes or checks fields of the generated records that match a
+// condition. Fields are named as in column specs.
+type Rule struct {
+	// Table limits the rule to the records of a table. Without it, the rule applies to
+	// the records of every table that has the fields it names.
+	Table string
+	// When is the condition of the records the rule applies to, all records without one
+	When string
+	// Chance is the percentage of the matching records the rule applies to, all of them
+	// when nil
+	Chance *float64
+	// Set maps fields to their new values, in which {field} is replaced by the value of a
+	// field before the rule. References are looked up by sys_id or display value, and
+	// setting assignment_group assigns the record to a member of the new group unless
+	// the rule sets assigned_to too.
+	Set map[string]string
+	// Require lists the fields that must not be empty
+	Require []string
+	// Check is a condition the records must meet
+	Check string
+}
+
+// RuleSet holds parsed rules, applied in order, so a rule sees the fields set by the
+// rules before it.
+//
+// Conditions compare a field with a value, or test it:
+//
+//	field == value, !=, <, <=, >, >=   values that are both numbers compare as numbers,
+//	                                   others as text, ignoring case
+//	field in [value, value]            the field has one of the values
+//	field contains value               the field holds the value, ignoring case
+//	field is empty, field is not empty
+//
+// combined with and, or, not and parentheses. Values with spaces or symbols are quoted,
+// as in state == "On Hold".
+type RuleSet struct {
+	rules []*parsedRule
+}
+
+// parsedRule is a rule with its conditions and templates parsed
+type parsedRule struct {
+	Rule
+	index  int
+	when   condition
+	check  condition
+	set    []ruleAssignment
+	fields []string // every field the rule names
+}
+
+// ruleAssignment is a field set by a rule
+type ruleAssignment struct {
+	field    string
+	template []valuePart
+}
+
+// condition reports whether the fields of a record meet it
+type condition func(r recordFields) bool
+
+// ParseRules parses rules, checking their syntax
+func ParseRules(rules []Rule) (*RuleSet, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	s := &RuleSet{}
+	for i, rule := range rules {
+		parsed, err := parseRule(i, rule)
+		if err != nil {
+			return nil, err
+		}
+		s.rules = append(s.rules, parsed)
+	}
+	return s, nil
+}
+
+func parseRule(index int, rule Rule) (*parsedRule, error) {
+	r := &parsedRule{Rule: rule, index: index}
+	named := make(map[string]bool)
+	name := func(fields ...string) {
+		for _, field := range fields {
+			if !named[field] {
+				named[field] = true
+				r.fields = append(r.fields, field)
+			}
+		}
+	}
+
+	if len(rule.Set) == 0 && len(rule.Require) == 0 && rule.Check == "" {
+		return nil, fmt.Errorf("rules[%d]: a rule needs set, require or check", index)
+	}
+	if rule.Chance != nil && (*rule.Chance < 0 || *rule.Chance > 100) {
+		return nil, fmt.Errorf("rules[%d].chance: %v is not between 0 and 100", index, *rule.Chance)
+	}
+	if rule.When != "" {
+		when, fields, err := parseCondition(rule.When)
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d].when: %w", index, err)
+		}
+		r.when = when
+		name(fields...)
+	}
+	if rule.Check != "" {
+		check, fields, err := parseCondition(rule.Check)
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d].check: %w", index, err)
+		}
+		r.check = check
+		name(fields...)
+	}
+
+	// Fields are set in name order, so the order of the map does not matter
+	fields := make([]string, 0, len(rule.Set))
+	for field := range rule.Set {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		template, err := parseTemplate(rule.Set[field])
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d].set.%s: %w", index, field, err)
+		}
+		r.set = append(r.set, ruleAssignment{field: field, template: template})
+		name(field)
+		for _, part := range template {
+			if part.placeholder {
+				name(part.text)
+			}
+		}
+	}
+	for _, field := range rule.Require {
+		if strings.TrimSpace(field) == "" {
+			return nil, fmt.Errorf("rules[%d].require: empty field name", index)
+		}
+	}
+	name(rule.Require...)
+	return r, nil
+}
+
+// Check checks the tables and fields the rules name against the record types of the
+// tables: a rule limited to a table must name fields of its records, and other rules
+// fields of the records of at least one table.
+func (s *RuleSet) Check(recordTypes map[string]reflect.Type) error {
+	if s == nil {
+		return nil
+	}
+	for _, r := range s.rules {
+		if r.Table != "" {
+			recordType, ok := recordTypes[r.Table]
+			if !ok {
+				return fmt.Errorf("rules[%d].table: unknown table %s", r.index, r.Table)
+			}
+			for _, field := range r.fields {
+				if _, err := lookupField(recordType, field); err != nil {
+					return fmt.Errorf("rules[%d]: %w", r.index, err)
+				}
+			}
+			continue
+		}
+		for _, field := range r.fields {
+			found := false
+			for _, recordType := range recordTypes {
+				if _, err := lookupField(recordType, field); err == nil {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("rules[%d]: no table has a field %s", r.index, field)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckReferences checks that the references the rules set to fixed values are found in
+// the reference data, so a rule cannot write a reference without a sys_id, and that the
+// groups they assign records to have members. References computed from fields are
+// looked up as records are generated.
+func (s *RuleSet) CheckReferences(recordTypes map[string]reflect.Type, rd *ReferenceData) error {
+	if s == nil {
+		return nil
+	}
+	for _, r := range s.rules {
+		_, setsAssignee := r.Set["assigned_to"]
+		for _, assignment := range r.set {
+			value, fixed := fixedValue(assignment.template)
+			if !fixed || value == "" || !setsReference(recordTypes, r.Table, assignment.field) {
+				continue
+			}
+			found := rd.FindReference(value)
+			if found == nil {
+				return fmt.Errorf("rules[%d].set.%s: no reference data matches %q", r.index, assignment.field, value)
+			}
+			if assignment.field == "assignment_group" && !setsAssignee && len(rd.GetGroupMembers(found.SysID)) == 0 {
+				return fmt.Errorf("rules[%d].set.%s: group %s has no members", r.index, assignment.field, found.DisplayValue)
+			}
+		}
+	}
+	return nil
+}
+
+// fixedValue returns the value of a template without placeholders
+func fixedValue(template []valuePart) (string, bool) {
+	var value strings.Builder
+	for _, part := range template {
+		if part.placeholder {
+			return "", false
+		}
+		value.WriteString(part.text)
+	}
+	return value.String(), true
+}
+
+// setsReference reports whether the field is a reference in the records of the table,
+// or of any table when the rule has none
+func setsReference(recordTypes map[string]reflect.Type, table, field string) bool {
+	for name, recordType := range recordTypes {
+		if table != "" && name != table {
+			continue
+		}
+		if i, err := lookupField(recordType, field); err == nil && recordType.Field(i).Type == referenceType {
+			return true
+		}
+	}
+	return false
+}
+
+// referenceType is the type of reference fields
+var referenceType = reflect.TypeOf(ReferenceValue{})
+
+// For returns the rules that apply to the records of a table, of the given type.
+// Rules of other tables and rules naming fields the records do not have are left out.
+// References set by the rules are looked up in rd.
+func (s *RuleSet) For(table string, recordType reflect.Type, rd *ReferenceData) *RecordRules {
+	if s == nil {
+		return nil
+	}
+	if recordType.Kind() == reflect.Ptr {
+		recordType = recordType.Elem()
+	}
+	rr := &RecordRules{recordType: recordType, fields: make(map[string]int), references: rd, assignee: -1}
+	if i, err := lookupField(recordType, "assigned_to"); err == nil && recordType.Field(i).Type == referenceType {
+		rr.assignee = i
+	}
+rules:
+	for _, r := range s.rules {
+		if r.Table != "" && r.Table != table {
+			continue
+		}
+		for _, field := range r.fields {
+			i, err := lookupField(recordType, field)
+			if err != nil {
+				continue rules
+			}
+			rr.fields[field] = i
+		}
+		rr.rules = append(rr.rules, r)
+	}
+	if len(rr.rules) == 0 {
+		return nil
+	}
+	return rr
+}
+
+// RecordRules are the rules that apply to the records of a table
+type RecordRules struct {
+	recordType reflect.Type
+	rules      []*parsedRule
+	fields     map[string]int // record field of each field name
+	references *ReferenceData
+	assignee   int // record field of assigned_to, -1 without one
+}
+
+// Apply applies the rules to a record, drawing their chances and the users assigned to
+// groups they set from rng. A record that misses a required field or fails a check is
+// an error.
+func (rr *RecordRules) Apply(record interface{}, rng *rand.Rand) error {
+	if rr == nil {
+		return nil
+	}
+	v := reflect.ValueOf(record)
+	if v.Kind() != reflect.Ptr || v.Elem().Type() != rr.recordType {
+		return fmt.Errorf("cannot apply rules of %s to a %T", rr.recordType.Name(), record)
+	}
+	r := recordFields{v: v.Elem(), fields: rr.fields}
+	if rng == nil {
+		rng = rand.New(rand.NewSource(rand.Int63()))
+	}
+
+	for _, rule := range rr.rules {
+		if rule.when != nil && !rule.when(r) {
+			continue
+		}
+		if rule.Chance != nil && rng.Float64()*100 >= *rule.Chance {
+			continue
+		}
+
+		// Values are computed from the fields before the rule sets any
+		values := make([]string, len(rule.set))
+		for i, assignment := range rule.set {
+			var value strings.Builder
+			for _, part := range assignment.template {
+				if part.placeholder {
+					value.WriteString(r.text(part.text))
+				} else {
+					value.WriteString(part.text)
+				}
+			}
+			values[i] = value.String()
+		}
+		for i, assignment := range rule.set {
+			if err := rr.setField(r.field(assignment.field), values[i]); err != nil {
+				return fmt.Errorf("rule %d: %s: %w", rule.index+1, assignment.field, err)
+			}
+		}
+		if err := rr.reassign(r, rule, rng); err != nil {
+			return fmt.Errorf("rule %d: assigned_to: %w", rule.index+1, err)
+		}
+
+		for _, field := range rule.Require {
+			if r.empty(field) {
+				if rule.When != "" {
+					return fmt.Errorf("rule %d: %s is required when %s", rule.index+1, field, rule.When)
+				}
+				return fmt.Errorf("rule %d: %s is required", rule.index+1, field)
+			}
+		}
+		if rule.check != nil && !rule.check(r) {
+			return fmt.Errorf("rule %d: the record does not meet %s", rule.index+1, rule.Check)
+		}
+	}
+	return nil
+}
+
+// reassign assigns a record whose group a rule set to a member of the group, unless the
+// rule sets assigned_to too. Unassigned records stay unassigned, and a group without
+// members is an error.
+func (rr *RecordRules) reassign(r recordFields, rule *parsedRule, rng *rand.Rand) error {
+	_, setsGroup := rule.Set["assignment_group"]
+	_, setsAssignee := rule.Set["assigned_to"]
+	if !setsGroup || setsAssignee || rr.assignee < 0 {
+		return nil
+	}
+	group, ok := r.field("assignment_group").Interface().(ReferenceValue)
+	assignee := r.v.Field(rr.assignee)
+	if !ok || assignee.Interface().(ReferenceValue).SysID == "" {
+		return nil
+	}
+	user := ReferenceValue{}
+	if group.SysID != "" {
+		member, err := rr.references.GetRandomUserInGroup(rng, group.SysID)
+		if err != nil {
+			return err
+		}
+		user = *member
+	}
+	assignee.Set(reflect.ValueOf(user))
+	return nil
+}
+
+// setField sets a field from text, converted to the type of the field
+func (rr *RecordRules) setField(field reflect.Value, value string) error {
+	if field.Type() == referenceType {
+		var ref ReferenceValue
+		if value != "" {
+			var found *ReferenceValue
+			if rr.references != nil {
+				found = rr.references.FindReference(value)
+			}
+			if found == nil {
+				return fmt.Errorf("no reference data matches %q", value)
+			}
+			ref = *found
+		}
+		field.Set(reflect.ValueOf(ref))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(valueOrZero(value), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+		field.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(valueOrZero(value), 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil && value != "" {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("fields of type %s cannot be set", field.Type())
+	}
+	return nil
+}
+
+func valueOrZero(value string) string {
+	if value == "" {
+		return "0"
+	}
+	return value
+}
+
+// recordFields reads the fields of a record by name
+type recordFields struct {
+	v      reflect.Value
+	fields map[string]int
+}
+
+func (r recordFields) field(name string) reflect.Value {
+	return r.v.Field(r.fields[name])
+}
+
+// text returns the value of a field as text; references give their display value
+func (r recordFields) text(name string) string {
+	return textValue(r.field(name).Interface())
+}
+
+// empty reports whether a field has its zero value
+func (r recordFields) empty(name string) bool {
+	return r.field(name).IsZero()
+}
+
+// parseCondition parses a condition, returning the fields it names
+func parseCondition(text string) (condition, []string, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &conditionParser{tokens: tokens}
+	cond, err := p.or()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !p.done() {
+		return nil, nil, fmt.Errorf("unexpected %s in %q", p.peek().text, text)
+	}
+	return cond, p.fields, nil
+}
+
+// token is a word, quoted value or symbol of a condition
+type token struct {
+	text   string
+	quoted bool
+}
+
+// keyword reports whether the token is the given unquoted keyword
+func (t token) keyword(word string) bool {
+	return !t.quoted && strings.EqualFold(t.text, word)
+}
+
+// symbols are the operators and punctuation of conditions, longest first
+var symbols = []string{"==", "!=", "<=", ">=", "<", ">", "=", "(", ")", "[", "]", ","}
+
+func tokenize(text string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(text[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed %c in %q", c, text)
+			}
+			tokens = append(tokens, token{text: text[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		default:
+			symbol := ""
+			for _, s := range symbols {
+				if strings.HasPrefix(text[i:], s) {
+					symbol = s
+					break
+				}
+			}
+			if symbol != "" {
+				tokens = append(tokens, token{text: symbol})
+				i += len(symbol)
+				continue
+			}
+			end := strings.IndexFunc(text[i:], func(r rune) bool {
+				return unicode.IsSpace(r) || strings.ContainsRune("\"'=!<>()[],", r)
+			})
+			if end < 0 {
+				end = len(text) - i
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("unexpected %c in %q", c, text)
+			}
+			tokens = append(tokens, token{text: text[i : i+end]})
+			i += end
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty condition")
+	}
+	return tokens, nil
+}
+
+// conditionParser parses the tokens of a condition by recursive descent
+type conditionParser struct {
+	tokens []token
+	pos    int
+	fields []string
+}
+
+func (p *conditionParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *conditionParser) peek() token {
+	if p.done() {
+		return token{}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *conditionParser) next() (token, error) {
+	if p.done() {
+		return token{}, fmt.Errorf("unexpected end of condition")
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *conditionParser) or() (condition, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("or") {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r recordFields) bool { return l(r) || right(r) }
+	}
+	return left, nil
+}
+
+func (p *conditionParser) and() (condition, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("and") {
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r recordFields) bool { return l(r) && right(r) }
+	}
+	return left, nil
+}
+
+func (p *conditionParser) unary() (condition, error) {
+	if p.peek().keyword("not") {
+		p.pos++
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(r recordFields) bool { return !operand(r) }, nil
+	}
+	if t := p.peek(); !t.quoted && t.text == "(" {
+		p.pos++
+		cond, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t, err := p.next(); err != nil || t.quoted || t.text != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return cond, nil
+	}
+	return p.comparison()
+}
+
+func (p *conditionParser) comparison() (condition, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if t.quoted || strings.ContainsAny(t.text, "=!<>()[],") {
+		return nil, fmt.Errorf("expected a field, got %s", t.text)
+	}
+	field := t.text
+	p.fields = append(p.fields, field)
+
+	op, err := p.next()
+	if err != nil {
+		return nil, fmt.Errorf("expected an operator after %s", field)
+	}
+	switch {
+	case op.keyword("is"):
+		negate := p.peek().keyword("not")
+		if negate {
+			p.pos++
+		}
+		if t, err := p.next(); err != nil || !t.keyword("empty") {
+			return nil, fmt.Errorf("expected empty after %s is", field)
+		}
+		return func(r recordFields) bool { return r.empty(field) != negate }, nil
+	case op.keyword("in"):
+		values, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		return func(r recordFields) bool {
+			value := r.text(field)
+			for _, v := range values {
+				if compareValues(value, v) == 0 {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case op.keyword("contains"):
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		value = strings.ToLower(value)
+		return func(r recordFields) bool { return strings.Contains(strings.ToLower(r.text(field)), value) }, nil
+	case op.quoted:
+		return nil, fmt.Errorf("expected an operator after %s, got %q", field, op.text)
+	}
+
+	var test func(int) bool
+	switch op.text {
+	case "==", "=":
+		test = func(c int) bool { return c == 0 }
+	case "!=":
+		test = func(c int) bool { return c != 0 }
+	case "<":
+		test = func(c int) bool { return c < 0 }
+	case "<=":
+		test = func(c int) bool { return c <= 0 }
+	case ">":
+		test = func(c int) bool { return c > 0 }
+	case ">=":
+		test = func(c int) bool { return c >= 0 }
+	default:
+		return nil, fmt.Errorf("expected an operator after %s, got %s", field, op.text)
+	}
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	return func(r recordFields) bool { return test(compareValues(r.text(field), value)) }, nil
+}
+
+// value parses a quoted or bare value
+func (p *conditionParser) value() (string, error) {
+	t, err := p.next()
+	if err != nil {
+		return "", fmt.Errorf("expected a value")
+	}
+	if !t.quoted && strings.ContainsAny(t.text, "=!<>()[],") {
+		return "", fmt.Errorf("expected a value, got %s", t.text)
+	}
+	return t.text, nil
+}
+
+// list parses a list of values in brackets
+func (p *conditionParser) list() ([]string, error) {
+	if t, err := p.next(); err != nil || t.quoted || t.text != "[" {
+		return nil, fmt.Errorf("expected [ after in")
+	}
+	var values []string
+	for {
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		t, err := p.next()
+		if err != nil {
+			return nil, fmt.Errorf("missing ]")
+		}
+		if !t.quoted && t.text == "]" {
+			return values, nil
+		}
+		if t.quoted || t.text != "," {
+			return nil, fmt.Errorf("expected , or ] in list, got %s", t.text)
+		}
+	}
+}
+
+// compareValues compares values as numbers when both are numbers, and as text
+// otherwise, ignoring case
+func compareValues(a, b string) int {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		default:
+			return 0
+		}
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
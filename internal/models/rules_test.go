@@ -0,0 +1,239 @@
+package models
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type ruleRecord struct {
+	Number     string         `json:"number" label:"Number"`
+	Category   string         `json:"category" label:"Category"`
+	State      string         `json:"state" label:"State"`
+	Priority   int            `json:"priority" label:"Priority"`
+	Channel    string         `json:"channel" label:"Channel"`
+	HoldReason string         `json:"hold_reason" label:"On hold reason"`
+	Group      ReferenceValue `json:"assignment_group" label:"Assignment group"`
+	Assignee   ReferenceValue `json:"assigned_to" label:"Assigned to"`
+}
+
+// ruleReferences is the reference data the rules of the tests look up
+var ruleReferences = &ReferenceData{
+	SysUserGroup:    []ReferenceValue{{SysID: "grp-sec", DisplayValue: "Security Ops"}, {SysID: "grp-net", DisplayValue: "Network"}, {SysID: "grp-night", DisplayValue: "Night Shift"}},
+	SysUser:         []ReferenceValue{{SysID: "usr-ada", DisplayValue: "Ada"}, {SysID: "usr-bob", DisplayValue: "Bob"}},
+	SysUserGrmember: []GroupMember{{Group: "grp-sec", User: "usr-ada"}, {Group: "grp-net", User: "usr-bob"}},
+}
+
+func chance(percent float64) *float64 {
+	return &percent
+}
+
+// applyRules applies rules to a record, failing the test if the rules are invalid
+func applyRules(t *testing.T, rules []Rule, record *ruleRecord, rng *rand.Rand) error {
+	t.Helper()
+	set, err := ParseRules(rules)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return set.For("incident", reflect.TypeOf(record), ruleReferences).Apply(record, rng)
+}
+
+func TestParseRulesInvalid(t *testing.T) {
+	for _, rule := range []Rule{
+		{When: "state == Closed"},
+		{When: "state ==", Set: map[string]string{"state": "New"}},
+		{When: "state == Closed and", Set: map[string]string{"state": "New"}},
+		{When: "(state == Closed", Set: map[string]string{"state": "New"}},
+		{When: "state in [New, Closed", Set: map[string]string{"state": "New"}},
+		{When: "state is full", Set: map[string]string{"state": "New"}},
+		{When: `state == "On Hold`, Set: map[string]string{"state": "New"}},
+		{When: "== Closed", Set: map[string]string{"state": "New"}},
+		{Check: "state Closed"},
+		{Set: map[string]string{"state": "{category"}},
+		{Require: []string{""}},
+		{Chance: chance(120), Set: map[string]string{"state": "New"}},
+	} {
+		if _, err := ParseRules([]Rule{rule}); err == nil {
+			t.Errorf("Expected an error for rule %+v", rule)
+		}
+	}
+}
+
+func TestRuleConditions(t *testing.T) {
+	record := ruleRecord{Category: "Security", State: "On Hold", Priority: 2, Group: ReferenceValue{SysID: "g1", DisplayValue: "Service Desk"}}
+	conditions := map[string]bool{
+		"category == Security":                                 true,
+		"category = security":                                  true,
+		"category != Security":                                 false,
+		`state == "On Hold"`:                                   true,
+		"priority < 3":                                         true,
+		"priority >= 10":                                       false,
+		"state in [New, 'On Hold']":                            true,
+		"category contains cur":                                true,
+		"hold_reason is empty":                                 true,
+		"channel is not empty":                                 false,
+		"assignment_group == 'Service Desk'":                   true,
+		"not (category == Security or priority == 1)":          false,
+		"category == Security and not priority > 2":            true,
+		"category == Network or state == 'On Hold'":            true,
+		"category == Network or state == Closed":               false,
+		"priority == 2 and (state == New or channel is empty)": true,
+	}
+	for text, expected := range conditions {
+		cond, fields, err := parseCondition(text)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", text, err)
+			continue
+		}
+		r := recordFields{v: reflect.ValueOf(record), fields: make(map[string]int)}
+		for _, field := range fields {
+			r.fields[field], _ = lookupField(reflect.TypeOf(record), field)
+		}
+		if got := cond(r); got != expected {
+			t.Errorf("Expected %q to be %v, got %v", text, expected, got)
+		}
+	}
+}
+
+func TestRuleSet(t *testing.T) {
+	rules := []Rule{
+		{When: "category == Security", Set: map[string]string{"assignment_group": "Security Ops", "category": "Security Incident"}},
+		{Set: map[string]string{"channel": "{category} via {channel}", "priority": "{priority}1"}},
+		{When: "state == 'On Hold'", Require: []string{"hold_reason"}},
+	}
+	record := &ruleRecord{Category: "Security", State: "New", Priority: 3, Channel: "Email"}
+	if err := applyRules(t, rules, record, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if record.Group.SysID != "grp-sec" || record.Category != "Security Incident" {
+		t.Errorf("Expected the security rule to route the record, got %+v", record)
+	}
+	// Later rules see the fields set before them
+	if record.Channel != "Security Incident via Email" || record.Priority != 31 {
+		t.Errorf("Expected computed fields, got %+v", record)
+	}
+
+	// A missing required field is an error
+	record = &ruleRecord{State: "On Hold"}
+	err := applyRules(t, rules, record, nil)
+	if err == nil || !strings.Contains(err.Error(), "hold_reason is required when state == 'On Hold'") {
+		t.Errorf("Expected the hold reason to be required, got %v", err)
+	}
+
+	// Failed checks and values of the wrong type are errors
+	if err := applyRules(t, []Rule{{Check: "priority <= 4"}}, &ruleRecord{Priority: 5}, nil); err == nil {
+		t.Error("Expected a failed check to be an error")
+	}
+	if err := applyRules(t, []Rule{{Set: map[string]string{"priority": "high"}}}, &ruleRecord{}, nil); err == nil {
+		t.Error("Expected an error setting a number to text")
+	}
+
+	// References computed from fields must be in the reference data
+	err = applyRules(t, []Rule{{Set: map[string]string{"assignment_group": "{category} Team"}}}, &ruleRecord{Category: "Facilities"}, nil)
+	if err == nil || !strings.Contains(err.Error(), `no reference data matches "Facilities Team"`) {
+		t.Errorf("Expected an unknown reference to be an error, got %v", err)
+	}
+}
+
+func TestRuleReassigns(t *testing.T) {
+	network := ReferenceValue{SysID: "grp-net", DisplayValue: "Network"}
+	bob := ReferenceValue{SysID: "usr-bob", DisplayValue: "Bob"}
+
+	// Records moved to another group are assigned to one of its members
+	record := &ruleRecord{Category: "Security", Group: network, Assignee: bob}
+	if err := applyRules(t, []Rule{{When: "category == Security", Set: map[string]string{"assignment_group": "Security Ops"}}}, record, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if record.Group.SysID != "grp-sec" || record.Assignee.SysID != "usr-ada" {
+		t.Errorf("Expected the record to be assigned within Security Ops, got %+v and %+v", record.Group, record.Assignee)
+	}
+
+	// unless the rule assigns them, or they were unassigned
+	record = &ruleRecord{Group: network, Assignee: bob}
+	if err := applyRules(t, []Rule{{Set: map[string]string{"assignment_group": "Security Ops", "assigned_to": "Bob"}}}, record, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if record.Assignee.SysID != "usr-bob" {
+		t.Errorf("Expected the assignee set by the rule, got %+v", record.Assignee)
+	}
+	record = &ruleRecord{Group: network}
+	if err := applyRules(t, []Rule{{Set: map[string]string{"assignment_group": "Security Ops"}}}, record, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if record.Assignee.SysID != "" {
+		t.Errorf("Expected the record to stay unassigned, got %+v", record.Assignee)
+	}
+
+	// Groups without members cannot take the record
+	record = &ruleRecord{Group: network, Assignee: bob}
+	if err := applyRules(t, []Rule{{Set: map[string]string{"assignment_group": "Night Shift"}}}, record, nil); err == nil || !strings.Contains(err.Error(), "group Night Shift has no members") {
+		t.Errorf("Expected a group without members, got %v", err)
+	}
+}
+
+func TestRuleChance(t *testing.T) {
+	rules := []Rule{{When: "priority == 1", Chance: chance(70), Set: map[string]string{"channel": "Phone"}}}
+	rng := rand.New(rand.NewSource(1))
+	phone := 0
+	for i := 0; i < 1000; i++ {
+		record := &ruleRecord{Priority: 1, Channel: "Email"}
+		if err := applyRules(t, rules, record, rng); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if record.Channel == "Phone" {
+			phone++
+		}
+	}
+	if phone < 650 || phone > 750 {
+		t.Errorf("Expected about 700 of 1000 records on the phone, got %d", phone)
+	}
+}
+
+func TestRuleSetTables(t *testing.T) {
+	set, err := ParseRules([]Rule{
+		{Table: "problem", Set: map[string]string{"state": "New"}},
+		{Set: map[string]string{"hold_reason": "Awaiting caller"}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	recordTypes := map[string]reflect.Type{"incident": reflect.TypeOf(ruleRecord{}), "spec": reflect.TypeOf(specRecord{})}
+	if err := set.Check(recordTypes); err == nil || !strings.Contains(err.Error(), "unknown table problem") {
+		t.Errorf("Expected an unknown table, got %v", err)
+	}
+	recordTypes["problem"] = reflect.TypeOf(specRecord{})
+	if err := set.Check(recordTypes); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// Rules of other tables, or naming fields the records lack, do not apply
+	if rules := set.For("spec", reflect.TypeOf(specRecord{}), nil); rules != nil {
+		t.Errorf("Expected no rules for spec records, got %d", len(rules.rules))
+	}
+	if rules := set.For("incident", reflect.TypeOf(ruleRecord{}), nil); rules == nil || len(rules.rules) != 1 {
+		t.Error("Expected the hold reason rule for incidents")
+	}
+
+	// Fixed references must be in the reference data
+	references, _ := ParseRules([]Rule{
+		{Set: map[string]string{"assignment_group": "{category}"}},
+		{Table: "incident", Set: map[string]string{"assignment_group": "Security Ops", "state": "Nowhere"}},
+	})
+	if err := references.CheckReferences(recordTypes, ruleReferences); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	missing, _ := ParseRules([]Rule{{Set: map[string]string{"assignment_group": "Facilities"}}})
+	if err := missing.CheckReferences(recordTypes, ruleReferences); err == nil || !strings.Contains(err.Error(), `rules[0].set.assignment_group: no reference data matches "Facilities"`) {
+		t.Errorf("Expected an unknown reference, got %v", err)
+	}
+	unstaffed, _ := ParseRules([]Rule{{Set: map[string]string{"assignment_group": "Night Shift"}}})
+	if err := unstaffed.CheckReferences(recordTypes, ruleReferences); err == nil || !strings.Contains(err.Error(), "rules[0].set.assignment_group: group Night Shift has no members") {
+		t.Errorf("Expected a group without members, got %v", err)
+	}
+
+	unknown, _ := ParseRules([]Rule{{Set: map[string]string{"u_missing": "x"}}})
+	if err := unknown.Check(recordTypes); err == nil || !strings.Contains(err.Error(), "no table has a field u_missing") {
+		t.Errorf("Expected an unknown field, got %v", err)
+	}
+}
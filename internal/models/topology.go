@@ -0,0 +1,382 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+)
+
+// Relationship types used in cmdb_rel_ci
+const (
+	RelDependsOn = "Depends on::Used by"
+	RelRunsOn    = "Runs on::Runs"
+	RelHostedOn  = "Hosted on::Hosts"
+)
+
+// CIRelationship represents a cmdb_rel_ci record between two configuration items
+type CIRelationship struct {
+	Parent string `json:"parent"`
+	Child  string `json:"child"`
+	Type   string `json:"type"`
+}
+
+// TopologyCI represents a configuration item in a topology file
+type TopologyCI struct {
+	SysID string `json:"sys_id"`
+	Name  string `json:"name"`
+	Class string `json:"sys_class_name"`
+}
+
+// Topology describes the services, configuration items and relationships of a CMDB
+type Topology struct {
+	Services            []TopologyCI     `json:"services"`
+	ApplicationServices []TopologyCI     `json:"application_services,omitempty"`
+	CIs                 []TopologyCI     `json:"cis"`
+	Relationships       []CIRelationship `json:"relationships"`
+}
+
+// ciClassWeights is the class mix used for synthetic topologies
+var ciClassWeights = []struct {
+	Class  string
+	Weight int
+}{
+	{"cmdb_ci_appl", 25},
+	{"cmdb_ci_linux_server", 20},
+	{"cmdb_ci_win_server", 15},
+	{"cmdb_ci_db_ora_instance", 8},
+	{"cmdb_ci_db_mssql_instance", 7},
+	{"cmdb_ci_db_mysql_instance", 5},
+	{"cmdb_ci_lb_bigip", 10},
+	{"cmdb_ci_ip_switch", 5},
+	{"cmdb_ci_ip_router", 5},
+}
+
+// serviceNames are the business functions used to name synthetic services
+var serviceNames = []string{
+	"Payroll", "Order Management", "Customer Portal", "Email", "Bond Trading",
+	"Inventory", "Billing", "CRM", "Data Warehouse", "Document Management",
+	"HR Self Service", "Procurement", "Logistics", "Point of Sale", "Identity",
+}
+
+// LoadTopology reads a CMDB topology from a JSON file
+func LoadTopology(filename string) (*Topology, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read topology file: %w", err)
+	}
+
+	var topology Topology
+	if err := json.Unmarshal(data, &topology); err != nil {
+		return nil, fmt.Errorf("failed to parse topology file: %w", err)
+	}
+
+	if len(topology.Services)+len(topology.ApplicationServices) == 0 {
+		return nil, fmt.Errorf("topology file %s contains no services", filename)
+	}
+	if len(topology.CIs) == 0 {
+		return nil, fmt.Errorf("topology file %s contains no configuration items", filename)
+	}
+	if err := topology.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid topology file %s: %w", filename, err)
+	}
+
+	return &topology, nil
+}
+
+// Validate checks that the relationships link services, application services and CIs
+// of the topology, since an edge to a missing item relates a service to nothing
+func (t *Topology) Validate() error {
+	known := make(map[string]bool, len(t.Services)+len(t.ApplicationServices)+len(t.CIs))
+	for _, items := range [][]TopologyCI{t.Services, t.ApplicationServices, t.CIs} {
+		for _, item := range items {
+			known[item.SysID] = true
+		}
+	}
+	var problems []string
+	for i, rel := range t.Relationships {
+		if !known[rel.Parent] {
+			problems = append(problems, fmt.Sprintf("relationship %d: unknown parent %s", i+1, rel.Parent))
+		}
+		if !known[rel.Child] {
+			problems = append(problems, fmt.Sprintf("relationship %d: unknown child %s", i+1, rel.Child))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// SaveTopology writes a CMDB topology to a JSON file
+func SaveTopology(topology *Topology, filename string) error {
+	data, err := json.MarshalIndent(topology, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal topology: %w", err)
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write topology file: %w", err)
+	}
+	return nil
+}
+
+// GenerateTopology builds a synthetic topology of the given number of services and
+// configuration items, drawing its random values from rng. Each service depends on an
+// application service fronted by load balancers and applications, which depend on
+// databases and run on servers.
+func GenerateTopology(rng *rand.Rand, serviceCount, ciCount int) *Topology {
+	topology := GenerateServices(rng, serviceCount)
+	cis := topology.GenerateCIs(rng, ciCount, 0)
+	topology.CIs = cis.CIs
+	topology.Relationships = append(topology.Relationships, cis.Relationships...)
+	return topology
+}
+
+// GenerateServices builds a synthetic topology of the given number of services, each
+// depending on its application service, without configuration items
+func GenerateServices(rng *rand.Rand, serviceCount int) *Topology {
+	topology := &Topology{}
+
+	for i := 0; i < serviceCount; i++ {
+		name := serviceNames[i%len(serviceNames)]
+		if i >= len(serviceNames) {
+			name = fmt.Sprintf("%s %d", name, i/len(serviceNames)+1)
+		}
+		topology.Services = append(topology.Services, TopologyCI{
+			SysID: NewSysID(rng),
+			Name:  name,
+			Class: "cmdb_ci_service",
+		})
+		topology.ApplicationServices = append(topology.ApplicationServices, TopologyCI{
+			SysID: NewSysID(rng),
+			Name:  name + " Application Service",
+			Class: "cmdb_ci_service_auto",
+		})
+		topology.link(topology.Services[i].SysID, []string{topology.ApplicationServices[i].SysID}, RelDependsOn)
+	}
+
+	return topology
+}
+
+// GenerateCIs builds ciCount configuration items for the services of the topology,
+// drawing their random values from rng, and returns them with their relationships to
+// each other and to the application services. The CIs are spread over the services in
+// turn from the service of the first-th CI on, so CIs generated in parts reach all of
+// them. The topology itself is left unchanged.
+func (t *Topology) GenerateCIs(rng *rand.Rand, ciCount, first int) *Topology {
+	generated := &Topology{Services: t.Services, ApplicationServices: t.ApplicationServices}
+	serviceCount := len(t.ApplicationServices)
+	if serviceCount == 0 {
+		return generated
+	}
+
+	// Spread the CIs across the services, keeping track of each service's tiers
+	serviceTiers := make([]map[string][]string, serviceCount)
+	for i := range serviceTiers {
+		serviceTiers[i] = make(map[string][]string)
+	}
+
+	for i := 0; i < ciCount; i++ {
+		class := randomCIClass(rng)
+		ci := TopologyCI{
+			SysID: NewSysID(rng),
+			Name:  ciName(class, first+i),
+			Class: class,
+		}
+		generated.CIs = append(generated.CIs, ci)
+
+		tier := ciTier(class)
+		service := (first + i) % serviceCount
+		serviceTiers[service][tier] = append(serviceTiers[service][tier], ci.SysID)
+	}
+
+	for i, tiers := range serviceTiers {
+		appService := t.ApplicationServices[i].SysID
+
+		// The application service depends on the outermost tier present, and each of the
+		// load balancer, application and database tiers depends on the next one present.
+		// Servers and network gear left unlinked below are attached to the application service.
+		parent := []string{appService}
+		for _, tier := range []string{"load_balancer", "application", "database"} {
+			if len(tiers[tier]) == 0 {
+				continue
+			}
+			for _, p := range parent {
+				generated.link(p, tiers[tier], RelDependsOn)
+			}
+			parent = tiers[tier]
+		}
+
+		// Spread applications and databases over the servers, and servers over the network gear
+		hosted := append(append([]string{}, tiers["application"]...), tiers["database"]...)
+		generated.spread(appService, hosted, tiers["server"], func(ci string) string {
+			if indexOf(tiers["database"], ci) >= 0 {
+				return RelHostedOn
+			}
+			return RelRunsOn
+		})
+		generated.spread(appService, tiers["server"], tiers["network"], func(string) string {
+			return RelDependsOn
+		})
+	}
+
+	return generated
+}
+
+// ApplyTopology replaces the services, CIs and relationships of the reference data
+// with those of the topology, and builds the lookups of the new CMDB once for the run
+func (rd *ReferenceData) ApplyTopology(topology *Topology) {
+	rd.CmdbCiService = nil
+	for _, s := range append(append([]TopologyCI{}, topology.Services...), topology.ApplicationServices...) {
+		rd.CmdbCiService = append(rd.CmdbCiService, s.referenceValue())
+	}
+
+	rd.CmdbCi = nil
+	for _, ci := range topology.CIs {
+		rd.CmdbCi = append(rd.CmdbCi, ci.referenceValue())
+	}
+
+	rd.CmdbRelCi = append([]CIRelationship{}, topology.Relationships...)
+	rd.indexes = rd.buildIndexes()
+}
+
+// GetRandomRelatedCI returns a random configuration item that the service depends on,
+// directly or through other CIs. If the service has no related CIs, a random CI is returned.
+func (rd *ReferenceData) GetRandomRelatedCI(rng *rand.Rand, serviceSysID string) *ReferenceValue {
+	idx := rd.index()
+	related, indexed := idx.related[serviceSysID]
+	if !indexed {
+		related = rd.relatedCIs(idx, serviceSysID)
+	}
+	if len(related) == 0 {
+		return rd.GetRandomReference(rng, "cmdb_ci")
+	}
+	return related[rng.Intn(len(related))]
+}
+
+// relatedCIs returns the CIs that the service depends on, directly or through other CIs,
+// or the CIs that depend on it when there are none
+func (rd *ReferenceData) relatedCIs(idx *referenceIndexes, serviceSysID string) []*ReferenceValue {
+	var related []*ReferenceValue
+	visited := map[string]bool{serviceSysID: true}
+	queue := []string{serviceSysID}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, child := range idx.children[current] {
+			if visited[child] {
+				continue
+			}
+			visited[child] = true
+			queue = append(queue, child)
+			if ci := idx.bySysID["cmdb_ci"][child]; ci != nil {
+				related = append(related, ci)
+			}
+		}
+	}
+
+	// Fall back to CIs that depend on the service, as the script include does
+	if len(related) == 0 {
+		for _, rel := range rd.CmdbRelCi {
+			if rel.Child == serviceSysID {
+				if ci := idx.bySysID["cmdb_ci"][rel.Parent]; ci != nil {
+					related = append(related, ci)
+				}
+			}
+		}
+	}
+	return related
+}
+
+// link adds a relationship from the parent to each of the children
+func (t *Topology) link(parent string, children []string, relType string) {
+	for _, child := range children {
+		t.Relationships = append(t.Relationships, CIRelationship{Parent: parent, Child: child, Type: relType})
+	}
+}
+
+// spread links each parent to one of the children in turn. Children left over once
+// every parent is linked are attached to the owner so that no CI is orphaned.
+func (t *Topology) spread(owner string, parents, children []string, relType func(parent string) string) {
+	if len(children) == 0 {
+		return
+	}
+	for i, parent := range parents {
+		t.link(parent, []string{children[i%len(children)]}, relType(parent))
+	}
+	if len(parents) < len(children) {
+		t.link(owner, children[len(parents):], RelDependsOn)
+	}
+}
+
+// indexOf returns the position of value in values, or -1 if it is not present
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// referenceValue converts a topology CI to a reference value carrying its class
+func (ci TopologyCI) referenceValue() ReferenceValue {
+	return ReferenceValue{
+		SysID:        ci.SysID,
+		DisplayValue: ci.Name,
+		Extra:        map[string]string{"sys_class_name": ci.Class},
+	}
+}
+
+// randomCIClass picks a CI class according to the synthetic class mix
+func randomCIClass(rng *rand.Rand) string {
+	total := 0
+	for _, w := range ciClassWeights {
+		total += w.Weight
+	}
+	n := rng.Intn(total)
+	for _, w := range ciClassWeights {
+		if n < w.Weight {
+			return w.Class
+		}
+		n -= w.Weight
+	}
+	return ciClassWeights[0].Class
+}
+
+// ciTier groups CI classes into the tier they occupy in a service
+func ciTier(class string) string {
+	switch {
+	case strings.HasSuffix(class, "_server"):
+		return "server"
+	case strings.HasPrefix(class, "cmdb_ci_db_"):
+		return "database"
+	case strings.HasPrefix(class, "cmdb_ci_lb"):
+		return "load_balancer"
+	case strings.HasPrefix(class, "cmdb_ci_ip_"):
+		return "network"
+	default:
+		return "application"
+	}
+}
+
+// ciName builds a plausible name for a synthetic CI of the given class
+func ciName(class string, index int) string {
+	prefixes := map[string]string{
+		"cmdb_ci_appl":              "app",
+		"cmdb_ci_linux_server":      "lnx",
+		"cmdb_ci_win_server":        "win",
+		"cmdb_ci_db_ora_instance":   "ora",
+		"cmdb_ci_db_mssql_instance": "sql",
+		"cmdb_ci_db_mysql_instance": "mys",
+		"cmdb_ci_lb_bigip":          "lb",
+		"cmdb_ci_ip_switch":         "sw",
+		"cmdb_ci_ip_router":         "rtr",
+	}
+	prefix, ok := prefixes[class]
+	if !ok {
+		prefix = "ci"
+	}
+	return fmt.Sprintf("%s%04d", prefix, index+1)
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadTopology(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "topology.json")
+	content := `{
+  "services": [{"sys_id": "svc1", "name": "Payroll", "sys_class_name": "cmdb_ci_service"}],
+  "cis": [
+    {"sys_id": "app1", "name": "payroll-app", "sys_class_name": "cmdb_ci_appl"},
+    {"sys_id": "srv1", "name": "lnx0001", "sys_class_name": "cmdb_ci_linux_server"},
+    {"sys_id": "srv2", "name": "lnx0002", "sys_class_name": "cmdb_ci_linux_server"}
+  ],
+  "relationships": [
+    {"parent": "svc1", "child": "app1", "type": "Depends on::Used by"},
+    {"parent": "app1", "child": "srv1", "type": "Runs on::Runs"}
+  ]
+}`
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write topology file: %v", err)
+	}
+
+	topology, err := LoadTopology(filename)
+	if err != nil {
+		t.Fatalf("Failed to load topology: %v", err)
+	}
+
+	rd := GetReferenceData()
+	rd.ApplyTopology(topology)
+
+	if len(rd.CmdbCiService) != 1 {
+		t.Errorf("Expected 1 service, got %d", len(rd.CmdbCiService))
+	}
+	if len(rd.CmdbCi) != 3 {
+		t.Errorf("Expected 3 CIs, got %d", len(rd.CmdbCi))
+	}
+	if rd.CmdbCi[0].Extra["sys_class_name"] != "cmdb_ci_appl" {
+		t.Errorf("Expected CI class to be kept, got %s", rd.CmdbCi[0].Extra["sys_class_name"])
+	}
+
+	// srv2 is not related to the service and must never be picked
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		ci := rd.GetRandomRelatedCI(rng, "svc1")
+		if ci.SysID != "app1" && ci.SysID != "srv1" {
+			t.Fatalf("Expected a CI related to the service, got %s", ci.DisplayValue)
+		}
+	}
+}
+
+func TestLoadTopologyWithoutServices(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "empty.json")
+	if err := os.WriteFile(filename, []byte(`{"cis": [{"sys_id": "a", "name": "a"}]}`), 0644); err != nil {
+		t.Fatalf("Failed to write topology file: %v", err)
+	}
+
+	if _, err := LoadTopology(filename); err == nil {
+		t.Error("Expected an error for a topology without services")
+	}
+}
+
+func TestLoadTopologyDanglingRelationships(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "topology.json")
+	content := `{
+  "services": [{"sys_id": "svc1", "name": "Payroll", "sys_class_name": "cmdb_ci_service"}],
+  "application_services": [{"sys_id": "as1", "name": "Payroll Application Service", "sys_class_name": "cmdb_ci_service_auto"}],
+  "cis": [{"sys_id": "app1", "name": "payroll-app", "sys_class_name": "cmdb_ci_appl"}],
+  "relationships": [
+    {"parent": "svc1", "child": "as1", "type": "Depends on::Used by"},
+    {"parent": "as1", "child": "app2", "type": "Depends on::Used by"},
+    {"parent": "svc2", "child": "app1", "type": "Depends on::Used by"}
+  ]
+}`
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write topology file: %v", err)
+	}
+
+	_, err := LoadTopology(filename)
+	if err == nil || !strings.Contains(err.Error(), "relationship 2: unknown child app2\n  relationship 3: unknown parent svc2") {
+		t.Errorf("Expected the dangling relationships to be reported, got %v", err)
+	}
+}
+
+func TestGenerateTopology(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	topology := GenerateTopology(rng, 4, 40)
+
+	if len(topology.Services) != 4 {
+		t.Errorf("Expected 4 services, got %d", len(topology.Services))
+	}
+	if len(topology.CIs) != 40 {
+		t.Errorf("Expected 40 CIs, got %d", len(topology.CIs))
+	}
+
+	rd := GetReferenceData()
+	rd.ApplyTopology(topology)
+
+	// Every CI must be reachable from exactly one service
+	reachable := make(map[string]bool)
+	for _, service := range topology.Services {
+		for i := 0; i < 200; i++ {
+			reachable[rd.GetRandomRelatedCI(rng, service.SysID).SysID] = true
+		}
+	}
+	for _, ci := range topology.CIs {
+		if !reachable[ci.SysID] {
+			t.Errorf("CI %s (%s) is not related to any service", ci.Name, ci.Class)
+		}
+	}
+}
+
+func TestDefaultReferenceDataRelatesServicesToCIs(t *testing.T) {
+	rd := GetReferenceData()
+
+	// Bond Trading depends on bond_trade_ny
+	ci := rd.GetRandomRelatedCI(rand.New(rand.NewSource(1)), "451047c6c0a8016400de0ae6df9b9d76")
+	if ci.DisplayValue != "bond_trade_ny" {
+		t.Errorf("Expected bond_trade_ny for Bond Trading, got %s", ci.DisplayValue)
+	}
+}
+
+func TestApplyTopologyReplacesIndexes(t *testing.T) {
+	rd := GetReferenceData()
+	rng := rand.New(rand.NewSource(1))
+	if ci := rd.GetRandomRelatedCI(rng, "451047c6c0a8016400de0ae6df9b9d76"); ci.DisplayValue != "bond_trade_ny" {
+		t.Fatalf("Expected bond_trade_ny for Bond Trading, got %s", ci.DisplayValue)
+	}
+
+	// CIs of a topology applied after the reference data is used are found, and the
+	// replaced ones are not
+	topology := GenerateTopology(rng, 2, 10)
+	rd.ApplyTopology(topology)
+	if rd.GetReferenceBySysID("cmdb_ci", "55c3578bc0a8010e0117f727897d0011") != nil {
+		t.Error("Expected the replaced CIs to be gone")
+	}
+	for _, ci := range topology.CIs {
+		if found := rd.GetReferenceBySysID("cmdb_ci", ci.SysID); found == nil || found.DisplayValue != ci.Name {
+			t.Errorf("Expected to find CI %s, got %v", ci.Name, found)
+		}
+	}
+	service := topology.Services[0].SysID
+	for i := 0; i < 20; i++ {
+		if ci := rd.GetRandomRelatedCI(rng, service); ci.Extra["sys_class_name"] == "" {
+			t.Errorf("Expected a CI of the topology, got %+v", ci)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// ValueType is the type of value a record field holds, for writers with typed columns
+type ValueType int
+
+// Value types of record fields
+const (
+	ValueString ValueType = iota
+	ValueInt
+	ValueBoolean
+	ValueTimestamp
+	ValueDate
+	ValueReference
+)
+
+// valueTypeTags maps the values of the "type" struct tag to value types
+var valueTypeTags = map[string]ValueType{
+	"int":       ValueInt,
+	"boolean":   ValueBoolean,
+	"timestamp": ValueTimestamp,
+	"date":      ValueDate,
+}
+
+// FieldValueType returns the value type of a record field. Go int and bool fields keep
+// their types, and string fields holding other values name their type in a "type" tag:
+// "int", "boolean", "timestamp" or "date".
+func FieldValueType(field reflect.StructField) (ValueType, error) {
+	if field.Type == reflect.TypeOf(ReferenceValue{}) {
+		return ValueReference, nil
+	}
+
+	switch field.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return ValueInt, nil
+	case reflect.Bool:
+		return ValueBoolean, nil
+	case reflect.String:
+		tag, ok := field.Tag.Lookup("type")
+		if !ok {
+			return ValueString, nil
+		}
+		valueType, ok := valueTypeTags[tag]
+		if !ok {
+			return 0, fmt.Errorf("unsupported value type %q on field %s", tag, field.Name)
+		}
+		return valueType, nil
+	default:
+		return 0, fmt.Errorf("unsupported type %s of field %s", field.Type, field.Name)
+	}
+}
+
+// ParseTime parses a ServiceNow date/time or date value as UTC
+func ParseTime(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02 15:04:05", value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFieldValueType(t *testing.T) {
+	type record struct {
+		Name     string
+		Impact   int
+		Active   bool
+		Caller   ReferenceValue
+		Priority string `type:"int"`
+		Flag     string `type:"boolean"`
+		Opened   string `type:"timestamp"`
+		Due      string `type:"date"`
+		Bad      string `type:"money"`
+		Tags     []string
+	}
+
+	expected := map[string]ValueType{
+		"Name":     ValueString,
+		"Impact":   ValueInt,
+		"Active":   ValueBoolean,
+		"Caller":   ValueReference,
+		"Priority": ValueInt,
+		"Flag":     ValueBoolean,
+		"Opened":   ValueTimestamp,
+		"Due":      ValueDate,
+	}
+
+	rt := reflect.TypeOf(record{})
+	for name, want := range expected {
+		field, _ := rt.FieldByName(name)
+		got, err := FieldValueType(field)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", name, err)
+		} else if got != want {
+			t.Errorf("Expected value type %d for %s, got %d", want, name, got)
+		}
+	}
+
+	for _, name := range []string{"Bad", "Tags"} {
+		field, _ := rt.FieldByName(name)
+		if _, err := FieldValueType(field); err == nil {
+			t.Errorf("Expected an error for field %s", name)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	got, err := ParseTime("2024-03-01 08:30:00")
+	if err != nil || !got.Equal(time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("Expected 2024-03-01 08:30:00 UTC, got %v (%v)", got, err)
+	}
+	got, err = ParseTime("2024-03-05")
+	if err != nil || !got.Equal(time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected 2024-03-05 UTC, got %v (%v)", got, err)
+	}
+	if _, err := ParseTime("yesterday"); err == nil {
+		t.Error("Expected an error for an invalid date")
+	}
+}
@@ -0,0 +1,353 @@
+package profile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/generator"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/pkg/parquet"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/pkg/sql"
+	"gopkg.in/yaml.v3"
+)
+
+// Profile describes a generation run in a YAML file. Settings left out keep the
+// defaults of the command line flags, and flags given on the command line override
+// the profile.
+type Profile struct {
+	Table         string                  `yaml:"table"`
+	Tables        []Table                 `yaml:"tables"`
+	Count         int                     `yaml:"count"`
+	Batch         int                     `yaml:"batch"`
+	Closed        *int                    `yaml:"closed"`
+	Split         *bool                   `yaml:"split"`
+	Seed          *int64                  `yaml:"seed"`
+	Workers       int                     `yaml:"workers"`
+	Timeout       time.Duration           `yaml:"timeout"`
+	OnError       string                  `yaml:"on_error"`
+	References    string                  `yaml:"references"`
+	ReferenceData ReferenceData           `yaml:"reference_data"`
+	Numbering     Numbering               `yaml:"numbering"`
+	Text          Text                    `yaml:"text"`
+	Distributions map[string]Distribution `yaml:"distributions"`
+	Rules         []Rule                  `yaml:"rules"`
+	Output        Output                  `yaml:"output"`
+}
+
+// Table is a table of a multi-table run. Settings left out take the values of the
+// profile or the flags.
+type Table struct {
+	Table   string `yaml:"table"`
+	Count   int    `yaml:"count"`
+	Closed  *int   `yaml:"closed"`
+	Columns string `yaml:"columns"`
+}
+
+// ReferenceData names the sources of the users, groups, accounts, contacts and CIs
+// records refer to
+type ReferenceData struct {
+	File             string `yaml:"file"`
+	Topology         string `yaml:"topology"`
+	TopologyServices int    `yaml:"topology_services"`
+	TopologyCIs      int    `yaml:"topology_cis"`
+	TopologyOut      string `yaml:"topology_out"`
+}
+
+// Numbering sets the record numbers of the table
+type Numbering struct {
+	Prefix string `yaml:"prefix"`
+	Start  int64  `yaml:"start"`
+	Digits int    `yaml:"digits"`
+	Last   string `yaml:"last"`
+}
+
+// Text configures how text fields are written
+type Text struct {
+	Engine      string            `yaml:"engine"`
+	Model       string            `yaml:"model"`
+	Temperature *float64          `yaml:"temperature"`
+	Fallback    bool              `yaml:"fallback"`
+	Prompts     map[string]string `yaml:"prompts"`
+}
+
+// Rule sets, computes or checks fields of the records matching a condition, after they
+// are generated
+type Rule struct {
+	Table   string            `yaml:"table"`
+	When    string            `yaml:"when"`
+	Chance  *float64          `yaml:"chance"`
+	Set     map[string]string `yaml:"set"`
+	Require []string          `yaml:"require"`
+	Check   string            `yaml:"check"`
+}
+
+// Output configures the output file and its columns
+type Output struct {
+	File         string `yaml:"file"`
+	Columns      string `yaml:"columns"`
+	Compression  string `yaml:"compression"`
+	SQLDialect   string `yaml:"sql_dialect"`
+	SQLCopy      *bool  `yaml:"sql_copy"`
+	ReferenceOut string `yaml:"reference_out"`
+}
+
+// Distribution is a distribution of values, written in YAML as a list of values picked
+// equally often or as a map of values to weights
+type Distribution struct {
+	generator.Distribution
+}
+
+// UnmarshalYAML reads a distribution from a list or a map, keeping the order of the file
+func (d *Distribution) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		var values []string
+		if err := node.Decode(&values); err != nil {
+			return err
+		}
+		d.Distribution = generator.Uniform(values...)
+		return nil
+	case yaml.MappingNode:
+		d.Distribution = generator.Distribution{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			var weight float64
+			if err := node.Content[i+1].Decode(&weight); err != nil {
+				return fmt.Errorf("line %d: weight of %q is not a number", node.Content[i+1].Line, node.Content[i].Value)
+			}
+			d.Values = append(d.Values, node.Content[i].Value)
+			d.Weights = append(d.Weights, weight)
+		}
+		return nil
+	default:
+		return fmt.Errorf("line %d: a distribution is a list of values or a map of values to weights", node.Line)
+	}
+}
+
+// Load reads and validates a profile. Reference data and topology files named in the
+// profile are relative to the profile's directory.
+func Load(filename string) (*Profile, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile: %w", err)
+	}
+
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid profile %s: %w", filename, err)
+	}
+
+	dir := filepath.Dir(filename)
+	for _, path := range []*string{&p.ReferenceData.File, &p.ReferenceData.Topology} {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(dir, *path)
+		}
+	}
+	return p, nil
+}
+
+// unknownField matches the error of a YAML key that is not part of the profile
+var unknownField = regexp.MustCompile(`field (\S+) not found in type profile\.\w+`)
+
+// Parse decodes and validates a profile. Unknown keys are errors, so misspelled
+// settings are not silently ignored.
+func Parse(data []byte) (*Profile, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	p := &Profile{}
+	if err := decoder.Decode(p); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("profile is empty")
+		}
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			problems := make([]string, len(typeErr.Errors))
+			for i, problem := range typeErr.Errors {
+				problems[i] = unknownField.ReplaceAllString(problem, "unknown setting $1")
+			}
+			return nil, fmt.Errorf("\n  %s", strings.Join(problems, "\n  "))
+		}
+		return nil, err
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Validate checks the settings of the profile, reporting every problem found
+func (p *Profile) Validate() error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if p.Table != "" && !contains(generator.Tables(), p.Table) {
+		problem("table: unknown table %s (known tables: %s)", p.Table, strings.Join(generator.Tables(), ", "))
+	}
+	if p.Table != "" && len(p.Tables) > 0 {
+		problem("tables: use either table or tables")
+	}
+	seen := make(map[string]bool)
+	for i, t := range p.Tables {
+		switch {
+		case t.Table == "":
+			problem("tables[%d].table: missing", i)
+		case !contains(generator.Tables(), t.Table):
+			problem("tables[%d].table: unknown table %s (known tables: %s)", i, t.Table, strings.Join(generator.Tables(), ", "))
+		case seen[t.Table]:
+			problem("tables[%d].table: %s is listed more than once", i, t.Table)
+		}
+		seen[t.Table] = true
+		if t.Count < 0 {
+			problem("tables[%d].count: %d is negative", i, t.Count)
+		}
+		if t.Closed != nil && (*t.Closed < 0 || *t.Closed > 100) {
+			problem("tables[%d].closed: %d is not between 0 and 100", i, *t.Closed)
+		}
+		if t.Columns != "" {
+			if _, err := models.ParseColumnSpec(t.Columns); err != nil {
+				problem("tables[%d].columns: %v", i, err)
+			}
+		}
+	}
+	if p.Count < 0 {
+		problem("count: %d is negative", p.Count)
+	}
+	if p.Batch < 0 {
+		problem("batch: %d is negative", p.Batch)
+	}
+	if p.Workers < 0 {
+		problem("workers: %d is negative", p.Workers)
+	}
+	if p.Timeout < 0 {
+		problem("timeout: %v is negative", p.Timeout)
+	}
+	if p.OnError != "" {
+		if _, err := generator.ParseErrorPolicy(p.OnError); err != nil {
+			problem("on_error: %v", err)
+		}
+	}
+	if p.Closed != nil && (*p.Closed < 0 || *p.Closed > 100) {
+		problem("closed: %d is not between 0 and 100", *p.Closed)
+	}
+	if p.References != "" {
+		if _, err := models.ParseReferenceMode(p.References); err != nil {
+			problem("references: %v", err)
+		}
+	}
+
+	if p.ReferenceData.File != "" && !strings.HasSuffix(strings.ToLower(p.ReferenceData.File), ".json") {
+		problem("reference_data.file: %s is not a JSON file", p.ReferenceData.File)
+	}
+	if p.ReferenceData.Topology != "" && p.ReferenceData.TopologyServices > 0 {
+		problem("reference_data: topology and topology_services cannot be used together")
+	}
+	if p.ReferenceData.TopologyServices < 0 {
+		problem("reference_data.topology_services: %d is negative", p.ReferenceData.TopologyServices)
+	}
+	if p.ReferenceData.TopologyCIs < 0 {
+		problem("reference_data.topology_cis: %d is negative", p.ReferenceData.TopologyCIs)
+	}
+
+	if p.Numbering.Start < 0 {
+		problem("numbering.start: %d is negative", p.Numbering.Start)
+	}
+	if p.Numbering.Digits < 0 || p.Numbering.Digits > 18 {
+		problem("numbering.digits: %d is not between 1 and 18", p.Numbering.Digits)
+	}
+
+	switch p.Text.Engine {
+	case "", generator.TextLLM, generator.TextTemplate:
+	default:
+		problem("text.engine: unknown engine %s (use %s or %s)", p.Text.Engine, generator.TextLLM, generator.TextTemplate)
+	}
+	if t := p.Text.Temperature; t != nil && (*t < 0 || *t > 2) {
+		problem("text.temperature: %v is not between 0 and 2", *t)
+	}
+	for _, name := range sortedKeys(p.Text.Prompts) {
+		if err := generator.ValidatePrompt(name, p.Text.Prompts[name]); err != nil {
+			problem("text.prompts.%s: %v", name, err)
+		}
+	}
+
+	for _, name := range sortedKeys(p.Distributions) {
+		if !contains(generator.DistributionNames(), name) {
+			problem("distributions.%s: unknown distribution (known distributions: %s)", name, strings.Join(generator.DistributionNames(), ", "))
+		} else if err := p.Distributions[name].Validate(); err != nil {
+			problem("distributions.%s: %v", name, err)
+		}
+	}
+
+	if _, err := generator.ParseRules(p.GeneratorRules()); err != nil {
+		problem("%v", err)
+	}
+
+	if p.Output.Columns != "" {
+		if _, err := models.ParseColumnSpec(p.Output.Columns); err != nil {
+			problem("output.columns: %v", err)
+		}
+	}
+	if p.Output.Compression != "" && !contains(parquet.Compressions(), p.Output.Compression) {
+		problem("output.compression: unknown codec %s (use %s)", p.Output.Compression, strings.Join(parquet.Compressions(), ", "))
+	}
+	if p.Output.SQLDialect != "" {
+		if _, err := sql.ParseDialect(p.Output.SQLDialect); err != nil {
+			problem("output.sql_dialect: %v", err)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// GeneratorDistributions returns the distributions of the profile as the generator
+// takes them
+func (p *Profile) GeneratorDistributions() map[string]generator.Distribution {
+	if len(p.Distributions) == 0 {
+		return nil
+	}
+	distributions := make(map[string]generator.Distribution, len(p.Distributions))
+	for name, d := range p.Distributions {
+		distributions[name] = d.Distribution
+	}
+	return distributions
+}
+
+// GeneratorRules returns the rules of the profile as the generator takes them
+func (p *Profile) GeneratorRules() []models.Rule {
+	rules := make([]models.Rule, len(p.Rules))
+	for i, r := range p.Rules {
+		rules[i] = models.Rule{Table: r.Table, When: r.When, Chance: r.Chance, Set: r.Set, Require: r.Require, Check: r.Check}
+	}
+	return rules
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
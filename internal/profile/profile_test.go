@@ -0,0 +1,226 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	p, err := Parse([]byte(`
+table: hr_case
+count: 250
+closed: 0
+timeout: 90m
+on_error: retry 2
+split: true
+text:
+  engine: template
+  temperature: 0.2
+  prompts:
+    knowledge_title: "Title about {category}"
+distributions:
+  hr_category:
+    Payroll: 3
+    Benefits: 1
+  change_risk: [Low, High]
+rules:
+  - when: priority == 1
+    chance: 70
+    set:
+      priority: 2
+  - table: hr_case
+    when: state == "Awaiting Info"
+    require: [assigned_to]
+output:
+  file: hr.xlsx
+  columns: "number,u_source=datagen"
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if p.Table != "hr_case" || p.Count != 250 || p.Output.File != "hr.xlsx" {
+		t.Errorf("Unexpected profile %+v", p)
+	}
+	if p.Closed == nil || *p.Closed != 0 {
+		t.Error("Expected closed: 0 to be kept apart from a missing setting")
+	}
+	if p.Timeout != 90*time.Minute {
+		t.Errorf("Expected a timeout of 90m, got %v", p.Timeout)
+	}
+	if p.OnError != "retry 2" {
+		t.Errorf("Expected the error policy to be kept, got %q", p.OnError)
+	}
+	if p.Split == nil || !*p.Split {
+		t.Error("Expected split to be set")
+	}
+	if p.Text.Temperature == nil || *p.Text.Temperature != 0.2 {
+		t.Error("Expected the temperature to be set")
+	}
+
+	distributions := p.GeneratorDistributions()
+	hr := distributions["hr_category"]
+	if !reflect.DeepEqual(hr.Values, []string{"Payroll", "Benefits"}) || !reflect.DeepEqual(hr.Weights, []float64{3, 1}) {
+		t.Errorf("Expected weighted values in file order, got %+v", hr)
+	}
+	risk := distributions["change_risk"]
+	if !reflect.DeepEqual(risk.Values, []string{"Low", "High"}) || !reflect.DeepEqual(risk.Weights, []float64{1, 1}) {
+		t.Errorf("Expected a list to give equal weights, got %+v", risk)
+	}
+
+	rules := p.GeneratorRules()
+	if len(rules) != 2 || rules[0].Set["priority"] != "2" || *rules[0].Chance != 70 || rules[1].Require[0] != "assigned_to" {
+		t.Errorf("Unexpected rules %+v", rules)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse([]byte(`
+table: problem
+closed: 120
+on_error: ignore
+references: names
+text:
+  engine: gpt
+  prompts:
+    change_test_plan: "Test plan for {product}"
+distributions:
+  change_risks: [Low]
+  hr_state: {}
+rules:
+  - when: state = = Closed
+    set:
+      state: Resolved
+output:
+  columns: "number,,state"
+  sql_dialect: oracle
+`))
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	// Every problem is reported at once, named by its setting
+	for _, expected := range []string{
+		"table: unknown table problem",
+		"closed: 120 is not between 0 and 100",
+		"on_error: unknown error policy",
+		"references: invalid reference mode",
+		"text.engine: unknown engine gpt",
+		"text.prompts.change_test_plan: prompt change_test_plan uses unknown placeholder {product}",
+		"distributions.change_risks: unknown distribution",
+		"distributions.hr_state: has no values",
+		"rules[0].when: expected a value",
+		"output.columns: empty entry",
+		"output.sql_dialect: unsupported SQL dialect",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to contain %q, got:%v", expected, err)
+		}
+	}
+}
+
+func TestParseTables(t *testing.T) {
+	p, err := Parse([]byte(`
+seed: 42
+tables:
+  - table: master_data
+    count: 50
+  - table: incident
+    closed: 0
+    columns: "number,state"
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if p.Seed == nil || *p.Seed != 42 {
+		t.Error("Expected the seed to be set")
+	}
+	if len(p.Tables) != 2 || p.Tables[0].Table != "master_data" || p.Tables[0].Count != 50 {
+		t.Fatalf("Unexpected tables %+v", p.Tables)
+	}
+	if p.Tables[1].Closed == nil || *p.Tables[1].Closed != 0 || p.Tables[1].Columns != "number,state" {
+		t.Errorf("Unexpected incident table %+v", p.Tables[1])
+	}
+
+	_, err = Parse([]byte(`
+table: incident
+tables:
+  - count: 5
+  - table: problem
+  - table: case
+    closed: -1
+  - table: case
+    columns: "number,"
+`))
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, expected := range []string{
+		"tables: use either table or tables",
+		"tables[0].table: missing",
+		"tables[1].table: unknown table problem",
+		"tables[2].closed: -1 is not between 0 and 100",
+		"tables[3].table: case is listed more than once",
+		"tables[3].columns: empty entry",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to contain %q, got:%v", expected, err)
+		}
+	}
+}
+
+func TestParseUnknownSetting(t *testing.T) {
+	_, err := Parse([]byte("table: incident\ncuont: 10\ntext:\n  engnie: llm\n"))
+	if err == nil {
+		t.Fatal("Expected an error for misspelled settings")
+	}
+	for _, expected := range []string{"line 2: unknown setting cuont", "line 4: unknown setting engnie"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to contain %q, got: %v", expected, err)
+		}
+	}
+
+	if _, err := Parse([]byte("distributions:\n  change_risk: Low\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected a distribution error with its line, got %v", err)
+	}
+	if _, err := Parse([]byte("distributions:\n  change_risk:\n    Low: often\n")); err == nil || !strings.Contains(err.Error(), `weight of "Low" is not a number`) {
+		t.Errorf("Expected an error for a weight that is not a number, got %v", err)
+	}
+	if _, err := Parse(nil); err == nil {
+		t.Error("Expected an error for an empty profile")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "scenario.yaml")
+	content := "table: incident\nreference_data:\n  file: reference.json\n  topology: /data/topology.json\n"
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+
+	p, err := Load(filename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if p.ReferenceData.File != filepath.Join(dir, "reference.json") {
+		t.Errorf("Expected the reference data file relative to the profile, got %s", p.ReferenceData.File)
+	}
+	if p.ReferenceData.Topology != "/data/topology.json" {
+		t.Errorf("Expected an absolute path to be kept, got %s", p.ReferenceData.Topology)
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("Expected an error for a missing profile")
+	}
+}
+
+func TestExampleProfile(t *testing.T) {
+	if _, err := Load("../../profiles/example.yaml"); err != nil {
+		t.Errorf("Example profile is invalid: %v", err)
+	}
+}
@@ -1,421 +1,578 @@
-package csv
-
-import (
-	"os"
-	"strings"
-	"testing"
-
-	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/generator"
-)
-
-func TestNewWriter(t *testing.T) {
-	filename := "test_output.csv"
-	defer os.Remove(filename) // Clean up
-
-	writer, err := NewWriter(filename)
-	if err != nil {
-		t.Fatalf("Failed to create CSV writer: %v", err)
-	}
-	defer writer.Close()
-
-	// Note: filename is private, so we can't test it directly
-	// Instead, we verify the writer was created successfully
-	if writer.file == nil {
-		t.Error("Expected file to be initialized")
-	}
-	if writer.writer == nil {
-		t.Error("Expected CSV writer to be initialized")
-	}
-}
-
-func TestGetIncidentHeaders(t *testing.T) {
-	headers := GetIncidentHeaders()
-
-	expectedHeaders := []string{
-		"Caller", "Category", "Subcategory", "Service", "Service offering",
-		"Configuration item", "Short description", "Description", "Channel",
-		"Opened", "Incident State", "Impact", "Urgency", "Priority",
-		"Assignment group", "Assigned to", "Resolution code", "Resolution notes",
-	}
-
-	if len(headers) != len(expectedHeaders) {
-		t.Errorf("Expected %d headers, got %d", len(expectedHeaders), len(headers))
-	}
-
-	for i, expected := range expectedHeaders {
-		if i >= len(headers) || headers[i] != expected {
-			t.Errorf("Expected header %d to be %s, got %s", i, expected, headers[i])
-		}
-	}
-}
-
-func TestGetCaseHeaders(t *testing.T) {
-	headers := GetCaseHeaders()
-
-	// Check that we have the expected number of headers
-	if len(headers) == 0 {
-		t.Error("Expected case headers to be non-empty")
-	}
-
-	// Check for some key headers
-	expectedHeaders := []string{"Number", "Account", "Contact", "Short description", "State"}
-	for _, expected := range expectedHeaders {
-		found := false
-		for _, header := range headers {
-			if header == expected {
-				found = true
-				break
-			}
-		}
-		if !found {
-			t.Errorf("Expected to find header %s in case headers", expected)
-		}
-	}
-}
-
-func TestSetHeaders(t *testing.T) {
-	filename := "test_headers.csv"
-	defer os.Remove(filename) // Clean up
-
-	writer, err := NewWriter(filename)
-	if err != nil {
-		t.Fatalf("Failed to create CSV writer: %v", err)
-	}
-	defer writer.Close()
-
-	headers := []string{"Header1", "Header2", "Header3"}
-	err = writer.SetHeaders(headers)
-	if err != nil {
-		t.Fatalf("Failed to set headers: %v", err)
-	}
-
-	// Close writer to flush data
-	writer.Close()
-
-	// Read the file and check headers
-	content, err := os.ReadFile(filename)
-	if err != nil {
-		t.Fatalf("Failed to read file: %v", err)
-	}
-
-	contentStr := string(content)
-	expectedLine := "Header1,Header2,Header3"
-	if !strings.Contains(contentStr, expectedLine) {
-		t.Errorf("Expected file to contain %s, got %s", expectedLine, contentStr)
-	}
-}
-
-func TestWriteIncidentRecords(t *testing.T) {
-	filename := "test_incidents.csv"
-	defer os.Remove(filename) // Clean up
-
-	writer, err := NewWriter(filename)
-	if err != nil {
-		t.Fatalf("Failed to create CSV writer: %v", err)
-	}
-	defer writer.Close()
-
-	// Set headers
-	headers := GetIncidentHeaders()
-	err = writer.SetHeaders(headers)
-	if err != nil {
-		t.Fatalf("Failed to set headers: %v", err)
-	}
-
-	// Create test incident records
-	records := []interface{}{
-		&generator.IncidentRecord{
-			Caller:           "John Doe",
-			Category:         "Hardware",
-			Subcategory:      "Printer",
-			Service:          "IT Services",
-			ShortDescription: "Printer not working",
-			Description:      "The office printer is not responding",
-			Channel:          "Phone",
-			Opened:           "2024-01-01 10:00:00",
-			IncidentState:    "New",
-			Impact:           2,
-			Urgency:          3,
-			Priority:         "",
-			AssignmentGroup:  "IT Support",
-			AssignedTo:       "Jane Smith",
-		},
-		&generator.IncidentRecord{
-			Caller:           "Bob Wilson",
-			Category:         "Software",
-			Subcategory:      "Email",
-			Service:          "Email Services",
-			ShortDescription: "Cannot send emails",
-			Description:      "User unable to send emails from Outlook",
-			Channel:          "Email",
-			Opened:           "2024-01-02 14:30:00",
-			IncidentState:    "In Progress",
-			Impact:           3,
-			Urgency:          2,
-			Priority:         "",
-			AssignmentGroup:  "Email Support",
-			AssignedTo:       "Mike Johnson",
-		},
-	}
-
-	// Write records
-	err = writer.WriteRecords(records)
-	if err != nil {
-		t.Fatalf("Failed to write records: %v", err)
-	}
-
-	// Close writer to flush data
-	writer.Close()
-
-	// Read the file and verify content
-	content, err := os.ReadFile(filename)
-	if err != nil {
-		t.Fatalf("Failed to read file: %v", err)
-	}
-
-	contentStr := string(content)
-
-	// Check that both records are present
-	if !strings.Contains(contentStr, "John Doe") {
-		t.Error("Expected file to contain first record")
-	}
-	if !strings.Contains(contentStr, "Bob Wilson") {
-		t.Error("Expected file to contain second record")
-	}
-	if !strings.Contains(contentStr, "Printer not working") {
-		t.Error("Expected file to contain first record's description")
-	}
-	if !strings.Contains(contentStr, "Cannot send emails") {
-		t.Error("Expected file to contain second record's description")
-	}
-
-	// Count lines (should be header + 2 records = 3 lines)
-	lines := strings.Split(strings.TrimSpace(contentStr), "\n")
-	if len(lines) != 3 {
-		t.Errorf("Expected 3 lines (header + 2 records), got %d", len(lines))
-	}
-}
-
-func TestWriteCaseRecords(t *testing.T) {
-	filename := "test_cases.csv"
-	defer os.Remove(filename) // Clean up
-
-	writer, err := NewWriter(filename)
-	if err != nil {
-		t.Fatalf("Failed to create CSV writer: %v", err)
-	}
-	defer writer.Close()
-
-	// Set headers
-	headers := GetCaseHeaders()
-	err = writer.SetHeaders(headers)
-	if err != nil {
-		t.Fatalf("Failed to set headers: %v", err)
-	}
-
-	// Create test case records
-	records := []interface{}{
-		&generator.CaseRecord{
-			Number:           "CS0001",
-			Account:          "Test Company",
-			Contact:          "John Doe",
-			ShortDescription: "Account access issue",
-			OpenedAt:         "2024-01-01 10:00:00",
-			Priority:         2,
-			State:            "New",
-		},
-	}
-
-	// Write records
-	err = writer.WriteRecords(records)
-	if err != nil {
-		t.Fatalf("Failed to write records: %v", err)
-	}
-
-	// Close writer to flush data
-	writer.Close()
-
-	// Read the file and verify content
-	content, err := os.ReadFile(filename)
-	if err != nil {
-		t.Fatalf("Failed to read file: %v", err)
-	}
-
-	contentStr := string(content)
-
-	// Check that record is present
-	if !strings.Contains(contentStr, "CS0001") {
-		t.Error("Expected file to contain case number")
-	}
-	if !strings.Contains(contentStr, "Test Company") {
-		t.Error("Expected file to contain account name")
-	}
-	if !strings.Contains(contentStr, "Account access issue") {
-		t.Error("Expected file to contain case description")
-	}
-}
-
-func TestWriteRecord(t *testing.T) {
-	filename := "test_single_record.csv"
-	defer os.Remove(filename) // Clean up
-
-	writer, err := NewWriter(filename)
-	if err != nil {
-		t.Fatalf("Failed to create CSV writer: %v", err)
-	}
-	defer writer.Close()
-
-	// Set headers
-	headers := GetIncidentHeaders()
-	err = writer.SetHeaders(headers)
-	if err != nil {
-		t.Fatalf("Failed to set headers: %v", err)
-	}
-
-	// Create test incident record
-	record := &generator.IncidentRecord{
-		Caller:           "Test User",
-		Category:         "Network",
-		Subcategory:      "Connectivity",
-		ShortDescription: "Network down",
-		Description:      "Network connectivity issues",
-		Opened:           "2024-01-01 10:00:00",
-		IncidentState:    "New",
-		Impact:           1,
-		Urgency:          1,
-	}
-
-	// Write single record
-	err = writer.WriteRecord(record)
-	if err != nil {
-		t.Fatalf("Failed to write record: %v", err)
-	}
-
-	// Close writer to flush data
-	writer.Close()
-
-	// Read the file and verify content
-	content, err := os.ReadFile(filename)
-	if err != nil {
-		t.Fatalf("Failed to read file: %v", err)
-	}
-
-	contentStr := string(content)
-
-	// Check that record is present
-	if !strings.Contains(contentStr, "Test User") {
-		t.Error("Expected file to contain caller name")
-	}
-	if !strings.Contains(contentStr, "Network down") {
-		t.Error("Expected file to contain short description")
-	}
-}
-
-func TestWriteUnsupportedRecordType(t *testing.T) {
-	filename := "test_unsupported.csv"
-	defer os.Remove(filename) // Clean up
-
-	writer, err := NewWriter(filename)
-	if err != nil {
-		t.Fatalf("Failed to create CSV writer: %v", err)
-	}
-	defer writer.Close()
-
-	// Set headers
-	headers := []string{"Test"}
-	err = writer.SetHeaders(headers)
-	if err != nil {
-		t.Fatalf("Failed to set headers: %v", err)
-	}
-
-	// Try to write unsupported record type (string is not a struct)
-	unsupportedRecord := "this is not a supported record type"
-	err = writer.WriteRecord(unsupportedRecord)
-
-	// The writer should handle this gracefully (no error, but empty values)
-	if err != nil {
-		t.Errorf("Expected no error for non-struct record type, got: %v", err)
-	}
-
-	// Close writer to flush data
-	writer.Close()
-
-	// Read the file and verify it only has headers
-	content, err := os.ReadFile(filename)
-	if err != nil {
-		t.Fatalf("Failed to read file: %v", err)
-	}
-
-	contentStr := string(content)
-	lines := strings.Split(strings.TrimSpace(contentStr), "\n")
-
-	// Should have only header line (non-struct records don't write data)
-	if len(lines) != 1 {
-		t.Errorf("Expected 1 line (header only), got %d", len(lines))
-	}
-
-	// Verify it's just the header
-	if lines[0] != "Test" {
-		t.Errorf("Expected header line to be 'Test', got %s", lines[0])
-	}
-}
-
-func TestClose(t *testing.T) {
-	filename := "test_close.csv"
-	defer os.Remove(filename) // Clean up
-
-	writer, err := NewWriter(filename)
-	if err != nil {
-		t.Fatalf("Failed to create CSV writer: %v", err)
-	}
-
-	// Close the writer
-	err = writer.Close()
-	if err != nil {
-		t.Fatalf("Failed to close writer: %v", err)
-	}
-
-	// Verify file exists
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		t.Error("Expected file to exist after closing writer")
-	}
-}
-
-// Benchmark tests
-
-func BenchmarkWriteIncidentRecord(b *testing.B) {
-	filename := "bench_incidents.csv"
-	defer os.Remove(filename) // Clean up
-
-	writer, err := NewWriter(filename)
-	if err != nil {
-		b.Fatalf("Failed to create CSV writer: %v", err)
-	}
-	defer writer.Close()
-
-	headers := GetIncidentHeaders()
-	err = writer.SetHeaders(headers)
-	if err != nil {
-		b.Fatalf("Failed to set headers: %v", err)
-	}
-
-	record := &generator.IncidentRecord{
-		Caller:           "Benchmark User",
-		Category:         "Hardware",
-		Subcategory:      "Printer",
-		ShortDescription: "Benchmark test",
-		Description:      "This is a benchmark test record",
-		Opened:           "2024-01-01 10:00:00",
-		IncidentState:    "New",
-		Impact:           2,
-		Urgency:          3,
-	}
-
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		err := writer.WriteRecord(record)
-		if err != nil {
-			b.Fatalf("Failed to write record: %v", err)
-		}
-	}
-}
+package csv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/generator"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+func TestNewWriter(t *testing.T) {
+	filename := "test_output.csv"
+	defer os.Remove(filename) // Clean up
+
+	writer, err := NewWriter(filename)
+	if err != nil {
+		t.Fatalf("Failed to create CSV writer: %v", err)
+	}
+	defer writer.Close()
+
+	// Note: filename is private, so we can't test it directly
+	// Instead, we verify the writer was created successfully
+	if writer.file == nil {
+		t.Error("Expected file to be initialized")
+	}
+	if writer.writer == nil {
+		t.Error("Expected CSV writer to be initialized")
+	}
+}
+
+// tableHeaders returns the column labels of a table's records
+func tableHeaders(tb testing.TB, table string) []string {
+	tb.Helper()
+	columns, err := generator.TableColumns(table, models.ReferenceDisplay)
+	if err != nil {
+		tb.Fatalf("Failed to get columns of %s: %v", table, err)
+	}
+	return models.ColumnLabels(columns)
+}
+
+func TestSetHeaders(t *testing.T) {
+	filename := "test_headers.csv"
+	defer os.Remove(filename) // Clean up
+
+	writer, err := NewWriter(filename)
+	if err != nil {
+		t.Fatalf("Failed to create CSV writer: %v", err)
+	}
+	defer writer.Close()
+
+	headers := []string{"Header1", "Header2", "Header3"}
+	err = writer.SetHeaders(headers)
+	if err != nil {
+		t.Fatalf("Failed to set headers: %v", err)
+	}
+
+	// Close writer to flush data
+	writer.Close()
+
+	// Read the file and check headers
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	contentStr := string(content)
+	expectedLine := "Header1,Header2,Header3"
+	if !strings.Contains(contentStr, expectedLine) {
+		t.Errorf("Expected file to contain %s, got %s", expectedLine, contentStr)
+	}
+}
+
+func TestWriteIncidentRecords(t *testing.T) {
+	filename := "test_incidents.csv"
+	defer os.Remove(filename) // Clean up
+
+	writer, err := NewWriter(filename)
+	if err != nil {
+		t.Fatalf("Failed to create CSV writer: %v", err)
+	}
+	defer writer.Close()
+
+	// Set headers
+	headers := tableHeaders(t, "incident")
+	err = writer.SetHeaders(headers)
+	if err != nil {
+		t.Fatalf("Failed to set headers: %v", err)
+	}
+
+	// Create test incident records
+	records := []interface{}{
+		&generator.IncidentRecord{
+			Caller:           models.ReferenceValue{DisplayValue: "John Doe"},
+			Category:         "Hardware",
+			Subcategory:      "Printer",
+			Service:          models.ReferenceValue{DisplayValue: "IT Services"},
+			ShortDescription: "Printer not working",
+			Description:      "The office printer is not responding",
+			Channel:          "Phone",
+			Opened:           "2024-01-01 10:00:00",
+			IncidentState:    "New",
+			Impact:           2,
+			Urgency:          3,
+			Priority:         "",
+			AssignmentGroup:  models.ReferenceValue{DisplayValue: "IT Support"},
+			AssignedTo:       models.ReferenceValue{DisplayValue: "Jane Smith"},
+		},
+		&generator.IncidentRecord{
+			Caller:           models.ReferenceValue{DisplayValue: "Bob Wilson"},
+			Category:         "Software",
+			Subcategory:      "Email",
+			Service:          models.ReferenceValue{DisplayValue: "Email Services"},
+			ShortDescription: "Cannot send emails",
+			Description:      "User unable to send emails from Outlook",
+			Channel:          "Email",
+			Opened:           "2024-01-02 14:30:00",
+			IncidentState:    "In Progress",
+			Impact:           3,
+			Urgency:          2,
+			Priority:         "",
+			AssignmentGroup:  models.ReferenceValue{DisplayValue: "Email Support"},
+			AssignedTo:       models.ReferenceValue{DisplayValue: "Mike Johnson"},
+		},
+	}
+
+	// Write records
+	err = writer.WriteRecords(records)
+	if err != nil {
+		t.Fatalf("Failed to write records: %v", err)
+	}
+
+	// Close writer to flush data
+	writer.Close()
+
+	// Read the file and verify content
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	contentStr := string(content)
+
+	// Check that both records are present
+	if !strings.Contains(contentStr, "John Doe") {
+		t.Error("Expected file to contain first record")
+	}
+	if !strings.Contains(contentStr, "Bob Wilson") {
+		t.Error("Expected file to contain second record")
+	}
+	if !strings.Contains(contentStr, "Printer not working") {
+		t.Error("Expected file to contain first record's description")
+	}
+	if !strings.Contains(contentStr, "Cannot send emails") {
+		t.Error("Expected file to contain second record's description")
+	}
+
+	// Count lines (should be header + 2 records = 3 lines)
+	lines := strings.Split(strings.TrimSpace(contentStr), "\n")
+	if len(lines) != 3 {
+		t.Errorf("Expected 3 lines (header + 2 records), got %d", len(lines))
+	}
+}
+
+func TestWriteCaseRecords(t *testing.T) {
+	filename := "test_cases.csv"
+	defer os.Remove(filename) // Clean up
+
+	writer, err := NewWriter(filename)
+	if err != nil {
+		t.Fatalf("Failed to create CSV writer: %v", err)
+	}
+	defer writer.Close()
+
+	// Set headers
+	headers := tableHeaders(t, "case")
+	err = writer.SetHeaders(headers)
+	if err != nil {
+		t.Fatalf("Failed to set headers: %v", err)
+	}
+
+	// Create test case records
+	records := []interface{}{
+		&generator.CaseRecord{
+			Number:           "CS0001",
+			Account:          models.ReferenceValue{DisplayValue: "Test Company"},
+			Contact:          models.ReferenceValue{DisplayValue: "John Doe"},
+			ShortDescription: "Account access issue",
+			OpenedAt:         "2024-01-01 10:00:00",
+			Priority:         2,
+			State:            "New",
+		},
+	}
+
+	// Write records
+	err = writer.WriteRecords(records)
+	if err != nil {
+		t.Fatalf("Failed to write records: %v", err)
+	}
+
+	// Close writer to flush data
+	writer.Close()
+
+	// Read the file and verify content
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	contentStr := string(content)
+
+	// Check that record is present
+	if !strings.Contains(contentStr, "CS0001") {
+		t.Error("Expected file to contain case number")
+	}
+	if !strings.Contains(contentStr, "Test Company") {
+		t.Error("Expected file to contain account name")
+	}
+	if !strings.Contains(contentStr, "Account access issue") {
+		t.Error("Expected file to contain case description")
+	}
+}
+
+func TestWriteRecord(t *testing.T) {
+	filename := "test_single_record.csv"
+	defer os.Remove(filename) // Clean up
+
+	writer, err := NewWriter(filename)
+	if err != nil {
+		t.Fatalf("Failed to create CSV writer: %v", err)
+	}
+	defer writer.Close()
+
+	// Set headers
+	headers := tableHeaders(t, "incident")
+	err = writer.SetHeaders(headers)
+	if err != nil {
+		t.Fatalf("Failed to set headers: %v", err)
+	}
+
+	// Create test incident record
+	record := &generator.IncidentRecord{
+		Caller:           models.ReferenceValue{DisplayValue: "Test User"},
+		Category:         "Network",
+		Subcategory:      "Connectivity",
+		ShortDescription: "Network down",
+		Description:      "Network connectivity issues",
+		Opened:           "2024-01-01 10:00:00",
+		IncidentState:    "New",
+		Impact:           1,
+		Urgency:          1,
+	}
+
+	// Write single record
+	err = writer.WriteRecord(record)
+	if err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+
+	// Close writer to flush data
+	writer.Close()
+
+	// Read the file and verify content
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	contentStr := string(content)
+
+	// Check that record is present
+	if !strings.Contains(contentStr, "Test User") {
+		t.Error("Expected file to contain caller name")
+	}
+	if !strings.Contains(contentStr, "Network down") {
+		t.Error("Expected file to contain short description")
+	}
+}
+
+func TestWriteUnsupportedRecordType(t *testing.T) {
+	filename := "test_unsupported.csv"
+	defer os.Remove(filename) // Clean up
+
+	writer, err := NewWriter(filename)
+	if err != nil {
+		t.Fatalf("Failed to create CSV writer: %v", err)
+	}
+	defer writer.Close()
+
+	// Set headers
+	headers := []string{"Test"}
+	err = writer.SetHeaders(headers)
+	if err != nil {
+		t.Fatalf("Failed to set headers: %v", err)
+	}
+
+	// Try to write unsupported record type (string is not a struct)
+	unsupportedRecord := "this is not a supported record type"
+	err = writer.WriteRecord(unsupportedRecord)
+
+	// The writer should handle this gracefully (no error, but empty values)
+	if err != nil {
+		t.Errorf("Expected no error for non-struct record type, got: %v", err)
+	}
+
+	// Close writer to flush data
+	writer.Close()
+
+	// Read the file and verify it only has headers
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	contentStr := string(content)
+	lines := strings.Split(strings.TrimSpace(contentStr), "\n")
+
+	// Should have only header line (non-struct records don't write data)
+	if len(lines) != 1 {
+		t.Errorf("Expected 1 line (header only), got %d", len(lines))
+	}
+
+	// Verify it's just the header
+	if lines[0] != "Test" {
+		t.Errorf("Expected header line to be 'Test', got %s", lines[0])
+	}
+}
+
+func TestWriteRecordReferenceModes(t *testing.T) {
+	record := &generator.IncidentRecord{
+		SysID:  "0123456789abcdef0123456789abcdef",
+		Number: "INC0010001",
+		Caller: models.ReferenceValue{SysID: "46d44a23a9fe19810012d100cca80666", DisplayValue: "Beth Anglin"},
+	}
+	tests := []struct {
+		mode     models.ReferenceMode
+		expected string
+	}{
+		{models.ReferenceDisplay, "Beth Anglin"},
+		{models.ReferenceSysID, "46d44a23a9fe19810012d100cca80666"},
+		{models.ReferenceBoth, "46d44a23a9fe19810012d100cca80666,Beth Anglin"},
+	}
+
+	for _, tt := range tests {
+		filename := "test_references_" + string(tt.mode) + ".csv"
+		defer os.Remove(filename) // Clean up
+
+		writer, err := NewWriter(filename)
+		if err != nil {
+			t.Fatalf("Failed to create CSV writer: %v", err)
+		}
+		writer.SetReferenceMode(tt.mode)
+
+		// Without headers the column labels are written
+		if err := writer.WriteRecord(record); err != nil {
+			t.Fatalf("Failed to write record: %v", err)
+		}
+		writer.Close()
+
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		expected := "0123456789abcdef0123456789abcdef,INC0010001," + tt.expected + ","
+		if len(lines) != 2 || !strings.HasPrefix(lines[1], expected) {
+			t.Errorf("Mode %s: expected record line starting %q, got %q", tt.mode, expected, lines)
+		}
+		header := "Sys ID,Number,Caller,"
+		if tt.mode == models.ReferenceBoth {
+			header = "Sys ID,Number,Caller,Caller.display,"
+		}
+		if !strings.HasPrefix(lines[0], header) {
+			t.Errorf("Mode %s: expected header line starting %q, got %q", tt.mode, header, lines[0])
+		}
+	}
+}
+
+func TestWriteLabelsFromTags(t *testing.T) {
+	filename := "test_labels.csv"
+	defer os.Remove(filename) // Clean up
+
+	writer, err := NewWriter(filename)
+	if err != nil {
+		t.Fatalf("Failed to create CSV writer: %v", err)
+	}
+	record := &generator.HRCaseRecord{
+		Number:        "HRC0010001",
+		OpenedFor:     models.ReferenceValue{DisplayValue: "Beth Anglin"},
+		HRService:     "Benefits Enrollment",
+		DueDate:       "2024-03-05",
+		Priority:      2,
+		SubjectPerson: models.ReferenceValue{DisplayValue: "Abel Tuter"},
+	}
+	if err := writer.WriteRecord(record); err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+	writer.Close()
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a header and a record line, got %q", lines)
+	}
+
+	// Every value lands under the header of its own field
+	headers := strings.Split(lines[0], ",")
+	values := strings.Split(lines[1], ",")
+	if len(headers) != len(values) {
+		t.Fatalf("Expected %d values, got %d", len(headers), len(values))
+	}
+	row := make(map[string]string)
+	for i, header := range headers {
+		row[header] = values[i]
+	}
+	for header, expected := range map[string]string{
+		"Number":         "HRC0010001",
+		"Opened for":     "Beth Anglin",
+		"HR service":     "Benefits Enrollment",
+		"Subject person": "Abel Tuter",
+		"Due date":       "2024-03-05",
+		"Priority":       "2",
+	} {
+		if row[header] != expected {
+			t.Errorf("Expected %q under %q, got %q", expected, header, row[header])
+		}
+	}
+}
+
+func TestHeaderColumnMismatch(t *testing.T) {
+	filename := "test_mismatch.csv"
+	defer os.Remove(filename) // Clean up
+
+	writer, err := NewWriter(filename)
+	if err != nil {
+		t.Fatalf("Failed to create CSV writer: %v", err)
+	}
+	defer writer.Close()
+
+	// Case headers on a change request would shift its columns
+	if err := writer.SetHeaders(tableHeaders(t, "case")); err != nil {
+		t.Fatalf("Failed to set headers: %v", err)
+	}
+	if err := writer.WriteRecord(&generator.ChangeRequestRecord{Number: "CHG0010001"}); err == nil {
+		t.Error("Expected an error writing records with more headers than columns")
+	}
+}
+
+func TestWriteMixedRecordTypes(t *testing.T) {
+	filename := "test_mixed.csv"
+	defer os.Remove(filename) // Clean up
+
+	writer, err := NewWriter(filename)
+	if err != nil {
+		t.Fatalf("Failed to create CSV writer: %v", err)
+	}
+	defer writer.Close()
+
+	if err := writer.WriteRecord(&generator.IncidentRecord{Number: "INC0010001"}); err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+	if err := writer.WriteRecord(&generator.CaseRecord{Number: "CS0010001"}); err == nil {
+		t.Error("Expected an error writing a case to an incident file")
+	}
+}
+
+func TestClose(t *testing.T) {
+	filename := "test_close.csv"
+	defer os.Remove(filename) // Clean up
+
+	writer, err := NewWriter(filename)
+	if err != nil {
+		t.Fatalf("Failed to create CSV writer: %v", err)
+	}
+
+	// Close the writer
+	err = writer.Close()
+	if err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+
+	// Verify file exists
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		t.Error("Expected file to exist after closing writer")
+	}
+}
+
+// Benchmark tests
+
+func BenchmarkWriteIncidentRecord(b *testing.B) {
+	filename := "bench_incidents.csv"
+	defer os.Remove(filename) // Clean up
+
+	writer, err := NewWriter(filename)
+	if err != nil {
+		b.Fatalf("Failed to create CSV writer: %v", err)
+	}
+	defer writer.Close()
+
+	headers := tableHeaders(b, "incident")
+	err = writer.SetHeaders(headers)
+	if err != nil {
+		b.Fatalf("Failed to set headers: %v", err)
+	}
+
+	record := &generator.IncidentRecord{
+		Caller:           models.ReferenceValue{DisplayValue: "Benchmark User"},
+		Category:         "Hardware",
+		Subcategory:      "Printer",
+		ShortDescription: "Benchmark test",
+		Description:      "This is a benchmark test record",
+		Opened:           "2024-01-01 10:00:00",
+		IncidentState:    "New",
+		Impact:           2,
+		Urgency:          3,
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := writer.WriteRecord(record)
+		if err != nil {
+			b.Fatalf("Failed to write record: %v", err)
+		}
+	}
+}
+
+func TestAppendWriter(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "append.csv")
+
+	writer, err := NewWriter(filename)
+	if err != nil {
+		t.Fatalf("Failed to create CSV writer: %v", err)
+	}
+	if err := writer.WriteRecord(&generator.IncidentRecord{Number: "INC0010001"}); err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+	offset, err := writer.Offset()
+	if err != nil {
+		t.Fatalf("Failed to get offset: %v", err)
+	}
+	// A record written after the offset was not checkpointed, so it is dropped
+	if err := writer.WriteRecord(&generator.IncidentRecord{Number: "INC0010002"}); err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+	writer.Close()
+
+	writer, err = NewAppendWriter(filename, offset)
+	if err != nil {
+		t.Fatalf("Failed to open CSV writer: %v", err)
+	}
+	if err := writer.SetHeaders(tableHeaders(t, "incident")); err != nil {
+		t.Fatalf("Failed to set headers: %v", err)
+	}
+	if err := writer.WriteRecord(&generator.IncidentRecord{Number: "INC0010003"}); err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+	writer.Close()
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected headers and 2 records, got %q", content)
+	}
+	if !strings.HasPrefix(lines[0], "Sys ID,Number") || !strings.Contains(lines[1], "INC0010001") || !strings.Contains(lines[2], "INC0010003") {
+		t.Errorf("Expected the headers once, the first record and the appended one, got %q", content)
+	}
+
+	if _, err := NewAppendWriter(filename, int64(len(content))+1); err == nil {
+		t.Error("Expected an error appending after the end of the file")
+	}
+}
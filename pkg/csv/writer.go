@@ -1,144 +1,165 @@
-package csv
-
-import (
-	"encoding/csv"
-	"fmt"
-	"os"
-	"reflect"
-	"strconv"
-)
-
-// Writer handles CSV file operations
-type Writer struct {
-	file    *os.File
-	writer  *csv.Writer
-	headers []string
-}
-
-// NewWriter creates a new CSV writer
-func NewWriter(filename string) (*Writer, error) {
-	file, err := os.Create(filename)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create CSV file: %w", err)
-	}
-
-	return &Writer{
-		file:   file,
-		writer: csv.NewWriter(file),
-	}, nil
-}
-
-// SetHeaders sets the column headers and writes them to the CSV
-func (w *Writer) SetHeaders(headers []string) error {
-	w.headers = headers
-	return w.writer.Write(headers)
-}
-
-// WriteRecord writes a record to the CSV file
-func (w *Writer) WriteRecord(record interface{}) error {
-	values := w.extractValues(record)
-	stringValues := make([]string, len(values))
-	
-	for i, value := range values {
-		stringValues[i] = fmt.Sprintf("%v", value)
-	}
-	
-	return w.writer.Write(stringValues)
-}
-
-// WriteRecords writes multiple records to the CSV file
-func (w *Writer) WriteRecords(records []interface{}) error {
-	for _, record := range records {
-		if err := w.WriteRecord(record); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-// Flush flushes any buffered data to the underlying writer
-func (w *Writer) Flush() {
-	w.writer.Flush()
-}
-
-// Close closes the CSV file
-func (w *Writer) Close() error {
-	w.writer.Flush()
-	return w.file.Close()
-}
-
-// extractValues extracts values from a struct using reflection
-func (w *Writer) extractValues(record interface{}) []interface{} {
-	var values []interface{}
-	
-	v := reflect.ValueOf(record)
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
-	}
-	
-	if v.Kind() != reflect.Struct {
-		return values
-	}
-	
-	t := v.Type()
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		fieldType := t.Field(i)
-		
-		// Skip unexported fields
-		if !field.CanInterface() {
-			continue
-		}
-		
-		// Get the value
-		value := field.Interface()
-		
-		// Convert to appropriate type for CSV
-		switch v := value.(type) {
-		case string:
-			values = append(values, v)
-		case int, int8, int16, int32, int64:
-			values = append(values, v)
-		case uint, uint8, uint16, uint32, uint64:
-			values = append(values, v)
-		case float32, float64:
-			values = append(values, v)
-		case bool:
-			values = append(values, strconv.FormatBool(v))
-		default:
-			values = append(values, fmt.Sprintf("%v", v))
-		}
-		
-		// Check if we have enough values for headers
-		if len(w.headers) > 0 && len(values) >= len(w.headers) {
-			break
-		}
-		
-		_ = fieldType // Avoid unused variable warning
-	}
-	
-	return values
-}
-
-// GetIncidentHeaders returns the headers for incident records
-func GetIncidentHeaders() []string {
-	return []string{
-		"Caller", "Category", "Subcategory", "Service", "Service offering",
-		"Configuration item", "Short description", "Description", "Channel",
-		"Opened", "Incident State", "Impact", "Urgency", "Priority",
-		"Assignment group", "Assigned to", "Resolution code", "Resolution notes",
-	}
-}
-
-// GetCaseHeaders returns the headers for case records
-func GetCaseHeaders() []string {
-	return []string{
-		"Number", "Channel", "Account", "Contact", "Consumer",
-		"Requesting Service Organization", "Product", "Asset", "Install Base",
-		"Partner Contact", "Parent", "Short description", "Needs attention",
-		"Opened", "Priority", "Assignment group", "Assigned to",
-		"Service Organization", "Contract", "Entitlement", "Partner",
-		"State", "Resolved by", "Resolved at", "Closed by", "Closed at",
-		"Resolution code", "Cause", "Close code", "Close notes", "Notes to comments",
-	}
-}
+package csv
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+// Writer handles CSV file operations. Columns are derived from the tags of the record
+// fields with models.RecordColumns.
+type Writer struct {
+	file    *os.File
+	writer  *csv.Writer
+	headers []string
+
+	columns    []models.Column
+	recordType reflect.Type
+
+	referenceMode models.ReferenceMode
+
+	// appending is set when records are added to an existing file, which has its headers
+	appending bool
+}
+
+// NewWriter creates a new CSV writer
+func NewWriter(filename string) (*Writer, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CSV file: %w", err)
+	}
+
+	return &Writer{
+		file:   file,
+		writer: csv.NewWriter(file),
+	}, nil
+}
+
+// NewAppendWriter opens an existing CSV file to add records to after offset, the size of
+// the file when its last complete record was written. Anything after offset is dropped,
+// and the headers already in the file are not written again.
+func NewAppendWriter(filename string, offset int64) (*Writer, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV file: %w", err)
+	}
+	if err := truncate(file, offset); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &Writer{
+		file:      file,
+		writer:    csv.NewWriter(file),
+		appending: true,
+	}, nil
+}
+
+// truncate cuts file to offset and moves to its end, failing if the file is shorter
+func truncate(file *os.File, offset int64) error {
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read CSV file: %w", err)
+	}
+	if info.Size() < offset {
+		return fmt.Errorf("%s has %d bytes, fewer than the %d written before", file.Name(), info.Size(), offset)
+	}
+	if err := file.Truncate(offset); err != nil {
+		return fmt.Errorf("failed to truncate CSV file: %w", err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek CSV file: %w", err)
+	}
+	return nil
+}
+
+// SetHeaders sets the column headers and writes them to the CSV. There must be one per
+// column of the records; without them the column labels are written.
+func (w *Writer) SetHeaders(headers []string) error {
+	w.headers = headers
+	if w.appending {
+		return nil
+	}
+	return w.writer.Write(headers)
+}
+
+// SetReferenceMode sets whether reference fields are written as display values, sys_ids or
+// both. It must be called before the first record is written.
+func (w *Writer) SetReferenceMode(mode models.ReferenceMode) {
+	w.referenceMode = mode
+}
+
+// WriteRecord writes a record to the CSV file. Records that are not structs are skipped.
+func (w *Writer) WriteRecord(record interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(record))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	if w.columns == nil {
+		columns, err := models.RecordColumns(v.Type(), w.referenceMode)
+		if err != nil {
+			return err
+		}
+		if w.headers == nil {
+			if err := w.SetHeaders(models.ColumnLabels(columns)); err != nil {
+				return err
+			}
+		} else if len(w.headers) != len(columns) {
+			return fmt.Errorf("%d headers set for the %d columns of %s", len(w.headers), len(columns), v.Type())
+		}
+		w.columns = columns
+		w.recordType = v.Type()
+	} else if v.Type() != w.recordType {
+		return fmt.Errorf("record type %s does not match the file's record type %s", v.Type(), w.recordType)
+	}
+
+	values := make([]string, len(w.columns))
+	for i, col := range w.columns {
+		switch value := col.Value(v, w.referenceMode).(type) {
+		case string:
+			values[i] = value
+		case bool:
+			values[i] = strconv.FormatBool(value)
+		default:
+			values[i] = fmt.Sprintf("%v", value)
+		}
+	}
+
+	return w.writer.Write(values)
+}
+
+// WriteRecords writes multiple records to the CSV file
+func (w *Writer) WriteRecords(records []interface{}) error {
+	for _, record := range records {
+		if err := w.WriteRecord(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush flushes any buffered data to the underlying writer
+func (w *Writer) Flush() {
+	w.writer.Flush()
+}
+
+// Offset flushes the records written so far and returns the size of the file
+func (w *Writer) Offset() (int64, error) {
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		return 0, fmt.Errorf("failed to flush CSV file: %w", err)
+	}
+	return w.file.Seek(0, io.SeekCurrent)
+}
+
+// Close closes the CSV file
+func (w *Writer) Close() error {
+	w.writer.Flush()
+	return w.file.Close()
+}
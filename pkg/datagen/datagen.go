@@ -0,0 +1,317 @@
+// Package datagen generates ServiceNow records in-process, for programs and tests that
+// embed the generator instead of running the command.
+package datagen
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/generator"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/llm"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+// Records of the built-in tables
+type (
+	IncidentRecord         = generator.IncidentRecord
+	CaseRecord             = generator.CaseRecord
+	HRCaseRecord           = generator.HRCaseRecord
+	ChangeRequestRecord    = generator.ChangeRequestRecord
+	KnowledgeArticleRecord = generator.KnowledgeArticleRecord
+)
+
+// Types used by records and options
+type (
+	ReferenceValue = models.ReferenceValue
+	ReferenceData  = models.ReferenceData
+	NumberFormat   = generator.NumberFormat
+	Distribution   = generator.Distribution
+	Rule           = models.Rule
+	Descriptions   = llm.DescriptionResponse
+	TextProvider   = generator.TextProvider
+	TableGenerator = generator.TableGenerator
+	FuncTable      = generator.FuncTable
+	Request        = generator.Request
+	RecordError    = generator.RecordError
+	BatchError     = generator.BatchError
+)
+
+// Text engines built in
+const (
+	TextLLM      = generator.TextLLM
+	TextTemplate = generator.TextTemplate
+)
+
+// Options configure a Generator. Settings left out take the defaults of the command.
+type Options struct {
+	Table     string // incident by default
+	Count     int    // 100 by default
+	BatchSize int    // 1000 by default
+	// ClosedPercentage is the percentage of closed or resolved records
+	ClosedPercentage int
+	// Seed makes the random values of the generator repeatable, whatever the number of
+	// Workers
+	Seed    int64
+	Workers int
+	// Now is the time generated dates are relative to, the time of New by default
+	Now time.Time
+
+	// Text comes from the LLM with an APIKey, and from templates without one, unless
+	// TextEngine selects the template engine or one added with RegisterTextEngine
+	APIKey      string
+	Model       string
+	TextEngine  string
+	Temperature *float64
+	// TextFallback writes template text in place of text the engine fails to write;
+	// by default the record fails and ErrorPolicy decides
+	TextFallback bool
+
+	// ErrorPolicy is fail-fast, skip, retry=N or placeholder, fail-fast by default
+	ErrorPolicy string
+
+	// ReferenceData replaces the built-in users, groups, accounts, contacts and CIs
+	ReferenceData *ReferenceData
+	// Number replaces the number format of the table when it has digits
+	Number NumberFormat
+	// Distributions replace the default distributions of the same names
+	Distributions map[string]Distribution
+	// Rules set, compute and check fields of the records after they are generated
+	Rules []Rule
+
+	// Log receives progress messages, which are discarded by default
+	Log io.Writer
+}
+
+// Generator generates the records of a table
+type Generator struct {
+	bg      *generator.BulkGenerator
+	count   int
+	batch   int
+	options Options
+}
+
+// New creates a generator, checking the options before anything is generated
+func New(options Options) (*Generator, error) {
+	if options.Table == "" {
+		options.Table = "incident"
+	}
+	if options.Count == 0 {
+		options.Count = 100
+	}
+	if options.BatchSize == 0 {
+		options.BatchSize = 1000
+	}
+	if options.Count < 0 || options.BatchSize < 0 {
+		return nil, fmt.Errorf("count %d and batch size %d must not be negative", options.Count, options.BatchSize)
+	}
+	if options.Log == nil {
+		options.Log = io.Discard
+	}
+	if options.Table == "cmdb" || options.Table == "master_data" {
+		return nil, fmt.Errorf("table %s writes records of several tables and cannot be streamed", options.Table)
+	}
+
+	policy, err := generator.ParseErrorPolicy(options.ErrorPolicy)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := generator.ParseRules(options.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	numbering := generator.NewNumbering()
+	if options.Number.Digits > 0 {
+		if err := numbering.SetFormat(options.Table, options.Number); err != nil {
+			return nil, err
+		}
+	}
+
+	config := generator.Config{
+		RecordCount:      options.Count,
+		BatchSize:        options.BatchSize,
+		TableName:        options.Table,
+		ClosedPercentage: options.ClosedPercentage,
+		APIKey:           options.APIKey,
+		Model:            options.Model,
+		ReferenceData:    options.ReferenceData,
+		Numbering:        numbering,
+		TextEngine:       options.TextEngine,
+		Temperature:      options.Temperature,
+		TextFallback:     options.TextFallback,
+		Workers:          options.Workers,
+		Seed:             options.Seed,
+		Now:              options.Now,
+		ErrorPolicy:      policy,
+		Distributions:    options.Distributions,
+		Rules:            rules,
+		Log:              options.Log,
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	for name, d := range options.Distributions {
+		if !contains(generator.DistributionNames(), name) {
+			return nil, fmt.Errorf("unknown distribution %s (known distributions: %s)", name, strings.Join(generator.DistributionNames(), ", "))
+		}
+		if err := d.Validate(); err != nil {
+			return nil, fmt.Errorf("distribution %s %w", name, err)
+		}
+	}
+
+	return &Generator{
+		bg:      generator.NewBulkGenerator(config),
+		count:   options.Count,
+		batch:   options.BatchSize,
+		options: options,
+	}, nil
+}
+
+// Table returns the table the generator generates
+func (g *Generator) Table() string {
+	return g.options.Table
+}
+
+// Records returns an iterator over the generated records. Records are generated in
+// batches in the background while the previous batch is read. Each iteration generates
+// new records, numbered after those of the iterations before.
+func (g *Generator) Records(ctx context.Context) *Iterator[interface{}] {
+	return Iterate[interface{}](ctx, g)
+}
+
+// Iterator streams the records of a generator as values of type T, such as
+// *IncidentRecord. Call Close when done, even if not all records were read.
+type Iterator[T any] struct {
+	batches <-chan generator.Batch
+	stop    func()
+	records []interface{}
+	record  T
+	err     error
+}
+
+// Iterate returns an iterator over the records of g as values of type T
+func Iterate[T any](ctx context.Context, g *Generator) *Iterator[T] {
+	batches, stop := g.bg.Batches(ctx, g.count, g.batch)
+	return &Iterator[T]{batches: batches, stop: stop}
+}
+
+// Next moves to the next record, reporting false once all records were read or
+// generation failed
+func (it *Iterator[T]) Next() bool {
+	for len(it.records) == 0 {
+		if it.err != nil {
+			return false
+		}
+		batch, ok := <-it.batches
+		if !ok {
+			return false
+		}
+		it.records, it.err = batch.Records, batch.Err
+	}
+
+	record, ok := it.records[0].(T)
+	if !ok {
+		var want T
+		it.err = fmt.Errorf("record of type %T is not a %T", it.records[0], want)
+		it.records = nil
+		return false
+	}
+	it.records = it.records[1:]
+	it.record = record
+	return true
+}
+
+// Record returns the current record
+func (it *Iterator[T]) Record() T {
+	return it.record
+}
+
+// Err returns the error that stopped generation, if any
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Close stops generating records
+func (it *Iterator[T]) Close() {
+	it.stop()
+}
+
+// Collect generates all the records of g as values of type T
+func Collect[T any](ctx context.Context, g *Generator) ([]T, error) {
+	it := Iterate[T](ctx, g)
+	defer it.Close()
+
+	records := make([]T, 0, g.count)
+	for it.Next() {
+		records = append(records, it.Record())
+	}
+	return records, it.Err()
+}
+
+// Sink receives generated records. The CSV, JSON, XML, Parquet and SQL writers of this
+// module are sinks, and so is any type with these methods.
+type Sink interface {
+	WriteRecords(records []interface{}) error
+	Close() error
+}
+
+// WriteTo generates the records of g into sink, one batch at a time, and closes the
+// sink. It returns the number of records written.
+func (g *Generator) WriteTo(ctx context.Context, sink Sink) (int, error) {
+	batches, stop := g.bg.Batches(ctx, g.count, g.batch)
+	defer stop()
+
+	written := 0
+	for batch := range batches {
+		if err := sink.WriteRecords(batch.Records); err != nil {
+			sink.Close()
+			return written, fmt.Errorf("failed to write records: %w", err)
+		}
+		written += len(batch.Records)
+		if batch.Err != nil {
+			sink.Close()
+			return written, batch.Err
+		}
+	}
+	return written, sink.Close()
+}
+
+// RegisterTable adds the table of a table generator, such as a FuncTable. Tables are
+// registered before generators are created, typically from init.
+func RegisterTable(table TableGenerator) error {
+	return generator.RegisterTableGenerator(table)
+}
+
+// RegisterTextEngine registers a text provider under a name Options.TextEngine can
+// select. Engines are registered before generators are created, typically from init.
+func RegisterTextEngine(name string, provider TextProvider) error {
+	return generator.RegisterTextEngine(name, provider)
+}
+
+// Tables returns the names of the tables a Generator can generate
+func Tables() []string {
+	var tables []string
+	for _, table := range generator.Tables() {
+		if table != "cmdb" && table != "master_data" {
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadReferenceData reads users, groups, accounts, contacts and CIs from a JSON file
+func LoadReferenceData(filename string) (*ReferenceData, error) {
+	return models.LoadReferenceData(filename)
+}
@@ -0,0 +1,235 @@
+package datagen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/pkg/csv"
+)
+
+type widgetRecord struct {
+	Number string `json:"number" label:"Number"`
+	Name   string `json:"name" label:"Name"`
+	Size   int    `json:"size" label:"Size"`
+}
+
+type cannedText struct{}
+
+func (cannedText) GenerateText(ctx context.Context, prompt string, maxLength int) (string, error) {
+	return "canned text", nil
+}
+
+func (cannedText) GenerateIncidentDescriptions(ctx context.Context, category, subcategory string) (*Descriptions, error) {
+	return &Descriptions{ShortDescription: "canned " + category, Description: "canned description"}, nil
+}
+
+func (cannedText) GenerateCaseDescriptions(ctx context.Context, category, subcategory, accountName, caseType string) (*Descriptions, error) {
+	return &Descriptions{ShortDescription: "canned " + category, Description: "canned description"}, nil
+}
+
+func (cannedText) GenerateCloseNotes(ctx context.Context, shortDescription, description, closeCode string) (string, error) {
+	return "canned notes", nil
+}
+
+func init() {
+	if err := RegisterTable(FuncTable{
+		TableName: "u_widget",
+		Record:    widgetRecord{},
+		Format:    NumberFormat{Prefix: "WDG", Start: 1, Digits: 4},
+		GenerateFunc: func(ctx context.Context, rng *rand.Rand, req Request) (interface{}, error) {
+			return &widgetRecord{Number: req.Number, Name: "Widget " + req.Number, Size: 1 + rng.Intn(10)}, nil
+		},
+	}); err != nil {
+		panic(err)
+	}
+	if err := RegisterTextEngine("canned", cannedText{}); err != nil {
+		panic(err)
+	}
+}
+
+// memorySink keeps the records written to it
+type memorySink struct {
+	records []interface{}
+	closed  bool
+}
+
+func (s *memorySink) WriteRecords(records []interface{}) error {
+	s.records = append(s.records, records...)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestCollect(t *testing.T) {
+	g, err := New(Options{Table: "case", Count: 25, BatchSize: 10, Rules: []Rule{{Set: map[string]string{"product": "Widget {priority}"}}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	cases, err := Collect[*CaseRecord](context.Background(), g)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(cases) != 25 {
+		t.Fatalf("Expected 25 cases, got %d", len(cases))
+	}
+	for i, c := range cases {
+		if expected := fmt.Sprintf("CS%07d", 1001+i); c.Number != expected {
+			t.Errorf("Expected case %d to be %s, got %s", i, expected, c.Number)
+		}
+		if expected := fmt.Sprintf("Widget %d", c.Priority); c.Product != expected {
+			t.Errorf("Expected the rule to set the product to %s, got %s", expected, c.Product)
+		}
+	}
+
+	// Records of another type stop the iteration with an error
+	if _, err := Collect[*IncidentRecord](context.Background(), g); err == nil {
+		t.Error("Expected an error collecting cases as incidents")
+	}
+}
+
+func TestIterateSeeded(t *testing.T) {
+	// Two generators with the same seed, read in turn, each repeat the values of the
+	// other without drawing from a shared source
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	var iterators []*Iterator[interface{}]
+	for i := 0; i < 2; i++ {
+		g, err := New(Options{Count: 20, BatchSize: 7, Seed: 42, Workers: 4, Now: now, TextEngine: TextTemplate})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		it := g.Records(context.Background())
+		defer it.Close()
+		iterators = append(iterators, it)
+	}
+
+	count := 0
+	for iterators[0].Next() {
+		if !iterators[1].Next() {
+			t.Fatalf("Expected the second generator to have record %d", count+1)
+		}
+		first, second := fmt.Sprintf("%+v", iterators[0].Record()), fmt.Sprintf("%+v", iterators[1].Record())
+		if first != second {
+			t.Errorf("Expected the same record %d from the same seed, got %s and %s", count+1, first, second)
+		}
+		count++
+	}
+	for _, it := range iterators {
+		if err := it.Err(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if count != 20 {
+		t.Errorf("Expected 20 records, got %d", count)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, options := range []Options{
+		{Table: "problem"},
+		{Table: "cmdb"},
+		{Count: -1},
+		{ErrorPolicy: "ignore"},
+		{TextEngine: "gpt"},
+		{Rules: []Rule{{When: "state ==", Set: map[string]string{"state": "New"}}}},
+		{Distributions: map[string]Distribution{"change_risks": {Values: []string{"Low"}, Weights: []float64{1}}}},
+	} {
+		if _, err := New(options); err == nil {
+			t.Errorf("Expected an error for options %+v", options)
+		}
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	g, err := New(Options{Table: "hr_case", Count: 12, BatchSize: 5})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sink := &memorySink{}
+	written, err := g.WriteTo(context.Background(), sink)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if written != 12 || len(sink.records) != 12 || !sink.closed {
+		t.Errorf("Expected 12 records written to a closed sink, got %d (%d records, closed %v)", written, len(sink.records), sink.closed)
+	}
+
+	// The writers of the module are sinks
+	filename := filepath.Join(t.TempDir(), "hr.csv")
+	writer, err := csv.NewWriter(filename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := g.WriteTo(context.Background(), writer); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lines := strings.Count(string(content), "\n"); lines != 13 {
+		t.Errorf("Expected headers and 12 records, got %d lines", lines)
+	}
+}
+
+func TestWriteToCancelled(t *testing.T) {
+	g, err := New(Options{Count: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	sink := &memorySink{}
+	if _, err := g.WriteTo(ctx, sink); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the run to be cancelled, got %v", err)
+	}
+	if !sink.closed {
+		t.Error("Expected the sink to be closed")
+	}
+}
+
+func TestRegisteredTableAndText(t *testing.T) {
+	if !contains(Tables(), "u_widget") || contains(Tables(), "cmdb") {
+		t.Errorf("Expected the registered table without cmdb, got %v", Tables())
+	}
+	if err := RegisterTable(FuncTable{TableName: "u_widget", Record: widgetRecord{}}); err == nil {
+		t.Error("Expected an error registering a table twice")
+	}
+
+	g, err := New(Options{Table: "u_widget", Count: 3})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	widgets, err := Collect[*widgetRecord](context.Background(), g)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(widgets) != 3 || widgets[2].Number != "WDG0003" || widgets[2].Name != "Widget WDG0003" || widgets[2].Size < 1 {
+		t.Errorf("Expected 3 numbered widgets, got %+v", widgets)
+	}
+
+	g, err = New(Options{Table: "incident", Count: 2, TextEngine: "canned"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	incidents, err := Collect[*IncidentRecord](context.Background(), g)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, incident := range incidents {
+		if incident.Description != "canned description" {
+			t.Errorf("Expected text from the registered engine, got %q", incident.Description)
+		}
+	}
+}
@@ -1,170 +1,506 @@
-package excel
-
-import (
-	"fmt"
-	"reflect"
-	"strconv"
-
-	"github.com/xuri/excelize/v2"
-)
-
-// Writer handles Excel file operations
-type Writer struct {
-	file      *excelize.File
-	sheetName string
-	headers   []string
-	rowIndex  int
-}
-
-// NewWriter creates a new Excel writer
-func NewWriter(sheetName string) *Writer {
-	f := excelize.NewFile()
-	// Create the sheet if it doesn't exist
-	if sheetName != "Sheet1" {
-		f.NewSheet(sheetName)
-		f.DeleteSheet("Sheet1")
-	} else {
-		sheetName = "Sheet1"
-	}
-	return &Writer{
-		file:      f,
-		sheetName: sheetName,
-		rowIndex:  1,
-	}
-}
-
-// SetHeaders sets the column headers
-func (w *Writer) SetHeaders(headers []string) error {
-	w.headers = headers
-	for i, header := range headers {
-		cell := fmt.Sprintf("%s%d", getColumnName(i), w.rowIndex)
-		if err := w.file.SetCellValue(w.sheetName, cell, header); err != nil {
-			return fmt.Errorf("failed to set header %s: %w", header, err)
-		}
-	}
-	w.rowIndex++
-	return nil
-}
-
-// WriteRecord writes a record to the Excel file
-func (w *Writer) WriteRecord(record interface{}) error {
-	values := w.extractValues(record)
-	
-	for i, value := range values {
-		if i >= len(w.headers) {
-			break // Don't write more columns than headers
-		}
-		cell := fmt.Sprintf("%s%d", getColumnName(i), w.rowIndex)
-		if err := w.file.SetCellValue(w.sheetName, cell, value); err != nil {
-			return fmt.Errorf("failed to set cell %s: %w", cell, err)
-		}
-	}
-	w.rowIndex++
-	return nil
-}
-
-// WriteRecords writes multiple records to the Excel file
-func (w *Writer) WriteRecords(records []interface{}) error {
-	for _, record := range records {
-		if err := w.WriteRecord(record); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-// SaveToFile saves the Excel file to disk
-func (w *Writer) SaveToFile(filename string) error {
-	return w.file.SaveAs(filename)
-}
-
-// Close closes the Excel file
-func (w *Writer) Close() error {
-	return w.file.Close()
-}
-
-// extractValues extracts values from a struct using reflection
-func (w *Writer) extractValues(record interface{}) []interface{} {
-	var values []interface{}
-	
-	v := reflect.ValueOf(record)
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
-	}
-	
-	if v.Kind() != reflect.Struct {
-		return values
-	}
-	
-	t := v.Type()
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		fieldType := t.Field(i)
-		
-		// Skip unexported fields
-		if !field.CanInterface() {
-			continue
-		}
-		
-		// Get the value
-		value := field.Interface()
-		
-		// Convert to string for Excel
-		switch v := value.(type) {
-		case string:
-			values = append(values, v)
-		case int, int8, int16, int32, int64:
-			values = append(values, fmt.Sprintf("%d", v))
-		case uint, uint8, uint16, uint32, uint64:
-			values = append(values, fmt.Sprintf("%d", v))
-		case float32, float64:
-			values = append(values, fmt.Sprintf("%f", v))
-		case bool:
-			values = append(values, strconv.FormatBool(v))
-		default:
-			values = append(values, fmt.Sprintf("%v", v))
-		}
-		
-		// Check if we have enough values for headers
-		if len(values) >= len(w.headers) {
-			break
-		}
-		
-		_ = fieldType // Avoid unused variable warning
-	}
-	
-	return values
-}
-
-// getColumnName converts a column index to Excel column name (A, B, C, ..., AA, AB, etc.)
-func getColumnName(index int) string {
-	result := ""
-	for index >= 0 {
-		result = string(rune('A'+index%26)) + result
-		index = index/26 - 1
-	}
-	return result
-}
-
-// GetIncidentHeaders returns the headers for incident records
-func GetIncidentHeaders() []string {
-	return []string{
-		"Caller", "Category", "Subcategory", "Service", "Service offering",
-		"Configuration item", "Short description", "Description", "Channel",
-		"Opened", "Incident State", "Impact", "Urgency", "Priority",
-		"Assignment group", "Assigned to", "Resolution code", "Resolution notes",
-	}
-}
-
-// GetCaseHeaders returns the headers for case records
-func GetCaseHeaders() []string {
-	return []string{
-		"Number", "Channel", "Account", "Contact", "Consumer",
-		"Requesting Service Organization", "Product", "Asset", "Install Base",
-		"Partner Contact", "Parent", "Short description", "Needs attention",
-		"Opened", "Priority", "Assignment group", "Assigned to",
-		"Service Organization", "Contract", "Entitlement", "Partner",
-		"State", "Resolved by", "Resolved at", "Closed by", "Closed at",
-		"Resolution code", "Cause", "Close code", "Close notes", "Notes to comments",
-	}
-}
+package excel
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+	"github.com/xuri/excelize/v2"
+)
+
+// Column widths are sized to the longest value, within these bounds
+const (
+	minColumnWidth = 8
+	maxColumnWidth = 60
+)
+
+// maxSheetNameLength is the longest sheet name Excel allows
+const maxSheetNameLength = 31
+
+var (
+	// widthSampleRows is the number of rows column widths are sized from. Streamed sheets
+	// need their widths before the first row is written, so these rows are held back.
+	widthSampleRows = 1000
+
+	// maxRows is the number of rows a sheet holds, header included, before the writer
+	// rolls over to a new sheet
+	maxRows = excelize.TotalRows
+)
+
+// Workbook is an Excel workbook holding one sheet per table. Rows are streamed to
+// temporary files rather than kept in memory.
+type Workbook struct {
+	file   *excelize.File
+	sheets []*Writer
+
+	headerStyle    int
+	timestampStyle int
+	dateStyle      int
+}
+
+// NewWorkbook creates an empty workbook. Sheets are added with AddSheet.
+func NewWorkbook() *Workbook {
+	f := excelize.NewFile()
+	b := &Workbook{file: f}
+
+	b.headerStyle, _ = f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true, Color: "FFFFFF"},
+		Fill:      excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"1F4E78"}},
+		Alignment: &excelize.Alignment{Vertical: "center"},
+	})
+	timestampFormat := "yyyy-mm-dd hh:mm:ss"
+	b.timestampStyle, _ = f.NewStyle(&excelize.Style{CustomNumFmt: &timestampFormat})
+	dateFormat := "yyyy-mm-dd"
+	b.dateStyle, _ = f.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat})
+
+	return b
+}
+
+// AddSheet adds a sheet for a table to the workbook and returns the writer of its rows.
+// Names are shortened to the 31 characters Excel allows and characters it rejects are
+// replaced.
+func (b *Workbook) AddSheet(name string) (*Writer, error) {
+	name = sheetName(name)
+	if err := b.newSheet(name); err != nil {
+		return nil, err
+	}
+
+	w := &Writer{workbook: b, name: name, sheetName: name, sheetCount: 1}
+	b.sheets = append(b.sheets, w)
+	return w, nil
+}
+
+// SaveToFile finishes the sheets and saves the workbook to disk. No more records can be
+// written once it is saved.
+func (b *Workbook) SaveToFile(filename string) error {
+	for _, sheet := range b.sheets {
+		if err := sheet.finish(); err != nil {
+			return err
+		}
+	}
+	return b.file.SaveAs(filename)
+}
+
+// Close closes the workbook and removes its temporary files
+func (b *Workbook) Close() error {
+	return b.file.Close()
+}
+
+// newSheet adds an empty sheet to the workbook
+func (b *Workbook) newSheet(name string) error {
+	if len(b.sheets) == 0 {
+		// A new file starts with Sheet1, which becomes the first table's sheet
+		if name != "Sheet1" {
+			if err := b.file.SetSheetName("Sheet1", name); err != nil {
+				return fmt.Errorf("failed to add sheet %s: %w", name, err)
+			}
+		}
+		return nil
+	}
+
+	for _, existing := range b.file.GetSheetList() {
+		if strings.EqualFold(existing, name) {
+			return fmt.Errorf("failed to add sheet %s: the workbook already has it", name)
+		}
+	}
+	if _, err := b.file.NewSheet(name); err != nil {
+		return fmt.Errorf("failed to add sheet %s: %w", name, err)
+	}
+	return nil
+}
+
+// Writer streams the rows of a table to a sheet of a workbook. Columns are derived from
+// the tags of the record fields with models.RecordColumns. A table with more rows than a
+// sheet holds continues on further sheets, named "<table> (2)" and so on.
+type Writer struct {
+	workbook *Workbook
+	ownsFile bool
+	headers  []string
+	widths   []int
+
+	columns    []models.Column
+	recordType reflect.Type
+
+	name       string // the table's sheet name
+	sheetName  string // the sheet being written
+	sheetCount int
+
+	stream   *excelize.StreamWriter
+	pending  [][]interface{} // rows held back to size the columns
+	rowIndex int
+	records  int
+	finished bool
+
+	referenceMode models.ReferenceMode
+}
+
+// NewWriter creates a new Excel writer of a workbook with a single sheet
+func NewWriter(sheetName string) *Writer {
+	w, err := NewWorkbook().AddSheet(sheetName)
+	if err != nil {
+		// Only a sheet name Excel rejects gets here, so fall back to the default
+		w, _ = NewWorkbook().AddSheet("Sheet1")
+	}
+	w.ownsFile = true
+	return w
+}
+
+// SetHeaders sets the column headers, one per column of the records; without them the
+// column labels are used. The header row is styled and frozen above the records of every
+// sheet of the table.
+func (w *Writer) SetHeaders(headers []string) error {
+	if w.stream != nil || w.finished {
+		return fmt.Errorf("failed to set headers: rows of sheet %s have already been written", w.sheetName)
+	}
+	w.headers = headers
+	w.widths = make([]int, len(headers))
+	for i, header := range headers {
+		// Leave room for the autofilter button
+		w.fitColumn(i, utf8.RuneCountInString(header)+3)
+	}
+	return nil
+}
+
+// SetReferenceMode sets whether reference fields are written as display values, sys_ids or
+// both. It must be called before the first record is written.
+func (w *Writer) SetReferenceMode(mode models.ReferenceMode) {
+	w.referenceMode = mode
+}
+
+// WriteRecord writes a record to the sheet. Integers, booleans, dates and timestamps are
+// written as typed cells and empty typed values are left blank. Text longer than the
+// 32,767 characters a cell holds is truncated with a warning.
+func (w *Writer) WriteRecord(record interface{}) error {
+	if w.finished {
+		return fmt.Errorf("failed to write record: sheet %s has already been saved", w.sheetName)
+	}
+	values, err := w.extractValues(record)
+	if err != nil {
+		return err
+	}
+	w.records++
+
+	row := make([]interface{}, len(values))
+	for i, value := range values {
+		row[i] = w.cell(i, value)
+	}
+
+	if w.stream == nil {
+		w.pending = append(w.pending, row)
+		if len(w.pending) < widthSampleRows {
+			return nil
+		}
+		return w.startStream()
+	}
+	return w.writeRow(row)
+}
+
+// WriteRecords writes multiple records to the sheet
+func (w *Writer) WriteRecords(records []interface{}) error {
+	for _, record := range records {
+		if err := w.WriteRecord(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveToFile saves the workbook the sheet belongs to
+func (w *Writer) SaveToFile(filename string) error {
+	return w.workbook.SaveToFile(filename)
+}
+
+// Close closes the workbook if the writer created it. Sheets added to a workbook are
+// closed with it.
+func (w *Writer) Close() error {
+	if !w.ownsFile {
+		return nil
+	}
+	return w.workbook.Close()
+}
+
+// cell converts a value into the cell written for column index, widening the column
+// while it is still being sized
+func (w *Writer) cell(index int, value interface{}) interface{} {
+	width := 0
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		value = excelize.Cell{StyleID: w.workbook.timestampStyle, Value: v}
+		width = len("2006-01-02 15:04:05")
+	case date:
+		value = excelize.Cell{StyleID: w.workbook.dateStyle, Value: time.Time(v)}
+		width = len("2006-01-02")
+	case string:
+		if length := utf8.RuneCountInString(v); length > excelize.TotalCellChars {
+			fmt.Fprintf(os.Stderr, "Warning: %s of record %d on sheet %s truncated from %d to %d characters, the most a cell holds\n",
+				w.headers[index], w.records, w.sheetName, length, excelize.TotalCellChars)
+			v = string([]rune(v)[:excelize.TotalCellChars])
+			value = v
+		}
+		if w.stream == nil {
+			// Multi-line values are sized by their longest line
+			for _, line := range strings.Split(v, "\n") {
+				width = max(width, utf8.RuneCountInString(line))
+			}
+		}
+	default:
+		if w.stream == nil {
+			width = len(fmt.Sprint(v))
+		}
+	}
+	if w.stream == nil {
+		w.fitColumn(index, width)
+	}
+	return value
+}
+
+// fitColumn widens a column to fit a value of the given width
+func (w *Writer) fitColumn(index, width int) {
+	if index < len(w.widths) && width > w.widths[index] {
+		w.widths[index] = width
+	}
+}
+
+// startStream starts streaming the current sheet: the column widths, frozen header row
+// and header are written, followed by any rows held back
+func (w *Writer) startStream() error {
+	stream, err := w.workbook.file.NewStreamWriter(w.sheetName)
+	if err != nil {
+		return fmt.Errorf("failed to stream sheet %s: %w", w.sheetName, err)
+	}
+	w.stream = stream
+
+	for i, width := range w.widths {
+		width = min(max(width+2, minColumnWidth), maxColumnWidth)
+		if err := stream.SetColWidth(i+1, i+1, float64(width)); err != nil {
+			return fmt.Errorf("failed to size column %s: %w", getColumnName(i), err)
+		}
+	}
+
+	if len(w.headers) > 0 {
+		err := stream.SetPanes(&excelize.Panes{
+			Freeze:      true,
+			YSplit:      1,
+			TopLeftCell: "A2",
+			ActivePane:  "bottomLeft",
+		})
+		if err != nil {
+			return fmt.Errorf("failed to freeze header row: %w", err)
+		}
+
+		header := make([]interface{}, len(w.headers))
+		for i, name := range w.headers {
+			header[i] = excelize.Cell{StyleID: w.workbook.headerStyle, Value: name}
+		}
+		if err := stream.SetRow("A1", header); err != nil {
+			return fmt.Errorf("failed to write headers: %w", err)
+		}
+	}
+	w.rowIndex = 2
+
+	rows := w.pending
+	w.pending = nil
+	for _, row := range rows {
+		if err := w.writeRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRow streams a row to the current sheet, rolling over to a new sheet when it is full
+func (w *Writer) writeRow(row []interface{}) error {
+	if w.rowIndex > maxRows {
+		if err := w.rollover(); err != nil {
+			return err
+		}
+	}
+
+	cell := fmt.Sprintf("A%d", w.rowIndex)
+	if err := w.stream.SetRow(cell, row); err != nil {
+		return fmt.Errorf("failed to write row %d of sheet %s: %w", w.rowIndex, w.sheetName, err)
+	}
+	w.rowIndex++
+	return nil
+}
+
+// rollover ends the full current sheet and continues the table on a new one
+func (w *Writer) rollover() error {
+	if err := w.endStream(); err != nil {
+		return err
+	}
+
+	w.sheetCount++
+	name := rolloverName(w.name, w.sheetCount)
+	if err := w.workbook.newSheet(name); err != nil {
+		return err
+	}
+	fmt.Printf("Sheet %s is full at %d rows, continuing on sheet %s\n", w.sheetName, maxRows, name)
+	w.sheetName = name
+	return w.startStream()
+}
+
+// endStream sets the autofilter over the header row and records of the current sheet and
+// completes it
+func (w *Writer) endStream() error {
+	if len(w.headers) > 0 {
+		filterRange := fmt.Sprintf("A1:%s%d", getColumnName(len(w.headers)-1), max(w.rowIndex-1, 1))
+		if err := w.workbook.file.AutoFilter(w.sheetName, filterRange, nil); err != nil {
+			return fmt.Errorf("failed to set autofilter: %w", err)
+		}
+	}
+	if err := w.stream.Flush(); err != nil {
+		return fmt.Errorf("failed to write sheet %s: %w", w.sheetName, err)
+	}
+	w.stream = nil
+	return nil
+}
+
+// finish writes any rows held back and completes the last sheet of the table
+func (w *Writer) finish() error {
+	if w.finished {
+		return nil
+	}
+	if w.stream == nil {
+		if err := w.startStream(); err != nil {
+			return err
+		}
+	}
+	if err := w.endStream(); err != nil {
+		return err
+	}
+	w.finished = true
+	return nil
+}
+
+// date is the value of a date cell
+type date time.Time
+
+// extractValues extracts the cell values of a record in column order. Fields declared as
+// int, boolean, timestamp or date by their type tag are converted to typed values, and
+// empty typed values are nil.
+func (w *Writer) extractValues(record interface{}) ([]interface{}, error) {
+	v := reflect.Indirect(reflect.ValueOf(record))
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unsupported record type %T", record)
+	}
+
+	if w.columns == nil {
+		columns, err := models.RecordColumns(v.Type(), w.referenceMode)
+		if err != nil {
+			return nil, err
+		}
+		if w.headers == nil {
+			if err := w.SetHeaders(models.ColumnLabels(columns)); err != nil {
+				return nil, err
+			}
+		} else if len(w.headers) != len(columns) {
+			return nil, fmt.Errorf("%d headers set for the %d columns of %s", len(w.headers), len(columns), v.Type())
+		}
+		w.columns = columns
+		w.recordType = v.Type()
+	} else if v.Type() != w.recordType {
+		return nil, fmt.Errorf("record type %s does not match the sheet's record type %s", v.Type(), w.recordType)
+	}
+
+	values := make([]interface{}, len(w.columns))
+	for i, col := range w.columns {
+		switch value := col.Value(v, w.referenceMode).(type) {
+		case string:
+			typed, err := typedValue(col, value)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", v.Type().Field(col.Field).Name, err)
+			}
+			values[i] = typed
+		case int, int8, int16, int32, int64:
+			values[i] = reflect.ValueOf(value).Int()
+		case uint, uint8, uint16, uint32, uint64:
+			values[i] = reflect.ValueOf(value).Uint()
+		case float32, float64:
+			values[i] = reflect.ValueOf(value).Float()
+		case bool:
+			values[i] = value
+		default:
+			values[i] = fmt.Sprintf("%v", value)
+		}
+	}
+
+	return values, nil
+}
+
+// typedValue converts the value of a string column to the type declared by its type tag
+func typedValue(col models.Column, value string) (interface{}, error) {
+	if col.Type == models.ValueString || col.Type == models.ValueReference {
+		return value, nil
+	}
+	if value == "" {
+		return nil, nil
+	}
+
+	switch col.Type {
+	case models.ValueInt:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", value)
+		}
+		return n, nil
+	case models.ValueBoolean:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", value)
+		}
+		return b, nil
+	case models.ValueTimestamp:
+		ts, err := models.ParseTime(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a date/time", value)
+		}
+		return ts, nil
+	case models.ValueDate:
+		d, err := models.ParseTime(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a date", value)
+		}
+		return date(d), nil
+	}
+	return value, nil
+}
+
+// sheetName makes a table name a valid sheet name
+func sheetName(name string) string {
+	name = strings.NewReplacer(":", "_", `\`, "_", "/", "_", "?", "_", "*", "_", "[", "(", "]", ")").Replace(name)
+	name = strings.Trim(name, "'")
+	if name == "" {
+		return "Sheet1"
+	}
+	if utf8.RuneCountInString(name) > maxSheetNameLength {
+		name = string([]rune(name)[:maxSheetNameLength])
+	}
+	return name
+}
+
+// rolloverName names the nth sheet of a table that spans several sheets
+func rolloverName(name string, n int) string {
+	suffix := fmt.Sprintf(" (%d)", n)
+	if runes := []rune(name); len(runes)+len(suffix) > maxSheetNameLength {
+		name = string(runes[:maxSheetNameLength-len(suffix)])
+	}
+	return name + suffix
+}
+
+// getColumnName converts a column index to Excel column name (A, B, C, ..., AA, AB, etc.)
+func getColumnName(index int) string {
+	result := ""
+	for index >= 0 {
+		result = string(rune('A'+index%26)) + result
+		index = index/26 - 1
+	}
+	return result
+}
@@ -0,0 +1,314 @@
+package excel
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+	"github.com/xuri/excelize/v2"
+)
+
+type testRecord struct {
+	Number         string                `json:"number"`
+	Caller         models.ReferenceValue `json:"caller"`
+	Impact         int                   `json:"impact"`
+	Priority       string                `json:"priority" type:"int"`
+	NeedsAttention string                `json:"needs_attention" type:"boolean"`
+	OpenedAt       string                `json:"opened_at" type:"timestamp"`
+	DueDate        string                `json:"due_date" type:"date"`
+}
+
+var testHeaders = []string{"Number", "Caller", "Impact", "Priority", "Needs attention", "Opened", "Due date"}
+
+func TestWriteTypedCells(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.xlsx")
+	writer := NewWriter("incident")
+	defer writer.Close()
+
+	if err := writer.SetHeaders(testHeaders); err != nil {
+		t.Fatalf("Failed to set headers: %v", err)
+	}
+	records := []interface{}{
+		&testRecord{
+			Number:         "INC0010001",
+			Caller:         models.ReferenceValue{SysID: "u1", DisplayValue: "Jane Doe"},
+			Impact:         2,
+			Priority:       "3",
+			NeedsAttention: "true",
+			OpenedAt:       "2024-03-01 08:30:00",
+			DueDate:        "2024-03-05",
+		},
+		&testRecord{Number: "INC0010002", Impact: 1},
+	}
+	if err := writer.WriteRecords(records); err != nil {
+		t.Fatalf("Failed to write records: %v", err)
+	}
+	if err := writer.SaveToFile(filename); err != nil {
+		t.Fatalf("Failed to save workbook: %v", err)
+	}
+
+	f, err := excelize.OpenFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to open workbook: %v", err)
+	}
+	defer f.Close()
+
+	if sheets := f.GetSheetList(); len(sheets) != 1 || sheets[0] != "incident" {
+		t.Fatalf("Expected a single incident sheet, got %v", sheets)
+	}
+
+	// Numeric cells are saved without a type attribute, so they read back as unset
+	cellTypes := map[string]excelize.CellType{
+		"A2": excelize.CellTypeInlineString,
+		"C2": excelize.CellTypeUnset,
+		"D2": excelize.CellTypeUnset,
+		"E2": excelize.CellTypeBool,
+		"F2": excelize.CellTypeUnset,
+		"G2": excelize.CellTypeUnset,
+	}
+	for cell, want := range cellTypes {
+		got, err := f.GetCellType("incident", cell)
+		if err != nil {
+			t.Fatalf("Failed to get type of %s: %v", cell, err)
+		}
+		if got != want {
+			t.Errorf("Expected %s to have cell type %d, got %d", cell, want, got)
+		}
+	}
+
+	// Dates and timestamps are shown in their number formats
+	for cell, want := range map[string]string{"F2": "2024-03-01 08:30:00", "G2": "2024-03-05", "C2": "2", "D3": ""} {
+		got, err := f.GetCellValue("incident", cell)
+		if err != nil || got != want {
+			t.Errorf("Expected %s to show %q, got %q (%v)", cell, want, got, err)
+		}
+	}
+}
+
+func TestHeaderFormatting(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.xlsx")
+	writer := NewWriter("incident")
+	defer writer.Close()
+
+	if err := writer.SetHeaders(testHeaders); err != nil {
+		t.Fatalf("Failed to set headers: %v", err)
+	}
+	record := &testRecord{Number: "INC0010001", Caller: models.ReferenceValue{DisplayValue: "A caller with a rather long display name"}}
+	if err := writer.WriteRecord(record); err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+	if err := writer.SaveToFile(filename); err != nil {
+		t.Fatalf("Failed to save workbook: %v", err)
+	}
+
+	f, err := excelize.OpenFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to open workbook: %v", err)
+	}
+	defer f.Close()
+
+	styleID, err := f.GetCellStyle("incident", "A1")
+	if err != nil {
+		t.Fatalf("Failed to get header style: %v", err)
+	}
+	style, err := f.GetStyle(styleID)
+	if err != nil || style.Font == nil || !style.Font.Bold {
+		t.Errorf("Expected a bold header row, got %+v (%v)", style, err)
+	}
+
+	panes, err := f.GetPanes("incident")
+	if err != nil {
+		t.Fatalf("Failed to get panes: %v", err)
+	}
+	if !panes.Freeze || panes.YSplit != 1 || panes.TopLeftCell != "A2" {
+		t.Errorf("Expected the header row to be frozen, got %+v", panes)
+	}
+
+	names := f.GetDefinedName()
+	if len(names) != 1 || names[0].Name != "_xlnm._FilterDatabase" || names[0].RefersTo != "'incident'!$A$1:$G$2" {
+		t.Errorf("Expected an autofilter over A1:G2, got %+v", names)
+	}
+
+	narrow, _ := f.GetColWidth("incident", "C")
+	wide, _ := f.GetColWidth("incident", "B")
+	if narrow < minColumnWidth || wide <= narrow || wide > maxColumnWidth {
+		t.Errorf("Expected columns sized to content, got widths %.0f and %.0f", narrow, wide)
+	}
+}
+
+func TestWorkbookSheets(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.xlsx")
+	workbook := NewWorkbook()
+	defer workbook.Close()
+
+	users, err := workbook.AddSheet("sys_user")
+	if err != nil {
+		t.Fatalf("Failed to add sheet: %v", err)
+	}
+	groups, err := workbook.AddSheet("sys_user_group")
+	if err != nil {
+		t.Fatalf("Failed to add sheet: %v", err)
+	}
+	if _, err := workbook.AddSheet("sys_user"); err == nil {
+		t.Error("Expected an error adding a sheet twice")
+	}
+	long, err := workbook.AddSheet("cmdb_ci_a_very_long_class_name_indeed")
+	if err != nil {
+		t.Fatalf("Failed to add sheet: %v", err)
+	}
+
+	// Rows of different sheets can be interleaved
+	for _, write := range []struct {
+		w      *Writer
+		number string
+	}{{users, "U1"}, {groups, "G1"}, {users, "U2"}, {long, "L1"}} {
+		if err := write.w.WriteRecord(&testRecord{Number: write.number}); err != nil {
+			t.Fatalf("Failed to write record %s: %v", write.number, err)
+		}
+	}
+
+	if err := workbook.SaveToFile(filename); err != nil {
+		t.Fatalf("Failed to save workbook: %v", err)
+	}
+
+	f, err := excelize.OpenFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to open workbook: %v", err)
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	expected := []string{"sys_user", "sys_user_group", "cmdb_ci_a_very_long_class_name_"}
+	if len(sheets) != len(expected) {
+		t.Fatalf("Expected sheets %v, got %v", expected, sheets)
+	}
+	for i := range expected {
+		if sheets[i] != expected[i] {
+			t.Errorf("Expected sheet %d to be %s, got %s", i, expected[i], sheets[i])
+		}
+	}
+
+	rows, _ := f.GetRows("sys_user")
+	if len(rows) != 3 || rows[2][0] != "U2" {
+		t.Errorf("Expected 2 user rows under the header, got %v", rows)
+	}
+	rows, _ = f.GetRows("sys_user_group")
+	if len(rows) != 2 || rows[1][0] != "G1" {
+		t.Errorf("Expected 1 group row under the header, got %v", rows)
+	}
+}
+
+func TestRolloverToNewSheet(t *testing.T) {
+	defer func(rows, sample int) { maxRows, widthSampleRows = rows, sample }(maxRows, widthSampleRows)
+	maxRows, widthSampleRows = 4, 2
+
+	filename := filepath.Join(t.TempDir(), "test.xlsx")
+	writer := NewWriter("cmdb_ci_db_mssql_instance_long")
+	defer writer.Close()
+
+	// More rows than are sampled for column widths, so rows are streamed before the save
+	for i := 1; i <= 8; i++ {
+		if err := writer.WriteRecord(&testRecord{Number: fmt.Sprintf("R%d", i)}); err != nil {
+			t.Fatalf("Failed to write record %d: %v", i, err)
+		}
+	}
+	if err := writer.SaveToFile(filename); err != nil {
+		t.Fatalf("Failed to save workbook: %v", err)
+	}
+	if err := writer.WriteRecord(&testRecord{Number: "late"}); err == nil {
+		t.Error("Expected an error writing a record after the save")
+	}
+
+	f, err := excelize.OpenFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to open workbook: %v", err)
+	}
+	defer f.Close()
+
+	// Each sheet holds a header and three records
+	sheets := f.GetSheetList()
+	if len(sheets) != 3 {
+		t.Fatalf("Expected 3 sheets, got %v", sheets)
+	}
+	if sheets[0] != "cmdb_ci_db_mssql_instance_long" || sheets[1] != "cmdb_ci_db_mssql_instance_l (2)" {
+		t.Errorf("Unexpected sheet names %v", sheets[:2])
+	}
+
+	rows, _ := f.GetRows(sheets[1])
+	if len(rows) != 4 || rows[0][0] != "Number" || rows[1][0] != "R4" {
+		t.Errorf("Expected the second sheet to repeat the header and continue at R4, got %v", rows)
+	}
+	rows, _ = f.GetRows(sheets[len(sheets)-1])
+	if len(rows) != 3 || rows[2][0] != "R8" {
+		t.Errorf("Expected the last sheet to hold R7 and R8, got %v", rows)
+	}
+}
+
+func TestTruncateLongText(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.xlsx")
+	writer := NewWriter("incident")
+	defer writer.Close()
+
+	if err := writer.WriteRecord(&testRecord{Number: strings.Repeat("é", excelize.TotalCellChars+10)}); err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+	if err := writer.SaveToFile(filename); err != nil {
+		t.Fatalf("Failed to save workbook: %v", err)
+	}
+
+	f, err := excelize.OpenFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to open workbook: %v", err)
+	}
+	defer f.Close()
+
+	value, _ := f.GetCellValue("incident", "A2")
+	if n := len([]rune(value)); n != excelize.TotalCellChars {
+		t.Errorf("Expected the value to be truncated to %d characters, got %d", excelize.TotalCellChars, n)
+	}
+}
+
+func TestColumnLabels(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.xlsx")
+	writer := NewWriter("incident")
+	defer writer.Close()
+	writer.SetReferenceMode(models.ReferenceBoth)
+
+	// Without headers the labels of the columns are written
+	record := &testRecord{Number: "INC0010001", Caller: models.ReferenceValue{SysID: "u1", DisplayValue: "Jane Doe"}, Impact: 2}
+	if err := writer.WriteRecord(record); err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+	if err := writer.SaveToFile(filename); err != nil {
+		t.Fatalf("Failed to save workbook: %v", err)
+	}
+
+	f, err := excelize.OpenFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to open workbook: %v", err)
+	}
+	defer f.Close()
+
+	rows, _ := f.GetRows("incident")
+	expected := [][]string{
+		{"Number", "Caller", "Caller.display", "Impact", "Priority", "Needs attention", "Opened at", "Due date"},
+		{"INC0010001", "u1", "Jane Doe", "2"},
+	}
+	if len(rows) != 2 || strings.Join(rows[0], "|") != strings.Join(expected[0], "|") || strings.Join(rows[1], "|") != strings.Join(expected[1], "|") {
+		t.Errorf("Expected rows %v, got %v", expected, rows)
+	}
+}
+
+func TestHeaderColumnMismatch(t *testing.T) {
+	writer := NewWriter("incident")
+	defer writer.Close()
+
+	if err := writer.SetHeaders([]string{"Number", "Caller"}); err != nil {
+		t.Fatalf("Failed to set headers: %v", err)
+	}
+	if err := writer.WriteRecord(&testRecord{Number: "INC0010001"}); err == nil {
+		t.Error("Expected an error writing records with fewer headers than columns")
+	}
+}
@@ -0,0 +1,232 @@
+package json
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+// Writer handles JSON file operations. Records are streamed to the file as they are
+// written, either as the elements of a JSON array or as newline-delimited JSON.
+type Writer struct {
+	file    *os.File
+	writer  *bufio.Writer
+	headers []string
+	lines   bool
+	count   int
+
+	referenceMode models.ReferenceMode
+}
+
+// NewWriter creates a new writer producing a JSON array of records
+func NewWriter(filename string) (*Writer, error) {
+	w, err := newWriter(filename, false)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.writer.WriteString("["); err != nil {
+		w.file.Close()
+		return nil, fmt.Errorf("failed to write JSON array: %w", err)
+	}
+	return w, nil
+}
+
+// NewLinesWriter creates a new writer producing newline-delimited JSON, one record per line
+func NewLinesWriter(filename string) (*Writer, error) {
+	return newWriter(filename, true)
+}
+
+// NewLinesAppendWriter opens an existing newline-delimited JSON file to add records to
+// after offset, the size of the file when its last complete record was written.
+// Anything after offset is dropped.
+func NewLinesAppendWriter(filename string, offset int64) (*Writer, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open JSON file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read JSON file: %w", err)
+	}
+	if info.Size() < offset {
+		file.Close()
+		return nil, fmt.Errorf("%s has %d bytes, fewer than the %d written before", filename, info.Size(), offset)
+	}
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to truncate JSON file: %w", err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to seek JSON file: %w", err)
+	}
+
+	return &Writer{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		lines:  true,
+	}, nil
+}
+
+func newWriter(filename string, lines bool) (*Writer, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JSON file: %w", err)
+	}
+
+	return &Writer{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		lines:  lines,
+	}, nil
+}
+
+// SetHeaders sets the column headers. JSON objects are keyed by the json tags of the
+// record fields, so the headers are kept for the writer contract but not written.
+func (w *Writer) SetHeaders(headers []string) error {
+	w.headers = headers
+	return nil
+}
+
+// SetReferenceMode sets whether reference fields are written as display values, sys_ids
+// or both. In both mode the sys_id is written under the field's name and the display
+// value under "<name>.display".
+func (w *Writer) SetReferenceMode(mode models.ReferenceMode) {
+	w.referenceMode = mode
+}
+
+// WriteRecord writes a record to the JSON file
+func (w *Writer) WriteRecord(record interface{}) error {
+	data, err := w.encode(record)
+	if err != nil {
+		return err
+	}
+
+	// Array elements are separated by commas, while each line holds a complete record
+	prefix, suffix := "\n", ""
+	if w.lines {
+		prefix, suffix = "", "\n"
+	} else if w.count > 0 {
+		prefix = ",\n"
+	}
+	if _, err := w.writer.WriteString(prefix); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	if _, err := w.writer.Write(data); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	if _, err := w.writer.WriteString(suffix); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+
+	w.count++
+	return nil
+}
+
+// WriteRecords writes multiple records to the JSON file
+func (w *Writer) WriteRecords(records []interface{}) error {
+	for _, record := range records {
+		if err := w.WriteRecord(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush flushes any buffered data to the underlying file
+func (w *Writer) Flush() error {
+	return w.writer.Flush()
+}
+
+// Offset flushes the records written so far and returns the size of the file
+func (w *Writer) Offset() (int64, error) {
+	if err := w.writer.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to flush JSON file: %w", err)
+	}
+	return w.file.Seek(0, io.SeekCurrent)
+}
+
+// Close terminates the JSON array if needed and closes the file
+func (w *Writer) Close() error {
+	if !w.lines {
+		if _, err := w.writer.WriteString("\n]\n"); err != nil {
+			w.file.Close()
+			return fmt.Errorf("failed to write JSON file: %w", err)
+		}
+	}
+	if err := w.writer.Flush(); err != nil {
+		w.file.Close()
+		return fmt.Errorf("failed to flush JSON file: %w", err)
+	}
+	return w.file.Close()
+}
+
+// encode encodes a record as a JSON object, keeping the order of the record columns
+func (w *Writer) encode(record interface{}) ([]byte, error) {
+	v := reflect.ValueOf(record)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unsupported record type %T", record)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	first := true
+	add := func(name string, value interface{}) error {
+		key, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to encode field %s: %w", name, err)
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(data)
+		return nil
+	}
+
+	columns, err := models.RecordColumns(v.Type(), w.referenceMode)
+	if err != nil {
+		return nil, err
+	}
+	for _, col := range columns {
+		field := v.Field(col.Field)
+		if omitEmpty(v.Type().Field(col.Field)) && field.IsZero() {
+			continue
+		}
+		if err := add(col.Name, col.Value(v, w.referenceMode)); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// omitEmpty reports whether a field tagged omitempty is left out when empty
+func omitEmpty(field reflect.StructField) bool {
+	_, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+	for _, option := range strings.Split(options, ",") {
+		if option == "omitempty" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package json
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/generator"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+func testIncidents() []interface{} {
+	return []interface{}{
+		&generator.IncidentRecord{
+			SysID:         "0123456789abcdef0123456789abcdef",
+			Number:        "INC0010001",
+			Caller:        models.ReferenceValue{SysID: "46d44a23a9fe19810012d100cca80666", DisplayValue: "Beth Anglin"},
+			Category:      "Network",
+			IncidentState: "New",
+			Impact:        2,
+		},
+		&generator.IncidentRecord{
+			SysID:         "fedcba9876543210fedcba9876543210",
+			Number:        "INC0010002",
+			Caller:        models.ReferenceValue{SysID: "5137153cc611227c000bbd1bd8cd2007", DisplayValue: "David Loo"},
+			Category:      "Software",
+			IncidentState: "Closed",
+			Impact:        3,
+		},
+	}
+}
+
+func TestWriteJSONArray(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "incidents.json")
+
+	writer, err := NewWriter(filename)
+	if err != nil {
+		t.Fatalf("Failed to create JSON writer: %v", err)
+	}
+	if err := writer.SetHeaders([]string{"Sys ID", "Number"}); err != nil {
+		t.Fatalf("Failed to set headers: %v", err)
+	}
+	if err := writer.WriteRecords(testIncidents()); err != nil {
+		t.Fatalf("Failed to write records: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	var records []map[string]interface{}
+	if err := json.Unmarshal(content, &records); err != nil {
+		t.Fatalf("Output is not a JSON array: %v\n%s", err, content)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	if records[0]["number"] != "INC0010001" || records[0]["caller"] != "Beth Anglin" {
+		t.Errorf("Unexpected first record: %v", records[0])
+	}
+	if records[1]["impact"] != float64(3) {
+		t.Errorf("Expected impact to be written as a number, got %v", records[1]["impact"])
+	}
+}
+
+func TestWriteEmptyJSONArray(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "empty.json")
+
+	writer, err := NewWriter(filename)
+	if err != nil {
+		t.Fatalf("Failed to create JSON writer: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	var records []map[string]interface{}
+	if err := json.Unmarshal(content, &records); err != nil || len(records) != 0 {
+		t.Errorf("Expected an empty JSON array, got %q (%v)", content, err)
+	}
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "incidents.ndjson")
+
+	writer, err := NewLinesWriter(filename)
+	if err != nil {
+		t.Fatalf("Failed to create NDJSON writer: %v", err)
+	}
+	writer.SetReferenceMode(models.ReferenceBoth)
+	if err := writer.WriteRecords(testIncidents()); err != nil {
+		t.Fatalf("Failed to write records: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d: %q", len(lines), content)
+	}
+	for _, line := range lines {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Line is not a JSON object: %v\n%s", err, line)
+		}
+		if len(record["caller"].(string)) != 32 || record["caller.display"] == "" {
+			t.Errorf("Expected caller sys_id and display value, got %v", record)
+		}
+	}
+
+	// Fields keep the order of the record struct
+	if !strings.HasPrefix(lines[0], `{"sys_id":"0123456789abcdef0123456789abcdef","number":"INC0010001","caller":`) {
+		t.Errorf("Unexpected field order: %s", lines[0])
+	}
+}
+
+func TestWriteUnsupportedRecordType(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "unsupported.ndjson")
+
+	writer, err := NewLinesWriter(filename)
+	if err != nil {
+		t.Fatalf("Failed to create NDJSON writer: %v", err)
+	}
+	defer writer.Close()
+
+	if err := writer.WriteRecord("not a record"); err == nil {
+		t.Error("Expected an error for a non-struct record")
+	}
+}
+
+func TestAppendNDJSON(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "incidents.ndjson")
+	records := testIncidents()
+
+	writer, err := NewLinesWriter(filename)
+	if err != nil {
+		t.Fatalf("Failed to create NDJSON writer: %v", err)
+	}
+	if err := writer.WriteRecord(records[0]); err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+	offset, err := writer.Offset()
+	if err != nil {
+		t.Fatalf("Failed to get offset: %v", err)
+	}
+	// A record written after the offset was not checkpointed, so it is dropped
+	if err := writer.WriteRecord(records[0]); err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+	writer.Close()
+
+	writer, err = NewLinesAppendWriter(filename, offset)
+	if err != nil {
+		t.Fatalf("Failed to open NDJSON writer: %v", err)
+	}
+	if err := writer.WriteRecord(records[1]); err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+	writer.Close()
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "INC0010001") || !strings.Contains(lines[1], "INC0010002") {
+		t.Errorf("Expected the first record followed by the appended one, got %q", content)
+	}
+
+	if _, err := NewLinesAppendWriter(filename, int64(len(content))+1); err == nil {
+		t.Error("Expected an error appending after the end of the file")
+	}
+}
+
+func TestWriteTaggedColumns(t *testing.T) {
+	type taggedRecord struct {
+		Number string `json:"number"`
+		Notes  string `json:"notes" label:"-"`
+		SysID  string `json:"sys_id" order:"1"`
+	}
+	filename := filepath.Join(t.TempDir(), "tagged.json")
+	writer, err := NewWriter(filename)
+	if err != nil {
+		t.Fatalf("Failed to create JSON writer: %v", err)
+	}
+	if err := writer.WriteRecord(&taggedRecord{Number: "INC0010001", Notes: "internal", SysID: "a1"}); err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	// Keys are the columns of CSV and Excel output: moved by order, without label:"-"
+	if got := strings.Join(strings.Fields(string(content)), ""); got != `[{"sys_id":"a1","number":"INC0010001"}]` {
+		t.Errorf("Expected the keys sys_id and number only, got %s", got)
+	}
+}
@@ -0,0 +1,257 @@
+package parquet
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+)
+
+// codecs are the compression codecs the writer supports, by name
+var codecs = map[string]compress.Codec{
+	"none":   &parquet.Uncompressed,
+	"snappy": &parquet.Snappy,
+	"gzip":   &parquet.Gzip,
+	"zstd":   &parquet.Zstd,
+	"lz4":    &parquet.Lz4Raw,
+	"brotli": &parquet.Brotli,
+}
+
+// Compressions returns the names of the supported compression codecs
+func Compressions() []string {
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Writer writes records to a Parquet file with columns typed after the value types of
+// the record fields. Typed string fields that are empty are null.
+type Writer struct {
+	file         *os.File
+	writer       *parquet.Writer
+	codec        compress.Codec
+	rowGroupSize int
+	headers      []string
+
+	recordType reflect.Type
+	rowType    reflect.Type
+	columns    []models.Column
+
+	referenceMode models.ReferenceMode
+}
+
+// NewWriter creates a Parquet writer that starts a new row group every rowGroupSize
+// records and compresses columns with the named codec
+func NewWriter(filename string, rowGroupSize int, compression string) (*Writer, error) {
+	codec, ok := codecs[strings.ToLower(compression)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported Parquet compression %q (use %s)", compression, strings.Join(Compressions(), ", "))
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Parquet file: %w", err)
+	}
+
+	return &Writer{
+		file:         file,
+		codec:        codec,
+		rowGroupSize: rowGroupSize,
+	}, nil
+}
+
+// SetHeaders sets the column headers. Columns are named after the record columns, so
+// the headers are kept for the writer contract but not written.
+func (w *Writer) SetHeaders(headers []string) error {
+	w.headers = headers
+	return nil
+}
+
+// SetReferenceMode sets whether reference fields are written as display values, sys_ids
+// or both. In both mode the sys_id is written under the field's name and the display
+// value under "<name>.display". It must be called before the first record is written.
+func (w *Writer) SetReferenceMode(mode models.ReferenceMode) {
+	w.referenceMode = mode
+}
+
+// WriteRecord writes a record to the Parquet file. The schema is derived from the type
+// of the first record, and every later record must have the same type.
+func (w *Writer) WriteRecord(record interface{}) error {
+	v := reflect.ValueOf(record)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("unsupported record type %T", record)
+	}
+
+	if w.writer == nil {
+		if err := w.configure(v.Type()); err != nil {
+			return err
+		}
+	} else if v.Type() != w.recordType {
+		return fmt.Errorf("record type %s does not match the file's record type %s", v.Type(), w.recordType)
+	}
+
+	row, err := w.row(v)
+	if err != nil {
+		return err
+	}
+	if err := w.writer.Write(row.Interface()); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	return nil
+}
+
+// WriteRecords writes multiple records to the Parquet file
+func (w *Writer) WriteRecords(records []interface{}) error {
+	for _, record := range records {
+		if err := w.WriteRecord(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close writes the last row group and the file footer, and closes the file
+func (w *Writer) Close() error {
+	if w.writer != nil {
+		if err := w.writer.Close(); err != nil {
+			w.file.Close()
+			return fmt.Errorf("failed to write Parquet file: %w", err)
+		}
+	}
+	return w.file.Close()
+}
+
+// configure builds the Parquet schema for records of the given type
+func (w *Writer) configure(recordType reflect.Type) error {
+	var fields []reflect.StructField
+	addField := func(name string, goType reflect.Type, tag string) {
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Column%d", len(fields)),
+			Type: goType,
+			Tag:  reflect.StructTag(fmt.Sprintf(`parquet:"%s%s"`, name, tag)),
+		})
+	}
+
+	columns, err := models.RecordColumns(recordType, w.referenceMode)
+	if err != nil {
+		return err
+	}
+	for _, col := range columns {
+		field := recordType.Field(col.Field)
+		name := col.Name
+		switch col.Type {
+		case models.ValueInt:
+			if field.Type.Kind() == reflect.String {
+				addField(name, reflect.TypeOf((*int64)(nil)), "")
+			} else {
+				addField(name, reflect.TypeOf(int64(0)), "")
+			}
+		case models.ValueBoolean:
+			if field.Type.Kind() == reflect.String {
+				addField(name, reflect.TypeOf((*bool)(nil)), "")
+			} else {
+				addField(name, reflect.TypeOf(false), "")
+			}
+		case models.ValueTimestamp:
+			addField(name, reflect.TypeOf(time.Time{}), ",timestamp(millisecond),optional")
+		case models.ValueDate:
+			addField(name, reflect.TypeOf(int32(0)), ",date,optional")
+		default:
+			addField(name, reflect.TypeOf(""), "")
+		}
+	}
+
+	w.columns = columns
+	w.recordType = recordType
+	w.rowType = reflect.StructOf(fields)
+
+	options := []parquet.WriterOption{
+		parquet.SchemaOf(reflect.New(w.rowType).Interface()),
+		parquet.Compression(w.codec),
+	}
+	if w.rowGroupSize > 0 {
+		options = append(options, parquet.MaxRowsPerRowGroup(int64(w.rowGroupSize)))
+	}
+	config, err := parquet.NewWriterConfig(options...)
+	if err != nil {
+		return fmt.Errorf("failed to configure Parquet writer: %w", err)
+	}
+	w.writer = parquet.NewWriter(w.file, config)
+	return nil
+}
+
+// row converts a record into a value of the schema's row type
+func (w *Writer) row(record reflect.Value) (reflect.Value, error) {
+	row := reflect.New(w.rowType).Elem()
+
+	for i, col := range w.columns {
+		field := record.Field(col.Field)
+		out := row.Field(i)
+
+		if col.Type == models.ValueReference {
+			out.SetString(col.Value(record, w.referenceMode).(string))
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			out.SetInt(field.Int())
+			continue
+		case reflect.Bool:
+			out.SetBool(field.Bool())
+			continue
+		}
+
+		value := field.String()
+		if col.Type == models.ValueString {
+			out.SetString(value)
+			continue
+		}
+		if value == "" {
+			continue
+		}
+
+		name := w.recordType.Field(col.Field).Name
+		switch col.Type {
+		case models.ValueInt:
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %s: %q is not an integer", name, value)
+			}
+			out.Set(reflect.ValueOf(&n))
+		case models.ValueBoolean:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %s: %q is not a boolean", name, value)
+			}
+			out.Set(reflect.ValueOf(&b))
+		case models.ValueTimestamp:
+			t, err := models.ParseTime(value)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %s: %q is not a date/time", name, value)
+			}
+			out.Set(reflect.ValueOf(t))
+		case models.ValueDate:
+			t, err := models.ParseTime(value)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %s: %q is not a date", name, value)
+			}
+			out.SetInt(t.Unix() / 86400)
+		}
+	}
+
+	return row, nil
+}
@@ -0,0 +1,204 @@
+package parquet
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+	"github.com/parquet-go/parquet-go"
+)
+
+type testRecord struct {
+	Number         string                `json:"number"`
+	Caller         models.ReferenceValue `json:"caller"`
+	Impact         int                   `json:"impact"`
+	Priority       string                `json:"priority" type:"int"`
+	NeedsAttention string                `json:"needs_attention" type:"boolean"`
+	OpenedAt       string                `json:"opened_at" type:"timestamp"`
+	DueDate        string                `json:"due_date" type:"date"`
+}
+
+// testRow is the row layout the writer produces for testRecord in both mode
+type testRow struct {
+	Number         string    `parquet:"number"`
+	Caller         string    `parquet:"caller"`
+	CallerDisplay  string    `parquet:"caller.display"`
+	Impact         int64     `parquet:"impact"`
+	Priority       *int64    `parquet:"priority"`
+	NeedsAttention *bool     `parquet:"needs_attention"`
+	OpenedAt       time.Time `parquet:"opened_at,timestamp(millisecond),optional"`
+	DueDate        int32     `parquet:"due_date,date,optional"`
+}
+
+func openFile(t *testing.T, filename string) *parquet.File {
+	t.Helper()
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Failed to open Parquet file: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("Failed to stat Parquet file: %v", err)
+	}
+	pf, err := parquet.OpenFile(f, info.Size())
+	if err != nil {
+		t.Fatalf("Failed to read Parquet file: %v", err)
+	}
+	return pf
+}
+
+func TestWriteTypedColumns(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.parquet")
+	writer, err := NewWriter(filename, 2, "zstd")
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	writer.SetReferenceMode(models.ReferenceBoth)
+
+	records := []interface{}{
+		&testRecord{
+			Number:         "CS0001001",
+			Caller:         models.ReferenceValue{SysID: "abc", DisplayValue: "Jane Doe"},
+			Impact:         2,
+			Priority:       "3",
+			NeedsAttention: "true",
+			OpenedAt:       "2024-03-01 08:30:00",
+			DueDate:        "2024-03-05",
+		},
+		&testRecord{Number: "CS0001002", Impact: 1, NeedsAttention: "false"},
+		&testRecord{Number: "CS0001003", Impact: 3},
+	}
+	if err := writer.WriteRecords(records); err != nil {
+		t.Fatalf("Failed to write records: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+
+	pf := openFile(t, filename)
+
+	// Row groups follow the configured size
+	if len(pf.RowGroups()) != 2 {
+		t.Errorf("Expected 2 row groups, got %d", len(pf.RowGroups()))
+	}
+
+	types := map[string]string{}
+	for _, field := range pf.Schema().Fields() {
+		types[field.Name()] = field.Type().String()
+	}
+	expected := map[string]string{
+		"number":          "STRING",
+		"caller.display":  "STRING",
+		"impact":          "INT(64",
+		"priority":        "INT(64",
+		"needs_attention": "BOOLEAN",
+		"opened_at":       "TIMESTAMP",
+		"due_date":        "DATE",
+	}
+	for name, prefix := range expected {
+		if !strings.HasPrefix(types[name], prefix) {
+			t.Errorf("Expected column %s of type %s, got %q", name, prefix, types[name])
+		}
+	}
+	if pf.Schema().Fields()[0].Name() != "number" || pf.Schema().Fields()[2].Name() != "caller.display" {
+		t.Error("Expected columns in struct field order")
+	}
+
+	reader := parquet.NewGenericReader[testRow](pf)
+	rows := make([]testRow, 3)
+	n, err := reader.Read(rows)
+	if err != nil && err != io.EOF {
+		t.Fatalf("Failed to read rows: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("Expected 3 rows, got %d", n)
+	}
+
+	first := rows[0]
+	if first.Caller != "abc" || first.CallerDisplay != "Jane Doe" || first.Impact != 2 {
+		t.Errorf("Unexpected first row %+v", first)
+	}
+	if first.Priority == nil || *first.Priority != 3 {
+		t.Errorf("Expected priority 3, got %v", first.Priority)
+	}
+	if first.NeedsAttention == nil || !*first.NeedsAttention {
+		t.Errorf("Expected needs_attention true, got %v", first.NeedsAttention)
+	}
+	if !first.OpenedAt.Equal(time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("Expected opened_at 2024-03-01 08:30:00, got %v", first.OpenedAt)
+	}
+	if first.DueDate != int32(time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC).Unix()/86400) {
+		t.Errorf("Expected due_date 2024-03-05, got %v", first.DueDate)
+	}
+
+	// Empty typed fields are null, while false stays false
+	if rows[1].NeedsAttention == nil || *rows[1].NeedsAttention {
+		t.Errorf("Expected needs_attention false, got %v", rows[1].NeedsAttention)
+	}
+	if rows[2].NeedsAttention != nil || rows[2].Priority != nil || !rows[2].OpenedAt.IsZero() {
+		t.Errorf("Expected empty typed fields to be null, got %+v", rows[2])
+	}
+}
+
+func TestWriteInvalidValue(t *testing.T) {
+	writer, err := NewWriter(filepath.Join(t.TempDir(), "test.parquet"), 10, "snappy")
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	defer writer.Close()
+
+	if err := writer.WriteRecord(&testRecord{Priority: "high"}); err == nil {
+		t.Error("Expected an error for a non-numeric int field")
+	}
+	if err := writer.WriteRecord("not a record"); err == nil {
+		t.Error("Expected an error for an unsupported record type")
+	}
+}
+
+func TestNewWriterCompression(t *testing.T) {
+	for _, name := range Compressions() {
+		writer, err := NewWriter(filepath.Join(t.TempDir(), name+".parquet"), 10, name)
+		if err != nil {
+			t.Errorf("Expected compression %s to be supported: %v", name, err)
+			continue
+		}
+		writer.Close()
+	}
+
+	if _, err := NewWriter(filepath.Join(t.TempDir(), "test.parquet"), 10, "rar"); err == nil {
+		t.Error("Expected an error for an unsupported compression")
+	}
+}
+
+func TestWriteTaggedColumns(t *testing.T) {
+	type taggedRecord struct {
+		Number string `json:"number"`
+		Notes  string `json:"notes" label:"-"`
+		SysID  string `json:"sys_id" order:"1"`
+	}
+	filename := filepath.Join(t.TempDir(), "test.parquet")
+	writer, err := NewWriter(filename, 10, "snappy")
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	if err := writer.WriteRecord(&taggedRecord{Number: "INC0010001", Notes: "internal", SysID: "a1"}); err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+
+	// Columns are those of CSV and Excel output: moved by order, without label:"-"
+	var names []string
+	for _, field := range openFile(t, filename).Schema().Fields() {
+		names = append(names, field.Name())
+	}
+	if strings.Join(names, ",") != "sys_id,number" {
+		t.Errorf("Expected the columns sys_id,number, got %v", names)
+	}
+}
@@ -0,0 +1,514 @@
+package servicenow
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+// Config holds the instance, credentials and tuning of a Loader
+type Config struct {
+	InstanceURL string
+	Username    string
+	Password    string
+
+	// ClientID and ClientSecret switch authentication from basic to OAuth. With a
+	// username the password grant is used, otherwise the client credentials grant.
+	ClientID     string
+	ClientSecret string
+
+	// Table is the target table of the Table API. When ImportSet is set, records are
+	// posted to that staging table instead and the transform map decides the target.
+	Table     string
+	ImportSet string
+
+	Parallelism int
+	MaxRetries  int
+	RetryDelay  time.Duration
+
+	// ResultsFile receives a CSV line per record mapping its number to the created sys_id
+	ResultsFile string
+
+	HTTPClient *http.Client
+}
+
+// Result is the outcome of loading a single record
+type Result struct {
+	Number string
+	SysID  string
+	Status string
+	Error  string
+}
+
+// Loader streams records into a ServiceNow instance through the Table API or the
+// Import Set API. It implements the same contract as the file writers, so it can be
+// used wherever records are written.
+type Loader struct {
+	config Config
+	client *http.Client
+
+	tokenMu sync.Mutex
+	token   string
+
+	results       *os.File
+	resultsWriter *csv.Writer
+	loaded        int
+	failed        int
+}
+
+// NewLoader creates a loader for the configured instance and opens the results file
+func NewLoader(config Config) (*Loader, error) {
+	if config.InstanceURL == "" {
+		return nil, fmt.Errorf("instance URL is required")
+	}
+	if config.Table == "" && config.ImportSet == "" {
+		return nil, fmt.Errorf("a target table or import set table is required")
+	}
+	if config.ClientID == "" && config.Username == "" {
+		return nil, fmt.Errorf("a username or OAuth client ID is required")
+	}
+	if config.Parallelism < 1 {
+		config.Parallelism = 1
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = time.Second
+	}
+	config.InstanceURL = strings.TrimRight(config.InstanceURL, "/")
+	if !strings.Contains(config.InstanceURL, "://") {
+		config.InstanceURL = "https://" + config.InstanceURL
+	}
+
+	l := &Loader{
+		config: config,
+		client: config.HTTPClient,
+	}
+	if l.client == nil {
+		l.client = &http.Client{Timeout: 60 * time.Second}
+	}
+
+	if config.ResultsFile != "" {
+		file, err := os.Create(config.ResultsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create results file: %w", err)
+		}
+		l.results = file
+		l.resultsWriter = csv.NewWriter(file)
+		if err := l.resultsWriter.Write([]string{"number", "sys_id", "status", "error"}); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write results file: %w", err)
+		}
+	}
+
+	return l, nil
+}
+
+// SetHeaders is part of the writer contract. Records are posted as field name/value
+// pairs, so the headers are not used.
+func (l *Loader) SetHeaders(headers []string) error {
+	return nil
+}
+
+// SetReferenceMode is part of the writer contract. References are always loaded as sys_ids.
+func (l *Loader) SetReferenceMode(mode models.ReferenceMode) {}
+
+// WriteRecord loads a single record
+func (l *Loader) WriteRecord(record interface{}) error {
+	return l.WriteRecords([]interface{}{record})
+}
+
+// WriteRecords loads a batch of records in parallel. Records the instance rejects are
+// counted and recorded in the results file rather than failing the batch.
+func (l *Loader) WriteRecords(records []interface{}) error {
+	return l.WriteRecordsContext(context.Background(), records)
+}
+
+// WriteRecordsContext loads a batch of records like WriteRecords until ctx is done. Then
+// requests in flight are cancelled, retries are not waited for, and the records left are
+// recorded as cancelled in the results file without being counted, and the error of ctx
+// is returned.
+func (l *Loader) WriteRecordsContext(ctx context.Context, records []interface{}) error {
+	payloads := make([]map[string]string, len(records))
+	for i, record := range records {
+		payload, err := encode(record)
+		if err != nil {
+			return err
+		}
+		payloads[i] = payload
+	}
+
+	results := make([]Result, len(payloads))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < l.config.Parallelism && w < len(payloads); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = l.load(ctx, payloads[i])
+			}
+		}()
+	}
+	sent := 0
+dispatch:
+	for ; sent < len(payloads) && ctx.Err() == nil; sent++ {
+		select {
+		case jobs <- sent:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, result := range results {
+		if i >= sent {
+			result = Result{Number: payloads[i]["number"], Status: "cancelled", Error: ctx.Err().Error()}
+		}
+		switch {
+		case result.Status == "cancelled":
+		case result.Error != "":
+			l.failed++
+		default:
+			l.loaded++
+		}
+		if l.resultsWriter != nil {
+			if err := l.resultsWriter.Write([]string{result.Number, result.SysID, result.Status, result.Error}); err != nil {
+				return fmt.Errorf("failed to write results file: %w", err)
+			}
+		}
+	}
+	if l.resultsWriter != nil {
+		l.resultsWriter.Flush()
+		if err := l.resultsWriter.Error(); err != nil {
+			return fmt.Errorf("failed to write results file: %w", err)
+		}
+	}
+
+	return ctx.Err()
+}
+
+// Loaded returns the number of records the instance accepted
+func (l *Loader) Loaded() int {
+	return l.loaded
+}
+
+// Failed returns the number of records that could not be loaded
+func (l *Loader) Failed() int {
+	return l.failed
+}
+
+// Close closes the results file. It is safe to call more than once.
+func (l *Loader) Close() error {
+	if l.results == nil {
+		return nil
+	}
+	file := l.results
+	l.results = nil
+
+	l.resultsWriter.Flush()
+	if err := l.resultsWriter.Error(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to flush results file: %w", err)
+	}
+	return file.Close()
+}
+
+// tableResponse is the body returned by the Table API
+type tableResponse struct {
+	Result map[string]interface{} `json:"result"`
+}
+
+// importResponse is the body returned by the Import Set API, with one entry per transform
+type importResponse struct {
+	Result []struct {
+		Table        string `json:"table"`
+		DisplayValue string `json:"display_value"`
+		Status       string `json:"status"`
+		StatusMsg    string `json:"status_message"`
+		ErrorMessage string `json:"error_message"`
+		SysID        string `json:"sys_id"`
+	} `json:"result"`
+}
+
+// load posts one record and reports what the instance did with it. A record whose
+// request is cut short by ctx is reported as cancelled.
+func (l *Loader) load(ctx context.Context, payload map[string]string) Result {
+	result := Result{Number: payload["number"]}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		result.Status, result.Error = "error", fmt.Sprintf("failed to encode record: %v", err)
+		return result
+	}
+
+	endpoint := l.config.InstanceURL + "/api/now/table/" + url.PathEscape(l.config.Table)
+	if l.config.ImportSet != "" {
+		endpoint = l.config.InstanceURL + "/api/now/import/" + url.PathEscape(l.config.ImportSet)
+	}
+
+	data, err := l.post(ctx, endpoint, body)
+	if err != nil {
+		result.Status, result.Error = "error", err.Error()
+		if ctx.Err() != nil {
+			result.Status = "cancelled"
+		}
+		return result
+	}
+
+	if l.config.ImportSet == "" {
+		var response tableResponse
+		if err := json.Unmarshal(data, &response); err != nil {
+			result.Status, result.Error = "error", fmt.Sprintf("failed to parse response: %v", err)
+			return result
+		}
+		result.SysID, _ = response.Result["sys_id"].(string)
+		if number, ok := response.Result["number"].(string); ok && number != "" {
+			result.Number = number
+		}
+		result.Status = "inserted"
+		return result
+	}
+
+	var response importResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		result.Status, result.Error = "error", fmt.Sprintf("failed to parse response: %v", err)
+		return result
+	}
+	if len(response.Result) == 0 {
+		result.Status, result.Error = "error", "import set response contains no transform result"
+		return result
+	}
+	transform := response.Result[0]
+	result.SysID = transform.SysID
+	result.Status = transform.Status
+	if transform.Status == "error" {
+		result.Error = transform.ErrorMessage
+		if result.Error == "" {
+			result.Error = transform.StatusMsg
+		}
+		if result.Error == "" {
+			result.Error = "transform failed"
+		}
+	}
+	return result
+}
+
+// post sends a JSON body, retrying rate limited and failed requests with exponential
+// backoff. A Retry-After header from the instance takes precedence over the backoff.
+// Waiting for a retry ends early when ctx is done.
+func (l *Loader) post(ctx context.Context, endpoint string, body []byte) ([]byte, error) {
+	delay := l.config.RetryDelay
+	reauthenticated := false
+
+	for attempt := 0; ; attempt++ {
+		status, data, retryAfter, err := l.send(ctx, endpoint, body)
+		if err == nil && status >= 200 && status < 300 {
+			return data, nil
+		}
+
+		// An expired OAuth token is renewed once without counting as a retry
+		if err == nil && status == http.StatusUnauthorized && l.config.ClientID != "" && !reauthenticated {
+			l.resetToken()
+			reauthenticated = true
+			attempt--
+			continue
+		}
+
+		retryable := err != nil || status == http.StatusTooManyRequests || status >= 500
+		if !retryable || attempt >= l.config.MaxRetries || ctx.Err() != nil {
+			if err != nil {
+				return nil, fmt.Errorf("request failed after %d attempts: %w", attempt+1, err)
+			}
+			return nil, fmt.Errorf("request failed with status %d: %s", status, errorMessage(data))
+		}
+
+		wait := delay
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("request cancelled after %d attempts: %w", attempt+1, ctx.Err())
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// send makes a single authenticated request
+func (l *Loader) send(ctx context.Context, endpoint string, body []byte) (int, []byte, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return 0, nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	if l.config.ClientID != "" {
+		token, err := l.accessToken(ctx)
+		if err != nil {
+			return 0, nil, 0, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		req.SetBasicAuth(l.config.Username, l.config.Password)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return 0, nil, 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, 0, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var retryAfter time.Duration
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+
+	return resp.StatusCode, data, retryAfter, nil
+}
+
+// accessToken returns the OAuth access token, requesting one from the instance if needed
+func (l *Loader) accessToken(ctx context.Context) (string, error) {
+	l.tokenMu.Lock()
+	defer l.tokenMu.Unlock()
+
+	if l.token != "" {
+		return l.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("client_id", l.config.ClientID)
+	form.Set("client_secret", l.config.ClientSecret)
+	if l.config.Username != "" {
+		form.Set("grant_type", "password")
+		form.Set("username", l.config.Username)
+		form.Set("password", l.config.Password)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", l.config.InstanceURL+"/oauth_token.do", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create OAuth token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request OAuth token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read OAuth token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("OAuth token request failed with status %d: %s", resp.StatusCode, errorMessage(data))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(data, &token); err != nil {
+		return "", fmt.Errorf("failed to parse OAuth token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("OAuth token response contains no access token")
+	}
+
+	l.token = token.AccessToken
+	return l.token, nil
+}
+
+// resetToken discards the OAuth access token so the next request fetches a new one
+func (l *Loader) resetToken() {
+	l.tokenMu.Lock()
+	defer l.tokenMu.Unlock()
+	l.token = ""
+}
+
+// errorMessage extracts the message of a ServiceNow error response, falling back to the raw body
+func errorMessage(data []byte) string {
+	var response struct {
+		Error struct {
+			Message string `json:"message"`
+			Detail  string `json:"detail"`
+		} `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(data, &response); err == nil {
+		switch {
+		case response.Error.Message != "" && response.Error.Detail != "":
+			return response.Error.Message + ": " + response.Error.Detail
+		case response.Error.Message != "":
+			return response.Error.Message
+		case response.ErrorDescription != "":
+			return response.ErrorDescription
+		}
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// encode converts a record into ServiceNow field values. Fields are named as in the
+// XML unload format and references are sent as sys_ids.
+func encode(record interface{}) (map[string]string, error) {
+	v := reflect.ValueOf(record)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unsupported record type %T", record)
+	}
+
+	payload := make(map[string]string)
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if !field.CanInterface() {
+			continue
+		}
+
+		name := models.ElementName(t.Field(i))
+		if name == "" {
+			continue
+		}
+
+		switch fv := field.Interface().(type) {
+		case models.ReferenceValue:
+			// References without a sys_id are matched on their display value by the instance
+			payload[name] = fv.SysID
+			if fv.SysID == "" {
+				payload[name] = fv.DisplayValue
+			}
+		default:
+			// Choice fields are sent as the values the instance stores, not their labels
+			payload[name] = models.StoredChoice(t.Field(i), fmt.Sprintf("%v", fv))
+		}
+	}
+	return payload, nil
+}
@@ -0,0 +1,397 @@
+package servicenow
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+type testRecord struct {
+	SysID  string                `json:"sys_id"`
+	Number string                `json:"number"`
+	Caller models.ReferenceValue `json:"caller" xml:"caller_id"`
+	Helper string                `json:"helper" xml:"-"`
+	Impact int                   `json:"impact"`
+	State  string                `json:"incident_state" choice:"incident_state"`
+}
+
+// stubInstance implements the Table, Import Set and OAuth endpoints of an instance
+type stubInstance struct {
+	mu       sync.Mutex
+	received []map[string]string
+	failures []int // statuses returned before requests succeed
+	auth     []string
+	tokens   int
+}
+
+func (s *stubInstance) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.URL.Path == "/oauth_token.do" {
+		if r.FormValue("client_id") != "client" || r.FormValue("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"access_denied","error_description":"bad client"}`)
+			return
+		}
+		s.tokens++
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":1800}`, s.tokens)
+		return
+	}
+
+	s.auth = append(s.auth, r.Header.Get("Authorization"))
+
+	if len(s.failures) > 0 {
+		status := s.failures[0]
+		s.failures = s.failures[1:]
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"error":{"message":"Try again","detail":"stub failure"},"status":"failure"}`)
+		return
+	}
+
+	var payload map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.received = append(s.received, payload)
+	sysID := fmt.Sprintf("created%025d", len(s.received))
+
+	switch {
+	case r.URL.Path == "/api/now/table/incident":
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"result": map[string]string{"sys_id": sysID, "number": payload["number"]},
+		})
+	case r.URL.Path == "/api/now/import/u_incident_import":
+		status, message := "inserted", ""
+		if payload["impact"] == "0" {
+			status, message = "error", "Invalid impact"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"import_set":    "ISET0010001",
+			"staging_table": "u_incident_import",
+			"result": []map[string]string{{
+				"transform_map": "Incident import",
+				"table":         "incident",
+				"display_name":  "number",
+				"display_value": payload["number"],
+				"status":        status,
+				"error_message": message,
+				"sys_id":        sysID,
+			}},
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":{"message":"Invalid table"},"status":"failure"}`)
+	}
+}
+
+func testRecords(n int) []interface{} {
+	records := make([]interface{}, n)
+	for i := range records {
+		records[i] = &testRecord{
+			SysID:  fmt.Sprintf("%032d", i+1),
+			Number: fmt.Sprintf("INC%07d", i+1),
+			Caller: models.ReferenceValue{SysID: "caller-sys-id", DisplayValue: "Jane Doe"},
+			Helper: "not sent",
+			Impact: i%3 + 1,
+			State:  "In Progress",
+		}
+	}
+	return records
+}
+
+func readResults(t *testing.T, filename string) [][]string {
+	t.Helper()
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Failed to open results file: %v", err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read results file: %v", err)
+	}
+	return rows
+}
+
+func TestLoadTableAPI(t *testing.T) {
+	stub := &stubInstance{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	resultsFile := filepath.Join(t.TempDir(), "results.csv")
+	loader, err := NewLoader(Config{
+		InstanceURL: server.URL,
+		Username:    "admin",
+		Password:    "secret",
+		Table:       "incident",
+		Parallelism: 4,
+		ResultsFile: resultsFile,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create loader: %v", err)
+	}
+
+	records := testRecords(25)
+	if err := loader.WriteRecords(records[:20]); err != nil {
+		t.Fatalf("Failed to load records: %v", err)
+	}
+	if err := loader.WriteRecords(records[20:]); err != nil {
+		t.Fatalf("Failed to load records: %v", err)
+	}
+	if err := loader.Close(); err != nil {
+		t.Fatalf("Failed to close loader: %v", err)
+	}
+
+	if loader.Loaded() != 25 || loader.Failed() != 0 {
+		t.Errorf("Expected 25 loaded and 0 failed, got %d and %d", loader.Loaded(), loader.Failed())
+	}
+	if len(stub.received) != 25 {
+		t.Fatalf("Expected 25 requests, got %d", len(stub.received))
+	}
+	for _, auth := range stub.auth {
+		if !strings.HasPrefix(auth, "Basic ") {
+			t.Fatalf("Expected basic auth, got %q", auth)
+		}
+	}
+
+	payload := stub.received[0]
+	if payload["caller_id"] != "caller-sys-id" {
+		t.Errorf("Expected reference sent as sys_id under caller_id, got %v", payload)
+	}
+	if _, ok := payload["helper"]; ok {
+		t.Error("Fields tagged xml:\"-\" should not be sent")
+	}
+	if payload["sys_id"] == "" || payload["impact"] == "" {
+		t.Errorf("Expected sys_id and impact in payload, got %v", payload)
+	}
+	if payload["incident_state"] != "2" {
+		t.Errorf("Expected the stored value of the In Progress state, got %v", payload)
+	}
+
+	rows := readResults(t, resultsFile)
+	if len(rows) != 26 {
+		t.Fatalf("Expected header and 25 result rows, got %d", len(rows))
+	}
+	if strings.Join(rows[0], ",") != "number,sys_id,status,error" {
+		t.Errorf("Unexpected results header %v", rows[0])
+	}
+	// Results are written in record order regardless of parallelism
+	for i, row := range rows[1:] {
+		if row[0] != fmt.Sprintf("INC%07d", i+1) {
+			t.Errorf("Expected row %d to map INC%07d, got %v", i+1, i+1, row)
+		}
+		if !strings.HasPrefix(row[1], "created") || row[2] != "inserted" || row[3] != "" {
+			t.Errorf("Unexpected result row %v", row)
+		}
+	}
+}
+
+func TestLoadImportSet(t *testing.T) {
+	stub := &stubInstance{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	resultsFile := filepath.Join(t.TempDir(), "results.csv")
+	loader, err := NewLoader(Config{
+		InstanceURL: server.URL,
+		Username:    "admin",
+		Password:    "secret",
+		ImportSet:   "u_incident_import",
+		ResultsFile: resultsFile,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create loader: %v", err)
+	}
+
+	records := testRecords(2)
+	records[1].(*testRecord).Impact = 0
+	if err := loader.WriteRecords(records); err != nil {
+		t.Fatalf("Failed to load records: %v", err)
+	}
+	loader.Close()
+
+	if loader.Loaded() != 1 || loader.Failed() != 1 {
+		t.Errorf("Expected 1 loaded and 1 failed, got %d and %d", loader.Loaded(), loader.Failed())
+	}
+
+	rows := readResults(t, resultsFile)
+	if rows[1][0] != "INC0000001" || rows[1][2] != "inserted" || rows[1][1] == "" {
+		t.Errorf("Unexpected result row %v", rows[1])
+	}
+	if rows[2][2] != "error" || rows[2][3] != "Invalid impact" {
+		t.Errorf("Expected transform error in results, got %v", rows[2])
+	}
+}
+
+func TestLoadRetries(t *testing.T) {
+	stub := &stubInstance{failures: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	loader, err := NewLoader(Config{
+		InstanceURL: server.URL,
+		Username:    "admin",
+		Table:       "incident",
+		MaxRetries:  2,
+		RetryDelay:  time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create loader: %v", err)
+	}
+	if err := loader.WriteRecords(testRecords(1)); err != nil {
+		t.Fatalf("Failed to load records: %v", err)
+	}
+	if loader.Loaded() != 1 || len(stub.auth) != 3 {
+		t.Errorf("Expected the record to load on the third attempt, got %d loaded after %d requests", loader.Loaded(), len(stub.auth))
+	}
+
+	// Once retries are exhausted the record is reported as failed
+	stub.failures = []int{500, 500, 500}
+	if err := loader.WriteRecords(testRecords(1)); err != nil {
+		t.Fatalf("Failed to load records: %v", err)
+	}
+	if loader.Failed() != 1 {
+		t.Errorf("Expected 1 failed record, got %d", loader.Failed())
+	}
+
+	// Client errors are not retried
+	stub.failures = []int{http.StatusBadRequest}
+	requests := len(stub.auth)
+	loader.WriteRecords(testRecords(1))
+	if len(stub.auth) != requests+1 || loader.Failed() != 2 {
+		t.Errorf("Expected a single attempt for a client error, got %d", len(stub.auth)-requests)
+	}
+}
+
+func TestLoadCancelled(t *testing.T) {
+	stub := &stubInstance{failures: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	loader, err := NewLoader(Config{
+		InstanceURL: server.URL,
+		Username:    "admin",
+		Table:       "incident",
+		MaxRetries:  3,
+		RetryDelay:  time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create loader: %v", err)
+	}
+
+	// The wait for the retry ends with ctx, and the record is neither loaded nor failed
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	err = loader.WriteRecordsContext(ctx, testRecords(1))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline of ctx, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("Expected loading to stop with ctx, took %v", elapsed)
+	}
+	if loader.Loaded() != 0 || loader.Failed() != 0 {
+		t.Errorf("Expected a cancelled record to be left out of the counts, got %d loaded and %d failed", loader.Loaded(), loader.Failed())
+	}
+
+	// Records are not sent once ctx is done
+	requests := len(stub.auth)
+	if err := loader.WriteRecordsContext(ctx, testRecords(3)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline of ctx, got %v", err)
+	}
+	if len(stub.auth) != requests {
+		t.Errorf("Expected no requests after ctx is done, got %d", len(stub.auth)-requests)
+	}
+}
+
+func TestLoadOAuth(t *testing.T) {
+	stub := &stubInstance{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	loader, err := NewLoader(Config{
+		InstanceURL:  server.URL,
+		Username:     "admin",
+		Password:     "secret",
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Table:        "incident",
+		Parallelism:  3,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create loader: %v", err)
+	}
+	if err := loader.WriteRecords(testRecords(6)); err != nil {
+		t.Fatalf("Failed to load records: %v", err)
+	}
+
+	if stub.tokens != 1 {
+		t.Errorf("Expected a single token request, got %d", stub.tokens)
+	}
+	for _, auth := range stub.auth {
+		if auth != "Bearer token-1" {
+			t.Fatalf("Expected bearer token, got %q", auth)
+		}
+	}
+
+	// An expired token is renewed
+	stub.failures = []int{http.StatusUnauthorized}
+	if err := loader.WriteRecords(testRecords(1)); err != nil {
+		t.Fatalf("Failed to load records: %v", err)
+	}
+	if stub.tokens != 2 || loader.Loaded() != 7 {
+		t.Errorf("Expected the token to be renewed, got %d tokens and %d loaded", stub.tokens, loader.Loaded())
+	}
+
+	bad, _ := NewLoader(Config{InstanceURL: server.URL, ClientID: "client", ClientSecret: "wrong", Table: "incident"})
+	bad.WriteRecords(testRecords(1))
+	if bad.Failed() != 1 {
+		t.Errorf("Expected the record to fail with bad client credentials")
+	}
+}
+
+func TestNewLoaderValidation(t *testing.T) {
+	tests := []Config{
+		{Username: "admin", Table: "incident"},
+		{InstanceURL: "dev.service-now.com", Username: "admin"},
+		{InstanceURL: "dev.service-now.com", Table: "incident"},
+	}
+	for _, config := range tests {
+		if _, err := NewLoader(config); err == nil {
+			t.Errorf("Expected an error for config %+v", config)
+		}
+	}
+
+	loader, err := NewLoader(Config{InstanceURL: "dev.service-now.com/", Username: "admin", Table: "incident"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if loader.config.InstanceURL != "https://dev.service-now.com" {
+		t.Errorf("Expected normalized instance URL, got %s", loader.config.InstanceURL)
+	}
+}
+
+func TestEncodeUnsupportedType(t *testing.T) {
+	loader, _ := NewLoader(Config{InstanceURL: "dev.service-now.com", Username: "admin", Table: "incident"})
+	if err := loader.WriteRecord("not a record"); err == nil {
+		t.Error("Expected an error for an unsupported record type")
+	}
+}
@@ -0,0 +1,174 @@
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+
+	// Registers the pure Go "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteWriter writes records straight into a table of a SQLite database file, creating
+// the file and the table if needed. Each batch is inserted in its own transaction.
+type SQLiteWriter struct {
+	db        *sql.DB
+	tableName string
+	batchSize int
+	headers   []string
+
+	table *table
+	rows  [][]interface{}
+
+	referenceMode models.ReferenceMode
+}
+
+// NewSQLiteWriter opens the SQLite database file to write records of the named table to
+func NewSQLiteWriter(filename, tableName string, batchSize int) (*SQLiteWriter, error) {
+	if batchSize < 1 {
+		batchSize = 1000
+	}
+
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	return &SQLiteWriter{
+		db:        db,
+		tableName: tableName,
+		batchSize: batchSize,
+	}, nil
+}
+
+// SetHeaders sets the column headers. Columns are named after the json tags of the
+// record fields, so the headers are kept for the writer contract but not used.
+func (w *SQLiteWriter) SetHeaders(headers []string) error {
+	w.headers = headers
+	return nil
+}
+
+// SetReferenceMode sets whether reference fields are written as display values, sys_ids
+// or both. It must be called before the first record is written.
+func (w *SQLiteWriter) SetReferenceMode(mode models.ReferenceMode) {
+	w.referenceMode = mode
+}
+
+// WriteRecord adds a record to the current batch, inserting the batch once it is full
+func (w *SQLiteWriter) WriteRecord(record interface{}) error {
+	v := reflect.ValueOf(record)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("unsupported record type %T", record)
+	}
+
+	if w.table == nil {
+		table, err := newTable(w.tableName, v.Type(), w.referenceMode)
+		if err != nil {
+			return err
+		}
+		if _, err := w.db.Exec(table.createStatement(SQLite)); err != nil {
+			return fmt.Errorf("failed to create table %s: %w", w.tableName, err)
+		}
+		w.table = table
+	} else if v.Type() != w.table.recordType {
+		return fmt.Errorf("record type %s does not match the table's record type %s", v.Type(), w.table.recordType)
+	}
+
+	values, err := w.table.values(v)
+	if err != nil {
+		return err
+	}
+	w.rows = append(w.rows, values)
+
+	if len(w.rows) >= w.batchSize {
+		return w.flushRows()
+	}
+	return nil
+}
+
+// WriteRecords writes multiple records to the database
+func (w *SQLiteWriter) WriteRecords(records []interface{}) error {
+	for _, record := range records {
+		if err := w.WriteRecord(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close inserts the last batch and closes the database
+func (w *SQLiteWriter) Close() error {
+	if err := w.flushRows(); err != nil {
+		w.db.Close()
+		return err
+	}
+	return w.db.Close()
+}
+
+// flushRows inserts the rows of the current batch in a single transaction
+func (w *SQLiteWriter) flushRows() error {
+	if len(w.rows) == 0 {
+		return nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(w.table.columns)), ", ")
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quoteIdentifier(SQLite, w.table.name), w.table.columnNames(SQLite), placeholders)
+
+	tx, err := w.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, row := range w.rows {
+		args := make([]interface{}, len(row))
+		for i, value := range row {
+			args[i] = sqliteValue(value)
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to insert record: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	w.rows = w.rows[:0]
+	return nil
+}
+
+// sqliteValue converts a column value to the value stored in SQLite, which keeps
+// booleans as integers and dates and times as text
+func sqliteValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	case date:
+		return time.Time(v).Format("2006-01-02")
+	default:
+		return v
+	}
+}
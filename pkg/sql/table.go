@@ -0,0 +1,228 @@
+package sql
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+// Dialect is the SQL dialect statements are written in
+type Dialect string
+
+// Supported SQL dialects
+const (
+	Postgres Dialect = "postgres"
+	MySQL    Dialect = "mysql"
+	SQLite   Dialect = "sqlite"
+)
+
+// ParseDialect parses a dialect name, defaulting to Postgres when it is empty
+func ParseDialect(name string) (Dialect, error) {
+	switch strings.ToLower(name) {
+	case "", "postgres", "postgresql":
+		return Postgres, nil
+	case "mysql":
+		return MySQL, nil
+	case "sqlite":
+		return SQLite, nil
+	default:
+		return "", fmt.Errorf("unsupported SQL dialect %q (use postgres, mysql or sqlite)", name)
+	}
+}
+
+// date is the value of a date column
+type date time.Time
+
+// table describes the SQL table that records of one type are written to
+type table struct {
+	name          string
+	recordType    reflect.Type
+	columns       []models.Column
+	referenceMode models.ReferenceMode
+}
+
+// newTable derives the columns of a table from the record columns of the record type
+func newTable(name string, recordType reflect.Type, mode models.ReferenceMode) (*table, error) {
+	columns, err := models.RecordColumns(recordType, mode)
+	if err != nil {
+		return nil, err
+	}
+	return &table{name: name, recordType: recordType, columns: columns, referenceMode: mode}, nil
+}
+
+// createStatement returns the CREATE TABLE statement of the table. A sys_id column
+// becomes the primary key.
+func (t *table) createStatement(dialect Dialect) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE IF NOT EXISTS %s (\n", quoteIdentifier(dialect, t.name))
+	for i, col := range t.columns {
+		fmt.Fprintf(&b, "  %s %s", quoteIdentifier(dialect, col.Name), columnType(dialect, col))
+		if i < len(t.columns)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(");\n")
+	return b.String()
+}
+
+// columnNames returns the quoted names of the table's columns
+func (t *table) columnNames(dialect Dialect) string {
+	names := make([]string, len(t.columns))
+	for i, col := range t.columns {
+		names[i] = quoteIdentifier(dialect, col.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// values converts a record into the values of the table's columns. Empty typed values
+// are nil, integers are int64, booleans bool, timestamps time.Time and dates date.
+func (t *table) values(record reflect.Value) ([]interface{}, error) {
+	values := make([]interface{}, len(t.columns))
+
+	for i, col := range t.columns {
+		field := record.Field(col.Field)
+		name := t.recordType.Field(col.Field).Name
+
+		if col.Type == models.ValueReference {
+			values[i] = col.Value(record, t.referenceMode)
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			values[i] = field.Int()
+			continue
+		case reflect.Bool:
+			values[i] = field.Bool()
+			continue
+		}
+
+		value := field.String()
+		if col.Type == models.ValueString {
+			values[i] = value
+			continue
+		}
+		if value == "" {
+			continue
+		}
+
+		switch col.Type {
+		case models.ValueInt:
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %q is not an integer", name, value)
+			}
+			values[i] = n
+		case models.ValueBoolean:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %q is not a boolean", name, value)
+			}
+			values[i] = b
+		case models.ValueTimestamp:
+			ts, err := models.ParseTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %q is not a date/time", name, value)
+			}
+			values[i] = ts
+		case models.ValueDate:
+			d, err := models.ParseTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %q is not a date", name, value)
+			}
+			values[i] = date(d)
+		}
+	}
+
+	return values, nil
+}
+
+// columnType returns the SQL type of a column in the dialect
+func columnType(dialect Dialect, col models.Column) string {
+	if col.Name == "sys_id" {
+		if dialect == SQLite {
+			return "TEXT PRIMARY KEY"
+		}
+		return "VARCHAR(32) PRIMARY KEY"
+	}
+
+	switch col.Type {
+	case models.ValueInt:
+		if dialect == SQLite {
+			return "INTEGER"
+		}
+		return "BIGINT"
+	case models.ValueBoolean:
+		if dialect == SQLite {
+			return "INTEGER"
+		}
+		return "BOOLEAN"
+	case models.ValueTimestamp:
+		switch dialect {
+		case MySQL:
+			return "DATETIME"
+		case SQLite:
+			return "TEXT"
+		}
+		return "TIMESTAMP"
+	case models.ValueDate:
+		if dialect == SQLite {
+			return "TEXT"
+		}
+		return "DATE"
+	default:
+		return "TEXT"
+	}
+}
+
+// quoteIdentifier quotes a table or column name for the dialect
+func quoteIdentifier(dialect Dialect, name string) string {
+	if dialect == MySQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// literal formats a value as an SQL literal of the dialect
+func literal(dialect Dialect, value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		if dialect == SQLite {
+			if v {
+				return "1"
+			}
+			return "0"
+		}
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case time.Time:
+		return "'" + v.Format("2006-01-02 15:04:05") + "'"
+	case date:
+		return "'" + time.Time(v).Format("2006-01-02") + "'"
+	default:
+		return quoteString(dialect, fmt.Sprintf("%v", v))
+	}
+}
+
+// quoteString quotes a string literal. MySQL treats backslashes as escapes by default,
+// while Postgres and SQLite only need quotes doubled. NUL characters are dropped, as
+// Postgres text columns cannot hold them.
+func quoteString(dialect Dialect, s string) string {
+	s = strings.ReplaceAll(s, "\x00", "")
+	if dialect == MySQL {
+		s = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\x1a", `\Z`).Replace(s)
+		return "'" + s + "'"
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,205 @@
+package sql
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+// Writer writes records as an SQL script: a CREATE TABLE statement derived from the
+// record type, followed by one multi-row INSERT, or COPY for Postgres, per batch
+type Writer struct {
+	file      *os.File
+	writer    *bufio.Writer
+	dialect   Dialect
+	tableName string
+	batchSize int
+	copy      bool
+	headers   []string
+
+	table *table
+	rows  [][]interface{}
+
+	referenceMode models.ReferenceMode
+}
+
+// NewWriter creates a writer of an SQL script loading records into the named table,
+// with batchSize rows per statement
+func NewWriter(filename, tableName string, dialect Dialect, batchSize int) (*Writer, error) {
+	if batchSize < 1 {
+		batchSize = 1000
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SQL file: %w", err)
+	}
+
+	return &Writer{
+		file:      file,
+		writer:    bufio.NewWriter(file),
+		dialect:   dialect,
+		tableName: tableName,
+		batchSize: batchSize,
+	}, nil
+}
+
+// SetCopy makes the writer load rows with COPY ... FROM stdin, as psql runs it, instead
+// of INSERT statements. It is only supported for Postgres.
+func (w *Writer) SetCopy(enabled bool) error {
+	if enabled && w.dialect != Postgres {
+		return fmt.Errorf("COPY is only supported for postgres, not %s", w.dialect)
+	}
+	w.copy = enabled
+	return nil
+}
+
+// SetHeaders sets the column headers. Columns are named after the json tags of the
+// record fields, so the headers are kept for the writer contract but not written.
+func (w *Writer) SetHeaders(headers []string) error {
+	w.headers = headers
+	return nil
+}
+
+// SetReferenceMode sets whether reference fields are written as display values, sys_ids
+// or both. In both mode the sys_id is written under the field's name and the display
+// value under "<name>.display". It must be called before the first record is written.
+func (w *Writer) SetReferenceMode(mode models.ReferenceMode) {
+	w.referenceMode = mode
+}
+
+// WriteRecord adds a record to the current batch, writing the batch once it is full
+func (w *Writer) WriteRecord(record interface{}) error {
+	v := reflect.ValueOf(record)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("unsupported record type %T", record)
+	}
+
+	if w.table == nil {
+		table, err := newTable(w.tableName, v.Type(), w.referenceMode)
+		if err != nil {
+			return err
+		}
+		w.table = table
+		if _, err := w.writer.WriteString(table.createStatement(w.dialect) + "\nBEGIN;\n"); err != nil {
+			return fmt.Errorf("failed to write SQL file: %w", err)
+		}
+	} else if v.Type() != w.table.recordType {
+		return fmt.Errorf("record type %s does not match the table's record type %s", v.Type(), w.table.recordType)
+	}
+
+	values, err := w.table.values(v)
+	if err != nil {
+		return err
+	}
+	w.rows = append(w.rows, values)
+
+	if len(w.rows) >= w.batchSize {
+		return w.flushRows()
+	}
+	return nil
+}
+
+// WriteRecords writes multiple records to the SQL file
+func (w *Writer) WriteRecords(records []interface{}) error {
+	for _, record := range records {
+		if err := w.WriteRecord(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close writes the last batch, commits the transaction and closes the file
+func (w *Writer) Close() error {
+	if w.table != nil {
+		if err := w.flushRows(); err != nil {
+			w.file.Close()
+			return err
+		}
+		if _, err := w.writer.WriteString("\nCOMMIT;\n"); err != nil {
+			w.file.Close()
+			return fmt.Errorf("failed to write SQL file: %w", err)
+		}
+	}
+	if err := w.writer.Flush(); err != nil {
+		w.file.Close()
+		return fmt.Errorf("failed to flush SQL file: %w", err)
+	}
+	return w.file.Close()
+}
+
+// flushRows writes the rows of the current batch as a single statement
+func (w *Writer) flushRows() error {
+	if len(w.rows) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	if w.copy {
+		fmt.Fprintf(&b, "\nCOPY %s (%s) FROM stdin;\n", quoteIdentifier(w.dialect, w.table.name), w.table.columnNames(w.dialect))
+		for _, row := range w.rows {
+			for i, value := range row {
+				if i > 0 {
+					b.WriteByte('\t')
+				}
+				b.WriteString(copyValue(value))
+			}
+			b.WriteByte('\n')
+		}
+		b.WriteString("\\.\n")
+	} else {
+		fmt.Fprintf(&b, "\nINSERT INTO %s (%s) VALUES\n", quoteIdentifier(w.dialect, w.table.name), w.table.columnNames(w.dialect))
+		for r, row := range w.rows {
+			b.WriteByte('(')
+			for i, value := range row {
+				if i > 0 {
+					b.WriteString(", ")
+				}
+				b.WriteString(literal(w.dialect, value))
+			}
+			b.WriteByte(')')
+			if r < len(w.rows)-1 {
+				b.WriteString(",\n")
+			}
+		}
+		b.WriteString(";\n")
+	}
+
+	if _, err := w.writer.WriteString(b.String()); err != nil {
+		return fmt.Errorf("failed to write SQL file: %w", err)
+	}
+	w.rows = w.rows[:0]
+	return nil
+}
+
+// copyValue formats a value in the text format of Postgres COPY
+func copyValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return `\N`
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		if v {
+			return "t"
+		}
+		return "f"
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	case date:
+		return time.Time(v).Format("2006-01-02")
+	default:
+		s := strings.ReplaceAll(fmt.Sprintf("%v", v), "\x00", "")
+		return strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`).Replace(s)
+	}
+}
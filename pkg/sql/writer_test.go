@@ -0,0 +1,234 @@
+package sql
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+type testRecord struct {
+	SysID          string                `json:"sys_id"`
+	Number         string                `json:"number"`
+	Caller         models.ReferenceValue `json:"caller"`
+	Description    string                `json:"description"`
+	Impact         int                   `json:"impact"`
+	Priority       string                `json:"priority" type:"int"`
+	NeedsAttention string                `json:"needs_attention" type:"boolean"`
+	OpenedAt       string                `json:"opened_at" type:"timestamp"`
+	DueDate        string                `json:"due_date" type:"date"`
+	Helper         string                `json:"-"`
+}
+
+func testRecords() []interface{} {
+	return []interface{}{
+		&testRecord{
+			SysID:          "a1",
+			Number:         "INC0010001",
+			Caller:         models.ReferenceValue{SysID: "u1", DisplayValue: "Jane O'Brien"},
+			Description:    "User's laptop says \"C:\\temp\" is full\n\tafter update",
+			Impact:         2,
+			Priority:       "3",
+			NeedsAttention: "true",
+			OpenedAt:       "2024-03-01 08:30:00",
+			DueDate:        "2024-03-05",
+		},
+		&testRecord{SysID: "a2", Number: "INC0010002", Impact: 1, NeedsAttention: "false"},
+		&testRecord{SysID: "a3", Number: "INC0010003", Impact: 3},
+	}
+}
+
+func writeScript(t *testing.T, dialect Dialect, batchSize int, copy bool) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "test.sql")
+	writer, err := NewWriter(filename, "incident", dialect, batchSize)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	if err := writer.SetCopy(copy); err != nil {
+		t.Fatalf("Failed to enable COPY: %v", err)
+	}
+	if err := writer.WriteRecords(testRecords()); err != nil {
+		t.Fatalf("Failed to write records: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read SQL file: %v", err)
+	}
+	return string(data)
+}
+
+func TestWritePostgresScript(t *testing.T) {
+	script := writeScript(t, Postgres, 2, false)
+
+	for _, want := range []string{
+		`CREATE TABLE IF NOT EXISTS "incident" (`,
+		`"sys_id" VARCHAR(32) PRIMARY KEY,`,
+		`"impact" BIGINT,`,
+		`"priority" BIGINT,`,
+		`"needs_attention" BOOLEAN,`,
+		`"opened_at" TIMESTAMP,`,
+		`"due_date" DATE`,
+		"BEGIN;",
+		`INSERT INTO "incident" ("sys_id", "number", "caller", "description", "impact", "priority", "needs_attention", "opened_at", "due_date") VALUES`,
+		`('a1', 'INC0010001', 'Jane O''Brien', 'User''s laptop says "C:\temp" is full` + "\n\tafter update', 2, 3, TRUE, '2024-03-01 08:30:00', '2024-03-05'),",
+		`('a2', 'INC0010002', '', '', 1, NULL, FALSE, NULL, NULL);`,
+		`('a3', 'INC0010003', '', '', 3, NULL, NULL, NULL, NULL);`,
+		"COMMIT;",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("Expected script to contain %q, got:\n%s", want, script)
+		}
+	}
+
+	// A batch size of 2 splits three records over two statements
+	if n := strings.Count(script, "INSERT INTO"); n != 2 {
+		t.Errorf("Expected 2 INSERT statements, got %d", n)
+	}
+	if strings.Contains(script, "Helper") || strings.Contains(script, "helper") {
+		t.Error("Fields tagged json:\"-\" should not be written")
+	}
+}
+
+func TestWriteMySQLScript(t *testing.T) {
+	script := writeScript(t, MySQL, 10, false)
+
+	for _, want := range []string{
+		"CREATE TABLE IF NOT EXISTS `incident` (",
+		"`opened_at` DATETIME,",
+		"'Jane O\\'Brien'",
+		`'User\'s laptop says "C:\\temp" is full`,
+		"TRUE",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("Expected script to contain %q, got:\n%s", want, script)
+		}
+	}
+	if n := strings.Count(script, "INSERT INTO"); n != 1 {
+		t.Errorf("Expected 1 INSERT statement, got %d", n)
+	}
+}
+
+func TestWritePostgresCopy(t *testing.T) {
+	script := writeScript(t, Postgres, 10, true)
+
+	for _, want := range []string{
+		`COPY "incident" ("sys_id", "number", "caller", "description", "impact", "priority", "needs_attention", "opened_at", "due_date") FROM stdin;`,
+		"a1\tINC0010001\tJane O'Brien\tUser's laptop says \"C:\\\\temp\" is full\\n\\tafter update\t2\t3\tt\t2024-03-01 08:30:00\t2024-03-05\n",
+		"a3\tINC0010003\t\t\t3\t\\N\t\\N\t\\N\t\\N\n",
+		"\\.\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("Expected script to contain %q, got:\n%s", want, script)
+		}
+	}
+
+	writer, err := NewWriter(filepath.Join(t.TempDir(), "test.sql"), "incident", MySQL, 10)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	defer writer.Close()
+	if err := writer.SetCopy(true); err == nil {
+		t.Error("Expected an error enabling COPY for mysql")
+	}
+}
+
+func TestWriteSQLite(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.db")
+	writer, err := NewSQLiteWriter(filename, "incident", 2)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	writer.SetReferenceMode(models.ReferenceBoth)
+	if err := writer.WriteRecords(testRecords()); err != nil {
+		t.Fatalf("Failed to write records: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM incident`).Scan(&count); err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 rows, got %d", count)
+	}
+
+	var caller, display, description, opened string
+	var priority, needsAttention int
+	err = db.QueryRow(`SELECT caller, "caller.display", description, priority, needs_attention, opened_at FROM incident WHERE sys_id = 'a1'`).
+		Scan(&caller, &display, &description, &priority, &needsAttention, &opened)
+	if err != nil {
+		t.Fatalf("Failed to query row: %v", err)
+	}
+	if caller != "u1" || display != "Jane O'Brien" || priority != 3 || needsAttention != 1 || opened != "2024-03-01 08:30:00" {
+		t.Errorf("Unexpected row: %s %s %d %d %s", caller, display, priority, needsAttention, opened)
+	}
+	if description != testRecords()[0].(*testRecord).Description {
+		t.Errorf("Expected description to round-trip, got %q", description)
+	}
+
+	var nullPriority sql.NullInt64
+	if err := db.QueryRow(`SELECT priority FROM incident WHERE sys_id = 'a3'`).Scan(&nullPriority); err != nil {
+		t.Fatalf("Failed to query row: %v", err)
+	}
+	if nullPriority.Valid {
+		t.Error("Expected an empty priority to be NULL")
+	}
+}
+
+func TestParseDialect(t *testing.T) {
+	tests := map[string]Dialect{"": Postgres, "postgresql": Postgres, "MySQL": MySQL, "sqlite": SQLite}
+	for name, want := range tests {
+		got, err := ParseDialect(name)
+		if err != nil || got != want {
+			t.Errorf("ParseDialect(%q) = %s, %v; want %s", name, got, err, want)
+		}
+	}
+	if _, err := ParseDialect("oracle"); err == nil {
+		t.Error("Expected an error for an unsupported dialect")
+	}
+}
+
+func TestWriteTaggedColumns(t *testing.T) {
+	type taggedRecord struct {
+		Number string `json:"number"`
+		Notes  string `json:"notes" label:"-"`
+		SysID  string `json:"sys_id" order:"1"`
+	}
+	filename := filepath.Join(t.TempDir(), "test.sql")
+	writer, err := NewWriter(filename, "incident", Postgres, 10)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	if err := writer.WriteRecord(&taggedRecord{Number: "INC0010001", Notes: "internal", SysID: "a1"}); err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read SQL file: %v", err)
+	}
+
+	// Columns are those of CSV and Excel output: moved by order, without label:"-"
+	script := string(data)
+	if !strings.Contains(script, `INSERT INTO "incident" ("sys_id", "number") VALUES`) || strings.Contains(script, "internal") {
+		t.Errorf("Expected the columns sys_id and number only, got:\n%s", script)
+	}
+}
@@ -0,0 +1,149 @@
+package xml
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+// Writer writes records in the ServiceNow unload format produced by "Export > XML" on a
+// list, so files can be loaded with "Import XML" without import sets or transform maps
+type Writer struct {
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *xml.Encoder
+	table   string
+	headers []string
+}
+
+// NewWriter creates a new XML unload writer for records of the given ServiceNow table
+func NewWriter(filename, table string) (*Writer, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create XML file: %w", err)
+	}
+
+	w := &Writer{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		table:  table,
+	}
+	w.encoder = xml.NewEncoder(w.writer)
+	w.encoder.Indent("", "  ")
+
+	if _, err := w.writer.WriteString(xml.Header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write XML header: %w", err)
+	}
+	unload := xml.StartElement{
+		Name: xml.Name{Local: "unload"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "unload_date"}, Value: time.Now().UTC().Format("2006-01-02 15:04:05")}},
+	}
+	if err := w.encoder.EncodeToken(unload); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write unload element: %w", err)
+	}
+
+	return w, nil
+}
+
+// SetHeaders sets the column headers. Elements are named after the ServiceNow fields of
+// the record, so the headers are kept for the writer contract but not written.
+func (w *Writer) SetHeaders(headers []string) error {
+	w.headers = headers
+	return nil
+}
+
+// SetReferenceMode is part of the writer contract. The unload format always writes
+// references as sys_ids, with the display value in a display_value attribute.
+func (w *Writer) SetReferenceMode(mode models.ReferenceMode) {}
+
+// WriteRecord writes a record as an INSERT_OR_UPDATE element of the writer's table
+func (w *Writer) WriteRecord(record interface{}) error {
+	v := reflect.ValueOf(record)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("unsupported record type %T", record)
+	}
+
+	start := xml.StartElement{
+		Name: xml.Name{Local: w.table},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "action"}, Value: "INSERT_OR_UPDATE"}},
+	}
+	if err := w.encoder.EncodeToken(start); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if !field.CanInterface() {
+			continue
+		}
+
+		name := models.ElementName(t.Field(i))
+		if name == "" {
+			continue
+		}
+
+		element := xml.StartElement{Name: xml.Name{Local: name}}
+		var value string
+		switch fv := field.Interface().(type) {
+		case models.ReferenceValue:
+			value = fv.SysID
+			if fv.DisplayValue != "" {
+				element.Attr = []xml.Attr{{Name: xml.Name{Local: "display_value"}, Value: fv.DisplayValue}}
+			}
+		default:
+			// Choice fields are written as the values the instance stores, not their labels
+			value = models.StoredChoice(t.Field(i), fmt.Sprintf("%v", fv))
+		}
+
+		if err := w.encoder.EncodeElement(value, element); err != nil {
+			return fmt.Errorf("failed to write field %s: %w", name, err)
+		}
+	}
+
+	if err := w.encoder.EncodeToken(start.End()); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	return nil
+}
+
+// WriteRecords writes multiple records to the XML file
+func (w *Writer) WriteRecords(records []interface{}) error {
+	for _, record := range records {
+		if err := w.WriteRecord(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the unload element and the file
+func (w *Writer) Close() error {
+	if err := w.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: "unload"}}); err != nil {
+		w.file.Close()
+		return fmt.Errorf("failed to close unload element: %w", err)
+	}
+	if err := w.encoder.Flush(); err != nil {
+		w.file.Close()
+		return fmt.Errorf("failed to flush XML file: %w", err)
+	}
+	if _, err := w.writer.WriteString("\n"); err != nil {
+		w.file.Close()
+		return fmt.Errorf("failed to write XML file: %w", err)
+	}
+	if err := w.writer.Flush(); err != nil {
+		w.file.Close()
+		return fmt.Errorf("failed to flush XML file: %w", err)
+	}
+	return w.file.Close()
+}
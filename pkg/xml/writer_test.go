@@ -0,0 +1,131 @@
+package xml
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/generator"
+	"github.com/michaelbuckner/NOW-Dynamic-Data-Generator/internal/models"
+)
+
+// unload mirrors the structure of a ServiceNow unload file for incidents
+type unload struct {
+	XMLName    xml.Name `xml:"unload"`
+	UnloadDate string   `xml:"unload_date,attr"`
+	Incidents  []struct {
+		Action   string `xml:"action,attr"`
+		SysID    string `xml:"sys_id"`
+		Number   string `xml:"number"`
+		CallerID struct {
+			DisplayValue string `xml:"display_value,attr"`
+			Value        string `xml:",chardata"`
+		} `xml:"caller_id"`
+		OpenedAt      string `xml:"opened_at"`
+		Impact        int    `xml:"impact"`
+		IncidentState string `xml:"incident_state"`
+		ContactType   string `xml:"contact_type"`
+	} `xml:"incident"`
+}
+
+func TestWriteUnload(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "incidents.xml")
+
+	writer, err := NewWriter(filename, "incident")
+	if err != nil {
+		t.Fatalf("Failed to create XML writer: %v", err)
+	}
+	records := []interface{}{
+		&generator.IncidentRecord{
+			SysID:         "0123456789abcdef0123456789abcdef",
+			Number:        "INC0010001",
+			Caller:        models.ReferenceValue{SysID: "46d44a23a9fe19810012d100cca80666", DisplayValue: "Beth Anglin"},
+			Opened:        "2024-01-01 10:00:00",
+			Impact:        2,
+			IncidentState: "In Progress",
+			Channel:       "Phone",
+		},
+		&generator.IncidentRecord{
+			SysID:            "fedcba9876543210fedcba9876543210",
+			Number:           "INC0010002",
+			ShortDescription: "Printer <jammed> & offline",
+		},
+	}
+	if err := writer.WriteRecords(records); err != nil {
+		t.Fatalf("Failed to write records: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	var result unload
+	if err := xml.Unmarshal(content, &result); err != nil {
+		t.Fatalf("Output is not valid XML: %v\n%s", err, content)
+	}
+	if result.UnloadDate == "" {
+		t.Error("Expected an unload_date attribute")
+	}
+	if len(result.Incidents) != 2 {
+		t.Fatalf("Expected 2 incident elements, got %d", len(result.Incidents))
+	}
+
+	first := result.Incidents[0]
+	if first.Action != "INSERT_OR_UPDATE" {
+		t.Errorf("Expected action INSERT_OR_UPDATE, got %q", first.Action)
+	}
+	if first.SysID != "0123456789abcdef0123456789abcdef" || first.Number != "INC0010001" {
+		t.Errorf("Unexpected sys_id or number: %+v", first)
+	}
+	if first.CallerID.Value != "46d44a23a9fe19810012d100cca80666" || first.CallerID.DisplayValue != "Beth Anglin" {
+		t.Errorf("Expected caller_id to hold the sys_id with a display_value attribute, got %+v", first.CallerID)
+	}
+	if first.OpenedAt != "2024-01-01 10:00:00" || first.Impact != 2 {
+		t.Errorf("Unexpected opened_at or impact: %+v", first)
+	}
+
+	if first.IncidentState != "2" || first.ContactType != "phone" {
+		t.Errorf("Expected the stored values of the choices, got %q and %q", first.IncidentState, first.ContactType)
+	}
+
+	// Labels must not be used as element names
+	if strings.Contains(string(content), "<caller>") || strings.Contains(string(content), "<opened>") {
+		t.Errorf("Expected ServiceNow element names, got %s", content)
+	}
+}
+
+func TestWriteUnloadSkipsExcludedFields(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "users.xml")
+
+	writer, err := NewWriter(filename, "sys_user")
+	if err != nil {
+		t.Fatalf("Failed to create XML writer: %v", err)
+	}
+	err = writer.WriteRecord(&generator.UserRecord{
+		SysID:       "0123456789abcdef0123456789abcdef",
+		UserName:    "beth.anglin",
+		Manager:     "46d44a23a9fe19810012d100cca80666",
+		ManagerName: "Fred Luddy",
+	})
+	if err != nil {
+		t.Fatalf("Failed to write record: %v", err)
+	}
+	writer.Close()
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if !strings.Contains(string(content), `<sys_user action="INSERT_OR_UPDATE">`) {
+		t.Errorf("Expected a sys_user element, got %s", content)
+	}
+	if strings.Contains(string(content), "manager_name") {
+		t.Errorf("Expected manager_name to be left out, got %s", content)
+	}
+}